@@ -14,6 +14,8 @@ import {
     type CacheConfig,
     type RequestConfig,
     type CachedResponse,
+    type CursorPage,
+    type OrderListParams,
     UserType,
 } from "./api-types";
 
@@ -524,8 +526,20 @@ class ApiClient {
     // ==========================================================================
 
     public orders = {
-        list: async (): Promise<unknown> => {
-            return this.get("/api/v1/orders");
+        list: async (params: OrderListParams = {}): Promise<CursorPage<unknown>> => {
+            const query = new URLSearchParams();
+            if (params.status) {
+                query.set("status", ([] as string[]).concat(params.status).join(","));
+            }
+            if (params.customerId) query.set("customer_id", String(params.customerId));
+            if (params.productId) query.set("product_id", String(params.productId));
+            if (params.createdFrom) query.set("created_from", params.createdFrom);
+            if (params.createdTo) query.set("created_to", params.createdTo);
+            if (params.sort) query.set("sort", params.sort);
+            if (params.limit) query.set("limit", String(params.limit));
+            if (params.cursor) query.set("cursor", params.cursor);
+            const qs = query.toString();
+            return this.get<CursorPage<unknown>>(`/api/v1/orders${qs ? `?${qs}` : ""}`);
         },
 
         get: async (id: string): Promise<unknown> => {
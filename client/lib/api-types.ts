@@ -103,6 +103,50 @@ export interface OrderItem {
     price: number;
 }
 
+export type OrderStatus =
+    | "pending"
+    | "paid"
+    | "processing"
+    | "shipped"
+    | "delivered"
+    | "cancelled"
+    | "refunded";
+
+export type OrderSort =
+    | "created_at"
+    | "-created_at"
+    | "total_price"
+    | "-total_price"
+    | "id"
+    | "-id";
+
+export interface OrderListParams {
+    status?: OrderStatus | OrderStatus[];
+    customerId?: number; // admin only
+    productId?: number;
+    createdFrom?: string; // RFC 3339 or YYYY-MM-DD
+    createdTo?: string;
+    sort?: OrderSort;
+    limit?: number;
+    cursor?: string; // nextCursor of the previous page
+}
+
+// ============================================================================
+// Pagination Types
+// ============================================================================
+
+/**
+ * Cursor-paginated list envelope. Pass next_cursor back as the cursor
+ * parameter to fetch the following page; it is empty on the last page.
+ */
+export interface CursorPage<T> {
+    data: T[];
+    next_cursor: string;
+    has_more: boolean;
+    total_count: number;
+    limit: number;
+}
+
 // ============================================================================
 // Future: Cart Types (placeholder for expansion)
 // ============================================================================
@@ -1,61 +1,22 @@
 package db
 
 import (
-	"database/sql"
-	"fmt"
-	"log"
-	"os"
+	"embed"
 
-	_ "github.com/lib/pq"
+	"go-microservices/pkg/platform"
 )
 
-// GetDB returns a database connection
-func GetDB() *sql.DB {
-	host := getEnv("DB_HOST", "admin-db")
-	port := getEnv("DB_PORT", "5438")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "canh177")
-	dbname := getEnv("DB_NAME", "admins_db")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Ping to verify connection
-	err = db.Ping()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Successfully connected to the admins database")
-	return db
+// Defaults is the admins database, used unless DB_* variables say otherwise
+var Defaults = platform.DBConfig{
+	Host:     "admin-db",
+	Port:     "5438",
+	User:     "postgres",
+	Password: "canh177",
+	Name:     "admins_db",
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
-
-// InitSchema initializes the database schema
-func InitSchema(db *sql.DB) {
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS admins (
-		id SERIAL PRIMARY KEY,
-		username TEXT NOT NULL,
-		role TEXT NOT NULL
-	);`
-
-	_, err := db.Exec(createTableSQL)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Admins table created or already exists")
-}
\ No newline at end of file
+// Migrations holds the service's numbered schema migrations, applied in
+// order at startup or with the migrate subcommand
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
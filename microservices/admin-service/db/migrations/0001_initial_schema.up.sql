@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS admins (
+    id SERIAL PRIMARY KEY,
+    username TEXT NOT NULL,
+    role TEXT NOT NULL
+);
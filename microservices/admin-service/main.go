@@ -6,31 +6,35 @@ import (
 	"go-microservices/admin-service/controller"
 	"go-microservices/admin-service/db"
 	"go-microservices/admin-service/routes"
-
-	"github.com/gin-gonic/gin"
+	"go-microservices/pkg/platform"
 )
 
 func main() {
+	config := platform.LoadConfig("admin-service", "8086", db.Defaults)
+	server := platform.NewServer(config)
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	// Create admin controller
 	adminController := controller.NewAdminController(database)
 
-	// Initialize router
-	router := gin.Default()
-
 	// Setup routes
+	routes.SetupRoutes(server.Router, adminController)
 
-
-
-
-
-
-
-
-}	}		log.Fatal("Failed to start server: ", err)	if err := router.Run(":8086"); err != nil {	log.Println("Admin Service starting on port 8086...")	// Start serveroutes.SetupRoutes(router, adminController)
\ No newline at end of file
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
+		log.Fatal("Failed to start server: ", err)
+	}
+}
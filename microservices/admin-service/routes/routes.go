@@ -7,8 +7,6 @@ import (
 )
 
 func SetupRoutes(r *gin.Engine, ac *controller.AdminController) {
-	r.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
-
 	r.GET("/admins", ac.GetAdmins)
 	r.GET("/admins/:id", ac.GetAdmin)
 	// Use POST /admins to create
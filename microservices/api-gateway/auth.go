@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -13,43 +14,96 @@ import (
 // jwtMiddleware validates a Bearer JWT and adds user info to headers
 func jwtMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		auth := c.GetHeader("Authorization")
-		if auth == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
-			return
+		if authenticate(c) {
+			c.Next()
 		}
-		parts := strings.SplitN(auth, " ", 2)
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header"})
-			return
+	}
+}
+
+// authenticate validates the request's Bearer JWT and replaces the identity
+// headers with its claims, aborting the request when the token isn't valid
+func authenticate(c *gin.Context) bool {
+	auth := c.GetHeader("Authorization")
+	if auth == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+		return false
+	}
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header"})
+		return false
+	}
+	tok := parts[1]
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "JWT_SECRET not configured"})
+		return false
+	}
+
+	parsed, err := jwt.Parse(tok, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrTokenMalformed
 		}
-		tok := parts[1]
-		secret := os.Getenv("JWT_SECRET")
-		if secret == "" {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "JWT_SECRET not configured"})
+		return []byte(secret), nil
+	})
+	if err != nil || !parsed.Valid {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return false
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+		return false
+	}
+	// Headers the client sent must not survive a token without the claim
+	c.Request.Header.Del("X-User-Id")
+	c.Request.Header.Del("X-User-Roles")
+	if uid, ok := claims["user_id" ]; ok {
+		c.Request.Header.Set("X-User-Id", toString(uid))
+	}
+	if roles, ok := claims["roles"]; ok {
+		c.Request.Header.Set("X-User-Roles", toString(roles))
+	}
+	return true
+}
+
+// paymentsAuth applies jwtMiddleware to payment routes except Stripe's
+// webhook, which can't carry a JWT and is verified by payment-service from
+// its Stripe-Signature header. Identity headers are dropped from webhook
+// requests so they can't be spoofed past the gateway.
+func paymentsAuth() gin.HandlerFunc {
+	requireJWT := jwtMiddleware()
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodPost && c.Param("path") == "/webhook" {
+			c.Request.Header.Del("X-User-Id")
+			c.Request.Header.Del("X-User-Roles")
+			c.Next()
 			return
 		}
+		requireJWT(c)
+	}
+}
 
-		parsed, err := jwt.Parse(tok, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrTokenMalformed
-			}
-			return []byte(secret), nil
-		})
-		if err != nil || !parsed.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+// inventoryAuth guards the inventory endpoints order-service uses to hold and
+// move stock. Reservations need a JWT, and committing or releasing one or
+// restocking also needs the admin or system role. The rest of the inventory
+// API is proxied as before.
+func inventoryAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := path.Clean(c.Param("path"))
+		if p != "/restock" && p != "/reservations" && !strings.HasPrefix(p, "/reservations/") {
+			c.Next()
 			return
 		}
-		claims, ok := parsed.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+		if !authenticate(c) {
 			return
 		}
-		if uid, ok := claims["user_id" ]; ok {
-			c.Request.Header.Set("X-User-Id", toString(uid))
-		}
-		if roles, ok := claims["roles"]; ok {
-			c.Request.Header.Set("X-User-Roles", toString(roles))
+		if p == "/restock" || strings.HasSuffix(p, "/commit") || strings.HasSuffix(p, "/release") {
+			roles := c.Request.Header.Get("X-User-Roles")
+			if !strings.Contains(roles, "admin") && !strings.Contains(roles, "system") {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin or system role required"})
+				return
+			}
 		}
 		c.Next()
 	}
@@ -5,7 +5,8 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"os"
+
+	"go-microservices/pkg/platform"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,19 +19,19 @@ type Service struct {
 
 // Configuration for our gateway
 var (
-	productServiceURL      = getEnv("PRODUCT_SERVICE_URL", "http://product-service:8080")
-	orderServiceURL        = getEnv("ORDER_SERVICE_URL", "http://order-service:8081")
-	inventoryServiceURL    = getEnv("INVENTORY_SERVICE_URL", "http://inventory-service:8082")
-	notificationServiceURL = getEnv("NOTIFICATION_SERVICE_URL", "http://notification-service:8083")
-	paymentServiceURL      = getEnv("PAYMENT_SERVICE_URL", "http://payment-service:8084")
-	customerServiceURL     = getEnv("CUSTOMER_SERVICE_URL", "http://customer-service:8085")
-	adminServiceURL        = getEnv("ADMIN_SERVICE_URL", "http://admin-service:8086")
-	authServiceURL         = getEnv("AUTH_SERVICE_URL", "http://auth-service:8070")
-	cartServiceURL         = getEnv("CART_SERVICE_URL", "http://cart-service:8087")
-	reviewServiceURL       = getEnv("REVIEW_SERVICE_URL", "http://review-rating-service:8088")
-	searchServiceURL       = getEnv("SEARCH_SERVICE_URL", "http://search-service:8089")
-	logisticsServiceURL    = getEnv("LOGISTICS_SERVICE_URL", "http://logistics-service:8090")
-	promotionServiceURL    = getEnv("PROMOTION_SERVICE_URL", "http://promotion-service:8091")
+	productServiceURL      = platform.Getenv("PRODUCT_SERVICE_URL", "http://product-service:8080")
+	orderServiceURL        = platform.Getenv("ORDER_SERVICE_URL", "http://order-service:8081")
+	inventoryServiceURL    = platform.Getenv("INVENTORY_SERVICE_URL", "http://inventory-service:8082")
+	notificationServiceURL = platform.Getenv("NOTIFICATION_SERVICE_URL", "http://notification-service:8083")
+	paymentServiceURL      = platform.Getenv("PAYMENT_SERVICE_URL", "http://payment-service:8084")
+	customerServiceURL     = platform.Getenv("CUSTOMER_SERVICE_URL", "http://customer-service:8085")
+	adminServiceURL        = platform.Getenv("ADMIN_SERVICE_URL", "http://admin-service:8086")
+	authServiceURL         = platform.Getenv("AUTH_SERVICE_URL", "http://auth-service:8070")
+	cartServiceURL         = platform.Getenv("CART_SERVICE_URL", "http://cart-service:8087")
+	reviewServiceURL       = platform.Getenv("REVIEW_SERVICE_URL", "http://review-rating-service:8088")
+	searchServiceURL       = platform.Getenv("SEARCH_SERVICE_URL", "http://search-service:8089")
+	logisticsServiceURL    = platform.Getenv("LOGISTICS_SERVICE_URL", "http://logistics-service:8090")
+	promotionServiceURL    = platform.Getenv("PROMOTION_SERVICE_URL", "http://promotion-service:8091")
 )
 
 // List of our services
@@ -43,10 +44,11 @@ var services = []Service{
 }
 
 func main() {
-	r := gin.Default()
+	server := platform.NewServer(platform.LoadConfig("api-gateway", "8000", platform.DBConfig{}))
+	r := server.Router
 
 	// Serve static files from the client/dist directory (Vite build output)
-	clientDistPath := getEnv("CLIENT_DIST_PATH", "./client/dist")
+	clientDistPath := platform.Getenv("CLIENT_DIST_PATH", "./client/dist")
 	r.Static("/assets", clientDistPath+"/assets")
 	r.StaticFile("/", clientDistPath+"/index.html")
 	r.StaticFile("/favicon.ico", clientDistPath+"/favicon.ico")
@@ -66,13 +68,6 @@ func main() {
 		c.Next()
 	})
 
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
-		})
-	})
-
 	// API routes - Gateway to microservices
 	// V1 API group
 	apiV1 := r.Group("/api/v1")
@@ -84,19 +79,24 @@ func main() {
 	// Orders (auth required)
 	apiV1.Any("/orders/*path", jwtMiddleware(), createReverseProxy(orderServiceURL, "/orders"))
 
+	// Returns (auth required)
+	apiV1.Any("/returns/*path", jwtMiddleware(), createReverseProxy(orderServiceURL, "/returns"))
+
 	// Inventory
-	apiV1.Any("/inventory/*path", createReverseProxy(inventoryServiceURL, "/inventory"))
+	apiV1.Any("/inventory/*path", inventoryAuth(), createReverseProxy(inventoryServiceURL, "/inventory"))
 
 	// Notifications
 	apiV1.Any("/notifications/*path", createReverseProxy(notificationServiceURL, "/notifications"))
 
-	// Payments (auth required)
-	apiV1.Any("/payments/*path", jwtMiddleware(), createReverseProxy(paymentServiceURL, "/payments"))
+	// Payments (auth required, except for the Stripe webhook)
+	apiV1.Any("/payments/*path", paymentsAuth(), createReverseProxy(paymentServiceURL, "/payments"))
 
 	// New services proxies
 	apiV1.Any("/customers/*path", createReverseProxy(customerServiceURL, "/customers"))
 	// Admin routes require admin role
 	apiV1.Any("/admins/*path", jwtMiddleware(), adminRequired(), createReverseProxy(adminServiceURL, "/admins"))
+	// Dead-lettered events are inspected and replayed through order-service
+	apiV1.Any("/admin/dead-letters/*path", jwtMiddleware(), adminRequired(), createReverseProxy(orderServiceURL, "/admin/dead-letters"))
 	apiV1.Any("/cart/*path", jwtMiddleware(), createReverseProxy(cartServiceURL, "/cart"))
 	apiV1.Any("/reviews/*path", createReverseProxy(reviewServiceURL, "/reviews"))
 	apiV1.Any("/search/*path", createReverseProxy(searchServiceURL, "/search"))
@@ -122,12 +122,21 @@ func main() {
 				"DELETE /api/v1/products/:id - Delete product",
 			},
 			"orders": {
-				"GET /api/v1/orders - List all orders",
+				"GET /api/v1/orders - List orders (cursor paginated, filterable)",
 				"GET /api/v1/orders/:id - Get order details",
 				"POST /api/v1/orders - Create new order",
 				"PUT /api/v1/orders/:id - Update order",
 				"DELETE /api/v1/orders/:id - Delete order",
 				"PATCH /api/v1/orders/:id/status - Update order status",
+				"GET /api/v1/orders/:id/history - Get order status history",
+			},
+			"returns": {
+				"POST /api/v1/orders/:id/returns - Request a return of a delivered order",
+				"GET /api/v1/orders/:id/returns - List an order's returns",
+				"GET /api/v1/returns/:returnId - Get return details and history",
+				"POST /api/v1/returns/:returnId/approve - Approve a return and refund it (admin)",
+				"POST /api/v1/returns/:returnId/reject - Reject a return (admin)",
+				"POST /api/v1/returns/:returnId/receive - Confirm receipt and restock (admin)",
 			},
 			"inventory": {
 				"GET /api/v1/inventory - List all inventory items",
@@ -136,6 +145,11 @@ func main() {
 				"PUT /api/v1/inventory/:id - Update inventory item",
 				"DELETE /api/v1/inventory/:id - Delete inventory item",
 				"POST /api/v1/inventory/check - Check product availability",
+				"POST /api/v1/inventory/reservations - Reserve stock for an order (JWT)",
+				"GET /api/v1/inventory/reservations/:id - Get a reservation (JWT)",
+				"POST /api/v1/inventory/reservations/:id/commit - Commit a reservation (admin or system)",
+				"POST /api/v1/inventory/reservations/:id/release - Release a reservation (admin or system)",
+				"POST /api/v1/inventory/restock - Return stock to inventory (admin or system)",
 			},
 			"notifications": {
 				"GET /api/v1/notifications - List all notifications",
@@ -150,6 +164,7 @@ func main() {
 				"POST /api/v1/payments/confirm - Confirm payment",
 				"GET /api/v1/payments/:id - Get payment details",
 				"GET /api/v1/payments/order/:orderId - Get payments by order ID",
+				"POST /api/v1/payments/webhook - Stripe webhook (Stripe-Signature, no JWT)",
 			},
 			"customers": {
 				"GET /api/v1/customers - List all customers",
@@ -165,6 +180,15 @@ func main() {
 				"PUT /api/v1/admins/:id - Update admin",
 				"DELETE /api/v1/admins/:id - Delete admin",
 			},
+			"dead-letters": {
+				"GET /api/v1/admin/dead-letters - List dead-letter queues and their depth (admin)",
+				"GET /api/v1/admin/dead-letters/:queue - List dead-lettered messages (admin)",
+				"GET /api/v1/admin/dead-letters/:queue/:messageId - Get a dead-lettered message (admin)",
+				"POST /api/v1/admin/dead-letters/:queue/replay - Replay a queue's dead letters (admin)",
+				"POST /api/v1/admin/dead-letters/:queue/:messageId/replay - Replay one message (admin)",
+				"DELETE /api/v1/admin/dead-letters/:queue - Purge a queue's dead letters (admin)",
+				"DELETE /api/v1/admin/dead-letters/:queue/:messageId - Purge one message (admin)",
+			},
 			"cart": {
 				"POST /api/v1/cart - Add item to cart",
 				"GET /api/v1/cart/:customerId - Get customer cart",
@@ -197,22 +221,12 @@ func main() {
 		})
 	})
 
-	port := getEnv("PORT", "8000")
-	log.Printf("API Gateway starting on port %s...\n", port)
-	if err := r.Run(":" + port); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start API Gateway: ", err)
 	}
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
-
 // createReverseProxy creates a gin handler function that forwards requests to the specified service
 func createReverseProxy(serviceURL, stripPrefix string) gin.HandlerFunc {
 	return func(c *gin.Context) {
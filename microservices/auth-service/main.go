@@ -6,30 +6,35 @@ import (
 	"go-microservices/auth-service/controller"
 	"go-microservices/auth-service/db"
 	"go-microservices/auth-service/routes"
-
-	"github.com/gin-gonic/gin"
+	"go-microservices/pkg/platform"
 )
 
 func main() {
+	config := platform.LoadConfig("auth-service", "8070", db.Defaults)
+	server := platform.NewServer(config)
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	// Create auth controller
 	authController := controller.NewAuthController(database)
 
-	// Initialize router
-	router := gin.Default()
-
 	// Setup routes
-	routes.SetupRoutes(router, authController)
+	routes.SetupRoutes(server.Router, authController)
 
-	// Start server
-	log.Println("Auth Service starting on port 8070...")
-	if err := router.Run(":8070"); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start auth service: ", err)
 	}
 }
@@ -6,30 +6,35 @@ import (
 	"go-microservices/cart-service/controller"
 	"go-microservices/cart-service/db"
 	"go-microservices/cart-service/routes"
-
-	"github.com/gin-gonic/gin"
+	"go-microservices/pkg/platform"
 )
 
 func main() {
+	config := platform.LoadConfig("cart-service", "8087", db.Defaults)
+	server := platform.NewServer(config)
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	// Create cart controller
 	cartController := controller.NewCartController(database)
 
-	// Initialize router
-	router := gin.Default()
-
 	// Setup routes
-	routes.SetupRoutes(router, cartController)
+	routes.SetupRoutes(server.Router, cartController)
 
-	// Start server
-	log.Println("Cart Service starting on port 8087...")
-	if err := router.Run(":8087"); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
@@ -8,8 +8,6 @@ import (
 )
 
 func SetupRoutes(r *gin.Engine, cc *controller.CartController) {
-	r.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
-
 	r.POST("/cart", middleware.RequireAuth(), cc.AddToCart)
 	r.GET("/cart/:customerId", middleware.RequireAuth(), cc.GetCart)
 	// Update and delete by cart item id
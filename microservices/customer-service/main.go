@@ -6,30 +6,35 @@ import (
 	"go-microservices/customer-service/controller"
 	"go-microservices/customer-service/db"
 	"go-microservices/customer-service/routes"
-
-	"github.com/gin-gonic/gin"
+	"go-microservices/pkg/platform"
 )
 
 func main() {
+	config := platform.LoadConfig("customer-service", "8085", db.Defaults)
+	server := platform.NewServer(config)
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	// Create customer controller
 	customerController := controller.NewCustomerController(database)
 
-	// Initialize router
-	router := gin.Default()
-
 	// Setup routes
-	routes.SetupRoutes(router, customerController)
+	routes.SetupRoutes(server.Router, customerController)
 
-	// Start server
-	log.Println("Customer Service starting on port 8085...")
-	if err := router.Run(":8085"); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
@@ -7,8 +7,6 @@ import (
 )
 
 func SetupRoutes(r *gin.Engine, cc *controller.CustomerController) {
-	r.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
-
 	r.GET("/customers", cc.GetCustomers)
 	r.GET("/customers/:id", cc.GetCustomer)
 	// Use POST /customers to create
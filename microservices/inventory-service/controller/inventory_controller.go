@@ -3,21 +3,62 @@ package controller
 import (
 	"database/sql"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	"go-microservices/inventory-service/model"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// defaultReservationTTL is how long a reservation holds stock unless configured otherwise
+	defaultReservationTTL = 15 * time.Minute
+	// defaultMaxReservationTTL caps the TTL a client may ask for
+	defaultMaxReservationTTL = time.Hour
+)
+
 // InventoryController handles inventory-related requests
 type InventoryController struct {
-	DB *sql.DB
+	DB             *sql.DB
+	ReservationTTL time.Duration
+	// MaxReservationTTL caps a requested ttl_seconds; zero means the default cap
+	MaxReservationTTL time.Duration
 }
 
-// NewInventoryController creates a new inventory controller
+// NewInventoryController creates a new inventory controller. Reservations
+// last RESERVATION_TTL unless the client asks otherwise, and never longer
+// than RESERVATION_MAX_TTL.
 func NewInventoryController(db *sql.DB) *InventoryController {
-	return &InventoryController{DB: db}
+	ttl, err := time.ParseDuration(os.Getenv("RESERVATION_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = defaultReservationTTL
+	}
+	maxTTL, err := time.ParseDuration(os.Getenv("RESERVATION_MAX_TTL"))
+	if err != nil || maxTTL <= 0 {
+		maxTTL = defaultMaxReservationTTL
+	}
+	if maxTTL < ttl {
+		maxTTL = ttl
+	}
+	return &InventoryController{DB: db, ReservationTTL: ttl, MaxReservationTTL: maxTTL}
+}
+
+// reservationTTL is how long a new reservation holds stock: the requested
+// seconds, capped at the maximum, or the configured TTL when none is asked for
+func (ic *InventoryController) reservationTTL(requestedSeconds int) time.Duration {
+	if requestedSeconds <= 0 {
+		return ic.ReservationTTL
+	}
+	maxTTL := ic.MaxReservationTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultMaxReservationTTL
+	}
+	if requestedSeconds > int(maxTTL/time.Second) {
+		return maxTTL
+	}
+	return time.Duration(requestedSeconds) * time.Second
 }
 
 // CreateInventory handles creation of a new inventory item
@@ -143,22 +184,30 @@ func (ic *InventoryController) CheckInventory(c *gin.Context) {
 		return
 	}
 
+	var stockRows int
 	var quantity int
-	err := ic.DB.QueryRow("SELECT quantity FROM inventory WHERE product_id = $1", check.ProductID).Scan(&quantity)
-
-	if err == sql.ErrNoRows {
+	err := ic.DB.QueryRow("SELECT COUNT(*), COALESCE(SUM(quantity), 0) FROM inventory WHERE product_id = $1", check.ProductID).
+		Scan(&stockRows, &quantity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stockRows == 0 {
 		c.JSON(http.StatusOK, model.InventoryResponse{
 			Available: false,
 			Message:   "Product not found in inventory",
 		})
 		return
 	}
+
+	// Stock held by active reservations is not available to new orders
+	reserved, err := activeReservedQuantities(ic.DB, []int{check.ProductID}, time.Now())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	available := quantity >= check.Quantity
+	available := quantity-reserved[check.ProductID] >= check.Quantity
 	message := ""
 	if !available {
 		message = "Not enough inventory"
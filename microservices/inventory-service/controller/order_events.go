@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go-microservices/inventory-service/model"
+	"go-microservices/pkg/events"
+)
+
+// orderStatusPaid is the order-service status at which an order's
+// reservation is committed
+const orderStatusPaid = "paid"
+
+// orderStatusProcessing is the order-service status of a paid order being
+// picked; its goods are still in the warehouse
+const orderStatusProcessing = "processing"
+
+// errInsufficientStock is returned when a paid order's expired reservation
+// can't be made good from the stock left. The event is retried and then
+// dead-lettered rather than dropped, so the oversold order gets seen.
+var errInsufficientStock = errors.New("insufficient stock")
+
+// HandleOrderStatusChanged commits the stock reservation of an order once it
+// has been paid. Other status changes, and orders without a reservation, are
+// ignored. Returning an error has the event redelivered.
+func (ic *InventoryController) HandleOrderStatusChanged(body []byte) error {
+	event, ok := decodeOrderEvent(body)
+	if !ok || event.Status != orderStatusPaid || event.ReservationID == 0 {
+		return nil
+	}
+
+	_, err := ic.changeReservation(strconv.Itoa(event.ReservationID), model.ReservationStatusCommitted, commitPaidReservation)
+	return orderEventResult(event, "commit", err)
+}
+
+// commitPaidReservation commits a paid order's reservation. The order is
+// paid whatever happened to its reservation, so one that expired before the
+// payment arrived is committed from the stock still available, provided
+// that doesn't take stock held by other reservations.
+func commitPaidReservation(tx *sql.Tx, reservation *model.Reservation) error {
+	if reservationState(reservation) != model.ReservationStatusExpired {
+		return commitReservation(tx, reservation)
+	}
+
+	productIDs := make([]int, 0, len(reservation.Items))
+	for _, item := range reservation.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	available, err := lockAvailableStock(tx, productIDs, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, item := range reservation.Items {
+		if available[item.ProductID] < item.Quantity {
+			return fmt.Errorf("%w: product %d has %d of the %d units paid for", errInsufficientStock,
+				item.ProductID, available[item.ProductID], item.Quantity)
+		}
+	}
+	for _, item := range reservation.Items {
+		if err := decrementStock(tx, item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	return setReservationStatus(tx, reservation, model.ReservationStatusCommitted)
+}
+
+// HandleOrderCancelled releases the stock reservation of a cancelled order.
+// Returning an error has the event redelivered.
+func (ic *InventoryController) HandleOrderCancelled(body []byte) error {
+	event, ok := decodeOrderEvent(body)
+	if !ok || event.ReservationID == 0 {
+		return nil
+	}
+
+	_, err := ic.changeReservation(strconv.Itoa(event.ReservationID), model.ReservationStatusReleased,
+		releaseCancelledReservation(event.PreviousStatus))
+	return orderEventResult(event, "release", err)
+}
+
+// releaseCancelledReservation releases a cancelled order's reservation.
+// Unlike an explicit release, a committed reservation is accepted when the
+// order was cancelled while paid or processing: it never shipped, so its
+// decremented stock is added back. Goods of an order cancelled later have
+// left the warehouse, and come back only through a restock.
+func releaseCancelledReservation(previousStatus string) func(*sql.Tx, *model.Reservation) error {
+	return func(tx *sql.Tx, reservation *model.Reservation) error {
+		if reservation.Status != model.ReservationStatusCommitted {
+			return releaseReservation(tx, reservation)
+		}
+		if previousStatus != orderStatusPaid && previousStatus != orderStatusProcessing {
+			return fmt.Errorf("%w: order left the warehouse after it was %s", errReservationConflict, previousStatus)
+		}
+
+		for _, item := range reservation.Items {
+			if err := incrementStock(tx, item.ProductID, item.Quantity); err != nil {
+				return err
+			}
+		}
+		return setReservationStatus(tx, reservation, model.ReservationStatusReleased)
+	}
+}
+
+// decodeOrderEvent parses an order status event, logging the ones that
+// can't be
+func decodeOrderEvent(body []byte) (events.StatusChange, bool) {
+	var event events.StatusChange
+	envelope, err := events.Parse(body)
+	if err == nil {
+		err = envelope.Decode(&event)
+	}
+	if err != nil {
+		// Redelivering a malformed event can't help; drop it
+		log.Printf("Failed to decode order event: %v\n", err)
+		return event, false
+	}
+	return event, true
+}
+
+// orderEventResult drops events whose reservation is missing or no longer in
+// a state the action applies to, and returns any other error, including a
+// paid order that can't get its stock, for a retry
+func orderEventResult(event events.StatusChange, action string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == sql.ErrNoRows, errors.Is(err, errReservationConflict), errors.Is(err, errNoInventory):
+		log.Printf("Skipping %s of reservation %d for order %d: %v\n", action, event.ReservationID, event.OrderID, err)
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go-microservices/inventory-service/model"
+	"go-microservices/pkg/events"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// orderEvent returns an order-service status event for pending order 42 as
+// it arrives from the broker
+func orderEvent(t *testing.T, eventType string, reservationID int, status string) []byte {
+	return orderEventFrom(t, eventType, reservationID, "pending", status)
+}
+
+// orderEventFrom returns a status event for order 42 leaving previousStatus
+func orderEventFrom(t *testing.T, eventType string, reservationID int, previousStatus, status string) []byte {
+	envelope, err := events.New("order-service", eventType, events.StatusChange{
+		OrderID: 42, CustomerID: 7, ReservationID: reservationID, PreviousStatus: previousStatus, Status: status,
+	})
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	body, _ := json.Marshal(envelope)
+	return body
+}
+
+func expectLockedReservation(mock sqlmock.Sqlmock, status string, expiresAt time.Time) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM inventory_reservations WHERE id = \$1 FOR UPDATE`).WithArgs("9").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reference", "status", "expires_at", "created_at"}).
+			AddRow(9, "order-42", status, expiresAt, time.Now().Add(-time.Hour)))
+	mock.ExpectQuery(`SELECT product_id, quantity FROM inventory_reservation_items`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(1, 2))
+}
+
+func TestHandleOrderStatusChanged_CommitsPaidOrders(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	expectLockedReservation(mock, model.ReservationStatusActive, time.Now().Add(time.Minute))
+	mock.ExpectQuery(`SELECT id, quantity FROM inventory WHERE product_id = \$1 ORDER BY id FOR UPDATE`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}).AddRow(5, 10))
+	mock.ExpectExec(`UPDATE inventory SET quantity = quantity - \$1`).WithArgs(2, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE inventory_reservations SET status`).
+		WithArgs(model.ReservationStatusCommitted, sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ic := &InventoryController{DB: database}
+	if err := ic.HandleOrderStatusChanged(orderEvent(t, events.OrderStatusChanged, 9, "paid")); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	// Other statuses leave the reservation alone
+	if err := ic.HandleOrderStatusChanged(orderEvent(t, events.OrderStatusChanged, 9, "shipped")); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderStatusChanged_CommitsExpiredReservationFromAvailableStock(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	// Paid after the reservation expired: stock is taken if nobody else holds it
+	expectLockedReservation(mock, model.ReservationStatusExpired, time.Now().Add(-time.Minute))
+	mock.ExpectQuery(`SELECT product_id, quantity FROM inventory WHERE product_id = ANY\(\$1\) ORDER BY product_id, id FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(1, 5))
+	mock.ExpectQuery(`SELECT ri.product_id, COALESCE\(SUM\(ri.quantity\), 0\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "sum"}).AddRow(1, 3))
+	mock.ExpectQuery(`SELECT id, quantity FROM inventory WHERE product_id = \$1 ORDER BY id FOR UPDATE`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}).AddRow(5, 5))
+	mock.ExpectExec(`UPDATE inventory SET quantity = quantity - \$1`).WithArgs(2, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE inventory_reservations SET status`).
+		WithArgs(model.ReservationStatusCommitted, sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ic := &InventoryController{DB: database}
+	if err := ic.HandleOrderStatusChanged(orderEvent(t, events.OrderStatusChanged, 9, "paid")); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderStatusChanged_RetriesPaidOrderShortOfStock(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	// Four on hand, three held by other reservations, two paid for
+	expectLockedReservation(mock, model.ReservationStatusActive, time.Now().Add(-time.Minute))
+	mock.ExpectQuery(`FROM inventory WHERE product_id = ANY\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(1, 4))
+	mock.ExpectQuery(`SELECT ri.product_id, COALESCE\(SUM\(ri.quantity\), 0\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "sum"}).AddRow(1, 3))
+	mock.ExpectRollback()
+
+	ic := &InventoryController{DB: database}
+	err = ic.HandleOrderStatusChanged(orderEvent(t, events.OrderStatusChanged, 9, "paid"))
+	if !errors.Is(err, errInsufficientStock) {
+		t.Fatalf("expected the event to be retried got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderCancelled_ReleasesActiveReservation(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	expectLockedReservation(mock, model.ReservationStatusActive, time.Now().Add(time.Minute))
+	mock.ExpectExec(`UPDATE inventory_reservations SET status`).
+		WithArgs(model.ReservationStatusReleased, sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ic := &InventoryController{DB: database}
+	if err := ic.HandleOrderCancelled(orderEvent(t, events.OrderCancelled, 9, "cancelled")); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderCancelled_RestocksCommittedReservation(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	expectLockedReservation(mock, model.ReservationStatusCommitted, time.Now().Add(-time.Minute))
+	mock.ExpectExec(`UPDATE inventory SET quantity = quantity \+ \$1`).WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE inventory_reservations SET status`).
+		WithArgs(model.ReservationStatusReleased, sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ic := &InventoryController{DB: database}
+	if err := ic.HandleOrderCancelled(orderEventFrom(t, events.OrderCancelled, 9, "paid", "cancelled")); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderCancelled_KeepsShippedStockOut(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	// An admin purge of a delivered order must not put its goods back
+	expectLockedReservation(mock, model.ReservationStatusCommitted, time.Now().Add(-time.Minute))
+	mock.ExpectRollback()
+
+	ic := &InventoryController{DB: database}
+	if err := ic.HandleOrderCancelled(orderEventFrom(t, events.OrderCancelled, 9, "delivered", "cancelled")); err != nil {
+		t.Fatalf("expected the event to be dropped got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderCancelled_RetriesDatabaseErrors(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+
+	ic := &InventoryController{DB: database}
+	if err := ic.HandleOrderCancelled(orderEvent(t, events.OrderCancelled, 9, "cancelled")); err == nil {
+		t.Fatal("expected an error so the event is redelivered")
+	}
+	// Orders without a reservation, malformed and unenveloped events are skipped
+	for _, body := range [][]byte{orderEvent(t, events.OrderCancelled, 0, "cancelled"), []byte(`{`), []byte(`{"order_id":42,"reservation_id":9}`)} {
+		if err := ic.HandleOrderCancelled(body); err != nil {
+			t.Fatalf("expected no error got %v", err)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"go-microservices/inventory-service/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// errReservationConflict is returned when a reservation is not in a state that allows the requested action
+var errReservationConflict = errors.New("reservation conflict")
+
+// CreateReservation holds stock for every requested product. Inventory rows for
+// the products are locked for the duration of the transaction, so concurrent
+// reservations for the same product are serialized and cannot oversell.
+func (ic *InventoryController) CreateReservation(c *gin.Context) {
+	var req model.ReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Merge duplicate lines so each product is checked against its full requested quantity
+	requested := make(map[int]int)
+	for _, item := range req.Items {
+		requested[item.ProductID] += item.Quantity
+	}
+	productIDs := make([]int, 0, len(requested))
+	for productID := range requested {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Ints(productIDs)
+
+	ttl := ic.reservationTTL(req.TTLSeconds)
+	now := time.Now()
+
+	tx, err := ic.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	available, err := lockAvailableStock(tx, productIDs, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, productID := range productIDs {
+		if available[productID] < requested[productID] {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      "Not enough inventory",
+				"product_id": productID,
+				"available":  available[productID],
+			})
+			return
+		}
+	}
+
+	reservation := model.Reservation{
+		Reference: req.Reference,
+		Status:    model.ReservationStatusActive,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = tx.QueryRow(
+		"INSERT INTO inventory_reservations (reference, status, expires_at, created_at, updated_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		reservation.Reference, reservation.Status, reservation.ExpiresAt, reservation.CreatedAt, reservation.UpdatedAt).Scan(&reservation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, productID := range productIDs {
+		item := model.ReservationItem{ProductID: productID, Quantity: requested[productID]}
+		if _, err := tx.Exec(
+			"INSERT INTO inventory_reservation_items (reservation_id, product_id, quantity) VALUES ($1, $2, $3)",
+			reservation.ID, item.ProductID, item.Quantity); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		reservation.Items = append(reservation.Items, item)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reservation)
+}
+
+// GetReservation returns a reservation and its items
+func (ic *InventoryController) GetReservation(c *gin.Context) {
+	id := c.Param("id")
+
+	var reservation model.Reservation
+	err := ic.DB.QueryRow("SELECT id, reference, status, expires_at, created_at, updated_at FROM inventory_reservations WHERE id = $1", id).
+		Scan(&reservation.ID, &reservation.Reference, &reservation.Status, &reservation.ExpiresAt, &reservation.CreatedAt, &reservation.UpdatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := ic.DB.Query("SELECT product_id, quantity FROM inventory_reservation_items WHERE reservation_id = $1 ORDER BY product_id", reservation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item model.ReservationItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		reservation.Items = append(reservation.Items, item)
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// CommitReservation converts a reservation into a permanent stock decrement.
+// Committing an already committed reservation is a no-op.
+func (ic *InventoryController) CommitReservation(c *gin.Context) {
+	ic.transitionReservation(c, model.ReservationStatusCommitted, commitReservation)
+}
+
+// ReleaseReservation returns held stock to the available pool. Releasing a
+// reservation that was already released or has expired is a no-op.
+func (ic *InventoryController) ReleaseReservation(c *gin.Context) {
+	ic.transitionReservation(c, model.ReservationStatusReleased, releaseReservation)
+}
+
+// commitReservation decrements stock for the items of an active reservation
+func commitReservation(tx *sql.Tx, reservation *model.Reservation) error {
+	switch {
+	case reservation.Status == model.ReservationStatusCommitted:
+		return nil
+	case reservation.Status != model.ReservationStatusActive || !reservation.ExpiresAt.After(time.Now()):
+		return fmt.Errorf("%w: cannot commit a reservation that is %s", errReservationConflict, reservationState(reservation))
+	}
+
+	for _, item := range reservation.Items {
+		if err := decrementStock(tx, item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseReservation checks that a reservation still holds stock or no
+// longer needs releasing; held stock is freed by the status change alone
+func releaseReservation(tx *sql.Tx, reservation *model.Reservation) error {
+	switch reservation.Status {
+	case model.ReservationStatusActive, model.ReservationStatusReleased, model.ReservationStatusExpired:
+		return nil
+	default:
+		return fmt.Errorf("%w: cannot release a reservation that is %s", errReservationConflict, reservation.Status)
+	}
+}
+
+// transitionReservation runs changeReservation for the reservation in the
+// path and writes the outcome
+func (ic *InventoryController) transitionReservation(c *gin.Context, target string, apply func(*sql.Tx, *model.Reservation) error) {
+	reservation, err := ic.changeReservation(c.Param("id"), target, apply)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+	case errors.Is(err, errReservationConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+// changeReservation locks a reservation, runs apply and moves it to the
+// target status when it is still active, all in one transaction
+func (ic *InventoryController) changeReservation(id string, target string, apply func(*sql.Tx, *model.Reservation) error) (*model.Reservation, error) {
+	tx, err := ic.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reservation, err := lockReservation(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(tx, reservation); err != nil {
+		return nil, err
+	}
+	if reservation.Status == model.ReservationStatusActive {
+		if err := setReservationStatus(tx, reservation, target); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// lockReservation loads a reservation and its items, locking the reservation
+// row until tx ends
+func lockReservation(tx *sql.Tx, id string) (*model.Reservation, error) {
+	var reservation model.Reservation
+	err := tx.QueryRow("SELECT id, reference, status, expires_at, created_at FROM inventory_reservations WHERE id = $1 FOR UPDATE", id).
+		Scan(&reservation.ID, &reservation.Reference, &reservation.Status, &reservation.ExpiresAt, &reservation.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT product_id, quantity FROM inventory_reservation_items WHERE reservation_id = $1 ORDER BY product_id", reservation.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.ReservationItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		reservation.Items = append(reservation.Items, item)
+	}
+	return &reservation, rows.Err()
+}
+
+// setReservationStatus moves a locked reservation to status
+func setReservationStatus(tx *sql.Tx, reservation *model.Reservation, status string) error {
+	reservation.Status = status
+	reservation.UpdatedAt = time.Now()
+	_, err := tx.Exec("UPDATE inventory_reservations SET status = $1, updated_at = $2 WHERE id = $3",
+		reservation.Status, reservation.UpdatedAt, reservation.ID)
+	return err
+}
+
+// ExpireReservations marks active reservations past their TTL as expired.
+// Availability checks already ignore them; this keeps their status accurate.
+func (ic *InventoryController) ExpireReservations() (int64, error) {
+	now := time.Now()
+	result, err := ic.DB.Exec(
+		"UPDATE inventory_reservations SET status = $1, updated_at = $2 WHERE status = $3 AND expires_at <= $2",
+		model.ReservationStatusExpired, now, model.ReservationStatusActive)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartReservationExpiry periodically expires reservations until stop is closed
+func (ic *InventoryController) StartReservationExpiry(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				expired, err := ic.ExpireReservations()
+				if err != nil {
+					log.Printf("Failed to expire reservations: %v\n", err)
+				} else if expired > 0 {
+					log.Printf("Expired %d inventory reservations\n", expired)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// lockAvailableStock locks the inventory rows of the given products (in a
+// stable order to avoid deadlocks) and returns on-hand minus active reservations
+func lockAvailableStock(tx *sql.Tx, productIDs []int, now time.Time) (map[int]int, error) {
+	available := make(map[int]int)
+
+	rows, err := tx.Query("SELECT product_id, quantity FROM inventory WHERE product_id = ANY($1) ORDER BY product_id, id FOR UPDATE", pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var productID, quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		available[productID] += quantity
+	}
+	rows.Close()
+
+	reserved, err := activeReservedQuantities(tx, productIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	for productID, quantity := range reserved {
+		available[productID] -= quantity
+	}
+
+	return available, nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// activeReservedQuantities sums the quantities held by unexpired active reservations
+func activeReservedQuantities(q queryer, productIDs []int, now time.Time) (map[int]int, error) {
+	rows, err := q.Query(`
+		SELECT ri.product_id, COALESCE(SUM(ri.quantity), 0)
+		FROM inventory_reservation_items ri
+		JOIN inventory_reservations r ON r.id = ri.reservation_id
+		WHERE r.status = $1 AND r.expires_at > $2 AND ri.product_id = ANY($3)
+		GROUP BY ri.product_id`,
+		model.ReservationStatusActive, now, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reserved := make(map[int]int)
+	for rows.Next() {
+		var productID, quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		reserved[productID] = quantity
+	}
+
+	return reserved, rows.Err()
+}
+
+// decrementStock removes quantity from the product's inventory rows, draining
+// them in ID order
+func decrementStock(tx *sql.Tx, productID int, quantity int) error {
+	rows, err := tx.Query("SELECT id, quantity FROM inventory WHERE product_id = $1 ORDER BY id FOR UPDATE", productID)
+	if err != nil {
+		return err
+	}
+	type stockRow struct{ id, quantity int }
+	var stock []stockRow
+	for rows.Next() {
+		var row stockRow
+		if err := rows.Scan(&row.id, &row.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		stock = append(stock, row)
+	}
+	rows.Close()
+
+	remaining := quantity
+	for _, row := range stock {
+		if remaining == 0 {
+			break
+		}
+		take := row.quantity
+		if take > remaining {
+			take = remaining
+		}
+		if take <= 0 {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE inventory SET quantity = quantity - $1 WHERE id = $2", take, row.id); err != nil {
+			return err
+		}
+		remaining -= take
+	}
+	if remaining > 0 {
+		return fmt.Errorf("%w: product %d is short by %d units", errReservationConflict, productID, remaining)
+	}
+
+	return nil
+}
+
+// reservationState describes a reservation's effective state, treating an
+// active reservation past its TTL as expired
+func reservationState(reservation *model.Reservation) string {
+	if reservation.Status == model.ReservationStatusActive && !reservation.ExpiresAt.After(time.Now()) {
+		return model.ReservationStatusExpired
+	}
+	return reservation.Status
+}
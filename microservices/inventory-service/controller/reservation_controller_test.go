@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go-microservices/inventory-service/db"
+	"go-microservices/inventory-service/model"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/platform"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func setupReservationRouter(ic *InventoryController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/inventory/reservations", ic.CreateReservation)
+	router.POST("/inventory/reservations/:id/commit", ic.CommitReservation)
+	router.POST("/inventory/reservations/:id/release", ic.ReleaseReservation)
+	return router
+}
+
+func postJSON(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	var b []byte
+	if body != nil {
+		b, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest("POST", path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateReservation_LocksStockAndReserves(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT product_id, quantity FROM inventory WHERE product_id = ANY\(\$1\) ORDER BY product_id, id FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(1, 3).AddRow(1, 2))
+	mock.ExpectQuery(`SELECT ri.product_id, COALESCE\(SUM\(ri.quantity\), 0\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "sum"}).AddRow(1, 1))
+	mock.ExpectQuery(`INSERT INTO inventory_reservations`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectExec(`INSERT INTO inventory_reservation_items`).WithArgs(10, 1, 4).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ic := &InventoryController{DB: database, ReservationTTL: time.Minute}
+	w := postJSON(setupReservationRouter(ic), "/inventory/reservations", model.ReservationRequest{
+		Reference: "checkout-saga-1",
+		Items:     []model.ReservationItem{{ProductID: 1, Quantity: 3}, {ProductID: 1, Quantity: 1}},
+	})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 got %d: %s", w.Code, w.Body.String())
+	}
+	var reservation model.Reservation
+	if err := json.Unmarshal(w.Body.Bytes(), &reservation); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if reservation.ID != 10 || reservation.Status != model.ReservationStatusActive {
+		t.Fatalf("unexpected reservation: %+v", reservation)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateReservation_InsufficientStock(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	// Five on hand, four already held by other reservations
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM inventory WHERE product_id = ANY\(\$1\) ORDER BY product_id, id FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(1, 5))
+	mock.ExpectQuery(`SELECT ri.product_id, COALESCE\(SUM\(ri.quantity\), 0\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "sum"}).AddRow(1, 4))
+	mock.ExpectRollback()
+
+	ic := &InventoryController{DB: database, ReservationTTL: time.Minute}
+	w := postJSON(setupReservationRouter(ic), "/inventory/reservations", model.ReservationRequest{
+		Items: []model.ReservationItem{{ProductID: 1, Quantity: 2}},
+	})
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateReservation_RejectsNegativeTTL(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	ic := &InventoryController{DB: database, ReservationTTL: time.Minute}
+	w := postJSON(setupReservationRouter(ic), "/inventory/reservations", model.ReservationRequest{
+		Items:      []model.ReservationItem{{ProductID: 1, Quantity: 1}},
+		TTLSeconds: -60,
+	})
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReservationTTL_CapsRequestedSeconds(t *testing.T) {
+	ic := &InventoryController{ReservationTTL: 15 * time.Minute, MaxReservationTTL: time.Hour}
+
+	for requested, want := range map[int]time.Duration{
+		0:       15 * time.Minute,
+		120:     2 * time.Minute,
+		3600:    time.Hour,
+		1 << 40: time.Hour,
+	} {
+		if got := ic.reservationTTL(requested); got != want {
+			t.Fatalf("ttl for %d seconds: expected %v got %v", requested, want, got)
+		}
+	}
+	// Without a configured cap the default one applies
+	if got := (&InventoryController{}).reservationTTL(1 << 40); got != defaultMaxReservationTTL {
+		t.Fatalf("expected the default cap got %v", got)
+	}
+}
+
+func TestCommitReservation_Expired(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM inventory_reservations WHERE id = \$1 FOR UPDATE`).WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reference", "status", "expires_at", "created_at"}).
+			AddRow(7, "", model.ReservationStatusActive, time.Now().Add(-time.Minute), time.Now().Add(-time.Hour)))
+	mock.ExpectQuery(`SELECT product_id, quantity FROM inventory_reservation_items`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(1, 1))
+	mock.ExpectRollback()
+
+	ic := &InventoryController{DB: database, ReservationTTL: time.Minute}
+	w := postJSON(setupReservationRouter(ic), "/inventory/reservations/7/commit", nil)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// openTestDB connects to the inventory database used by integration runs
+func openTestDB(t *testing.T) *sql.DB {
+	if os.Getenv("RUN_INTEGRATION_TESTS") != "true" {
+		t.Skip("set RUN_INTEGRATION_TESTS=true to run integration tests")
+	}
+	database, err := platform.OpenDB(platform.DBConfigFromEnv(db.Defaults))
+	if err != nil {
+		t.Fatalf("failed to connect to inventory database: %v", err)
+	}
+	migrations, err := migrate.Load(db.Migrations, platform.MigrationsDir)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrate.New(database, migrations).Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate inventory database: %v", err)
+	}
+	return database
+}
+
+func TestReservations_ConcurrentRequestsDoNotOversell(t *testing.T) {
+	database := openTestDB(t)
+	defer database.Close()
+
+	productID := int(time.Now().UnixNano() % 1000000000)
+	if _, err := database.Exec("INSERT INTO inventory (product_id, quantity, sku, location) VALUES ($1, 5, 'sku-race', 'test')", productID); err != nil {
+		t.Fatalf("failed to seed inventory: %v", err)
+	}
+	defer database.Exec("DELETE FROM inventory WHERE product_id = $1", productID)
+
+	router := setupReservationRouter(&InventoryController{DB: database, ReservationTTL: time.Minute})
+
+	const attempts = 25
+	var wg sync.WaitGroup
+	codes := make(chan int, attempts)
+	ids := make(chan int, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := postJSON(router, "/inventory/reservations", model.ReservationRequest{
+				Reference: fmt.Sprintf("race-%d", i),
+				Items:     []model.ReservationItem{{ProductID: productID, Quantity: 1}},
+			})
+			codes <- w.Code
+			if w.Code == http.StatusCreated {
+				var reservation model.Reservation
+				_ = json.Unmarshal(w.Body.Bytes(), &reservation)
+				ids <- reservation.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	close(ids)
+
+	created, conflicts := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	if created != 5 || conflicts != attempts-5 {
+		t.Fatalf("expected 5 reservations and %d conflicts, got %d and %d", attempts-5, created, conflicts)
+	}
+
+	// Commit every reservation concurrently; stock must end at exactly zero
+	var commitWG sync.WaitGroup
+	for id := range ids {
+		commitWG.Add(1)
+		go func(id int) {
+			defer commitWG.Done()
+			if w := postJSON(router, fmt.Sprintf("/inventory/reservations/%d/commit", id), nil); w.Code != http.StatusOK {
+				t.Errorf("commit %d failed: %d %s", id, w.Code, w.Body.String())
+			}
+		}(id)
+	}
+	commitWG.Wait()
+
+	var onHand int
+	if err := database.QueryRow("SELECT SUM(quantity) FROM inventory WHERE product_id = $1", productID).Scan(&onHand); err != nil {
+		t.Fatalf("failed to read stock: %v", err)
+	}
+	if onHand != 0 {
+		t.Fatalf("expected stock to be fully committed, %d left", onHand)
+	}
+}
+
+func TestReservations_ExpiredHoldsReturnToStock(t *testing.T) {
+	database := openTestDB(t)
+	defer database.Close()
+
+	productID := int(time.Now().UnixNano()%1000000000) + 1
+	if _, err := database.Exec("INSERT INTO inventory (product_id, quantity, sku, location) VALUES ($1, 1, 'sku-ttl', 'test')", productID); err != nil {
+		t.Fatalf("failed to seed inventory: %v", err)
+	}
+	defer database.Exec("DELETE FROM inventory WHERE product_id = $1", productID)
+
+	ic := &InventoryController{DB: database, ReservationTTL: time.Second}
+	router := setupReservationRouter(ic)
+	request := model.ReservationRequest{Items: []model.ReservationItem{{ProductID: productID, Quantity: 1}}}
+
+	if w := postJSON(router, "/inventory/reservations", request); w.Code != http.StatusCreated {
+		t.Fatalf("first reservation failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := postJSON(router, "/inventory/reservations", request); w.Code != http.StatusConflict {
+		t.Fatalf("expected stock to be held, got %d", w.Code)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := ic.ExpireReservations(); err != nil {
+		t.Fatalf("failed to expire reservations: %v", err)
+	}
+	if w := postJSON(router, "/inventory/reservations", request); w.Code != http.StatusCreated {
+		t.Fatalf("expected expired hold to free stock, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"go-microservices/inventory-service/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errNoInventory is returned when a product has no inventory row to restock
+var errNoInventory = errors.New("no inventory for product")
+
+// Restock adds units back to stock. Each reference is applied once: a retry
+// with the same reference returns the original restock without adding stock
+// again, so callers can safely retry after a timeout.
+func (ic *InventoryController) Restock(c *gin.Context) {
+	var req model.RestockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Merge duplicate lines and lock rows in product order, like reservations
+	quantities := make(map[int]int)
+	for _, item := range req.Items {
+		quantities[item.ProductID] += item.Quantity
+	}
+	productIDs := make([]int, 0, len(quantities))
+	for productID := range quantities {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Ints(productIDs)
+
+	tx, err := ic.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	restock := model.Restock{Reference: req.Reference, CreatedAt: time.Now()}
+	err = tx.QueryRow(`
+		INSERT INTO inventory_restocks (reference, created_at) VALUES ($1, $2)
+		ON CONFLICT (reference) DO NOTHING
+		RETURNING id`, restock.Reference, restock.CreatedAt).Scan(&restock.ID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		ic.getRestock(c, req.Reference)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, productID := range productIDs {
+		item := model.RestockItem{ProductID: productID, Quantity: quantities[productID]}
+		if err := incrementStock(tx, item.ProductID, item.Quantity); err != nil {
+			if errors.Is(err, errNoInventory) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "product_id": productID})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO inventory_restock_items (restock_id, product_id, quantity) VALUES ($1, $2, $3)",
+			restock.ID, item.ProductID, item.Quantity); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		restock.Items = append(restock.Items, item)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, restock)
+}
+
+// getRestock responds with the restock already applied for reference
+func (ic *InventoryController) getRestock(c *gin.Context, reference string) {
+	var restock model.Restock
+	err := ic.DB.QueryRow("SELECT id, reference, created_at FROM inventory_restocks WHERE reference = $1", reference).
+		Scan(&restock.ID, &restock.Reference, &restock.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := ic.DB.Query("SELECT product_id, quantity FROM inventory_restock_items WHERE restock_id = $1 ORDER BY product_id", restock.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item model.RestockItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		restock.Items = append(restock.Items, item)
+	}
+
+	c.JSON(http.StatusOK, restock)
+}
+
+// incrementStock adds quantity to the product's first inventory row, the
+// one decrementStock drains first
+func incrementStock(tx *sql.Tx, productID int, quantity int) error {
+	result, err := tx.Exec(`
+		UPDATE inventory SET quantity = quantity + $1
+		WHERE id = (SELECT id FROM inventory WHERE product_id = $2 ORDER BY id LIMIT 1 FOR UPDATE)`,
+		quantity, productID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%w %d", errNoInventory, productID)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"go-microservices/inventory-service/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func setupRestockRouter(ic *InventoryController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/inventory/restock", ic.Restock)
+	return router
+}
+
+func TestRestock_AddsStockOnce(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO inventory_restocks .* ON CONFLICT \(reference\) DO NOTHING`).
+		WithArgs("return-5", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`UPDATE inventory SET quantity = quantity \+ \$1`).WithArgs(3, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO inventory_restock_items`).WithArgs(3, 1, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	ic := &InventoryController{DB: database}
+	w := postJSON(setupRestockRouter(ic), "/inventory/restock", model.RestockRequest{
+		Reference: "return-5",
+		Items:     []model.RestockItem{{ProductID: 1, Quantity: 2}, {ProductID: 1, Quantity: 1}},
+	})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRestock_RepeatedReferenceIsNoOp(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO inventory_restocks`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	mock.ExpectQuery(`FROM inventory_restocks WHERE reference = \$1`).WithArgs("return-5").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reference", "created_at"}).AddRow(3, "return-5", time.Now()))
+	mock.ExpectQuery(`FROM inventory_restock_items WHERE restock_id = \$1`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(1, 3))
+
+	ic := &InventoryController{DB: database}
+	w := postJSON(setupRestockRouter(ic), "/inventory/restock", model.RestockRequest{
+		Reference: "return-5",
+		Items:     []model.RestockItem{{ProductID: 1, Quantity: 3}},
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 got %d: %s", w.Code, w.Body.String())
+	}
+	var restock model.Restock
+	if err := json.Unmarshal(w.Body.Bytes(), &restock); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if restock.ID != 3 || len(restock.Items) != 1 {
+		t.Fatalf("unexpected restock: %+v", restock)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRestock_UnknownProduct(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO inventory_restocks`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`UPDATE inventory SET quantity = quantity \+ \$1`).WithArgs(1, 9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	ic := &InventoryController{DB: database}
+	w := postJSON(setupRestockRouter(ic), "/inventory/restock", model.RestockRequest{
+		Reference: "return-6",
+		Items:     []model.RestockItem{{ProductID: 9, Quantity: 1}},
+	})
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -1,64 +1,22 @@
 package db
 
 import (
-	"database/sql"
-	"fmt"
-	"log"
-	"os"
+	"embed"
 
-	_ "github.com/lib/pq"
+	"go-microservices/pkg/platform"
 )
 
-// GetDB returns a database connection
-func GetDB() *sql.DB {
-	// Read from environment variables or use defaults
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "canh177")
-	dbname := getEnv("DB_NAME", "inventory_db")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Check if connection is established
-	err = db.Ping()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Successfully connected to the inventory database")
-	return db
-}
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
+// Defaults is the inventory database, used unless DB_* variables say otherwise
+var Defaults = platform.DBConfig{
+	Host:     "localhost",
+	Port:     "5432",
+	User:     "postgres",
+	Password: "canh177",
+	Name:     "inventory_db",
 }
 
-// InitSchema initializes the database schema
-func InitSchema(db *sql.DB) {
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS inventory (
-		id SERIAL PRIMARY KEY,
-		product_id INT NOT NULL,
-		quantity INT NOT NULL,
-		sku VARCHAR(50) NOT NULL,
-		location VARCHAR(100)
-	);`
-
-	_, err := db.Exec(createTableSQL)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Inventory table created or already exists")
-}
+// Migrations holds the service's numbered schema migrations, applied in
+// order at startup or with the migrate subcommand
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS inventory_restock_items;
+DROP TABLE IF EXISTS inventory_restocks;
+DROP TABLE IF EXISTS inventory_reservation_items;
+DROP TABLE IF EXISTS inventory_reservations;
+DROP TABLE IF EXISTS inventory;
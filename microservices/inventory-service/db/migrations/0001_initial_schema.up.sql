@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS inventory (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL,
+    sku VARCHAR(50) NOT NULL,
+    location VARCHAR(100)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_product_id ON inventory(product_id);
+
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+    id SERIAL PRIMARY KEY,
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_reservations_active ON inventory_reservations(status, expires_at);
+
+CREATE TABLE IF NOT EXISTS inventory_reservation_items (
+    id SERIAL PRIMARY KEY,
+    reservation_id INT NOT NULL REFERENCES inventory_reservations(id) ON DELETE CASCADE,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_reservation_items_product ON inventory_reservation_items(product_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_reservation_items_reservation ON inventory_reservation_items(reservation_id);
+
+CREATE TABLE IF NOT EXISTS inventory_restocks (
+    id SERIAL PRIMARY KEY,
+    reference VARCHAR(100) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS inventory_restock_items (
+    id SERIAL PRIMARY KEY,
+    restock_id INT NOT NULL REFERENCES inventory_restocks(id) ON DELETE CASCADE,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
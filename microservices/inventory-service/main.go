@@ -2,34 +2,76 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"go-microservices/inventory-service/controller"
 	"go-microservices/inventory-service/db"
 	"go-microservices/inventory-service/routes"
-
-	"github.com/gin-gonic/gin"
+	"go-microservices/pkg/platform"
+	"go-microservices/pkg/queue"
 )
 
 func main() {
+	config := platform.LoadConfig("inventory-service", "8082", db.Defaults)
+	server := platform.NewServer(config)
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	// Create inventory controller
 	inventoryController := controller.NewInventoryController(database)
 
-	// Initialize router
-	router := gin.Default()
+	// Expire reservations that outlived their TTL until the service stops
+	inventoryController.StartReservationExpiry(time.Minute, server.Context().Done())
+
+	// Initialize RabbitMQ
+	if err := queue.InitRabbitMQ(); err != nil {
+		log.Printf("Warning: Failed to initialize RabbitMQ: %v\n", err)
+	}
+	defer queue.Close()
+
+	// Commit reservations of paid orders and release those of cancelled ones
+	consumeOrderEvents(inventoryController)
 
 	// Setup routes
-	routes.SetupRoutes(router, inventoryController)
+	routes.SetupRoutes(server.Router, inventoryController)
 
-	// Start server
-	log.Println("Inventory Service starting on port 8082...")
-	if err := router.Run(":8082"); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
 }
+
+// consumeOrderEvents subscribes to the order events from order-service that
+// settle stock reservations
+func consumeOrderEvents(inventoryController *controller.InventoryController) {
+	subscriptions := []struct {
+		config  queue.Config
+		handler func([]byte) error
+	}{
+		{queue.Config{QueueName: "inventory.order-status", RoutingKey: "order.status_changed"}, inventoryController.HandleOrderStatusChanged},
+		{queue.Config{QueueName: "inventory.order-cancelled", RoutingKey: "order.cancelled"}, inventoryController.HandleOrderCancelled},
+	}
+	for _, sub := range subscriptions {
+		sub.config.ExchangeName = "orders"
+		sub.config.ExchangeType = "topic"
+		if err := queue.DeclareQueue(sub.config); err != nil {
+			log.Printf("Warning: Failed to declare queue %s: %v\n", sub.config.QueueName, err)
+			continue
+		}
+		if err := queue.ConsumeMessages(sub.config, sub.handler); err != nil {
+			log.Printf("Warning: Failed to consume queue %s: %v\n", sub.config.QueueName, err)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // Inventory represents an inventory item for a product
 type Inventory struct {
 	ID        int    `json:"id"`
@@ -20,3 +22,57 @@ type InventoryResponse struct {
 	Available bool   `json:"available"`
 	Message   string `json:"message,omitempty"`
 }
+
+// Reservation statuses
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusCommitted = "committed"
+	ReservationStatusReleased  = "released"
+	ReservationStatusExpired   = "expired"
+)
+
+// Reservation holds stock for an order until it is committed, released or expires
+type Reservation struct {
+	ID        int               `json:"id"`
+	Reference string            `json:"reference"`
+	Status    string            `json:"status"`
+	Items     []ReservationItem `json:"items"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// ReservationItem is a single product quantity held by a reservation
+type ReservationItem struct {
+	ProductID int `json:"product_id" binding:"required"`
+	Quantity  int `json:"quantity" binding:"required,min=1"`
+}
+
+// ReservationRequest is used to reserve stock for one or more products.
+// TTLSeconds is capped at the service's maximum reservation TTL.
+type ReservationRequest struct {
+	Reference  string            `json:"reference"`
+	Items      []ReservationItem `json:"items" binding:"required,min=1,dive"`
+	TTLSeconds int               `json:"ttl_seconds,omitempty" binding:"min=0"`
+}
+
+// Restock returns previously sold units to stock, such as the items of a
+// received customer return
+type Restock struct {
+	ID        int           `json:"id"`
+	Reference string        `json:"reference"`
+	Items     []RestockItem `json:"items"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// RestockItem is a single product quantity added back to stock
+type RestockItem struct {
+	ProductID int `json:"product_id" binding:"required"`
+	Quantity  int `json:"quantity" binding:"required,min=1"`
+}
+
+// RestockRequest is used to add stock back; the reference makes retries safe
+type RestockRequest struct {
+	Reference string        `json:"reference" binding:"required,max=100"`
+	Items     []RestockItem `json:"items" binding:"required,min=1,dive"`
+}
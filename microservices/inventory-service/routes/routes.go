@@ -17,4 +17,13 @@ func SetupRoutes(router *gin.Engine, inventoryController *controller.InventoryCo
 
 	// Inventory check route for order service
 	router.POST("/inventory/check", inventoryController.CheckInventory)
+
+	// Stock reservations held during checkout
+	router.POST("/inventory/reservations", inventoryController.CreateReservation)
+	router.GET("/inventory/reservations/:id", inventoryController.GetReservation)
+	router.POST("/inventory/reservations/:id/commit", inventoryController.CommitReservation)
+	router.POST("/inventory/reservations/:id/release", inventoryController.ReleaseReservation)
+
+	// Stock returned to inventory, such as received customer returns
+	router.POST("/inventory/restock", inventoryController.Restock)
 }
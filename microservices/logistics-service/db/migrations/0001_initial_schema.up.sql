@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS shipments (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL,
+    status TEXT NOT NULL
+);
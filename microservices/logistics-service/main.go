@@ -6,24 +6,30 @@ import (
 	"go-microservices/logistics-service/controller"
 	"go-microservices/logistics-service/db"
 	"go-microservices/logistics-service/routes"
-
-	"github.com/gin-gonic/gin"
+	"go-microservices/pkg/platform"
 )
 
 func main() {
-	database := db.GetDB()
+	config := platform.LoadConfig("logistics-service", "8090", db.Defaults)
+	server := platform.NewServer(config)
+
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	db.InitSchema(database)
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	logisticsController := controller.NewLogisticsController(database)
 
-	router := gin.Default()
-
-	routes.SetupRoutes(router, logisticsController)
+	routes.SetupRoutes(server.Router, logisticsController)
 
-	log.Println("Logistics Service starting on port 8090...")
-	if err := router.Run(":8090"); err != nil {
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
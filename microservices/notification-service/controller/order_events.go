@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go-microservices/pkg/events"
+)
+
+// HandleOrderCreated confirms a new order to its customer. Returning an
+// error has the event redelivered.
+func (nc *NotificationController) HandleOrderCreated(body []byte) error {
+	var order events.Order
+	envelope, ok := decodeOrderEvent(body, &order)
+	if !ok {
+		return nil
+	}
+
+	return nc.insertEventNotification(envelope.ID, order.ID, order.CustomerID,
+		fmt.Sprintf("Your order #%d has been placed", order.ID))
+}
+
+// HandleOrderStatusChanged tells a customer their order moved to a new
+// status. Returning an error has the event redelivered.
+func (nc *NotificationController) HandleOrderStatusChanged(body []byte) error {
+	var change events.StatusChange
+	envelope, ok := decodeOrderEvent(body, &change)
+	if !ok {
+		return nil
+	}
+
+	return nc.insertEventNotification(envelope.ID, change.OrderID, change.CustomerID,
+		fmt.Sprintf("Your order #%d status has changed to: %s", change.OrderID, change.Status))
+}
+
+// HandleReturnStatusChanged tells a customer one of their returns moved to a
+// new status. Returning an error has the event redelivered.
+func (nc *NotificationController) HandleReturnStatusChanged(body []byte) error {
+	var change events.ReturnStatusChange
+	envelope, ok := decodeOrderEvent(body, &change)
+	if !ok {
+		return nil
+	}
+
+	return nc.insertEventNotification(envelope.ID, change.OrderID, change.CustomerID,
+		fmt.Sprintf("Return #%d for order #%d is %s", change.ReturnID, change.OrderID, change.Status))
+}
+
+// decodeOrderEvent parses an order event into payload, logging the ones that
+// can't be
+func decodeOrderEvent(body []byte, payload interface{}) (*events.Envelope, bool) {
+	envelope, err := events.Parse(body)
+	if err == nil {
+		err = envelope.Decode(payload)
+	}
+	if err != nil {
+		// Redelivering a malformed event can't help; drop it
+		log.Printf("Failed to decode order event: %v\n", err)
+		return nil, false
+	}
+	return envelope, true
+}
+
+// insertEventNotification stores a pending notification for an event.
+// Events are delivered at least once; a redelivered event finds its
+// notification already stored under its ID and adds nothing.
+func (nc *NotificationController) insertEventNotification(eventID string, orderID, customerID int, message string) error {
+	_, err := nc.DB.Exec(`
+		INSERT INTO notifications (order_id, customer_id, message, status, created_at, event_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (event_key) DO NOTHING`,
+		orderID, customerID, message, "pending", time.Now(), eventID)
+	return err
+}
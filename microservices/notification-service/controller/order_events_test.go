@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go-microservices/pkg/events"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// orderEventBody returns an enveloped order-service event as it arrives from
+// the broker, with the envelope so tests can match its ID
+func orderEventBody(t *testing.T, eventType string, payload interface{}) ([]byte, *events.Envelope) {
+	envelope, err := events.New("order-service", eventType, payload)
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	body, _ := json.Marshal(envelope)
+	return body, envelope
+}
+
+const insertNotification = `INSERT INTO notifications \(order_id, customer_id, message, status, created_at, event_key\)\s+` +
+	`VALUES \(\$1, \$2, \$3, \$4, \$5, \$6\)\s+ON CONFLICT \(event_key\) DO NOTHING`
+
+func TestHandleOrderCreated_StoresNotificationUnderEventID(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	body, envelope := orderEventBody(t, events.OrderCreated, events.Order{
+		ID: 42, CustomerID: 7, Items: []events.OrderItem{{ProductID: 1, Quantity: 2}}, Status: "pending",
+	})
+	mock.ExpectExec(insertNotification).
+		WithArgs(42, 7, "Your order #42 has been placed", "pending", sqlmock.AnyArg(), envelope.ID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	nc := &NotificationController{DB: database}
+	if err := nc.HandleOrderCreated(body); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderStatusChanged_RedeliveryAddsNothing(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	body, envelope := orderEventBody(t, events.OrderStatusChanged, events.StatusChange{
+		OrderID: 42, CustomerID: 7, PreviousStatus: "pending", Status: "paid",
+	})
+	message := "Your order #42 status has changed to: paid"
+	mock.ExpectExec(insertNotification).
+		WithArgs(42, 7, message, "pending", sqlmock.AnyArg(), envelope.ID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// The redelivered event conflicts on its key and inserts no row
+	mock.ExpectExec(insertNotification).
+		WithArgs(42, 7, message, "pending", sqlmock.AnyArg(), envelope.ID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	nc := &NotificationController{DB: database}
+	for i := 0; i < 2; i++ {
+		if err := nc.HandleOrderStatusChanged(body); err != nil {
+			t.Fatalf("delivery %d: expected no error got %v", i+1, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderStatusChanged_RetriesDatabaseErrors(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	body, _ := orderEventBody(t, events.OrderStatusChanged, events.StatusChange{OrderID: 42, CustomerID: 7, Status: "paid"})
+	mock.ExpectExec(insertNotification).WillReturnError(errors.New("connection refused"))
+
+	nc := &NotificationController{DB: database}
+	if err := nc.HandleOrderStatusChanged(body); err == nil {
+		t.Fatal("expected an error so the event is redelivered")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleReturnStatusChanged_StoresNotificationUnderEventID(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	body, envelope := orderEventBody(t, events.OrderReturnStatusChanged, events.ReturnStatusChange{
+		ReturnID: 5, OrderID: 42, CustomerID: 7, PreviousStatus: "requested", Status: "approved",
+	})
+	mock.ExpectExec(insertNotification).
+		WithArgs(42, 7, "Return #5 for order #42 is approved", "pending", sqlmock.AnyArg(), envelope.ID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	nc := &NotificationController{DB: database}
+	if err := nc.HandleReturnStatusChanged(body); err != nil {
+		t.Fatalf("expected no error got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestHandleOrderEvents_DropMalformedEnvelopes(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	defer database.Close()
+
+	// Malformed JSON, an unenveloped payload and an envelope whose payload
+	// doesn't decode are dropped without touching the database
+	bodies := [][]byte{
+		[]byte(`{`),
+		[]byte(`{"order_id":42,"customer_id":7,"status":"paid"}`),
+		[]byte(`{"id":"e1","type":"order.created","version":1,"source":"order-service","payload":"not an order"}`),
+	}
+	nc := &NotificationController{DB: database}
+	for _, body := range bodies {
+		if err := nc.HandleOrderCreated(body); err != nil {
+			t.Fatalf("expected %s to be dropped got %v", body, err)
+		}
+		if err := nc.HandleOrderStatusChanged(body); err != nil {
+			t.Fatalf("expected %s to be dropped got %v", body, err)
+		}
+		if err := nc.HandleReturnStatusChanged(body); err != nil {
+			t.Fatalf("expected %s to be dropped got %v", body, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE notifications DROP COLUMN IF EXISTS event_key;
@@ -0,0 +1,3 @@
+-- Notifications created from order events are keyed by the event, so a
+-- redelivered event doesn't notify twice
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS event_key VARCHAR(100) UNIQUE;
@@ -6,30 +6,69 @@ import (
 	"go-microservices/notification-service/controller"
 	"go-microservices/notification-service/db"
 	"go-microservices/notification-service/routes"
-
-	"github.com/gin-gonic/gin"
+	"go-microservices/pkg/platform"
+	"go-microservices/pkg/queue"
 )
 
 func main() {
+	config := platform.LoadConfig("notification-service", "8083", db.Defaults)
+	server := platform.NewServer(config)
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	// Create notification controller
 	notificationController := controller.NewNotificationController(database)
 
-	// Initialize router
-	router := gin.Default()
+	// Initialize RabbitMQ
+	if err := queue.InitRabbitMQ(); err != nil {
+		log.Printf("Warning: Failed to initialize RabbitMQ: %v\n", err)
+	}
+	defer queue.Close()
+
+	// Notify customers of new orders and of order and return status changes
+	consumeOrderEvents(notificationController)
 
 	// Setup routes
-	routes.SetupRoutes(router, notificationController)
+	routes.SetupRoutes(server.Router, notificationController)
 
-	// Start server
-	log.Println("Notification Service starting on port 8083...")
-	if err := router.Run(":8083"); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
 }
+
+// consumeOrderEvents subscribes to the order events from order-service that
+// customers are notified of
+func consumeOrderEvents(notificationController *controller.NotificationController) {
+	subscriptions := []struct {
+		config  queue.Config
+		handler func([]byte) error
+	}{
+		{queue.Config{QueueName: "notification.order-created", RoutingKey: "order.created"}, notificationController.HandleOrderCreated},
+		{queue.Config{QueueName: "notification.order-status", RoutingKey: "order.status_changed"}, notificationController.HandleOrderStatusChanged},
+		{queue.Config{QueueName: "notification.return-status", RoutingKey: "order.return_status_changed"}, notificationController.HandleReturnStatusChanged},
+	}
+	for _, sub := range subscriptions {
+		sub.config.ExchangeName = "orders"
+		sub.config.ExchangeType = "topic"
+		if err := queue.DeclareQueue(sub.config); err != nil {
+			log.Printf("Warning: Failed to declare queue %s: %v\n", sub.config.QueueName, err)
+			continue
+		}
+		if err := queue.ConsumeMessages(sub.config, sub.handler); err != nil {
+			log.Printf("Warning: Failed to consume queue %s: %v\n", sub.config.QueueName, err)
+		}
+	}
+}
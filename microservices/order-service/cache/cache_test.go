@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-process store for tests
+type memoryStore struct {
+	mu      sync.Mutex
+	values  map[string][]byte
+	expires map[string]time.Duration
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: make(map[string][]byte), expires: make(map[string]time.Duration)}
+}
+
+func (s *memoryStore) get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.values[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	return data, nil
+}
+
+func (s *memoryStore) set(ctx context.Context, key string, data []byte, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = data
+	s.expires[key] = expiration
+	return nil
+}
+
+func (s *memoryStore) setNX(ctx context.Context, key string, data []byte, expiration time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		return false, nil
+	}
+	s.values[key] = data
+	s.expires[key] = expiration
+	return true, nil
+}
+
+func (s *memoryStore) del(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+func (s *memoryStore) delIfEquals(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(s.values[key]) == string(data) {
+		delete(s.values, key)
+	}
+	return nil
+}
+
+// useMemoryStore points the package at a fresh memoryStore for one test
+func useMemoryStore(t *testing.T, opts Options) *memoryStore {
+	s := newMemoryStore()
+	previousBackend, previousOptions := backend, options
+	backend, options = s, opts
+	t.Cleanup(func() {
+		backend, options = previousBackend, previousOptions
+	})
+	return s
+}
+
+// ctx is the context of test calls that don't exercise cancellation
+var ctx = context.Background()
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func TestGetOrSetCachesLoadedValue(t *testing.T) {
+	s := useMemoryStore(t, Options{})
+
+	calls := 0
+	load := func() (interface{}, error) {
+		calls++
+		return item{Name: "mug"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		var got item
+		if err := GetOrSet(ctx, "item:1", &got, time.Minute, load); err != nil {
+			t.Fatalf("GetOrSet: %v", err)
+		}
+		if got.Name != "mug" {
+			t.Fatalf("got %+v, want mug", got)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loaded %d times, want 1", calls)
+	}
+	if s.expires["item:1"] != time.Minute {
+		t.Fatalf("expiration %v, want 1m without jitter", s.expires["item:1"])
+	}
+}
+
+func TestGetOrSetSharesConcurrentLoads(t *testing.T) {
+	useMemoryStore(t, Options{})
+
+	var calls int32
+	release := make(chan struct{})
+	load := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return item{Name: "mug"}, nil
+	}
+
+	const readers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var got item
+			errs <- GetOrSet(ctx, "item:1", &got, time.Minute, load)
+		}()
+	}
+	// Let the readers pile up behind the first load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("GetOrSet: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loaded %d times, want 1", calls)
+	}
+}
+
+func TestGetOrSetStopsWaitingWhenContextIsDone(t *testing.T) {
+	useMemoryStore(t, Options{})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	loaded := make(chan error)
+	go func() {
+		loaded <- GetOrSet(ctx, "item:1", new(item), time.Minute, func() (interface{}, error) {
+			close(started)
+			<-release
+			return item{Name: "mug"}, nil
+		})
+	}()
+	<-started
+	defer func() {
+		close(release)
+		<-loaded
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err := GetOrSet(waitCtx, "item:1", new(item), time.Minute, func() (interface{}, error) {
+		t.Error("loaded while another load was running")
+		return nil, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("GetOrSet err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestGetOrSetCachesNotFound(t *testing.T) {
+	s := useMemoryStore(t, Options{NegativeTTL: 10 * time.Second})
+
+	calls := 0
+	load := func() (interface{}, error) {
+		calls++
+		return nil, sql.ErrNoRows
+	}
+	for i := 0; i < 2; i++ {
+		var got item
+		if err := GetOrSet(ctx, "item:404", &got, time.Minute, load); err != sql.ErrNoRows {
+			t.Fatalf("GetOrSet err = %v, want sql.ErrNoRows", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loaded %d times, want 1", calls)
+	}
+	if s.expires["item:404"] != 10*time.Second {
+		t.Fatalf("negative entry expires in %v, want 10s", s.expires["item:404"])
+	}
+
+	// Invalidating the key lets the next read find the new value
+	Delete(ctx, "item:404")
+	var got item
+	if err := GetOrSet(ctx, "item:404", &got, time.Minute, func() (interface{}, error) { return item{Name: "plate"}, nil }); err != nil {
+		t.Fatalf("GetOrSet after delete: %v", err)
+	}
+	if got.Name != "plate" {
+		t.Fatalf("got %+v, want plate", got)
+	}
+}
+
+func TestGetOrSetWithoutNegativeTTLDoesNotCacheNotFound(t *testing.T) {
+	s := useMemoryStore(t, Options{})
+
+	var got item
+	if err := GetOrSet(ctx, "item:404", &got, time.Minute, func() (interface{}, error) { return nil, sql.ErrNoRows }); err != sql.ErrNoRows {
+		t.Fatalf("GetOrSet err = %v, want sql.ErrNoRows", err)
+	}
+	if _, ok := s.values["item:404"]; ok {
+		t.Fatal("not-found result was cached")
+	}
+}
+
+func TestGetOrSetWaitsForLockHolder(t *testing.T) {
+	s := useMemoryStore(t, Options{Lock: true, LockTTL: time.Second})
+	// Another replica holds the lock and fills the key shortly
+	s.setNX(ctx, "lock:item:1", []byte("other"), time.Second)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.set(ctx, "item:1", []byte(`{"name":"mug"}`), time.Minute)
+	}()
+
+	var got item
+	err := GetOrSet(ctx, "item:1", &got, time.Minute, func() (interface{}, error) {
+		t.Error("loaded while another replica held the lock")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("GetOrSet: %v", err)
+	}
+	if got.Name != "mug" {
+		t.Fatalf("got %+v, want mug", got)
+	}
+}
+
+func TestGetOrSetReleasesLock(t *testing.T) {
+	s := useMemoryStore(t, Options{Lock: true, LockTTL: time.Second})
+
+	var got item
+	if err := GetOrSet(ctx, "item:1", &got, time.Minute, func() (interface{}, error) { return item{Name: "mug"}, nil }); err != nil {
+		t.Fatalf("GetOrSet: %v", err)
+	}
+	if _, ok := s.values["lock:item:1"]; ok {
+		t.Fatal("lock was not released")
+	}
+}
+
+func TestJitterStaysWithinExpiration(t *testing.T) {
+	useMemoryStore(t, Options{TTLJitter: 0.2})
+
+	for i := 0; i < 100; i++ {
+		got := jitter(time.Minute)
+		if got > time.Minute || got < 48*time.Second {
+			t.Fatalf("jitter(1m) = %v, want within [48s, 1m]", got)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Redis is the shared Redis cache as a value, for code that takes its cache
+// as an interface. Unlike Tiered it has no per-replica layer, so every
+// replica sees the same keys.
+type Redis struct{}
+
+// Get retrieves a value from Redis
+func (Redis) Get(ctx context.Context, key string, value interface{}) error {
+	return Get(ctx, key, value)
+}
+
+// Set stores a value in Redis with expiration
+func (Redis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return Set(ctx, key, value, expiration)
+}
+
+// SetNX stores a value only if the key does not exist yet
+func (Redis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return SetNX(ctx, key, value, expiration)
+}
+
+// Delete removes a key from Redis
+func (Redis) Delete(ctx context.Context, key string) error {
+	return Delete(ctx, key)
+}
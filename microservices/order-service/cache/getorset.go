@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	mathrand "math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"go-microservices/order-service/metrics"
+)
+
+const (
+	defaultNegativeTTL = 30 * time.Second
+	defaultTTLJitter   = 0.1
+	defaultLockTTL     = 5 * time.Second
+	defaultTimeout     = 250 * time.Millisecond
+	lockPollInterval   = 50 * time.Millisecond
+
+	// notFoundMarker is stored for keys whose loader found nothing. It is not
+	// valid JSON, so it can't collide with a cached value.
+	notFoundMarker = "!not_found"
+)
+
+// Options tunes GetOrSet
+type Options struct {
+	// NegativeTTL is how long a sql.ErrNoRows result is cached; zero
+	// disables negative caching
+	NegativeTTL time.Duration
+	// TTLJitter shortens each expiration by a random fraction of up to this
+	// much, so keys cached together don't expire together
+	TTLJitter float64
+	// Lock takes a Redis lock per key while loading it, so only one replica
+	// recomputes an expired key and the others wait for its result
+	Lock bool
+	// LockTTL bounds how long a lock is held and how long others wait on it
+	LockTTL time.Duration
+	// Timeout bounds each Redis command; a command that runs out of time
+	// counts as a miss and marks Redis unavailable
+	Timeout time.Duration
+}
+
+var (
+	options = OptionsFromEnv()
+	loads   = newGroup()
+)
+
+// OptionsFromEnv reads Options from CACHE_NEGATIVE_TTL, CACHE_TTL_JITTER,
+// CACHE_LOCK ("redis" enables the lock), CACHE_LOCK_TTL and CACHE_TIMEOUT
+func OptionsFromEnv() Options {
+	opts := Options{
+		NegativeTTL: defaultNegativeTTL,
+		TTLJitter:   defaultTTLJitter,
+		Lock:        os.Getenv("CACHE_LOCK") == "redis",
+		LockTTL:     defaultLockTTL,
+		Timeout:     defaultTimeout,
+	}
+	if v := os.Getenv("CACHE_NEGATIVE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			opts.NegativeTTL = d
+		}
+	}
+	if v := os.Getenv("CACHE_TTL_JITTER"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f < 1 {
+			opts.TTLJitter = f
+		}
+	}
+	if v := os.Getenv("CACHE_LOCK_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			opts.LockTTL = d
+		}
+	}
+	if v := os.Getenv("CACHE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			opts.Timeout = d
+		}
+	}
+	return opts
+}
+
+// Configure replaces the options read from the environment
+func Configure(opts Options) {
+	options = opts
+}
+
+// GetOrSet retrieves value from cache or, on a miss, loads it with fn and
+// caches it. Concurrent misses on a key share a single call to fn, and a
+// sql.ErrNoRows from fn is cached for Options.NegativeTTL and returned again
+// until then. Once ctx is done GetOrSet stops waiting and returns its error.
+func GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
+	if data, err := backend.get(ctx, key); err == nil {
+		metrics.CacheHits.WithLabelValues("redis").Inc()
+		return decode(data, value)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	metrics.CacheMisses.Inc()
+
+	// The load is shared, so one caller giving up mustn't cut it short for
+	// the others
+	loadCtx := context.WithoutCancel(ctx)
+	data, err, shared := loads.do(ctx, key, func() ([]byte, error) {
+		return load(loadCtx, key, expiration, fn)
+	})
+	if shared {
+		metrics.CacheStampedes.WithLabelValues("local").Inc()
+	}
+	if err != nil {
+		return err
+	}
+
+	return decode(data, value)
+}
+
+// load fills a missed key, under the Redis lock when it is enabled
+func load(ctx context.Context, key string, expiration time.Duration, fn func() (interface{}, error)) ([]byte, error) {
+	if !options.Lock {
+		return compute(ctx, key, expiration, fn)
+	}
+
+	lock := "lock:" + key
+	token := newToken()
+	acquired, err := backend.setNX(ctx, lock, token, options.LockTTL)
+	if err != nil {
+		// Without Redis there's no one to coordinate with
+		return compute(ctx, key, expiration, fn)
+	}
+	if !acquired {
+		// Another replica is loading the key; use its result, or load it
+		// here if it doesn't arrive in time
+		metrics.CacheStampedes.WithLabelValues("redis").Inc()
+		if data, err := waitFor(ctx, key, options.LockTTL); err == nil {
+			return data, nil
+		}
+		return compute(ctx, key, expiration, fn)
+	}
+	defer func() {
+		if err := backend.delIfEquals(ctx, lock, token); err != nil {
+			log.Printf("Failed to release cache lock %s: %v\n", lock, err)
+		}
+	}()
+
+	// The previous holder may have filled the key since the lookup
+	if data, err := backend.get(ctx, key); err == nil {
+		return data, nil
+	}
+	return compute(ctx, key, expiration, fn)
+}
+
+// compute calls fn and caches its result, or the fact that it found nothing
+func compute(ctx context.Context, key string, expiration time.Duration, fn func() (interface{}, error)) ([]byte, error) {
+	result, err := fn()
+	if errors.Is(err, sql.ErrNoRows) {
+		if options.NegativeTTL > 0 {
+			if err := backend.set(ctx, key, []byte(notFoundMarker), options.NegativeTTL); err != nil {
+				log.Printf("Failed to cache missing %s: %v\n", key, err)
+			}
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	// A value that couldn't be cached is still good to return
+	if err := backend.set(ctx, key, data, jitter(expiration)); err != nil {
+		log.Printf("Failed to cache %s: %v\n", key, err)
+	}
+	return data, nil
+}
+
+// waitFor polls for a key until it is cached or timeout elapses
+func waitFor(ctx context.Context, key string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	for {
+		data, err := backend.get(ctx, key)
+		if err == nil {
+			return data, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ErrMiss
+		case <-ticker.C:
+		}
+	}
+}
+
+// decode unmarshals cached data into value, turning a negative entry back
+// into sql.ErrNoRows
+func decode(data []byte, value interface{}) error {
+	if string(data) == notFoundMarker {
+		return sql.ErrNoRows
+	}
+	return json.Unmarshal(data, value)
+}
+
+// jitter shortens expiration by a random fraction of up to
+// Options.TTLJitter, so it never exceeds what the caller asked for
+func jitter(expiration time.Duration) time.Duration {
+	if options.TTLJitter <= 0 || expiration <= 0 {
+		return expiration
+	}
+	return expiration - time.Duration(mathrand.Float64()*options.TTLJitter*float64(expiration))
+}
+
+// newToken returns a random value identifying one holder of a lock
+func newToken() []byte {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return []byte(hex.EncodeToString(b))
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go-microservices/order-service/metrics"
+)
+
+// LRU is a bounded in-process cache. Entries expire after their TTL, capped
+// at the cache's own, and the least recently used entry is evicted when it
+// is full. Values are stored as JSON, like in Redis, so callers never share
+// them. Contexts only bound how long GetOrSet waits for a shared load.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	maxTTL   time.Duration
+	entries  *list.List
+	index    map[string]*list.Element
+	loads    *group
+}
+
+// lruEntry is an element of LRU.entries
+type lruEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+// NewLRU creates an LRU holding up to capacity entries for at most maxTTL each
+func NewLRU(capacity int, maxTTL time.Duration) *LRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU{
+		capacity: capacity,
+		maxTTL:   maxTTL,
+		entries:  list.New(),
+		index:    make(map[string]*list.Element),
+		loads:    newGroup(),
+	}
+}
+
+// Get retrieves a value from the cache
+func (c *LRU) Get(ctx context.Context, key string, value interface{}) error {
+	data, ok := c.get(key)
+	if !ok {
+		return ErrMiss
+	}
+	return decode(data, value)
+}
+
+// Set stores a value in the cache with expiration
+func (c *LRU) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.set(key, data, expiration)
+	return nil
+}
+
+// GetOrSet retrieves value from the cache or loads it with fn. Like the
+// Redis GetOrSet, concurrent misses share one call to fn and a sql.ErrNoRows
+// is cached for Options.NegativeTTL.
+func (c *LRU) GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
+	if data, ok := c.get(key); ok {
+		metrics.CacheHits.WithLabelValues("memory").Inc()
+		return decode(data, value)
+	}
+	metrics.CacheMisses.Inc()
+
+	data, err, shared := c.loads.do(ctx, key, func() ([]byte, error) {
+		result, err := fn()
+		if errors.Is(err, sql.ErrNoRows) {
+			if options.NegativeTTL > 0 {
+				c.set(key, []byte(notFoundMarker), options.NegativeTTL)
+			}
+			return nil, err
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		c.set(key, data, jitter(expiration))
+		return data, nil
+	})
+	if shared {
+		metrics.CacheStampedes.WithLabelValues("local").Inc()
+	}
+	if err != nil {
+		return err
+	}
+
+	return decode(data, value)
+}
+
+// Delete removes a key from the cache
+func (c *LRU) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.index[key]; ok {
+		c.remove(elem)
+	}
+	return nil
+}
+
+// Len returns the number of entries, including expired ones not yet evicted
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+// get returns the data of an unexpired entry, marking it recently used
+func (c *LRU) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.entries.MoveToFront(elem)
+	return entry.data, true
+}
+
+// set stores data under key, evicting the least recently used entry if the
+// cache is full
+func (c *LRU) set(key string, data []byte, expiration time.Duration) {
+	if expiration <= 0 || expiration > c.maxTTL {
+		expiration = c.maxTTL
+	}
+	expires := time.Now().Add(expiration)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.index[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.data = data
+		entry.expires = expires
+		c.entries.MoveToFront(elem)
+		return
+	}
+	c.index[key] = c.entries.PushFront(&lruEntry{key: key, data: data, expires: expires})
+	for c.entries.Len() > c.capacity {
+		c.remove(c.entries.Back())
+	}
+}
+
+// remove drops an element; the caller holds c.mu
+func (c *LRU) remove(elem *list.Element) {
+	c.entries.Remove(elem)
+	delete(c.index, elem.Value.(*lruEntry).key)
+}
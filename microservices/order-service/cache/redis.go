@@ -3,18 +3,31 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"sync/atomic"
 	"time"
 
+	"go-microservices/order-service/metrics"
+
 	"github.com/redis/go-redis/v9"
 )
 
 var (
 	redisClient *redis.Client
-	ctx         = context.Background()
+	// redisUp tracks whether Redis answered the last health check and
+	// hasn't failed a command since
+	redisUp atomic.Bool
 )
 
+// ErrUnavailable is returned while Redis is not connected
+var ErrUnavailable = errors.New("redis is unavailable")
+
+// healthCheckTimeout bounds each ping of Redis
+const healthCheckTimeout = 2 * time.Second
+
 // InitRedis initializes Redis connection
 func InitRedis() error {
 	redisHost := os.Getenv("REDIS_HOST")
@@ -26,10 +39,15 @@ func InitRedis() error {
 		Addr:     fmt.Sprintf("%s:6379", redisHost),
 		Password: "", // no password set
 		DB:       0,  // use default DB
+		// Let callers' deadlines cut slow commands short
+		ContextTimeoutEnabled: true,
 	})
 
 	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
 	_, err := redisClient.Ping(ctx).Result()
+	setAvailable(err == nil)
 	if err != nil {
 		return fmt.Errorf("failed to connect to Redis: %w", err)
 	}
@@ -37,61 +55,178 @@ func InitRedis() error {
 	return nil
 }
 
-// Get retrieves a value from cache
-func Get(key string, value interface{}) error {
-	data, err := redisClient.Get(ctx, key).Result()
-	if err == redis.Nil {
-		return fmt.Errorf("key does not exist")
-	} else if err != nil {
-		return err
+// Available reports whether Redis is reachable. It turns false as soon as a
+// command fails and true again on the next successful health check.
+func Available() bool {
+	return redisUp.Load()
+}
+
+// MonitorRedis pings Redis every interval until stop is closed, so callers
+// switch back to it once it recovers
+func MonitorRedis(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if redisClient != nil {
+					ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+					setAvailable(redisClient.Ping(ctx).Err() == nil)
+					cancel()
+				}
+			}
+		}
+	}()
+}
+
+// setAvailable records whether Redis is reachable, logging changes
+func setAvailable(up bool) {
+	if redisUp.Swap(up) != up {
+		if up {
+			log.Println("Redis is available again")
+		} else {
+			log.Println("Warning: Redis is unavailable, caching in memory only")
+		}
 	}
+	if up {
+		metrics.CacheRedisUp.Set(1)
+	} else {
+		metrics.CacheRedisUp.Set(0)
+	}
+}
 
-	return json.Unmarshal([]byte(data), value)
+// checkRedis passes err through, marking Redis unavailable if the command
+// failed for any reason other than a missing key or the caller giving up
+func checkRedis(ctx context.Context, err error) error {
+	if err != nil && err != redis.Nil && ctx.Err() == nil {
+		setAvailable(false)
+	}
+	return err
 }
 
-// Set stores a value in cache with expiration
-func Set(key string, value interface{}, expiration time.Duration) error {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return err
+// withTimeout bounds a Redis command by Options.Timeout within ctx
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if options.Timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, options.Timeout)
+}
 
-	return redisClient.Set(ctx, key, data, expiration).Err()
+// ErrMiss is returned by Get when a key is not cached
+var ErrMiss = errors.New("key does not exist")
+
+// store is the subset of Redis the cache functions use
+type store interface {
+	get(ctx context.Context, key string) ([]byte, error)
+	set(ctx context.Context, key string, data []byte, expiration time.Duration) error
+	setNX(ctx context.Context, key string, data []byte, expiration time.Duration) (bool, error)
+	del(ctx context.Context, key string) error
+	// delIfEquals deletes key only while it still holds data
+	delIfEquals(ctx context.Context, key string, data []byte) error
 }
 
-// Delete removes a key from cache
-func Delete(key string) error {
-	return redisClient.Del(ctx, key).Err()
+// backend is where cached values live
+var backend store = redisStore{}
+
+// redisStore implements store on the shared Redis client. Each command is
+// bounded by Options.Timeout, so a slow Redis can't hold up a request.
+type redisStore struct{}
+
+func (redisStore) get(ctx context.Context, key string) ([]byte, error) {
+	if redisClient == nil {
+		return nil, ErrUnavailable
+	}
+	opCtx, cancel := withTimeout(ctx)
+	defer cancel()
+	data, err := redisClient.Get(opCtx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+	return data, checkRedis(ctx, err)
 }
 
-// GetOrSet retrieves value from cache or sets it if not exists
-func GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
-	// Try to get from cache first
-	err := Get(key, value)
-	if err == nil {
-		return nil
+func (redisStore) set(ctx context.Context, key string, data []byte, expiration time.Duration) error {
+	if redisClient == nil {
+		return ErrUnavailable
 	}
+	opCtx, cancel := withTimeout(ctx)
+	defer cancel()
+	return checkRedis(ctx, redisClient.Set(opCtx, key, data, expiration).Err())
+}
+
+func (redisStore) setNX(ctx context.Context, key string, data []byte, expiration time.Duration) (bool, error) {
+	if redisClient == nil {
+		return false, ErrUnavailable
+	}
+	opCtx, cancel := withTimeout(ctx)
+	defer cancel()
+	stored, err := redisClient.SetNX(opCtx, key, data, expiration).Result()
+	return stored, checkRedis(ctx, err)
+}
+
+func (redisStore) del(ctx context.Context, key string) error {
+	if redisClient == nil {
+		return ErrUnavailable
+	}
+	opCtx, cancel := withTimeout(ctx)
+	defer cancel()
+	return checkRedis(ctx, redisClient.Del(opCtx, key).Err())
+}
+
+// delIfEqualsScript deletes a key atomically with checking its value
+var delIfEqualsScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+func (redisStore) delIfEquals(ctx context.Context, key string, data []byte) error {
+	if redisClient == nil {
+		return ErrUnavailable
+	}
+	opCtx, cancel := withTimeout(ctx)
+	defer cancel()
+	return checkRedis(ctx, delIfEqualsScript.Run(opCtx, redisClient, []string{key}, data).Err())
+}
 
-	// If not in cache, call the function
-	result, err := fn()
+// Get retrieves a value from cache. A key cached as not found by GetOrSet
+// returns sql.ErrNoRows.
+func Get(ctx context.Context, key string, value interface{}) error {
+	data, err := backend.get(ctx, key)
 	if err != nil {
 		return err
 	}
 
-	// Store result in cache
-	if err := Set(key, result, expiration); err != nil {
+	return decode(data, value)
+}
+
+// Set stores a value in cache with expiration
+func Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
 		return err
 	}
 
-	// Update the value reference
-	data, err := json.Marshal(result)
+	return backend.set(ctx, key, data, expiration)
+}
+
+// SetNX stores a value only if the key does not exist yet and reports whether it was stored
+func SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return json.Unmarshal(data, value)
+	return backend.setNX(ctx, key, data, expiration)
 }
 
+// Delete removes a key from cache
+func Delete(ctx context.Context, key string) error {
+	return backend.del(ctx, key)
+}
 
 func Close() error {
 	if redisClient != nil {
@@ -101,7 +236,7 @@ func Close() error {
 }
 
 // Flush clears all keys in the current DB (useful for testing)
-func Flush() error {
+func Flush(ctx context.Context) error {
 	if redisClient != nil {
 		return redisClient.FlushDB(ctx).Err()
 	}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+// call is an in-flight or completed group.do call
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// group runs at most one function per key at a time; callers arriving while
+// it runs wait for and share its result
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+func newGroup() *group {
+	return &group{calls: make(map[string]*call)}
+}
+
+// do runs fn for key unless a call for key is already running, in which case
+// it waits for that call, or for ctx to be done. shared reports whether the
+// result came from another caller's call.
+func (g *group) do(ctx context.Context, key string, fn func() ([]byte, error)) (data []byte, err error, shared bool) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.data, c.err, true
+		case <-ctx.Done():
+			return nil, ctx.Err(), true
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.data, c.err = fn()
+	return c.data, c.err, false
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-microservices/order-service/metrics"
+)
+
+const (
+	defaultMemoryCacheSize = 10000
+	defaultMemoryCacheTTL  = time.Minute
+)
+
+// Tiered caches in an in-process LRU (L1) in front of Redis (L2). While
+// Redis is unavailable it serves from the LRU alone, so reads keep working
+// and fall through to the loader instead of failing. The LRU's short TTL
+// bounds how long one replica can serve an entry another replica changed.
+type Tiered struct {
+	L1 *LRU
+
+	// missed holds keys deleted while Redis was unavailable, to delete there
+	// once it is back
+	mu     sync.Mutex
+	missed map[string]struct{}
+}
+
+// NewTiered creates a Tiered cache in front of Redis
+func NewTiered(l1 *LRU) *Tiered {
+	return &Tiered{L1: l1, missed: make(map[string]struct{})}
+}
+
+// NewTieredFromEnv creates a Tiered cache whose LRU holds CACHE_MEMORY_SIZE
+// entries for up to CACHE_MEMORY_TTL each
+func NewTieredFromEnv() *Tiered {
+	size := defaultMemoryCacheSize
+	if v := os.Getenv("CACHE_MEMORY_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			size = n
+		}
+	}
+	ttl := defaultMemoryCacheTTL
+	if v := os.Getenv("CACHE_MEMORY_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ttl = d
+		}
+	}
+	return NewTiered(NewLRU(size, ttl))
+}
+
+// Get retrieves a value from the LRU, then from Redis
+func (t *Tiered) Get(ctx context.Context, key string, value interface{}) error {
+	if data, ok := t.L1.get(key); ok {
+		return decode(data, value)
+	}
+	if !t.redisAvailable(ctx) {
+		return ErrMiss
+	}
+
+	data, err := backend.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	t.L1.set(key, data, 0)
+	return decode(data, value)
+}
+
+// Set stores a value in both tiers. Only the LRU has to succeed; a Redis
+// failure switches the cache to memory only.
+func (t *Tiered) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	t.L1.set(key, data, expiration)
+	if t.redisAvailable(ctx) {
+		backend.set(ctx, key, data, expiration)
+	}
+	return nil
+}
+
+// GetOrSet retrieves value from the LRU, then from Redis, and only then
+// loads it with fn
+func (t *Tiered) GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
+	if !t.redisAvailable(ctx) {
+		return t.L1.GetOrSet(ctx, key, value, expiration, fn)
+	}
+	if data, ok := t.L1.get(key); ok {
+		metrics.CacheHits.WithLabelValues("memory").Inc()
+		return decode(data, value)
+	}
+
+	var data json.RawMessage
+	err := GetOrSet(ctx, key, &data, expiration, fn)
+	if errors.Is(err, sql.ErrNoRows) {
+		if options.NegativeTTL > 0 {
+			t.L1.set(key, []byte(notFoundMarker), options.NegativeTTL)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	t.L1.set(key, data, expiration)
+	return decode(data, value)
+}
+
+// Delete removes a key from both tiers. While Redis is unavailable the key
+// is remembered and deleted there once it is back, so Redis doesn't serve
+// what was invalidated during the outage.
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	t.L1.Delete(ctx, key)
+	if t.redisAvailable(ctx) {
+		if err := backend.del(ctx, key); err == nil {
+			return nil
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.missed) < t.L1.capacity {
+		t.missed[key] = struct{}{}
+	}
+	return nil
+}
+
+// redisAvailable reports whether Redis is available, first replaying the
+// deletes it missed while it wasn't
+func (t *Tiered) redisAvailable(ctx context.Context) bool {
+	if !Available() {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.missed {
+		if err := backend.del(ctx, key); err != nil {
+			return false
+		}
+		delete(t.missed, key)
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// setRedisAvailable fakes the Redis health state for one test
+func setRedisAvailable(t *testing.T, up bool) {
+	previous := Available()
+	setAvailable(up)
+	t.Cleanup(func() { setAvailable(previous) })
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2, time.Minute)
+	c.Set(ctx, "a", item{Name: "a"}, time.Minute)
+	c.Set(ctx, "b", item{Name: "b"}, time.Minute)
+
+	var got item
+	if err := c.Get(ctx, "a", &got); err != nil {
+		t.Fatalf("Get(a): %v", err)
+	}
+	c.Set(ctx, "c", item{Name: "c"}, time.Minute)
+
+	if err := c.Get(ctx, "b", &got); err != ErrMiss {
+		t.Fatalf("Get(b) err = %v, want ErrMiss after eviction", err)
+	}
+	if err := c.Get(ctx, "a", &got); err != nil || got.Name != "a" {
+		t.Fatalf("Get(a) = %+v, %v; want a to survive", got, err)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", c.Len())
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	c := NewLRU(10, 20*time.Millisecond)
+	// The cache's own TTL caps longer expirations
+	c.Set(ctx, "a", item{Name: "a"}, time.Hour)
+	time.Sleep(30 * time.Millisecond)
+
+	var got item
+	if err := c.Get(ctx, "a", &got); err != ErrMiss {
+		t.Fatalf("Get(a) err = %v, want ErrMiss after expiry", err)
+	}
+}
+
+func TestTieredServesFromMemoryWhileRedisIsDown(t *testing.T) {
+	s := useMemoryStore(t, Options{NegativeTTL: time.Second})
+	setRedisAvailable(t, false)
+	c := NewTiered(NewLRU(10, time.Minute))
+
+	calls := 0
+	load := func() (interface{}, error) {
+		calls++
+		return item{Name: "mug"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		var got item
+		if err := c.GetOrSet(ctx, "item:1", &got, time.Minute, load); err != nil {
+			t.Fatalf("GetOrSet: %v", err)
+		}
+		if got.Name != "mug" {
+			t.Fatalf("got %+v, want mug", got)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loaded %d times, want 1", calls)
+	}
+	if _, ok := s.values["item:1"]; ok {
+		t.Fatal("wrote to Redis while it was down")
+	}
+
+	var got item
+	if err := c.GetOrSet(ctx, "item:404", &got, time.Minute, func() (interface{}, error) { return nil, sql.ErrNoRows }); err != sql.ErrNoRows {
+		t.Fatalf("GetOrSet err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestTieredFillsMemoryFromRedis(t *testing.T) {
+	s := useMemoryStore(t, Options{})
+	setRedisAvailable(t, true)
+	c := NewTiered(NewLRU(10, time.Minute))
+	s.set(ctx, "item:1", []byte(`{"name":"mug"}`), time.Minute)
+
+	var got item
+	if err := c.GetOrSet(ctx, "item:1", &got, time.Minute, func() (interface{}, error) {
+		t.Error("loaded a key cached in Redis")
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("GetOrSet: %v", err)
+	}
+
+	// Later reads are served from memory even if Redis loses the key
+	s.del(ctx, "item:1")
+	got = item{}
+	if err := c.Get(ctx, "item:1", &got); err != nil || got.Name != "mug" {
+		t.Fatalf("Get = %+v, %v; want mug from memory", got, err)
+	}
+}
+
+func TestTieredReplaysDeletesMissedDuringOutage(t *testing.T) {
+	s := useMemoryStore(t, Options{})
+	setRedisAvailable(t, true)
+	c := NewTiered(NewLRU(10, time.Minute))
+	c.Set(ctx, "item:1", item{Name: "mug"}, time.Minute)
+
+	setAvailable(false)
+	c.Delete(ctx, "item:1")
+	if _, ok := s.values["item:1"]; !ok {
+		t.Fatal("deleted from Redis while it was down")
+	}
+
+	setAvailable(true)
+	var got item
+	if err := c.GetOrSet(ctx, "item:1", &got, time.Minute, func() (interface{}, error) { return item{Name: "plate"}, nil }); err != nil {
+		t.Fatalf("GetOrSet: %v", err)
+	}
+	if got.Name != "plate" {
+		t.Fatalf("got %+v, want the reloaded plate rather than the stale mug", got)
+	}
+}
@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/worker"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Modes of POST /orders/batch
+const (
+	// BatchModePartial creates every valid order and reports the others
+	BatchModePartial = "partial"
+	// BatchModeAllOrNothing creates the orders only if all of them are valid
+	BatchModeAllOrNothing = "all_or_nothing"
+)
+
+const (
+	batchWorkers        = 10
+	batchTimeout        = 30 * time.Second
+	maxBatchOrders      = 500
+	maxAsyncBatchOrders = 5000
+)
+
+// CreateBatchOrders creates several orders in parallel, running each through
+// the same validation, inventory check and pricing as CreateOrder. In
+// partial mode each valid order is created on its own; in all_or_nothing
+// mode the orders are created in one transaction once all of them passed.
+// With async=true the batch is stored as a job and processed in the
+// background; the response is 202 with the URL to poll.
+func (oc *OrderController) CreateBatchOrders(c *gin.Context) {
+	mode := c.DefaultQuery("mode", BatchModePartial)
+	if mode != BatchModePartial && mode != BatchModeAllOrNothing {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be partial or all_or_nothing"})
+		return
+	}
+	async, err := strconv.ParseBool(c.DefaultQuery("async", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "async must be true or false"})
+		return
+	}
+	if async && oc.Batches == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "async batches are not available"})
+		return
+	}
+	limit := maxBatchOrders
+	if async {
+		limit = maxAsyncBatchOrders
+	}
+
+	// Orders are validated one by one so a single bad order doesn't fail the
+	// whole request in partial mode
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var orders []model.Order
+	if err := json.Unmarshal(body, &orders); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(orders) == 0 || len(orders) > limit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a batch must contain between 1 and %d orders", limit)})
+		return
+	}
+
+	uid := c.GetHeader("X-User-Id")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	if async {
+		oc.createBatchJob(c, uid, mode, orders)
+		return
+	}
+
+	// The request context is cancelled when the client goes away, which
+	// stops the workers from starting further orders
+	ctx, cancel := context.WithTimeout(c.Request.Context(), batchTimeout)
+	defer cancel()
+
+	start := time.Now()
+	results := worker.ProcessBatch(ctx, orders, batchWorkers, oc.batchOrderProcessor(uid, mode == BatchModePartial))
+	status := http.StatusOK
+	if mode == BatchModeAllOrNothing {
+		if err := oc.commitBatch(ctx, results); err != nil {
+			status = http.StatusUnprocessableEntity
+			if !errors.Is(err, errBatchRejected) {
+				status = http.StatusServiceUnavailable
+			}
+		}
+	}
+	elapsed := time.Since(start)
+
+	items := make([]model.BatchItemResult, len(results))
+	successful, failed := 0, 0
+	for i, result := range results {
+		items[i] = batchItemResult(result)
+		switch items[i].Status {
+		case model.BatchItemCreated:
+			successful++
+		case model.BatchItemFailed:
+			failed++
+		}
+	}
+
+	c.JSON(status, gin.H{
+		"mode":               mode,
+		"total_orders":       len(orders),
+		"successful":         successful,
+		"failed":             failed,
+		"results":            items,
+		"processing_time":    elapsed.String(),
+		"processing_time_ms": elapsed.Milliseconds(),
+	})
+}
+
+// createBatchJob stores the batch as a queued job and hands it to the runner
+func (oc *OrderController) createBatchJob(c *gin.Context, uid string, mode string, orders []model.Order) {
+	customerID, err := strconv.Atoi(uid)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user"})
+		return
+	}
+
+	job := &model.BatchJob{CustomerID: customerID, Mode: mode}
+	if err := oc.Batches.Store.CreateJob(job, orders); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	oc.Batches.Enqueue(job.ID)
+
+	statusURL := fmt.Sprintf("/orders/batch/%d", job.ID)
+	c.Header("Location", statusURL)
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":       job.ID,
+		"status":       job.Status,
+		"mode":         job.Mode,
+		"total_orders": job.Total,
+		"status_url":   statusURL,
+	})
+}
+
+// GetBatchJob reports the progress of an async batch: its counts and the
+// outcome of each order processed so far
+func (oc *OrderController) GetBatchJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+	if oc.Batches == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch job not found"})
+		return
+	}
+
+	job, err := oc.Batches.Store.GetJob(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Enforce ownership: allow owner or admin
+	uid := c.GetHeader("X-User-Id")
+	roles := c.GetHeader("X-User-Roles")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if strconv.Itoa(job.CustomerID) != uid && !strings.Contains(roles, "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// batchOrderProcessor returns the pipeline run for each order of a batch.
+// With insert false the order is only validated, checked and priced.
+func (oc *OrderController) batchOrderProcessor(uid string, insert bool) worker.ProcessFunc {
+	return func(ctx context.Context, job worker.Job) worker.Result {
+		order := job.Order
+		result := worker.Result{Index: job.Index}
+
+		if err := oc.prepareOrder(ctx, &order, uid); err != nil {
+			result.Error = err
+			return result
+		}
+		if insert {
+			if err := ctx.Err(); err != nil {
+				result.Error = err
+				return result
+			}
+			if err := oc.OrderRepo.InsertOrder(&order); err != nil {
+				result.Error = fmt.Errorf("failed to create order: %w", err)
+				return result
+			}
+		}
+
+		result.OrderID = order.ID
+		result.Order = &order
+		return result
+	}
+}
+
+// prepareOrder validates an order, assigns it to uid, checks stock and
+// prices it, stopping early when ctx is cancelled
+func (oc *OrderController) prepareOrder(ctx context.Context, order *model.Order, uid string) error {
+	if err := binding.Validator.ValidateStruct(order); err != nil {
+		return &orderError{http.StatusBadRequest, gin.H{"error": err.Error()}}
+	}
+	if err := assignCustomer(order, uid); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := oc.verifyAvailability(order.Items); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return oc.applyPricing(order)
+}
+
+// errBatchRejected is returned when an all-or-nothing batch has invalid orders
+var errBatchRejected = errors.New("batch rejected")
+
+// commitBatch creates the prepared orders of an all-or-nothing batch in one
+// transaction if every order passed. Otherwise nothing is created and the
+// valid orders are reported as not created.
+func (oc *OrderController) commitBatch(ctx context.Context, results []worker.Result) error {
+	for _, result := range results {
+		if result.Error != nil {
+			return errBatchRejected
+		}
+	}
+
+	err := ctx.Err()
+	if err == nil {
+		orders := make([]*model.Order, len(results))
+		for i := range results {
+			orders[i] = results[i].Order
+		}
+		err = oc.OrderRepo.InsertOrders(orders)
+	}
+	if err != nil {
+		for i := range results {
+			results[i].Error = fmt.Errorf("failed to create orders: %w", err)
+		}
+		return err
+	}
+
+	for i := range results {
+		results[i].OrderID = results[i].Order.ID
+	}
+	return nil
+}
+
+// batchItemResult converts a worker result to its response entry
+func batchItemResult(result worker.Result) model.BatchItemResult {
+	item := model.BatchItemResult{Index: result.Index, OrderID: result.OrderID}
+	switch {
+	case result.Error == nil && result.OrderID != 0:
+		item.Status = model.BatchItemCreated
+	case result.Error == nil:
+		// Valid, but another order of an all-or-nothing batch failed
+		item.Status = model.BatchItemNotCreated
+	default:
+		item.Status = model.BatchItemFailed
+		item.Error = result.Error.Error()
+		item.Code = http.StatusInternalServerError
+		var oe *orderError
+		switch {
+		case errors.As(result.Error, &oe):
+			item.Code = oe.status
+		case errors.Is(result.Error, context.DeadlineExceeded), errors.Is(result.Error, context.Canceled),
+			errors.Is(result.Error, worker.ErrNotProcessed):
+			item.Code = http.StatusServiceUnavailable
+		}
+	}
+	return item
+}
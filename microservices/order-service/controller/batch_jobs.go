@@ -0,0 +1,528 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/worker"
+)
+
+// batchJobLockClass namespaces the advisory locks that claim batch jobs
+const batchJobLockClass = 0x6f62
+
+// batchJobPollInterval is how often the runner looks for jobs it wasn't
+// handed directly, such as those orphaned by another instance
+const batchJobPollInterval = 30 * time.Second
+
+// BatchJobStore persists async batch jobs and the outcome of each order, so
+// a job interrupted by a restart resumes with the orders still pending
+type BatchJobStore interface {
+	// CreateJob stores a queued job with one pending item per order
+	CreateJob(job *model.BatchJob, orders []model.Order) error
+	// GetJob returns a job with the result of each of its orders
+	GetJob(id int) (*model.BatchJob, error)
+	// Claim takes exclusive ownership of an unfinished job and marks it
+	// running. It returns false when the job is finished or owned elsewhere.
+	Claim(id int) (release func(), claimed bool, err error)
+	// PendingOrders returns the orders of a job that have no outcome yet,
+	// with Index set to their position in the batch
+	PendingOrders(id int) ([]worker.Job, error)
+	// CreateOrders inserts orders, keyed by batch position, and marks their
+	// items created in one transaction
+	CreateOrders(id int, orders map[int]*model.Order) error
+	// RecordItems stores the outcome of orders that were not created
+	RecordItems(id int, items []model.BatchItemResult) error
+	// FinishJob marks a job completed or failed. Orders of a failed job that
+	// are still pending are marked not created with it, so none is left
+	// pending once the job is finished.
+	FinishJob(id int, status string, errMsg string) error
+	// UnfinishedJobs lists queued and running jobs, oldest first
+	UnfinishedJobs() ([]int, error)
+}
+
+// DBBatchJobStore implements BatchJobStore using the orders database
+type DBBatchJobStore struct {
+	DB     *sql.DB
+	Orders *DBOrderRepository
+}
+
+// NewDBBatchJobStore creates a batch job store that creates orders through orders
+func NewDBBatchJobStore(db *sql.DB, orders *DBOrderRepository) *DBBatchJobStore {
+	return &DBBatchJobStore{DB: db, Orders: orders}
+}
+
+// CreateJob inserts the job and its items and assigns the job ID
+func (s *DBBatchJobStore) CreateJob(job *model.BatchJob, orders []model.Order) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	job.Status = model.BatchJobQueued
+	job.Total = len(orders)
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	err = tx.QueryRow(`
+		INSERT INTO batch_jobs (customer_id, mode, status, total, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		job.CustomerID, job.Mode, job.Status, job.Total, job.CreatedAt, job.UpdatedAt,
+	).Scan(&job.ID)
+	if err != nil {
+		return err
+	}
+
+	for i, order := range orders {
+		payload, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO batch_job_items (job_id, idx, payload, status) VALUES ($1, $2, $3, $4)",
+			job.ID, i, payload, model.BatchItemPending)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetJob loads a job and its item results
+func (s *DBBatchJobStore) GetJob(id int) (*model.BatchJob, error) {
+	var job model.BatchJob
+	var completedAt sql.NullTime
+	err := s.DB.QueryRow(`
+		SELECT id, customer_id, mode, status, total, processed, successful, failed, error, created_at, updated_at, completed_at
+		FROM batch_jobs WHERE id = $1`, id).Scan(
+		&job.ID, &job.CustomerID, &job.Mode, &job.Status, &job.Total, &job.Processed, &job.Successful, &job.Failed,
+		&job.Error, &job.CreatedAt, &job.UpdatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT idx, status, order_id, error, code
+		FROM batch_job_items WHERE job_id = $1
+		ORDER BY idx`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	job.Items = make([]model.BatchItemResult, 0, job.Total)
+	for rows.Next() {
+		var item model.BatchItemResult
+		if err := rows.Scan(&item.Index, &item.Status, &item.OrderID, &item.Error, &item.Code); err != nil {
+			return nil, err
+		}
+		job.Items = append(job.Items, item)
+	}
+
+	return &job, rows.Err()
+}
+
+// Claim takes a session-level advisory lock on the job, held on a dedicated
+// connection until release is called or the process dies
+func (s *DBBatchJobStore) Claim(id int) (func(), bool, error) {
+	ctx := context.Background()
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, $2)", batchJobLockClass, id).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+	release := func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1, $2)", batchJobLockClass, id); err != nil {
+			log.Printf("Failed to unlock batch job %d: %v\n", id, err)
+		}
+		conn.Close()
+	}
+
+	result, err := s.DB.Exec(`
+		UPDATE batch_jobs SET status = $1, updated_at = $2
+		WHERE id = $3 AND status IN ($4, $1)`,
+		model.BatchJobRunning, time.Now(), id, model.BatchJobQueued)
+	if err != nil {
+		release()
+		return nil, false, err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		release()
+		return nil, false, nil
+	}
+	return release, true, nil
+}
+
+// PendingOrders decodes the orders of the job that are still pending
+func (s *DBBatchJobStore) PendingOrders(id int) ([]worker.Job, error) {
+	rows, err := s.DB.Query(`
+		SELECT idx, payload FROM batch_job_items
+		WHERE job_id = $1 AND status = $2
+		ORDER BY idx`, id, model.BatchItemPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]worker.Job, 0)
+	for rows.Next() {
+		var job worker.Job
+		var payload []byte
+		if err := rows.Scan(&job.Index, &payload); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &job.Order); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// CreateOrders inserts the orders and marks their items created in one
+// transaction, so a resumed job never creates an order twice
+func (s *DBBatchJobStore) CreateOrders(id int, orders map[int]*model.Order) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockBatchJob(tx, id); err != nil {
+		return err
+	}
+
+	entries := make([]model.OrderStatusHistory, 0, len(orders))
+	for index, order := range orders {
+		entry, err := insertOrder(tx, order)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+
+		_, err = tx.Exec("UPDATE batch_job_items SET status = $1, order_id = $2 WHERE job_id = $3 AND idx = $4",
+			model.BatchItemCreated, order.ID, id, index)
+		if err != nil {
+			return err
+		}
+	}
+	if err := updateBatchJobCounts(tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if s.Orders != nil {
+		for _, entry := range entries {
+			s.Orders.notifyTransition(entry)
+		}
+	}
+	return nil
+}
+
+// RecordItems stores item outcomes and refreshes the job's counters
+func (s *DBBatchJobStore) RecordItems(id int, items []model.BatchItemResult) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockBatchJob(tx, id); err != nil {
+		return err
+	}
+	for _, item := range items {
+		_, err := tx.Exec("UPDATE batch_job_items SET status = $1, error = $2, code = $3 WHERE job_id = $4 AND idx = $5",
+			item.Status, item.Error, item.Code, id, item.Index)
+		if err != nil {
+			return err
+		}
+	}
+	if err := updateBatchJobCounts(tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FinishJob marks the job finished, settling the pending items of a failed
+// job in the same transaction
+func (s *DBBatchJobStore) FinishJob(id int, status string, errMsg string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockBatchJob(tx, id); err != nil {
+		return err
+	}
+	if status == model.BatchJobFailed {
+		_, err := tx.Exec("UPDATE batch_job_items SET status = $1 WHERE job_id = $2 AND status = $3",
+			model.BatchItemNotCreated, id, model.BatchItemPending)
+		if err != nil {
+			return err
+		}
+		if err := updateBatchJobCounts(tx, id); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	_, err = tx.Exec("UPDATE batch_jobs SET status = $1, error = $2, updated_at = $3, completed_at = $3 WHERE id = $4",
+		status, errMsg, now, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnfinishedJobs returns the IDs of queued and running jobs
+func (s *DBBatchJobStore) UnfinishedJobs() ([]int, error) {
+	rows, err := s.DB.Query("SELECT id FROM batch_jobs WHERE status IN ($1, $2) ORDER BY id",
+		model.BatchJobQueued, model.BatchJobRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// lockBatchJob locks the job row first, so counters recomputed later in the
+// transaction see the items committed by every other worker
+func lockBatchJob(tx *sql.Tx, id int) error {
+	var locked int
+	return tx.QueryRow("SELECT id FROM batch_jobs WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+}
+
+// updateBatchJobCounts recomputes the job's counters from its items
+func updateBatchJobCounts(tx *sql.Tx, id int) error {
+	_, err := tx.Exec(`
+		UPDATE batch_jobs j
+		SET processed = c.processed, successful = c.successful, failed = c.failed, updated_at = $2
+		FROM (
+			SELECT COUNT(*) FILTER (WHERE status <> 'pending') AS processed,
+			       COUNT(*) FILTER (WHERE status = 'created') AS successful,
+			       COUNT(*) FILTER (WHERE status = 'failed') AS failed
+			FROM batch_job_items WHERE job_id = $1
+		) c
+		WHERE j.id = $1`, id, time.Now())
+	return err
+}
+
+// BatchRunner processes async batch jobs in the background, one job at a
+// time, each on the worker pool
+type BatchRunner struct {
+	Store  BatchJobStore
+	Orders *OrderController
+	queue  chan int
+}
+
+// NewBatchRunner creates a runner for jobs stored in store
+func NewBatchRunner(store BatchJobStore, orders *OrderController) *BatchRunner {
+	return &BatchRunner{
+		Store:  store,
+		Orders: orders,
+		queue:  make(chan int, 100),
+	}
+}
+
+// Enqueue hands a new job to the runner. When the queue is full the job
+// stays queued in the store and is picked up by the next poll.
+func (r *BatchRunner) Enqueue(id int) {
+	select {
+	case r.queue <- id:
+	default:
+	}
+}
+
+// Start runs jobs until ctx is cancelled, beginning with those left
+// unfinished by a previous process. A job interrupted by cancellation keeps
+// its pending orders and is resumed on the next start.
+func (r *BatchRunner) Start(ctx context.Context) {
+	go func() {
+		r.runUnfinished(ctx)
+
+		ticker := time.NewTicker(batchJobPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case id := <-r.queue:
+				r.Run(ctx, id)
+			case <-ticker.C:
+				r.runUnfinished(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// runUnfinished runs every queued or running job not owned elsewhere
+func (r *BatchRunner) runUnfinished(ctx context.Context) {
+	ids, err := r.Store.UnfinishedJobs()
+	if err != nil {
+		log.Printf("Failed to load unfinished batch jobs: %v\n", err)
+		return
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		r.Run(ctx, id)
+	}
+}
+
+// Run processes the pending orders of a job if it can be claimed
+func (r *BatchRunner) Run(ctx context.Context, id int) {
+	release, claimed, err := r.Store.Claim(id)
+	if err != nil {
+		log.Printf("Failed to claim batch job %d: %v\n", id, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	defer release()
+
+	job, err := r.Store.GetJob(id)
+	if err != nil {
+		log.Printf("Failed to load batch job %d: %v\n", id, err)
+		return
+	}
+	pending, err := r.Store.PendingOrders(id)
+	if err != nil {
+		log.Printf("Failed to load orders of batch job %d: %v\n", id, err)
+		return
+	}
+
+	if job.Mode == BatchModeAllOrNothing {
+		err = r.runAllOrNothing(ctx, job, pending)
+	} else {
+		err = r.runPartial(ctx, job, pending)
+	}
+	if ctx.Err() != nil {
+		log.Printf("Batch job %d interrupted; it will resume on restart\n", id)
+		return
+	}
+
+	status, errMsg := model.BatchJobCompleted, ""
+	if err != nil {
+		status, errMsg = model.BatchJobFailed, err.Error()
+	}
+	if err := r.Store.FinishJob(id, status, errMsg); err != nil {
+		log.Printf("Failed to finish batch job %d: %v\n", id, err)
+	}
+}
+
+// runPartial creates each valid order as soon as it is ready, recording the
+// outcome of every order as it completes
+func (r *BatchRunner) runPartial(ctx context.Context, job *model.BatchJob, pending []worker.Job) error {
+	uid := strconv.Itoa(job.CustomerID)
+	orders := make([]model.Order, len(pending))
+	for i := range pending {
+		orders[i] = pending[i].Order
+	}
+
+	worker.ProcessBatch(ctx, orders, batchWorkers, func(ctx context.Context, w worker.Job) worker.Result {
+		index := pending[w.Index].Index
+		order := w.Order
+		result := worker.Result{Index: w.Index}
+
+		err := r.Orders.prepareOrder(ctx, &order, uid)
+		if err == nil {
+			if err = ctx.Err(); err == nil {
+				err = r.Store.CreateOrders(job.ID, map[int]*model.Order{index: &order})
+			}
+		}
+		if err == nil {
+			result.OrderID = order.ID
+			return result
+		}
+
+		// Orders cut short by shutdown stay pending and are retried on resume
+		result.Error = err
+		if ctx.Err() == nil {
+			item := batchItemResult(result)
+			item.Index = index
+			if err := r.Store.RecordItems(job.ID, []model.BatchItemResult{item}); err != nil {
+				log.Printf("Failed to record order %d of batch job %d: %v\n", index, job.ID, err)
+			}
+		}
+		return result
+	})
+
+	return nil
+}
+
+// runAllOrNothing prepares every order and creates them in one transaction
+// only if all passed; otherwise it records why and creates none
+func (r *BatchRunner) runAllOrNothing(ctx context.Context, job *model.BatchJob, pending []worker.Job) error {
+	uid := strconv.Itoa(job.CustomerID)
+	orders := make([]model.Order, len(pending))
+	for i := range pending {
+		orders[i] = pending[i].Order
+	}
+
+	results := worker.ProcessBatch(ctx, orders, batchWorkers, r.Orders.batchOrderProcessor(uid, false))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	failed := 0
+	items := make([]model.BatchItemResult, len(results))
+	prepared := make(map[int]*model.Order, len(results))
+	for i, result := range results {
+		items[i] = batchItemResult(result)
+		items[i].Index = pending[i].Index
+		if result.Error != nil {
+			failed++
+		} else {
+			prepared[items[i].Index] = result.Order
+		}
+	}
+
+	if failed > 0 {
+		if err := r.Store.RecordItems(job.ID, items); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %d of %d orders are invalid", errBatchRejected, failed, len(results))
+	}
+
+	if err := r.Store.CreateOrders(job.ID, prepared); err != nil {
+		return fmt.Errorf("failed to create orders: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-microservices/order-service/lifecycle"
+	"go-microservices/order-service/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Refund outcomes of a cancelled order. Pending, processing, refunded and
+// failed are recorded on the order; processing marks a refund that one
+// request has claimed and is carrying out.
+const (
+	RefundNotRequired = "not_required"
+	RefundPending     = "pending"
+	RefundProcessing  = "processing"
+	RefundCompleted   = "refunded"
+	RefundFailed      = "failed"
+)
+
+// cancelRefundReason is the payment-service refund reason for cancellations
+const cancelRefundReason = "requested_by_customer"
+
+// refundClaimTimeout is how long a claimed refund of a cancelled order or a
+// return may stay processing before another request takes it over, in case
+// the one carrying it out died
+const refundClaimTimeout = 5 * time.Minute
+
+// CancelOrder soft-cancels an order. The order keeps its row and history;
+// open payment intents of a pending order are cancelled and, if it had been
+// paid, its payments are refunded. Cancelling again retries a refund that
+// failed. order.cancelled is emitted
+// through the outbox with the status change, and inventory-service releases
+// the reservation from it. Orders can't be cancelled once they have shipped.
+func (oc *OrderController) CancelOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	order, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Enforce ownership: only owner, admin or system can cancel
+	uid := c.GetHeader("X-User-Id")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	actor := lifecycle.ActorFromHeaders(uid, c.GetHeader("X-User-Roles"))
+	if actor.Role == lifecycle.RoleCustomer && strconv.Itoa(order.CustomerID) != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	if order.Status == lifecycle.StatusCancelled {
+		oc.retryCancelRefund(c, order)
+		return
+	}
+
+	// A checked-out order has an open payment intent the customer could
+	// still pay after the cancellation, so it is cancelled first. If that
+	// fails the order stays as it is and the cancel can be retried.
+	if order.Status == lifecycle.StatusPending {
+		if err := oc.PaymentService.CancelOrderPayments(id, order.CustomerID); err != nil {
+			log.Printf("Failed to cancel payments of order %d: %v\n", id, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to cancel the order's payment: " + err.Error()})
+			return
+		}
+	}
+
+	// The transition locks the order and checks its current status, so a
+	// concurrent shipment can't slip past the check above
+	entry, err := oc.OrderRepo.TransitionOrderStatus(id, lifecycle.StatusCancelled, actor, req.Reason)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	case errors.Is(err, lifecycle.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order can no longer be cancelled", "status": order.Status})
+		return
+	case errors.Is(err, lifecycle.ErrForbiddenTransition):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	case entry == nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is already cancelled", "status": lifecycle.StatusCancelled})
+		return
+	}
+
+	// The order stays cancelled even if a follow-up fails; the response
+	// reports what still needs attention
+	response := gin.H{
+		"message":         "Order cancelled successfully",
+		"order_id":        id,
+		"status":          lifecycle.StatusCancelled,
+		"previous_status": entry.FromStatus,
+	}
+
+	response["refund"] = gin.H{"status": RefundNotRequired}
+	if wasPaid(entry.FromStatus) {
+		response["refund"] = oc.refundCancelledOrder(order)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// retryCancelRefund answers a repeated cancel of an already cancelled order.
+// A refund that failed or was interrupted is retried; otherwise there is
+// nothing left to do.
+func (oc *OrderController) retryCancelRefund(c *gin.Context, order *model.Order) {
+	claimed, err := oc.OrderRepo.ClaimOrderRefund(order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is already cancelled", "status": order.Status})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Refund retried",
+		"order_id": order.ID,
+		"status":   lifecycle.StatusCancelled,
+		"refund":   oc.refundOrder(order),
+	})
+}
+
+// refundCancelledOrder claims and carries out the refund a paid order owes
+// once it is cancelled
+func (oc *OrderController) refundCancelledOrder(order *model.Order) gin.H {
+	claimed, err := oc.OrderRepo.ClaimOrderRefund(order.ID)
+	if err != nil {
+		log.Printf("Failed to claim refund of cancelled order %d: %v\n", order.ID, err)
+		return gin.H{"status": RefundPending, "error": err.Error()}
+	}
+	if !claimed {
+		// A retried cancel got to it first
+		return gin.H{"status": RefundProcessing}
+	}
+	return oc.refundOrder(order)
+}
+
+// refundOrder refunds the payments of a cancelled order whose refund has
+// been claimed, and records the outcome on the order
+func (oc *OrderController) refundOrder(order *model.Order) gin.H {
+	amount, err := oc.PaymentService.RefundOrder(order.ID, order.CustomerID, cancelRefundReason)
+	refund := gin.H{"status": RefundCompleted, "amount": amount}
+	if err != nil {
+		log.Printf("Failed to refund cancelled order %d: %v\n", order.ID, err)
+		refund["status"] = RefundFailed
+		refund["error"] = err.Error()
+	}
+	if err := oc.OrderRepo.RecordOrderRefund(order.ID, refund["status"].(string)); err != nil {
+		log.Printf("Failed to record refund of cancelled order %d: %v\n", order.ID, err)
+	}
+	return refund
+}
+
+// ClaimOrderRefund marks the pending or failed refund of a cancelled order as
+// processing, or takes over one whose claim has gone stale. It reports false
+// when there is no refund to claim, so concurrent cancels can't refund an
+// order twice.
+func (r *DBOrderRepository) ClaimOrderRefund(orderID int) (bool, error) {
+	now := time.Now()
+	result, err := r.DB.Exec(`
+		UPDATE orders SET refund_status = $1, refund_claimed_at = $2
+		WHERE id = $3 AND status = $4
+		  AND (refund_status IN ($5, $6) OR (refund_status = $1 AND refund_claimed_at < $7))`,
+		RefundProcessing, now, orderID, lifecycle.StatusCancelled, RefundPending, RefundFailed, now.Add(-refundClaimTimeout))
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+// RecordOrderRefund stores the outcome of a claimed refund of a cancelled order
+func (r *DBOrderRepository) RecordOrderRefund(orderID int, status string) error {
+	_, err := r.DB.Exec("UPDATE orders SET refund_status = $1 WHERE id = $2", status, orderID)
+	return err
+}
+
+// wasPaid reports whether an order in status had captured its payment
+func wasPaid(status string) bool {
+	return status == lifecycle.StatusPaid || status == lifecycle.StatusProcessing
+}
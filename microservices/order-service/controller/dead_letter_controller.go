@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-microservices/pkg/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeadLetterLimit = 50
+	maxDeadLetterLimit     = 1000
+)
+
+// DeadLetterQueue defines the interface for managing messages that
+// exhausted their delivery attempts
+type DeadLetterQueue interface {
+	Queues() ([]queue.DeadLetterQueue, error)
+	List(queueName string, limit int) ([]queue.DeadLetter, error)
+	Get(queueName string, id string) (*queue.DeadLetter, error)
+	Replay(ctx context.Context, queueName string, id string) (int, error)
+	Purge(queueName string, id string) (int, error)
+}
+
+// RabbitMQDeadLetters implements DeadLetterQueue interface using RabbitMQ
+type RabbitMQDeadLetters struct{}
+
+// Queues lists the dead-letter queues
+func (r *RabbitMQDeadLetters) Queues() ([]queue.DeadLetterQueue, error) {
+	return queue.DeadLetterQueues()
+}
+
+// List returns messages of a dead-letter queue
+func (r *RabbitMQDeadLetters) List(queueName string, limit int) ([]queue.DeadLetter, error) {
+	return queue.ListDeadLetters(queueName, limit)
+}
+
+// Get returns one message of a dead-letter queue
+func (r *RabbitMQDeadLetters) Get(queueName string, id string) (*queue.DeadLetter, error) {
+	return queue.GetDeadLetter(queueName, id)
+}
+
+// Replay sends dead letters back to the queue they failed on
+func (r *RabbitMQDeadLetters) Replay(ctx context.Context, queueName string, id string) (int, error) {
+	return queue.ReplayDeadLetters(ctx, queueName, id)
+}
+
+// Purge deletes dead letters
+func (r *RabbitMQDeadLetters) Purge(queueName string, id string) (int, error) {
+	return queue.PurgeDeadLetters(queueName, id)
+}
+
+// ListDeadLetterQueues lists the dead-letter queues with their message counts
+func (oc *OrderController) ListDeadLetterQueues(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	queues, err := oc.DeadLetters.Queues()
+	if err != nil {
+		deadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queues": queues})
+}
+
+// ListDeadLetters returns up to ?limit= messages of a dead-letter queue,
+// oldest first, without removing them
+func (oc *OrderController) ListDeadLetters(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	limit := defaultDeadLetterLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeadLetterLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		limit = n
+	}
+
+	messages, err := oc.DeadLetters.List(c.Param("queue"), limit)
+	if err != nil {
+		deadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queue": c.Param("queue"), "messages": messages})
+}
+
+// GetDeadLetter returns one dead-lettered message with its failure details
+func (oc *OrderController) GetDeadLetter(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	message, err := oc.DeadLetters.Get(c.Param("queue"), c.Param("messageId"))
+	if err != nil {
+		deadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// ReplayDeadLetters publishes dead letters back to the queue they failed on
+// with a fresh set of attempts; without a message ID the whole queue is
+// replayed
+func (oc *OrderController) ReplayDeadLetters(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	replayed, err := oc.DeadLetters.Replay(c.Request.Context(), c.Param("queue"), c.Param("messageId"))
+	if err != nil && replayed == 0 {
+		deadLetterError(c, err)
+		return
+	}
+	if err != nil {
+		// Some were replayed before the failure; report both
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "replayed": replayed})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed})
+}
+
+// PurgeDeadLetters deletes dead letters; without a message ID the whole
+// queue is purged
+func (oc *OrderController) PurgeDeadLetters(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	purged, err := oc.DeadLetters.Purge(c.Param("queue"), c.Param("messageId"))
+	if err != nil {
+		deadLetterError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// requireAdmin writes 403 and returns false unless the caller is an admin
+func requireAdmin(c *gin.Context) bool {
+	if !strings.Contains(c.GetHeader("X-User-Roles"), "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		return false
+	}
+	return true
+}
+
+// deadLetterError maps a dead-letter queue error to its response
+func deadLetterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, queue.ErrUnknownDeadLetterQueue), errors.Is(err, queue.ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, queue.ErrOriginalQueueGone):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, queue.ErrNotConnected), errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/pkg/events"
+)
+
+// orderCacheTTL bounds how long a missed invalidation can serve a stale order
+const orderCacheTTL = 30 * time.Minute
+
+// orderCacheKey returns the cache key of an order
+func orderCacheKey(orderID int) string {
+	return "order:" + strconv.Itoa(orderID)
+}
+
+// loadOrder reads an order through the cache, falling back to the database
+// when no cache is configured. A slow cache only delays the read by the
+// cache timeout, but ctx's error is returned once the caller is done.
+func (oc *OrderController) loadOrder(ctx context.Context, orderID int) (*model.Order, error) {
+	if oc.Cache == nil {
+		return oc.OrderRepo.GetOrderFromDB(strconv.Itoa(orderID))
+	}
+
+	var order model.Order
+	err := oc.Cache.GetOrSet(ctx, orderCacheKey(orderID), &order, orderCacheTTL, func() (interface{}, error) {
+		return oc.OrderRepo.GetOrderFromDB(strconv.Itoa(orderID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// InvalidateOrder drops the cached copy of an order after it changed. A
+// failure is only logged; the entry then expires with orderCacheTTL.
+func (oc *OrderController) InvalidateOrder(ctx context.Context, orderID int) {
+	if oc.Cache == nil {
+		return
+	}
+	if err := oc.Cache.Delete(ctx, orderCacheKey(orderID)); err != nil {
+		log.Printf("Failed to invalidate cached order %d: %v\n", orderID, err)
+	}
+}
+
+// HandleOrderEvent invalidates the order an event from the orders exchange
+// refers to. Events are only published once their change has committed, so
+// this also drops entries that a concurrent read cached from the database
+// just before the write, and covers orders changed by other replicas.
+func (oc *OrderController) HandleOrderEvent(body []byte) error {
+	envelope, err := events.Parse(body)
+	if err != nil {
+		// Redelivering a malformed event can't help; drop it
+		log.Printf("Failed to decode order event: %v\n", err)
+		return nil
+	}
+
+	// order.created and order.updated carry the order itself; every other
+	// event names it with order_id
+	var event struct {
+		ID      int `json:"id"`
+		OrderID int `json:"order_id"`
+	}
+	if err := envelope.Decode(&event); err != nil {
+		log.Printf("Failed to decode order event: %v\n", err)
+		return nil
+	}
+
+	orderID := event.OrderID
+	if orderID == 0 {
+		orderID = event.ID
+	}
+	if orderID != 0 {
+		oc.InvalidateOrder(context.Background(), orderID)
+	}
+	return nil
+}
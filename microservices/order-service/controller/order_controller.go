@@ -1,153 +1,648 @@
 package controller
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"go-microservices/order-service/cache"
+	"go-microservices/order-service/lifecycle"
 	"go-microservices/order-service/metrics"
 	"go-microservices/order-service/model"
-	"go-microservices/order-service/queue"
+	"go-microservices/order-service/outbox"
+	"go-microservices/order-service/pricing"
+	"go-microservices/order-service/saga"
 	"go-microservices/order-service/service"
-	"go-microservices/order-service/worker"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/queue"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 // InventoryServiceInterface defines the interface for inventory service
 type InventoryServiceInterface interface {
 	CheckAvailability(productID int, quantity int) (bool, error)
+	Restock(reference string, items []model.ReturnItem) error
 }
 
-// NotificationServiceInterface defines the interface for notification service
-type NotificationServiceInterface interface {
-	SendOrderNotification(orderID int) error
-	SendOrderStatusUpdate(orderID int, customerID int, status string) error
+// ProductServiceInterface defines the interface for product service
+type ProductServiceInterface interface {
+	GetProduct(productID int) (*model.Product, error)
+}
+
+// PromotionServiceInterface defines the interface for promotion service
+type PromotionServiceInterface interface {
+	GetPromotion(code string) (*model.Promotion, error)
 }
 
 // PaymentServiceInterface defines the interface for payment service
 type PaymentServiceInterface interface {
 	CreatePayment(orderID int, customerID int, amount float64, currency string) (*service.PaymentResponse, error)
+	CancelOrderPayments(orderID int, customerID int) error
+	RefundOrder(orderID int, customerID int, reason string) (float64, error)
+	RefundAmount(orderID int, customerID int, amount float64, reason string, idempotencyKey string) (float64, error)
 }
 
 // OrderRepository defines the interface for order database operations
 type OrderRepository interface {
 	InsertOrder(order *model.Order) error
+	InsertOrders(orders []*model.Order) error
 	GetOrderFromDB(orderID string) (*model.Order, error)
+	ListOrders(params model.OrderListParams) (*model.OrderPage, error)
+	UpdateOrder(order *model.Order) error
+	UpdateOrderStatus(orderID int, status string) error
+	TransitionOrderStatus(orderID int, status string, actor lifecycle.Actor, reason string) (*model.OrderStatusHistory, error)
+	ClaimOrderRefund(orderID int) (bool, error)
+	RecordOrderRefund(orderID int, status string) error
+	GetOrderHistory(orderID int) ([]model.OrderStatusHistory, error)
+	DeleteOrder(orderID int) error
 }
 
+// ErrOrderNotEditable is returned when the items of an order that has left
+// pending are modified
+var ErrOrderNotEditable = errors.New("order can only be modified while pending")
+
+// ErrOrderCheckedOut is returned when modifying a pending order that already
+// holds a reservation and payment intent for its current lines and total;
+// the customer cancels it and checks out again instead
+var ErrOrderCheckedOut = errors.New("order has been checked out; cancel it and check out again to change it")
+
 // Cache defines the interface for cache operations
 type Cache interface {
-	Get(key string, value interface{}) error
-	Set(key string, value interface{}, expiration time.Duration) error
-	GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error
+	Get(ctx context.Context, key string, value interface{}) error
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error
+	Delete(ctx context.Context, key string) error
 }
 
 // MessageQueue defines the interface for message queue operations
 type MessageQueue interface {
-	PublishMessage(config queue.Config, message interface{}) error
+	PublishMessage(ctx context.Context, config queue.Config, message interface{}) error
 }
 
 // OrderController handles order-related requests
 type OrderController struct {
-	DB                  *sql.DB
-	OrderRepo           OrderRepository
-	Cache               Cache
-	Queue               MessageQueue
-	InventoryService    InventoryServiceInterface
-	NotificationService NotificationServiceInterface
-	PaymentService      PaymentServiceInterface
+	DB               *sql.DB
+	OrderRepo        OrderRepository
+	Checkout         *saga.CheckoutOrchestrator
+	Cache            Cache
+	Queue            MessageQueue
+	InventoryService InventoryServiceInterface
+	ProductService   ProductServiceInterface
+	PromotionService PromotionServiceInterface
+	PaymentService   PaymentServiceInterface
+	Pricing          *pricing.Calculator
+	Batches          *BatchRunner
+	Returns          ReturnRepository
+	DeadLetters      DeadLetterQueue
 }
 
 // DBOrderRepository implements OrderRepository interface using SQL database
 type DBOrderRepository struct {
 	DB *sql.DB
+	// OnTransition, when set, is called after every committed status
+	// change, including the initial pending status of a new order
+	OnTransition func(model.OrderStatusHistory)
 }
 
-// InsertOrder inserts a new order into the database
+// InsertOrder inserts a new order and its lines in a single transaction,
+// together with its order.created outbox event
 func (r *DBOrderRepository) InsertOrder(order *model.Order) error {
-	query := `
-		INSERT INTO orders (product_id, quantity, status, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id`
+	return r.InsertOrders([]*model.Order{order})
+}
 
-	order.Status = "pending"
-	order.CreatedAt = time.Now()
+// InsertOrders inserts several orders in a single transaction, so either all
+// of them are created or none is
+func (r *DBOrderRepository) InsertOrders(orders []*model.Order) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	entries := make([]model.OrderStatusHistory, 0, len(orders))
+	for _, order := range orders {
+		entry, err := insertOrder(tx, order)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
 
-	return r.DB.QueryRow(
-		query,
-		order.ProductID,
-		order.Quantity,
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		r.notifyTransition(entry)
+	}
+	return nil
+}
+
+// insertOrder writes a new pending order, its lines, its first history entry
+// and its order.created event within the given transaction
+func insertOrder(tx *sql.Tx, order *model.Order) (model.OrderStatusHistory, error) {
+	order.Status = lifecycle.StatusPending
+	order.CreatedAt = time.Now()
+	order.CalculateTotal()
+
+	err := tx.QueryRow(`
+		INSERT INTO orders (customer_id, promo_code, subtotal, discount_amount, tax_amount, total_price, reservation_id, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`,
+		order.CustomerID,
+		order.PromoCode,
+		order.Subtotal,
+		order.DiscountAmount,
+		order.TaxAmount,
+		order.TotalPrice,
+		order.ReservationID,
 		order.Status,
 		order.CreatedAt,
 	).Scan(&order.ID)
+	if err != nil {
+		return model.OrderStatusHistory{}, err
+	}
+
+	if err := insertOrderItems(tx, order); err != nil {
+		return model.OrderStatusHistory{}, err
+	}
+	entry := model.OrderStatusHistory{
+		OrderID:    order.ID,
+		CustomerID: order.CustomerID,
+		ToStatus:   order.Status,
+		ActorRole:  lifecycle.RoleSystem,
+		CreatedAt:  order.CreatedAt,
+	}
+	if err := insertStatusHistory(tx, &entry); err != nil {
+		return model.OrderStatusHistory{}, err
+	}
+	if err := outbox.Enqueue(tx, outbox.EventOrderCreated, order.ID, order); err != nil {
+		return model.OrderStatusHistory{}, err
+	}
+	return entry, nil
 }
 
-// GetOrderFromDB retrieves an order from the database by ID
+// GetOrderFromDB retrieves an order and its lines from the database by ID
 func (r *DBOrderRepository) GetOrderFromDB(orderID string) (*model.Order, error) {
 	var order model.Order
 	query := `
-		SELECT id, product_id, quantity, status, created_at
+		SELECT id, customer_id, promo_code, subtotal, discount_amount, tax_amount, total_price, reservation_id, status, created_at
 		FROM orders
 		WHERE id = $1`
 
 	err := r.DB.QueryRow(query, orderID).Scan(
 		&order.ID,
-		&order.ProductID,
-		&order.Quantity,
+		&order.CustomerID,
+		&order.PromoCode,
+		&order.Subtotal,
+		&order.DiscountAmount,
+		&order.TaxAmount,
+		&order.TotalPrice,
+		&order.ReservationID,
 		&order.Status,
 		&order.CreatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
 
+	items, err := r.getOrderItems([]int{order.ID})
 	if err != nil {
 		return nil, err
 	}
+	order.Items = items[order.ID]
 
 	return &order, nil
 }
 
+// ListOrders returns one page of orders, with their lines, matching the
+// filters of params. Pages are keyed on the sort column and ID so they stay
+// stable while new orders are created.
+func (r *DBOrderRepository) ListOrders(params model.OrderListParams) (*model.OrderPage, error) {
+	if params.Sort == "" {
+		params.Sort = defaultOrderSort
+	}
+	if params.Limit <= 0 {
+		params.Limit = defaultOrderPageSize
+	}
+	column, ok := orderSortColumns[strings.TrimPrefix(params.Sort, "-")]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort %q", params.Sort)
+	}
+	direction, comparison := "ASC", ">"
+	if strings.HasPrefix(params.Sort, "-") {
+		direction, comparison = "DESC", "<"
+	}
+
+	where, args := orderListFilters(params)
+
+	page := &model.OrderPage{Data: make([]model.Order, 0), Limit: params.Limit}
+	if err := r.DB.QueryRow("SELECT COUNT(*) FROM orders"+whereClause(where), args...).Scan(&page.TotalCount); err != nil {
+		return nil, err
+	}
+
+	if params.After != nil {
+		value, err := orderCursorValue(*params.After)
+		if err != nil {
+			return nil, errInvalidCursor
+		}
+		args = append(args, value, params.After.ID)
+		where = append(where, fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+	}
+	args = append(args, params.Limit+1)
+
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		SELECT id, customer_id, promo_code, subtotal, discount_amount, tax_amount, total_price, reservation_id, status, created_at
+		FROM orders%s
+		ORDER BY %s %s, id %s
+		LIMIT $%d`, whereClause(where), column, direction, direction, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var o model.Order
+		if err := rows.Scan(&o.ID, &o.CustomerID, &o.PromoCode, &o.Subtotal, &o.DiscountAmount, &o.TaxAmount,
+			&o.TotalPrice, &o.ReservationID, &o.Status, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		page.Data = append(page.Data, o)
+		ids = append(ids, o.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// One extra row was fetched to tell whether another page follows
+	if len(page.Data) > params.Limit {
+		page.Data = page.Data[:params.Limit]
+		ids = ids[:params.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeOrderCursor(params.Sort, page.Data[len(page.Data)-1])
+	}
+
+	items, err := r.getOrderItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Data {
+		page.Data[i].Items = items[page.Data[i].ID]
+	}
+
+	return page, nil
+}
+
+// UpdateOrder replaces the promo code, pricing and lines of a pending order
+// that has not been checked out, in a single transaction. Customer and status
+// are never changed here; status moves only through TransitionOrderStatus.
+func (r *DBOrderRepository) UpdateOrder(order *model.Order) error {
+	order.CalculateTotal()
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var customerID int
+	var status string
+	var reservationID sql.NullInt64
+	err = tx.QueryRow("SELECT customer_id, status, reservation_id FROM orders WHERE id = $1 FOR UPDATE", order.ID).
+		Scan(&customerID, &status, &reservationID)
+	if err != nil {
+		return err
+	}
+	if status != lifecycle.StatusPending {
+		return ErrOrderNotEditable
+	}
+	if reservationID.Int64 != 0 {
+		return ErrOrderCheckedOut
+	}
+	order.CustomerID = customerID
+	order.Status = status
+
+	_, err = tx.Exec(`
+		UPDATE orders
+		SET promo_code = $1, subtotal = $2, discount_amount = $3, tax_amount = $4, total_price = $5
+		WHERE id = $6`,
+		order.PromoCode, order.Subtotal, order.DiscountAmount, order.TaxAmount, order.TotalPrice, order.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM order_items WHERE order_id = $1", order.ID); err != nil {
+		return err
+	}
+	if err := insertOrderItems(tx, order); err != nil {
+		return err
+	}
+	if err := outbox.Enqueue(tx, outbox.EventOrderUpdated, order.ID, order); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateOrderStatus moves an order to status on behalf of the system
+func (r *DBOrderRepository) UpdateOrderStatus(orderID int, status string) error {
+	_, err := r.TransitionOrderStatus(orderID, status, lifecycle.System, "")
+	return err
+}
+
+// TransitionOrderStatus moves an order to status if the state machine allows
+// actor to do so. The status change, its history entry and its outbox events
+// are written in one transaction. Moving to the current status is a no-op and
+// returns a nil entry.
+func (r *DBOrderRepository) TransitionOrderStatus(orderID int, status string, actor lifecycle.Actor, reason string) (*model.OrderStatusHistory, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var customerID int
+	var previousStatus string
+	var reservationID sql.NullInt64
+	err = tx.QueryRow("SELECT customer_id, status, reservation_id FROM orders WHERE id = $1 FOR UPDATE", orderID).
+		Scan(&customerID, &previousStatus, &reservationID)
+	if err != nil {
+		return nil, err
+	}
+	if previousStatus == status {
+		return nil, nil
+	}
+	if err := lifecycle.CanTransition(previousStatus, status, actor); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE orders SET status = $1 WHERE id = $2", status, orderID); err != nil {
+		return nil, err
+	}
+	// A paid order that is cancelled owes its refund from the same commit,
+	// so the refund can be retried even if the caller never gets to it
+	if status == lifecycle.StatusCancelled && wasPaid(previousStatus) {
+		if _, err := tx.Exec("UPDATE orders SET refund_status = $1 WHERE id = $2", RefundPending, orderID); err != nil {
+			return nil, err
+		}
+	}
+	entry := model.OrderStatusHistory{
+		OrderID:    orderID,
+		CustomerID: customerID,
+		FromStatus: previousStatus,
+		ToStatus:   status,
+		ActorRole:  actor.Role,
+		ActorID:    actor.UserID,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	}
+	if err := insertStatusHistory(tx, &entry); err != nil {
+		return nil, err
+	}
+	if err := enqueueStatusChange(tx, orderID, customerID, int(reservationID.Int64), previousStatus, status); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	r.notifyTransition(entry)
+	return &entry, nil
+}
+
+// GetOrderHistory returns the status changes of an order, oldest first
+func (r *DBOrderRepository) GetOrderHistory(orderID int) ([]model.OrderStatusHistory, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, order_id, from_status, to_status, actor_role, actor_id, reason, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]model.OrderStatusHistory, 0)
+	for rows.Next() {
+		var h model.OrderStatusHistory
+		if err := rows.Scan(&h.ID, &h.OrderID, &h.FromStatus, &h.ToStatus, &h.ActorRole, &h.ActorID,
+			&h.Reason, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+
+	return history, rows.Err()
+}
+
+// notifyTransition passes a committed status change to OnTransition
+func (r *DBOrderRepository) notifyTransition(entry model.OrderStatusHistory) {
+	if r.OnTransition != nil {
+		r.OnTransition(entry)
+	}
+}
+
+// DeleteOrder removes an order and its lines, emitting order.deleted and,
+// when the order could still have been cancelled, order.cancelled for
+// consumers. Orders that shipped, or were cancelled or refunded already,
+// don't emit it, so inventory never restocks goods that left the warehouse.
+func (r *DBOrderRepository) DeleteOrder(orderID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var customerID int
+	var previousStatus string
+	var reservationID sql.NullInt64
+	err = tx.QueryRow("SELECT customer_id, status, reservation_id FROM orders WHERE id = $1 FOR UPDATE", orderID).
+		Scan(&customerID, &previousStatus, &reservationID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM orders WHERE id = $1", orderID); err != nil {
+		return err
+	}
+	err = outbox.Enqueue(tx, outbox.EventOrderDeleted, orderID, events.OrderRemoval{OrderID: orderID, CustomerID: customerID})
+	if err != nil {
+		return err
+	}
+	if lifecycle.CanTransition(previousStatus, lifecycle.StatusCancelled, lifecycle.System) == nil {
+		err = outbox.Enqueue(tx, outbox.EventOrderCancelled, orderID, events.StatusChange{
+			OrderID:        orderID,
+			CustomerID:     customerID,
+			ReservationID:  int(reservationID.Int64),
+			PreviousStatus: previousStatus,
+			Status:         lifecycle.StatusCancelled,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// enqueueStatusChange writes order.status_changed, plus order.cancelled when
+// the order moves to cancelled, within the given transaction
+func enqueueStatusChange(tx *sql.Tx, orderID, customerID, reservationID int, previousStatus, status string) error {
+	if previousStatus == status {
+		return nil
+	}
+
+	event := events.StatusChange{
+		OrderID:        orderID,
+		CustomerID:     customerID,
+		ReservationID:  reservationID,
+		PreviousStatus: previousStatus,
+		Status:         status,
+	}
+	if err := outbox.Enqueue(tx, outbox.EventOrderStatusChanged, orderID, event); err != nil {
+		return err
+	}
+	if status == lifecycle.StatusCancelled {
+		return outbox.Enqueue(tx, outbox.EventOrderCancelled, orderID, event)
+	}
+	return nil
+}
+
+// insertStatusHistory records a status change within the given transaction
+func insertStatusHistory(tx *sql.Tx, entry *model.OrderStatusHistory) error {
+	return tx.QueryRow(`
+		INSERT INTO order_status_history (order_id, from_status, to_status, actor_role, actor_id, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		entry.OrderID, entry.FromStatus, entry.ToStatus, entry.ActorRole, entry.ActorID, entry.Reason, entry.CreatedAt,
+	).Scan(&entry.ID)
+}
+
+// insertOrderItems writes the order lines within the given transaction
+func insertOrderItems(tx *sql.Tx, order *model.Order) error {
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.OrderID = order.ID
+		err := tx.QueryRow(`
+			INSERT INTO order_items (order_id, product_id, product_name, quantity, unit_price, line_total)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`,
+			item.OrderID, item.ProductID, item.ProductName, item.Quantity, item.UnitPrice, item.LineTotal,
+		).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getOrderItems loads the lines for the given orders, keyed by order ID
+func (r *DBOrderRepository) getOrderItems(orderIDs []int) (map[int][]model.OrderItem, error) {
+	items := make(map[int][]model.OrderItem)
+	if len(orderIDs) == 0 {
+		return items, nil
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT id, order_id, product_id, product_name, quantity, unit_price, line_total
+		FROM order_items
+		WHERE order_id = ANY($1)
+		ORDER BY id`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item model.OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.ProductName, &item.Quantity,
+			&item.UnitPrice, &item.LineTotal); err != nil {
+			return nil, err
+		}
+		items[item.OrderID] = append(items[item.OrderID], item)
+	}
+
+	return items, rows.Err()
+}
+
 // RedisCache implements Cache interface using Redis
 type RedisCache struct{}
 
 // Get retrieves a value from cache
-func (r *RedisCache) Get(key string, value interface{}) error {
-	return cache.Get(key, value)
+func (r *RedisCache) Get(ctx context.Context, key string, value interface{}) error {
+	return cache.Get(ctx, key, value)
 }
 
 // Set stores a value in cache with expiration
-func (r *RedisCache) Set(key string, value interface{}, expiration time.Duration) error {
-	return cache.Set(key, value, expiration)
+func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return cache.Set(ctx, key, value, expiration)
 }
 
 // GetOrSet retrieves value from cache or sets it if not exists
-func (r *RedisCache) GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
-	return cache.GetOrSet(key, value, expiration, fn)
+func (r *RedisCache) GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
+	return cache.GetOrSet(ctx, key, value, expiration, fn)
+}
+
+// Delete removes a value from cache
+func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	return cache.Delete(ctx, key)
 }
 
 // RabbitMQQueue implements MessageQueue interface using RabbitMQ
 type RabbitMQQueue struct{}
 
 // PublishMessage publishes a message to RabbitMQ
-func (r *RabbitMQQueue) PublishMessage(config queue.Config, message interface{}) error {
-	return queue.PublishMessage(config, message)
+func (r *RabbitMQQueue) PublishMessage(ctx context.Context, config queue.Config, message interface{}) error {
+	return queue.PublishMessage(ctx, config, message)
 }
 
 // NewOrderController creates a new order controller
 func NewOrderController(db *sql.DB) *OrderController {
-	return &OrderController{
-		DB:                  db,
-		OrderRepo:           &DBOrderRepository{DB: db},
-		Cache:               &RedisCache{},
-		Queue:               &RabbitMQQueue{},
-		InventoryService:    service.NewInventoryService(),
-		NotificationService: service.NewNotificationService(),
-		PaymentService:      service.NewPaymentService(),
+	orderRepo := &DBOrderRepository{DB: db}
+	inventoryService := service.NewInventoryService()
+	paymentService := service.NewPaymentService()
+
+	oc := &OrderController{
+		DB:               db,
+		OrderRepo:        orderRepo,
+		Checkout:         saga.NewCheckoutOrchestrator(saga.NewDBStore(db), inventoryService, orderRepo, paymentService),
+		Cache:            cache.NewTieredFromEnv(),
+		Queue:            &RabbitMQQueue{},
+		DeadLetters:      &RabbitMQDeadLetters{},
+		InventoryService: inventoryService,
+		ProductService:   service.NewProductService(),
+		PromotionService: service.NewPromotionService(),
+		PaymentService:   paymentService,
+		Pricing:          pricing.NewCalculator(),
+	}
+	orderRepo.OnTransition = oc.RecordTransition
+	oc.Batches = NewBatchRunner(NewDBBatchJobStore(db, orderRepo), oc)
+	oc.Returns = &DBReturnRepository{DB: db}
+	return oc
+}
+
+// RecordTransition drops the cached order and updates the order metrics for
+// a committed status change. Customers are notified by notification-service,
+// which consumes the order.status_changed event.
+func (oc *OrderController) RecordTransition(entry model.OrderStatusHistory) {
+	oc.InvalidateOrder(context.Background(), entry.OrderID)
+
+	if entry.FromStatus == "" {
+		metrics.OrdersCreated.Inc()
+		metrics.ActiveOrders.Inc()
+		return
+	}
+
+	metrics.OrderStatusUpdated.WithLabelValues(entry.ToStatus).Inc()
+	if lifecycle.IsActive(entry.FromStatus) && !lifecycle.IsActive(entry.ToStatus) {
+		metrics.ActiveOrders.Dec()
 	}
 }
 
@@ -166,32 +661,24 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		return
 	}
 	// If client provided CustomerID and it doesn't match header, reject
-	if order.CustomerID != 0 {
-		if strconv.Itoa(order.CustomerID) != uid {
-			c.JSON(http.StatusForbidden, gin.H{"error": "cannot create order for another user"})
-			return
-		}
-	} else {
-		// Set customer ID from header
-		cid, _ := strconv.Atoi(uid)
-		order.CustomerID = cid
+	if err := assignCustomer(&order, uid); err != nil {
+		writeOrderError(c, err)
+		return
 	}
 
-	// Check inventory availability using circuit breaker
-	available, err := oc.InventoryService.CheckAvailability(order.ProductID, order.Quantity)
-	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check inventory: " + err.Error()})
+	// Check inventory availability for every order line
+	if !oc.checkItemsAvailability(c, order.Items) {
 		return
 	}
-	if !available {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product not available in requested quantity"})
+
+	// Price the order server-side from the product catalogue
+	if !oc.priceOrder(c, &order) {
 		return
 	}
 
-	// Insert order into database
+	// Insert order and its lines into database
 	if oc.OrderRepo != nil {
-		err = oc.OrderRepo.InsertOrder(&order)
-		if err != nil {
+		if err := oc.OrderRepo.InsertOrder(&order); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
 			return
 		}
@@ -202,24 +689,9 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		order.CreatedAt = time.Now()
 	}
 
-	// Publish order created event to message queue
-	if oc.Queue != nil {
-		orderMsg, _ := json.Marshal(order)
-		if err := oc.Queue.PublishMessage(queue.Config{
-			QueueName:    "orders",
-			RoutingKey:   "order.created",
-			ExchangeName: "orders",
-		}, orderMsg); err != nil {
-			log.Printf("Warning: Failed to publish order created event: %v\n", err)
-		}
-	}
-
-	// Send notification using circuit breaker
-	go func() {
-		if err := oc.NotificationService.SendOrderNotification(order.ID); err != nil {
-			log.Printf("Failed to send notification: %v\n", err)
-		}
-	}()
+	// The order.created event was written to the outbox with the order and
+	// is published by the outbox relay; notification-service confirms the
+	// order to the customer from it
 
 	c.JSON(http.StatusCreated, order)
 }
@@ -242,76 +714,36 @@ func (oc *OrderController) CreateOrderWithPayment(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
-	if orderWithPayment.CustomerID != 0 {
-		if strconv.Itoa(orderWithPayment.CustomerID) != uid {
-			c.JSON(http.StatusForbidden, gin.H{"error": "cannot create order for another user"})
-			return
-		}
-	} else {
-		cid, _ := strconv.Atoi(uid)
-		orderWithPayment.CustomerID = cid
-	}
-
-	// Check inventory availability using circuit breaker
-	available, err := oc.InventoryService.CheckAvailability(orderWithPayment.ProductID, orderWithPayment.Quantity)
-	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check inventory: " + err.Error()})
-		return
-	}
-	if !available {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product not available in requested quantity"})
+	if err := assignCustomer(&orderWithPayment.Order, uid); err != nil {
+		writeOrderError(c, err)
 		return
 	}
 
-	// Insert order into database
-	if oc.OrderRepo != nil {
-		err = oc.OrderRepo.InsertOrder(&orderWithPayment.Order)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
-			return
-		}
-	} else {
-		// For testing purposes, set a mock ID
-		orderWithPayment.Order.ID = 1
-		orderWithPayment.Order.Status = "pending"
-		orderWithPayment.Order.CreatedAt = time.Now()
-	}
-
-	// Create payment intent
-	paymentResp, err := oc.PaymentService.CreatePayment(
-		orderWithPayment.ID,
-		orderWithPayment.CustomerID,
-		orderWithPayment.TotalPrice,
-		orderWithPayment.Currency,
-	)
-	if err != nil {
-		log.Printf("Warning: Failed to create payment intent: %v\n", err)
-		// Still return the order but indicate payment failed
-		c.JSON(http.StatusCreated, gin.H{
-			"order": orderWithPayment.Order,
-			"payment_error": "Failed to create payment intent: " + err.Error(),
-		})
+	// Price the order server-side; the payment amount is never taken from the client
+	if !oc.priceOrder(c, &orderWithPayment.Order) {
 		return
 	}
 
-	// Publish order created event to message queue
-	if oc.Queue != nil {
-		orderMsg, _ := json.Marshal(orderWithPayment.Order)
-		if err := oc.Queue.PublishMessage(queue.Config{
-			QueueName:    "orders",
-			RoutingKey:   "order.created",
-			ExchangeName: "orders",
-		}, orderMsg); err != nil {
-			log.Printf("Warning: Failed to publish order created event: %v\n", err)
+	// Reserve stock, create the order and the payment intent as one saga;
+	// any failure releases the reservation and cancels the order
+	checkout, err := oc.Checkout.Execute(&orderWithPayment.Order, orderWithPayment.Currency)
+	if err != nil {
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, service.ErrInsufficientStock) {
+			status = http.StatusBadRequest
 		}
+		response := gin.H{"error": "Checkout failed: " + err.Error()}
+		if checkout != nil {
+			response["checkout_id"] = checkout.ID
+			response["checkout_status"] = checkout.Status
+		}
+		c.JSON(status, response)
+		return
 	}
+	paymentResp := checkout.Payment
 
-	// Send notification using circuit breaker
-	go func() {
-		if err := oc.NotificationService.SendOrderNotification(orderWithPayment.ID); err != nil {
-			log.Printf("Failed to send notification: %v\n", err)
-		}
-	}()
+	// The saga inserted the order through the repository, so order.created
+	// is already in the outbox
 
 	c.JSON(http.StatusCreated, gin.H{
 		"order": orderWithPayment.Order,
@@ -319,83 +751,81 @@ func (oc *OrderController) CreateOrderWithPayment(c *gin.Context) {
 	})
 }
 
-// GetOrders returns all orders
+// GetOrders returns a page of orders. Admins see every order and may filter
+// by customer; everyone else only sees their own orders.
 func (oc *OrderController) GetOrders(c *gin.Context) {
-	rows, err := oc.DB.Query("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders")
+	params, err := parseOrderListParams(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	var orders []model.Order
-	for rows.Next() {
-		var o model.Order
-		if err := rows.Scan(&o.ID, &o.CustomerID, &o.ProductID, &o.Quantity, &o.TotalPrice, &o.Status); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	uid := c.GetHeader("X-User-Id")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if !strings.Contains(c.GetHeader("X-User-Roles"), "admin") {
+		if params.CustomerID != 0 && strconv.Itoa(params.CustomerID) != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot list orders of another user"})
+			return
+		}
+		cid, err := strconv.Atoi(uid)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		params.CustomerID = cid
+	}
+
+	page, err := oc.OrderRepo.ListOrders(params)
+	if err != nil {
+		if errors.Is(err, errInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		orders = append(orders, o)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, orders)
+	c.JSON(http.StatusOK, page)
 }
 
-// GetOrder returns a specific order by ID
+// GetOrder returns a specific order by ID. Orders are read through the
+// cache; the ownership check applies whether or not the order was cached.
 func (oc *OrderController) GetOrder(c *gin.Context) {
-	orderID := c.Param("id")
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
 
-	// Try to get order from cache first
-	var order model.Order
-	cacheKey := "order:" + orderID
-	
-	// If cache is not available, get directly from database
-	if oc.Cache == nil {
-		if oc.OrderRepo != nil {
-			order, err := oc.OrderRepo.GetOrderFromDB(orderID)
-			if err != nil {
-				if err == sql.ErrNoRows {
-					c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order: " + err.Error()})
-				return
-			}
-			// Enforce ownership: allow owner or admin
-			uid := c.GetHeader("X-User-Id")
-			roles := c.GetHeader("X-User-Roles")
-			if uid == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
-				return
-			}
-			if strconv.Itoa(order.CustomerID) != uid && !strings.Contains(roles, "admin") {
-				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-				return
-			}
-			c.JSON(http.StatusOK, order)
-			return
-		}
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	uid := c.GetHeader("X-User-Id")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
-	
-	err := oc.Cache.GetOrSet(cacheKey, &order, 30*time.Minute, func() (interface{}, error) {
-		// If not in cache, get from database
-		if oc.OrderRepo != nil {
-			return oc.OrderRepo.GetOrderFromDB(orderID)
-		}
-		return nil, sql.ErrNoRows
-	})
 
+	order, err := oc.loadOrder(c.Request.Context(), id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Timed out getting order"})
+		return
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-			return
-		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order: " + err.Error()})
 		return
 	}
 
+	// Enforce ownership: allow owner or admin
+	if strconv.Itoa(order.CustomerID) != uid && !strings.Contains(c.GetHeader("X-User-Roles"), "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
 	c.JSON(http.StatusOK, order)
 }
 
@@ -407,11 +837,7 @@ func (oc *OrderController) UpdateOrder(c *gin.Context) {
 		return
 	}
 
-	// Get existing order to compare status change
-	var existingOrder model.Order
-	err = oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&existingOrder.ID, &existingOrder.CustomerID, &existingOrder.ProductID, &existingOrder.Quantity, &existingOrder.TotalPrice, &existingOrder.Status)
-
+	existingOrder, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
@@ -439,87 +865,74 @@ func (oc *OrderController) UpdateOrder(c *gin.Context) {
 		return
 	}
 
-	result, err := oc.DB.Exec(
-		"UPDATE orders SET customer_id = $1, product_id = $2, quantity = $3, total_price = $4, status = $5 WHERE id = $6",
-		updatedOrder.CustomerID, updatedOrder.ProductID, updatedOrder.Quantity, updatedOrder.TotalPrice, updatedOrder.Status, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Only items and promo code can change; the owner and status are kept,
+	// and status moves through PATCH /orders/:id/status
+	if existingOrder.Status != lifecycle.StatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": ErrOrderNotEditable.Error(), "status": existingOrder.Status})
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	// A checkout's reservation and payment intent cover the current lines
+	// and total, so editing it would leave both stale
+	if existingOrder.ReservationID != 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": ErrOrderCheckedOut.Error()})
 		return
 	}
-
-	// If status changed, send notification
-	if existingOrder.Status != updatedOrder.Status {
-		err = oc.NotificationService.SendOrderStatusUpdate(id, updatedOrder.CustomerID, updatedOrder.Status)
-		if err != nil {
-			// Log the error but continue (non-blocking)
-			fmt.Printf("Failed to send status update notification: %v\n", err)
-		}
-	}
-
 	updatedOrder.ID = id
-	c.JSON(http.StatusOK, updatedOrder)
-}
-
-// DeleteOrder deletes an order
-func (oc *OrderController) DeleteOrder(c *gin.Context) {
-	id := c.Param("id")
-
-	// Get the order first
-	var order model.Order
-	err := oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&order.ID, &order.CustomerID, &order.ProductID, &order.Quantity, &order.TotalPrice, &order.Status)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	updatedOrder.CustomerID = existingOrder.CustomerID
+	updatedOrder.Status = existingOrder.Status
+	updatedOrder.CreatedAt = existingOrder.CreatedAt
+	if !oc.priceOrder(c, &updatedOrder) {
 		return
 	}
-	if err != nil {
+	if err := oc.OrderRepo.UpdateOrder(&updatedOrder); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if errors.Is(err, ErrOrderNotEditable) || errors.Is(err, ErrOrderCheckedOut) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// The update has committed, so invalidate even if the client has gone
+	oc.InvalidateOrder(context.WithoutCancel(c.Request.Context()), id)
 
-	// Enforce ownership: only owner or admin can delete
-	uid := c.GetHeader("X-User-Id")
-	roles := c.GetHeader("X-User-Roles")
-	if uid == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
-		return
-	}
-	if strconv.Itoa(order.CustomerID) != uid && !strings.Contains(roles, "admin") {
-		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	c.JSON(http.StatusOK, updatedOrder)
+}
+
+// DeleteOrder purges an order and its history. It is reserved for admins;
+// customers cancel orders with CancelOrder, which keeps the audit trail.
+func (oc *OrderController) DeleteOrder(c *gin.Context) {
+	if !strings.Contains(c.GetHeader("X-User-Roles"), "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin role required"})
 		return
 	}
 
-	// Delete the order
-	result, err := oc.DB.Exec("DELETE FROM orders WHERE id = $1", id)
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	err = oc.OrderRepo.DeleteOrder(id)
+	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
-
-	// Send notification that order was deleted/cancelled
-	err = oc.NotificationService.SendOrderStatusUpdate(order.ID, order.CustomerID, "cancelled")
 	if err != nil {
-		// Log the error but continue (non-blocking)
-		fmt.Printf("Failed to send cancellation notification: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	oc.InvalidateOrder(context.WithoutCancel(c.Request.Context()), id)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Order purged successfully"})
 }
 
-// UpdateOrderStatus updates only the status of an order
+// UpdateOrderStatus moves an order along its lifecycle. Customers may only
+// cancel their own orders before they ship; every other transition needs the
+// admin or system role.
 func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -528,18 +941,16 @@ func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 	}
 
 	var statusUpdate struct {
-		Status string `json:"status"`
+		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
 	}
-	if err := c.BindJSON(&statusUpdate); err != nil {
+	if err := c.ShouldBindJSON(&statusUpdate); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Get existing order to get customer ID
-	var order model.Order
-	err = oc.DB.QueryRow("SELECT id, customer_id, product_id, quantity, total_price, status FROM orders WHERE id = $1", id).
-		Scan(&order.ID, &order.CustomerID, &order.ProductID, &order.Quantity, &order.TotalPrice, &order.Status)
-
+	order, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
@@ -549,92 +960,201 @@ func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	// Enforce ownership: only owner or admin can update status
+	// Enforce ownership: only owner, admin or system can update status
 	uid := c.GetHeader("X-User-Id")
-	roles := c.GetHeader("X-User-Roles")
 	if uid == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
-	if strconv.Itoa(order.CustomerID) != uid && !strings.Contains(roles, "admin") {
+	actor := lifecycle.ActorFromHeaders(uid, c.GetHeader("X-User-Roles"))
+	if actor.Role == lifecycle.RoleCustomer && strconv.Itoa(order.CustomerID) != uid {
 		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 		return
 	}
 
-	// Update order status
-	result, err := oc.DB.Exec("UPDATE orders SET status = $1 WHERE id = $2", statusUpdate.Status, id)
-	if err != nil {
+	// Transition the order; metrics and notifications follow from the
+	// repository's OnTransition hook
+	_, err = oc.OrderRepo.TransitionOrderStatus(id, statusUpdate.Status, actor, statusUpdate.Reason)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	case errors.Is(err, lifecycle.ErrUnknownStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, lifecycle.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": order.Status})
+		return
+	case errors.Is(err, lifecycle.ErrForbiddenTransition):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Order status updated successfully",
+		"order_id": id,
+		"status":   statusUpdate.Status,
+	})
+}
+
+// GetOrderHistory returns the status changes of an order, oldest first
+func (oc *OrderController) GetOrderHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	metrics.OrderStatusUpdated.WithLabelValues(statusUpdate.Status).Inc()
+	order, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Update active orders metric based on status
-	if statusUpdate.Status == "completed" || statusUpdate.Status == "cancelled" {
-		metrics.ActiveOrders.Dec()
+	// Enforce ownership: allow owner or admin
+	uid := c.GetHeader("X-User-Id")
+	roles := c.GetHeader("X-User-Roles")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if strconv.Itoa(order.CustomerID) != uid && !strings.Contains(roles, "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
 	}
 
-	// Send notification about status change
-	err = oc.NotificationService.SendOrderStatusUpdate(id, order.CustomerID, statusUpdate.Status)
+	history, err := oc.OrderRepo.GetOrderHistory(id)
 	if err != nil {
-		// Log the error but continue (non-blocking)
-		fmt.Printf("Failed to send status update notification: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Order status updated successfully",
 		"order_id": id,
-		"status":   statusUpdate.Status,
+		"status":   order.Status,
+		"history":  history,
 	})
 }
 
-// CreateBatchOrders handles creation of multiple orders in parallel
-func (oc *OrderController) CreateBatchOrders(c *gin.Context) {
-	var orders []model.Order
-	if err := c.ShouldBindJSON(&orders); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// orderError is a create-order failure together with the response it maps to
+type orderError struct {
+	status int
+	body   gin.H
+}
+
+func (e *orderError) Error() string {
+	msg, _ := e.body["error"].(string)
+	return msg
+}
+
+// writeOrderError writes err as the response, using the status of an orderError
+func writeOrderError(c *gin.Context, err error) {
+	var oe *orderError
+	if errors.As(err, &oe) {
+		c.JSON(oe.status, oe.body)
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
 
-	// Configure batch processing
-	numWorkers := 10            // Số lượng worker xử lý song song
-	timeout := 30 * time.Second // Thời gian timeout cho toàn bộ batch
-
-	// Process orders in parallel using worker pool
-	results := worker.ProcessBatch(orders, numWorkers, timeout)
+// assignCustomer makes uid the customer of the order, rejecting orders placed
+// on behalf of another user
+func assignCustomer(order *model.Order, uid string) error {
+	if order.CustomerID != 0 {
+		if strconv.Itoa(order.CustomerID) != uid {
+			return &orderError{http.StatusForbidden, gin.H{"error": "cannot create order for another user"}}
+		}
+		return nil
+	}
+	cid, _ := strconv.Atoi(uid)
+	order.CustomerID = cid
+	return nil
+}
 
-	// Count successes and failures
-	successful := 0
-	failed := 0
-	failedOrders := make([]map[string]interface{}, 0)
+// checkItemsAvailability checks inventory for every order line and writes an
+// error response when any line cannot be fulfilled
+func (oc *OrderController) checkItemsAvailability(c *gin.Context, items []model.OrderItem) bool {
+	if err := oc.verifyAvailability(items); err != nil {
+		writeOrderError(c, err)
+		return false
+	}
+	return true
+}
 
-	for _, result := range results {
-		if result.Error != nil {
-			failed++
-			failedOrders = append(failedOrders, map[string]interface{}{
-				"order_id": result.OrderID,
-				"error":    result.Error.Error(),
-			})
-		} else {
-			successful++
+// verifyAvailability checks inventory for every order line
+func (oc *OrderController) verifyAvailability(items []model.OrderItem) error {
+	for _, item := range items {
+		available, err := oc.InventoryService.CheckAvailability(item.ProductID, item.Quantity)
+		if err != nil {
+			return &orderError{http.StatusServiceUnavailable, gin.H{"error": "Failed to check inventory: " + err.Error()}}
+		}
+		if !available {
+			return &orderError{http.StatusBadRequest, gin.H{
+				"error":      "Product not available in requested quantity",
+				"product_id": item.ProductID,
+			}}
 		}
 	}
+	return nil
+}
 
-	// Return summary
-	c.JSON(http.StatusOK, gin.H{
-		"total_orders":    len(orders),
-		"successful":      successful,
-		"failed":          failed,
-		"failed_orders":   failedOrders,
-		"processing_time": timeout,
-	})
+// priceOrder prices the order (see applyPricing). It writes an error
+// response and returns false when the order cannot be priced.
+func (oc *OrderController) priceOrder(c *gin.Context, order *model.Order) bool {
+	if err := oc.applyPricing(order); err != nil {
+		writeOrderError(c, err)
+		return false
+	}
+	return true
 }
 
+// applyPricing looks up current catalogue prices and any promotion, then
+// computes line totals, discount, tax and total on the order
+func (oc *OrderController) applyPricing(order *model.Order) error {
+	products := make(map[int]*model.Product, len(order.Items))
+	for _, item := range order.Items {
+		if _, ok := products[item.ProductID]; ok {
+			continue
+		}
+		product, err := oc.ProductService.GetProduct(item.ProductID)
+		if err != nil {
+			if errors.Is(err, service.ErrProductNotFound) {
+				return &orderError{http.StatusBadRequest, gin.H{"error": "Product not found", "product_id": item.ProductID}}
+			}
+			return &orderError{http.StatusServiceUnavailable, gin.H{"error": "Failed to get product price: " + err.Error()}}
+		}
+		products[item.ProductID] = product
+	}
+
+	var promotion *model.Promotion
+	if order.PromoCode != "" {
+		var err error
+		promotion, err = oc.PromotionService.GetPromotion(order.PromoCode)
+		if err != nil {
+			if errors.Is(err, service.ErrPromotionNotFound) {
+				return &orderError{http.StatusBadRequest, gin.H{"error": "Invalid promo code"}}
+			}
+			return &orderError{http.StatusServiceUnavailable, gin.H{"error": "Failed to get promotion: " + err.Error()}}
+		}
+	}
+
+	calculator := oc.Pricing
+	if calculator == nil {
+		calculator = &pricing.Calculator{}
+	}
+	if err := calculator.Apply(order, products, promotion); err != nil {
+		if errors.Is(err, pricing.ErrPriceMismatch) {
+			return &orderError{http.StatusConflict, gin.H{"error": err.Error()}}
+		}
+		return &orderError{http.StatusBadRequest, gin.H{"error": err.Error()}}
+	}
 
+	return nil
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-microservices/order-service/lifecycle"
+	"go-microservices/order-service/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultOrderPageSize = 20
+	maxOrderPageSize     = 100
+	defaultOrderSort     = "-created_at"
+)
+
+// orderSortColumns maps the sortable fields of GET /orders to their columns
+var orderSortColumns = map[string]string{
+	"created_at":  "created_at",
+	"total_price": "total_price",
+	"id":          "id",
+}
+
+// errInvalidCursor is returned for a cursor that is malformed or was issued
+// for a different sort order
+var errInvalidCursor = errors.New("invalid cursor")
+
+// parseOrderListParams reads the filters, sort and page of GET /orders from
+// the query string
+func parseOrderListParams(c *gin.Context) (model.OrderListParams, error) {
+	params := model.OrderListParams{
+		Sort:  c.DefaultQuery("sort", defaultOrderSort),
+		Limit: defaultOrderPageSize,
+	}
+
+	if _, ok := orderSortColumns[strings.TrimPrefix(params.Sort, "-")]; !ok {
+		return params, fmt.Errorf("invalid sort %q", params.Sort)
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxOrderPageSize {
+			return params, fmt.Errorf("limit must be between 1 and %d", maxOrderPageSize)
+		}
+		params.Limit = limit
+	}
+
+	if v := c.Query("status"); v != "" {
+		for _, status := range strings.Split(v, ",") {
+			status = strings.TrimSpace(status)
+			if !lifecycle.IsKnown(status) {
+				return params, fmt.Errorf("invalid status %q", status)
+			}
+			params.Statuses = append(params.Statuses, status)
+		}
+	}
+
+	if v := c.Query("customer_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return params, errors.New("invalid customer_id")
+		}
+		params.CustomerID = id
+	}
+
+	if v := c.Query("product_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return params, errors.New("invalid product_id")
+		}
+		params.ProductID = id
+	}
+
+	var err error
+	if params.CreatedFrom, err = parseTimeQuery(c, "created_from", false); err != nil {
+		return params, err
+	}
+	if params.CreatedTo, err = parseTimeQuery(c, "created_to", true); err != nil {
+		return params, err
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := decodeOrderCursor(v)
+		if err != nil || cursor.Sort != params.Sort {
+			return params, errInvalidCursor
+		}
+		params.After = cursor
+	}
+
+	return params, nil
+}
+
+// parseTimeQuery parses an RFC 3339 timestamp or a YYYY-MM-DD date. A date
+// used as an upper bound covers the whole day.
+func parseTimeQuery(c *gin.Context, name string, endOfDay bool) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: use RFC 3339 or YYYY-MM-DD", name)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+// encodeOrderCursor builds the opaque cursor pointing after order for sort
+func encodeOrderCursor(sort string, order model.Order) string {
+	cursor := model.OrderCursor{Sort: sort, ID: order.ID}
+	switch strings.TrimPrefix(sort, "-") {
+	case "created_at":
+		cursor.Value = order.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "total_price":
+		cursor.Value = strconv.FormatFloat(order.TotalPrice, 'f', -1, 64)
+	default:
+		cursor.Value = strconv.Itoa(order.ID)
+	}
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeOrderCursor reverses encodeOrderCursor
+func decodeOrderCursor(s string) (*model.OrderCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cursor model.OrderCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, errInvalidCursor
+	}
+	if _, err := orderCursorValue(cursor); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// orderCursorValue converts the cursor's sort value to the column's type
+func orderCursorValue(cursor model.OrderCursor) (interface{}, error) {
+	switch strings.TrimPrefix(cursor.Sort, "-") {
+	case "created_at":
+		return time.Parse(time.RFC3339Nano, cursor.Value)
+	case "total_price":
+		return strconv.ParseFloat(cursor.Value, 64)
+	case "id":
+		return strconv.Atoi(cursor.Value)
+	}
+	return nil, errInvalidCursor
+}
+
+// orderListFilters builds the WHERE conditions and arguments for the filters
+// of params; the cursor is not included
+func orderListFilters(params model.OrderListParams) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+
+	if params.CustomerID != 0 {
+		add("customer_id = $%d", params.CustomerID)
+	}
+	if len(params.Statuses) > 0 {
+		add("status = ANY($%d)", pq.Array(params.Statuses))
+	}
+	if params.ProductID != 0 {
+		add("EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND oi.product_id = $%d)", params.ProductID)
+	}
+	if params.CreatedFrom != nil {
+		add("created_at >= $%d", *params.CreatedFrom)
+	}
+	if params.CreatedTo != nil {
+		add("created_at <= $%d", *params.CreatedTo)
+	}
+
+	return where, args
+}
+
+// whereClause joins conditions into a WHERE clause
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
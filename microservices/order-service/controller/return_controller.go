@@ -0,0 +1,739 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-microservices/order-service/lifecycle"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/outbox"
+	"go-microservices/order-service/returns"
+	"go-microservices/pkg/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrOrderNotReturnable is returned when a return is requested for an
+	// order that hasn't been delivered
+	ErrOrderNotReturnable = errors.New("only delivered orders can be returned")
+	// ErrReturnExceedsOrder is returned when a return asks for more units
+	// than the order has left to return
+	ErrReturnExceedsOrder = errors.New("return exceeds the returnable quantity")
+)
+
+// returnRefundReason is the payment-service refund reason for returns
+const returnRefundReason = "requested_by_customer"
+
+// ReturnRepository defines the interface for return database operations
+type ReturnRepository interface {
+	CreateReturn(ret *model.Return, actor lifecycle.Actor) error
+	GetReturn(returnID int) (*model.Return, error)
+	ListOrderReturns(orderID int) ([]model.Return, error)
+	GetReturnHistory(returnID int) ([]model.ReturnStatusHistory, error)
+	TransitionReturn(returnID int, status string, actor lifecycle.Actor, note string) (*model.ReturnStatusHistory, error)
+	ClaimReturnRefund(returnID int) (float64, bool, error)
+	RecordReturnRefund(returnID int, status string, refunded float64) error
+	MarkReturnRestocked(returnID int) error
+	IsOrderFullyReturned(orderID int) (bool, error)
+}
+
+// DBReturnRepository implements ReturnRepository using SQL database
+type DBReturnRepository struct {
+	DB *sql.DB
+	// OnTransition, if set, is called after each committed status change,
+	// including the initial request
+	OnTransition func(model.ReturnStatusHistory)
+}
+
+// CreateReturn records a return request for a delivered order. The order row
+// is locked so concurrent requests can't return the same units twice. Each
+// item is priced at what the customer paid for it, and the refund is the
+// items' share of the order total, discount and tax included.
+func (r *DBReturnRepository) CreateReturn(ret *model.Return, actor lifecycle.Actor) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var subtotal, total float64
+	err = tx.QueryRow("SELECT customer_id, status, subtotal, total_price FROM orders WHERE id = $1 FOR UPDATE", ret.OrderID).
+		Scan(&ret.CustomerID, &status, &subtotal, &total)
+	if err != nil {
+		return err
+	}
+	if status != lifecycle.StatusDelivered {
+		return ErrOrderNotReturnable
+	}
+
+	returnable, err := returnableItems(tx, ret.OrderID)
+	if err != nil {
+		return err
+	}
+
+	// Merge duplicate lines so each product is checked against its full quantity
+	requested := make(map[int]int)
+	for _, item := range ret.Items {
+		requested[item.ProductID] += item.Quantity
+	}
+	productIDs := make([]int, 0, len(requested))
+	for productID := range requested {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Ints(productIDs)
+
+	ret.Items = make([]model.ReturnItem, 0, len(productIDs))
+	value := 0.0
+	for _, productID := range productIDs {
+		available := returnable[productID]
+		if requested[productID] > available.Quantity {
+			return fmt.Errorf("%w: %d of product %d can be returned", ErrReturnExceedsOrder, available.Quantity, productID)
+		}
+		item := available
+		item.Quantity = requested[productID]
+		ret.Items = append(ret.Items, item)
+		value += float64(item.Quantity) * item.UnitPrice
+	}
+	if subtotal > 0 {
+		value = value * total / subtotal
+	}
+
+	now := time.Now()
+	ret.Status = returns.StatusRequested
+	ret.RefundAmount = model.RoundPrice(value)
+	ret.CreatedAt = now
+	ret.UpdatedAt = now
+	err = tx.QueryRow(`
+		INSERT INTO order_returns (order_id, customer_id, status, reason, notes, refund_amount, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		ret.OrderID, ret.CustomerID, ret.Status, ret.Reason, ret.Notes, ret.RefundAmount, ret.CreatedAt, ret.UpdatedAt,
+	).Scan(&ret.ID)
+	if err != nil {
+		return err
+	}
+	for _, item := range ret.Items {
+		_, err := tx.Exec(`
+			INSERT INTO order_return_items (return_id, product_id, product_name, quantity, unit_price)
+			VALUES ($1, $2, $3, $4, $5)`,
+			ret.ID, item.ProductID, item.ProductName, item.Quantity, item.UnitPrice)
+		if err != nil {
+			return err
+		}
+	}
+
+	entry := model.ReturnStatusHistory{
+		ReturnID:   ret.ID,
+		OrderID:    ret.OrderID,
+		CustomerID: ret.CustomerID,
+		ToStatus:   ret.Status,
+		ActorRole:  actor.Role,
+		ActorID:    actor.UserID,
+		Note:       ret.Reason,
+		CreatedAt:  now,
+	}
+	if err := recordReturnTransition(tx, &entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.notifyTransition(entry)
+	return nil
+}
+
+// returnableItems returns, per product of an order, the units not already
+// claimed by a pending or accepted return, priced at what was paid per unit
+func returnableItems(tx *sql.Tx, orderID int) (map[int]model.ReturnItem, error) {
+	rows, err := tx.Query(`
+		SELECT product_id, MAX(product_name), SUM(quantity), SUM(line_total)
+		FROM order_items WHERE order_id = $1
+		GROUP BY product_id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[int]model.ReturnItem)
+	for rows.Next() {
+		var item model.ReturnItem
+		var lineTotal float64
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Quantity, &lineTotal); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		item.UnitPrice = model.RoundPrice(lineTotal / float64(item.Quantity))
+		items[item.ProductID] = item
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(`
+		SELECT ri.product_id, SUM(ri.quantity)
+		FROM order_return_items ri
+		JOIN order_returns r ON r.id = ri.return_id
+		WHERE r.order_id = $1 AND r.status <> $2
+		GROUP BY ri.product_id`, orderID, returns.StatusRejected)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var productID, quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		item := items[productID]
+		item.Quantity -= quantity
+		items[productID] = item
+	}
+
+	return items, rows.Err()
+}
+
+// GetReturn retrieves a return and its items
+func (r *DBReturnRepository) GetReturn(returnID int) (*model.Return, error) {
+	var ret model.Return
+	err := r.DB.QueryRow(`
+		SELECT id, order_id, customer_id, status, reason, notes, refund_amount, refunded_amount, refund_status, restocked, created_at, updated_at
+		FROM order_returns WHERE id = $1`, returnID).Scan(
+		&ret.ID, &ret.OrderID, &ret.CustomerID, &ret.Status, &ret.Reason, &ret.Notes,
+		&ret.RefundAmount, &ret.RefundedAmount, &ret.RefundStatus, &ret.Restocked, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.Items, err = r.getReturnItems(ret.ID); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// ListOrderReturns returns the returns of an order, oldest first
+func (r *DBReturnRepository) ListOrderReturns(orderID int) ([]model.Return, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, order_id, customer_id, status, reason, notes, refund_amount, refunded_amount, refund_status, restocked, created_at, updated_at
+		FROM order_returns WHERE order_id = $1
+		ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.Return, 0)
+	for rows.Next() {
+		var ret model.Return
+		if err := rows.Scan(&ret.ID, &ret.OrderID, &ret.CustomerID, &ret.Status, &ret.Reason, &ret.Notes,
+			&ret.RefundAmount, &ret.RefundedAmount, &ret.RefundStatus, &ret.Restocked, &ret.CreatedAt, &ret.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, ret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		if list[i].Items, err = r.getReturnItems(list[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// getReturnItems loads the items of a return
+func (r *DBReturnRepository) getReturnItems(returnID int) ([]model.ReturnItem, error) {
+	rows, err := r.DB.Query(`
+		SELECT product_id, product_name, quantity, unit_price
+		FROM order_return_items WHERE return_id = $1
+		ORDER BY id`, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]model.ReturnItem, 0)
+	for rows.Next() {
+		var item model.ReturnItem
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Quantity, &item.UnitPrice); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetReturnHistory returns the status changes of a return, oldest first
+func (r *DBReturnRepository) GetReturnHistory(returnID int) ([]model.ReturnStatusHistory, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, return_id, from_status, to_status, actor_role, actor_id, note, created_at
+		FROM order_return_status_history
+		WHERE return_id = $1
+		ORDER BY created_at, id`, returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]model.ReturnStatusHistory, 0)
+	for rows.Next() {
+		var entry model.ReturnStatusHistory
+		if err := rows.Scan(&entry.ID, &entry.ReturnID, &entry.FromStatus, &entry.ToStatus,
+			&entry.ActorRole, &entry.ActorID, &entry.Note, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
+
+// TransitionReturn moves a return to status on behalf of actor, recording the
+// change in its history and the outbox. Approving marks the refund pending.
+// It returns nil when the return already has status.
+func (r *DBReturnRepository) TransitionReturn(returnID int, status string, actor lifecycle.Actor, note string) (*model.ReturnStatusHistory, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry := model.ReturnStatusHistory{
+		ReturnID:  returnID,
+		ToStatus:  status,
+		ActorRole: actor.Role,
+		ActorID:   actor.UserID,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+	err = tx.QueryRow("SELECT order_id, customer_id, status FROM order_returns WHERE id = $1 FOR UPDATE", returnID).
+		Scan(&entry.OrderID, &entry.CustomerID, &entry.FromStatus)
+	if err != nil {
+		return nil, err
+	}
+	if entry.FromStatus == status {
+		return nil, nil
+	}
+	if err := returns.CanTransition(entry.FromStatus, status, actor); err != nil {
+		return nil, err
+	}
+
+	query := "UPDATE order_returns SET status = $1, updated_at = $2 WHERE id = $3"
+	if status == returns.StatusApproved {
+		query = "UPDATE order_returns SET status = $1, updated_at = $2, refund_status = '" + returns.RefundPending + "' WHERE id = $3"
+	}
+	if _, err := tx.Exec(query, status, entry.CreatedAt, returnID); err != nil {
+		return nil, err
+	}
+	if err := recordReturnTransition(tx, &entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	r.notifyTransition(entry)
+	return &entry, nil
+}
+
+// ClaimReturnRefund marks the pending or failed refund of a return as
+// processing and returns the amount still to refund. A refund left
+// processing for refundClaimTimeout, because the approval carrying it out
+// died, is taken over. It reports false when the refund is complete or
+// another approval is carrying it out, so concurrent approvals can't refund
+// the same return twice.
+func (r *DBReturnRepository) ClaimReturnRefund(returnID int) (float64, bool, error) {
+	var amount, refunded float64
+	now := time.Now()
+	err := r.DB.QueryRow(`
+		UPDATE order_returns SET refund_status = $1, refund_claimed_at = $2, updated_at = $2
+		WHERE id = $3 AND (refund_status IN ($4, $5) OR (refund_status = $1 AND refund_claimed_at < $6))
+		RETURNING refund_amount, refunded_amount`,
+		returns.RefundProcessing, now, returnID, returns.RefundPending, returns.RefundFailed, now.Add(-refundClaimTimeout)).Scan(&amount, &refunded)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return model.RoundPrice(amount - refunded), true, nil
+}
+
+// RecordReturnRefund stores the outcome of a claimed refund, adding what was
+// refunded to the return's running total so a retry only refunds the rest
+func (r *DBReturnRepository) RecordReturnRefund(returnID int, status string, refunded float64) error {
+	_, err := r.DB.Exec(`
+		UPDATE order_returns SET refund_status = $1, refunded_amount = refunded_amount + $2, updated_at = $3
+		WHERE id = $4`,
+		status, refunded, time.Now(), returnID)
+	return err
+}
+
+// MarkReturnRestocked records that the items of a received return are back in stock
+func (r *DBReturnRepository) MarkReturnRestocked(returnID int) error {
+	_, err := r.DB.Exec("UPDATE order_returns SET restocked = TRUE, updated_at = $1 WHERE id = $2", time.Now(), returnID)
+	return err
+}
+
+// IsOrderFullyReturned reports whether approved returns cover every unit of an order
+func (r *DBReturnRepository) IsOrderFullyReturned(orderID int) (bool, error) {
+	var outstanding int
+	err := r.DB.QueryRow(`
+		SELECT COUNT(*) FROM (
+			SELECT oi.product_id, SUM(oi.quantity) AS ordered
+			FROM order_items oi WHERE oi.order_id = $1
+			GROUP BY oi.product_id
+		) o
+		LEFT JOIN (
+			SELECT ri.product_id, SUM(ri.quantity) AS returned
+			FROM order_return_items ri
+			JOIN order_returns r ON r.id = ri.return_id
+			WHERE r.order_id = $1 AND r.status IN ($2, $3)
+			GROUP BY ri.product_id
+		) rt ON rt.product_id = o.product_id
+		WHERE COALESCE(rt.returned, 0) < o.ordered`,
+		orderID, returns.StatusApproved, returns.StatusReceived).Scan(&outstanding)
+	if err != nil {
+		return false, err
+	}
+	return outstanding == 0, nil
+}
+
+// recordReturnTransition writes a return's status change to its history and
+// the outbox within the given transaction
+func recordReturnTransition(tx *sql.Tx, entry *model.ReturnStatusHistory) error {
+	err := tx.QueryRow(`
+		INSERT INTO order_return_status_history (return_id, from_status, to_status, actor_role, actor_id, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		entry.ReturnID, entry.FromStatus, entry.ToStatus, entry.ActorRole, entry.ActorID, entry.Note, entry.CreatedAt,
+	).Scan(&entry.ID)
+	if err != nil {
+		return err
+	}
+
+	return outbox.Enqueue(tx, outbox.EventReturnStatusChanged, entry.OrderID, events.ReturnStatusChange{
+		ReturnID:       entry.ReturnID,
+		OrderID:        entry.OrderID,
+		CustomerID:     entry.CustomerID,
+		PreviousStatus: entry.FromStatus,
+		Status:         entry.ToStatus,
+	})
+}
+
+// notifyTransition passes a committed status change to OnTransition
+func (r *DBReturnRepository) notifyTransition(entry model.ReturnStatusHistory) {
+	if r.OnTransition != nil {
+		r.OnTransition(entry)
+	}
+}
+
+// CreateReturn lets a customer request a return of items of a delivered order
+func (oc *OrderController) CreateReturn(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req model.ReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !returns.IsReason(req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid reason %q", req.Reason)})
+		return
+	}
+
+	order, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Enforce ownership: only owner or admin can request a return
+	uid := c.GetHeader("X-User-Id")
+	roles := c.GetHeader("X-User-Roles")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if strconv.Itoa(order.CustomerID) != uid && !strings.Contains(roles, "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	ret := &model.Return{OrderID: id, Reason: req.Reason, Notes: req.Notes, Items: req.Items}
+	err = oc.Returns.CreateReturn(ret, lifecycle.ActorFromHeaders(uid, roles))
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	case errors.Is(err, ErrOrderNotReturnable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": order.Status})
+		return
+	case errors.Is(err, ErrReturnExceedsOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ret)
+}
+
+// GetOrderReturns lists the returns of an order
+func (oc *OrderController) GetOrderReturns(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	order, err := oc.OrderRepo.GetOrderFromDB(strconv.Itoa(id))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Enforce ownership: allow owner or admin
+	uid := c.GetHeader("X-User-Id")
+	roles := c.GetHeader("X-User-Roles")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if strconv.Itoa(order.CustomerID) != uid && !strings.Contains(roles, "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	list, err := oc.Returns.ListOrderReturns(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": id, "returns": list})
+}
+
+// GetReturn returns a return with its items and status history
+func (oc *OrderController) GetReturn(c *gin.Context) {
+	ret, ok := oc.loadReturn(c)
+	if !ok {
+		return
+	}
+
+	// Enforce ownership: allow owner or admin
+	uid := c.GetHeader("X-User-Id")
+	if strconv.Itoa(ret.CustomerID) != uid && !strings.Contains(c.GetHeader("X-User-Roles"), "admin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	history, err := oc.Returns.GetReturnHistory(ret.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ret.History = history
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// ApproveReturn accepts a return and refunds its amount through
+// payment-service. Once returns cover the whole order, the order moves to
+// refunded. Approving again retries a refund that failed, refunding only
+// what the failed attempt didn't; an approval racing another one leaves the
+// refund to whichever claimed it.
+func (oc *OrderController) ApproveReturn(c *gin.Context) {
+	ret, ok := oc.decideReturn(c, returns.StatusApproved)
+	if !ok {
+		return
+	}
+
+	remaining, claimed, err := oc.Returns.ClaimReturnRefund(ret.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	refund := gin.H{}
+	if claimed {
+		status := returns.RefundCompleted
+		refunded, err := oc.PaymentService.RefundAmount(ret.OrderID, ret.CustomerID, remaining, returnRefundReason, fmt.Sprintf("return-%d", ret.ID))
+		if err != nil {
+			log.Printf("Failed to refund return %d: %v\n", ret.ID, err)
+			status = returns.RefundFailed
+			refund["error"] = err.Error()
+		}
+		if err := oc.Returns.RecordReturnRefund(ret.ID, status, refunded); err != nil {
+			log.Printf("Failed to record refund of return %d: %v\n", ret.ID, err)
+		}
+		ret.RefundStatus = status
+		ret.RefundedAmount = model.RoundPrice(ret.RefundedAmount + refunded)
+	}
+	refund["status"] = ret.RefundStatus
+	refund["amount"] = ret.RefundedAmount
+
+	if ret.RefundStatus == returns.RefundCompleted {
+		oc.refundReturnedOrder(ret)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Return approved",
+		"return":  ret,
+		"refund":  refund,
+	})
+}
+
+// refundReturnedOrder moves an order to refunded once its returns cover all of it
+func (oc *OrderController) refundReturnedOrder(ret *model.Return) {
+	fully, err := oc.Returns.IsOrderFullyReturned(ret.OrderID)
+	if err != nil {
+		log.Printf("Failed to check returns of order %d: %v\n", ret.OrderID, err)
+		return
+	}
+	if !fully {
+		return
+	}
+	reason := fmt.Sprintf("return %d", ret.ID)
+	if _, err := oc.OrderRepo.TransitionOrderStatus(ret.OrderID, lifecycle.StatusRefunded, lifecycle.System, reason); err != nil {
+		log.Printf("Failed to mark order %d refunded: %v\n", ret.OrderID, err)
+	}
+}
+
+// RejectReturn declines a return request
+func (oc *OrderController) RejectReturn(c *gin.Context) {
+	ret, ok := oc.decideReturn(c, returns.StatusRejected)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Return rejected", "return": ret})
+}
+
+// ReceiveReturn confirms the returned goods arrived and puts them back in
+// stock. Receiving again retries a restock that failed.
+func (oc *OrderController) ReceiveReturn(c *gin.Context) {
+	ret, ok := oc.decideReturn(c, returns.StatusReceived)
+	if !ok {
+		return
+	}
+
+	response := gin.H{"message": "Return received", "return": ret}
+	if !ret.Restocked {
+		err := oc.InventoryService.Restock(fmt.Sprintf("return-%d", ret.ID), ret.Items)
+		if err == nil {
+			err = oc.Returns.MarkReturnRestocked(ret.ID)
+		}
+		if err != nil {
+			log.Printf("Failed to restock return %d: %v\n", ret.ID, err)
+			response["restock_error"] = err.Error()
+		} else {
+			ret.Restocked = true
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// decideReturn moves the return of the request to status on behalf of staff,
+// writing the error response and returning false when it can't
+func (oc *OrderController) decideReturn(c *gin.Context, status string) (*model.Return, bool) {
+	var req struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+
+	ret, ok := oc.loadReturn(c)
+	if !ok {
+		return nil, false
+	}
+
+	actor := lifecycle.ActorFromHeaders(c.GetHeader("X-User-Id"), c.GetHeader("X-User-Roles"))
+	if actor.Role == lifecycle.RoleCustomer {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		return nil, false
+	}
+
+	// A received return was approved first, so approving it again only
+	// retries its refund
+	if status == returns.StatusApproved && ret.Status == returns.StatusReceived {
+		return ret, true
+	}
+
+	_, err := oc.Returns.TransitionReturn(ret.ID, status, actor, req.Note)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return nil, false
+	case errors.Is(err, returns.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": ret.Status})
+		return nil, false
+	case errors.Is(err, returns.ErrForbiddenTransition):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	// Reload so the response reflects the new status and refund state
+	updated, err := oc.Returns.GetReturn(ret.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return updated, true
+}
+
+// loadReturn reads the return named by the request, writing the error
+// response and returning false when it can't
+func (oc *OrderController) loadReturn(c *gin.Context) (*model.Return, bool) {
+	id, err := strconv.Atoi(c.Param("returnId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return nil, false
+	}
+	if c.GetHeader("X-User-Id") == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return nil, false
+	}
+
+	ret, err := oc.Returns.GetReturn(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return ret, true
+}
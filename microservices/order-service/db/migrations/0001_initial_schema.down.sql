@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS batch_job_items;
+DROP TABLE IF EXISTS batch_jobs;
+DROP TABLE IF EXISTS order_return_status_history;
+DROP TABLE IF EXISTS order_return_items;
+DROP TABLE IF EXISTS order_returns;
+DROP TABLE IF EXISTS order_status_history;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS checkout_sagas;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,2 @@
+-- Backfilled order lines and renamed statuses are kept; the legacy columns
+-- stay nullable
@@ -0,0 +1,18 @@
+-- Payment confirmation used to mark orders 'completed'; that state is now 'paid'
+UPDATE orders SET status = 'paid' WHERE status = 'completed';
+
+-- Orders created before order lines existed carried a single product per row;
+-- move those into order_items and relax the legacy columns so new inserts succeed.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'orders' AND column_name = 'product_id') THEN
+        INSERT INTO order_items (order_id, product_id, quantity, unit_price, line_total)
+        SELECT o.id, o.product_id, o.quantity, o.total_price / o.quantity, o.total_price
+        FROM orders o
+        WHERE o.product_id IS NOT NULL AND o.quantity > 0
+            AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = o.id);
+        ALTER TABLE orders ALTER COLUMN product_id DROP NOT NULL;
+        ALTER TABLE orders ALTER COLUMN quantity DROP NOT NULL;
+    END IF;
+END $$;
@@ -0,0 +1 @@
+ALTER TABLE order_returns DROP COLUMN IF EXISTS refunded_amount;
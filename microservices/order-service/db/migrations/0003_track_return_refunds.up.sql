@@ -0,0 +1,4 @@
+-- Returns record how much of their refund payment-service has paid out, so a
+-- retried approval only refunds what is left.
+ALTER TABLE order_returns ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+UPDATE order_returns SET refunded_amount = refund_amount WHERE refund_status = 'refunded';
@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS refund_claimed_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS refund_status;
@@ -0,0 +1,5 @@
+-- Cancelled orders record the refund of their payments, so a refund that
+-- failed or was interrupted can be retried. refund_claimed_at is when the
+-- current attempt started; a stale one may be taken over.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refund_status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refund_claimed_at TIMESTAMP;
@@ -0,0 +1,5 @@
+-- Requests hold their idempotency key for a short lease while they run, so a
+-- key left behind by a crash can be taken over instead of blocking retries
+-- until it expires. Only completed responses are kept for the full TTL.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+UPDATE idempotency_keys SET locked_until = CURRENT_TIMESTAMP WHERE completed = FALSE;
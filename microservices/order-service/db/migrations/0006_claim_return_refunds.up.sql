@@ -0,0 +1,4 @@
+-- Returns record when their refund was claimed, so a refund left processing
+-- by an approval that died can be taken over once the claim is stale.
+ALTER TABLE order_returns ADD COLUMN IF NOT EXISTS refund_claimed_at TIMESTAMP;
+UPDATE order_returns SET refund_claimed_at = updated_at WHERE refund_status = 'processing';
@@ -36,33 +36,76 @@ package docs
 // @Router /orders [post]
 func CreateOrderDoc() {}
 
+// GetOrders godoc
+// @Summary List orders
+// @Description List orders one page at a time. Non-admins only see their own orders.
+// @Description Pass next_cursor from a response as cursor to get the following page.
+// @Tags orders
+// @Produce json
+// @Param status query string false "Comma-separated statuses"
+// @Param customer_id query int false "Customer ID (admin only)"
+// @Param product_id query int false "Only orders containing this product"
+// @Param created_from query string false "Created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_to query string false "Created at or before (RFC 3339 or YYYY-MM-DD)"
+// @Param sort query string false "created_at, total_price or id; prefix with - for descending" default(-created_at)
+// @Param limit query int false "Page size, at most 100" default(20)
+// @Param cursor query string false "Cursor from a previous page"
+// @Success 200 {object} model.OrderPage
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /orders [get]
+func GetOrdersDoc() {}
+
 // GetOrder godoc
 // @Summary Get order by ID
-// @Description Get order details by its ID
+// @Description Get order details by its ID. Only the owner or an admin may read an order, cached or not.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param id path int true "Order ID"
 // @Success 200 {object} model.Order
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /orders/{id} [get]
 func GetOrderDoc() {}
 
 // CreateBatchOrders godoc
 // @Summary Create multiple orders
-// @Description Create multiple orders in batch
+// @Description Create up to 500 orders in parallel. Each order is validated, checked against inventory and priced like a single order.
+// @Description In partial mode every valid order is created; in all_or_nothing mode the orders are created together only if all are valid.
+// @Description The response lists a result per order, in request order, and the actual processing time.
+// @Description With async=true up to 5000 orders are accepted as a background job; the 202 response carries the job ID and the URL to poll.
 // @Tags orders
 // @Accept json
 // @Produce json
+// @Param mode query string false "partial or all_or_nothing" default(partial)
+// @Param async query bool false "Process the batch in the background" default(false)
 // @Param orders body []model.Order true "Array of orders"
 // @Success 200 {object} map[string]interface{}
+// @Success 202 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
 // @Router /orders/batch [post]
 func CreateBatchOrdersDoc() {}
 
+// GetBatchJob godoc
+// @Summary Get an async batch job
+// @Description Get the status and counts of a batch created with async=true, and the outcome of each order processed so far
+// @Tags orders
+// @Produce json
+// @Param jobId path int true "Batch job ID"
+// @Success 200 {object} model.BatchJob
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /orders/batch/{jobId} [get]
+func GetBatchJobDoc() {}
+
 // UpdateOrder godoc
 // @Summary Update an order
-// @Description Update an existing order
+// @Description Update the items and promo code of a pending order
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -70,30 +113,216 @@ func CreateBatchOrdersDoc() {}
 // @Param order body model.Order true "Updated order object"
 // @Success 200 {object} model.Order
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /orders/{id} [put]
 func UpdateOrderDoc() {}
 
 // DeleteOrder godoc
-// @Summary Delete an order
-// @Description Delete an order by its ID
+// @Summary Purge an order
+// @Description Permanently delete an order and its status history. Admin only; customers cancel orders instead.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param id path int true "Order ID"
 // @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /orders/{id} [delete]
 func DeleteOrderDoc() {}
 
+// CancelOrder godoc
+// @Summary Cancel an order
+// @Description Cancel a pending, paid or processing order. The order and its history are kept, its inventory reservation is released,
+// @Description a paid order is refunded through payment-service and an order.cancelled event is emitted. Shipped orders can't be cancelled.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param cancellation body map[string]string false "Optional reason"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /orders/{id}/cancel [post]
+func CancelOrderDoc() {}
+
 // UpdateOrderStatus godoc
 // @Summary Update order status
-// @Description Update the status of an existing order
+// @Description Move an order along pending → paid → processing → shipped → delivered, or to cancelled/refunded.
+// @Description Customers may only cancel their own orders before shipping; other transitions need the admin or system role.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param id path int true "Order ID"
-// @Param status body map[string]string true "Status object"
+// @Param status body map[string]string true "Status object with status and optional reason"
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /orders/{id}/status [patch]
 func UpdateOrderStatusDoc() {}
+
+// GetOrderHistory godoc
+// @Summary Get order status history
+// @Description Get the status changes of an order, oldest first
+// @Tags orders
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /orders/{id}/history [get]
+func GetOrderHistoryDoc() {}
+
+// CreateReturn godoc
+// @Summary Request a return
+// @Description Request a return of items of a delivered order with a reason code
+// @Description (damaged, defective, wrong_item, not_as_described, no_longer_needed, other). The refund amount is the items' share of what was paid.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param return body model.ReturnRequest true "Return request"
+// @Success 201 {object} model.Return
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /orders/{id}/returns [post]
+func CreateReturnDoc() {}
+
+// GetOrderReturns godoc
+// @Summary List the returns of an order
+// @Description Get the returns requested for an order, oldest first
+// @Tags returns
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /orders/{id}/returns [get]
+func GetOrderReturnsDoc() {}
+
+// GetReturn godoc
+// @Summary Get a return
+// @Description Get a return with its items and status history
+// @Tags returns
+// @Produce json
+// @Param returnId path int true "Return ID"
+// @Success 200 {object} model.Return
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /returns/{returnId} [get]
+func GetReturnDoc() {}
+
+// ApproveReturn godoc
+// @Summary Approve a return
+// @Description Approve a requested return and refund it through payment-service. Admin only.
+// @Description Once returns cover the whole order it moves to refunded. Approving again retries a failed refund.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Param returnId path int true "Return ID"
+// @Param decision body map[string]string false "Optional note"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /returns/{returnId}/approve [post]
+func ApproveReturnDoc() {}
+
+// RejectReturn godoc
+// @Summary Reject a return
+// @Description Reject a requested return. Admin only.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Param returnId path int true "Return ID"
+// @Param decision body map[string]string false "Optional note"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /returns/{returnId}/reject [post]
+func RejectReturnDoc() {}
+
+// ReceiveReturn godoc
+// @Summary Confirm receipt of a return
+// @Description Confirm the goods of an approved return arrived and restock them in inventory-service. Admin or system only.
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Param returnId path int true "Return ID"
+// @Param receipt body map[string]string false "Optional note"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /returns/{returnId}/receive [post]
+func ReceiveReturnDoc() {}
+
+// ListDeadLetterQueues godoc
+// @Summary List dead-letter queues
+// @Description List the dead-letter queues of this service's consumers with their message counts. Admin only.
+// @Tags dead-letters
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /admin/dead-letters [get]
+func ListDeadLetterQueuesDoc() {}
+
+// ListDeadLetters godoc
+// @Summary List dead-lettered messages
+// @Description List messages that exhausted their delivery attempts, oldest first, without removing them. Admin only.
+// @Tags dead-letters
+// @Produce json
+// @Param queue path string true "Dead-letter queue"
+// @Param limit query int false "Messages to return (1-1000, default 50)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/dead-letters/{queue} [get]
+func ListDeadLettersDoc() {}
+
+// GetDeadLetter godoc
+// @Summary Inspect a dead-lettered message
+// @Description Get a dead-lettered message with its attempts, last error and original queue. Admin only.
+// @Tags dead-letters
+// @Produce json
+// @Param queue path string true "Dead-letter queue"
+// @Param messageId path string true "Message ID"
+// @Success 200 {object} queue.DeadLetter
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/dead-letters/{queue}/{messageId} [get]
+func GetDeadLetterDoc() {}
+
+// ReplayDeadLetters godoc
+// @Summary Replay dead-lettered messages
+// @Description Publish dead-lettered messages back to the queue they failed on with a fresh set of attempts.
+// @Description POST /admin/dead-letters/{queue}/replay replays the whole queue. Admin only.
+// @Tags dead-letters
+// @Produce json
+// @Param queue path string true "Dead-letter queue"
+// @Param messageId path string true "Message ID"
+// @Success 200 {object} map[string]int
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/dead-letters/{queue}/{messageId}/replay [post]
+func ReplayDeadLettersDoc() {}
+
+// PurgeDeadLetters godoc
+// @Summary Purge dead-lettered messages
+// @Description Delete a dead-lettered message. DELETE /admin/dead-letters/{queue} purges the whole queue. Admin only.
+// @Tags dead-letters
+// @Produce json
+// @Param queue path string true "Dead-letter queue"
+// @Param messageId path string true "Message ID"
+// @Success 200 {object} map[string]int
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/dead-letters/{queue}/{messageId} [delete]
+func PurgeDeadLettersDoc() {}
@@ -0,0 +1,117 @@
+package lifecycle
+
+import (
+	"errors"
+	"strings"
+)
+
+// Order statuses
+const (
+	StatusPending    = "pending"
+	StatusPaid       = "paid"
+	StatusProcessing = "processing"
+	StatusShipped    = "shipped"
+	StatusDelivered  = "delivered"
+	StatusCancelled  = "cancelled"
+	StatusRefunded   = "refunded"
+)
+
+// Roles allowed to trigger transitions. System is used by other services
+// (payment-service, the checkout saga) acting on an order.
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+	RoleSystem   = "system"
+)
+
+var (
+	// ErrUnknownStatus is returned for a status outside the state machine
+	ErrUnknownStatus = errors.New("unknown order status")
+	// ErrInvalidTransition is returned when the target status cannot be reached from the current one
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrForbiddenTransition is returned when the actor may not trigger the transition
+	ErrForbiddenTransition = errors.New("order status transition not allowed for this role")
+)
+
+// Actor identifies who triggers a transition
+type Actor struct {
+	Role   string
+	UserID string
+}
+
+// System is the actor used for service-initiated transitions
+var System = Actor{Role: RoleSystem}
+
+// ActorFromHeaders derives the actor from the X-User-Id and X-User-Roles headers
+func ActorFromHeaders(userID, roles string) Actor {
+	switch {
+	case strings.Contains(roles, RoleAdmin):
+		return Actor{Role: RoleAdmin, UserID: userID}
+	case strings.Contains(roles, RoleSystem):
+		return Actor{Role: RoleSystem, UserID: userID}
+	}
+	return Actor{Role: RoleCustomer, UserID: userID}
+}
+
+var staff = []string{RoleAdmin, RoleSystem}
+var anyone = []string{RoleCustomer, RoleAdmin, RoleSystem}
+
+// transitions lists, for each status, the statuses it may move to and who
+// may trigger the move. Customers can only cancel, and only before shipping.
+var transitions = map[string]map[string][]string{
+	StatusPending: {
+		StatusPaid:      staff,
+		StatusCancelled: anyone,
+	},
+	StatusPaid: {
+		StatusProcessing: staff,
+		StatusCancelled:  anyone,
+		StatusRefunded:   staff,
+	},
+	StatusProcessing: {
+		StatusShipped:   staff,
+		StatusCancelled: anyone,
+		StatusRefunded:  staff,
+	},
+	StatusShipped: {
+		StatusDelivered: staff,
+		StatusRefunded:  staff,
+	},
+	StatusDelivered: {
+		StatusRefunded: staff,
+	},
+	StatusCancelled: {},
+	StatusRefunded:  {},
+}
+
+// IsKnown reports whether status belongs to the state machine
+func IsKnown(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransition checks whether actor may move an order from one status to another
+func CanTransition(from, to string, actor Actor) error {
+	if !IsKnown(to) {
+		return ErrUnknownStatus
+	}
+	roles, ok := transitions[from][to]
+	if !ok {
+		return ErrInvalidTransition
+	}
+	for _, role := range roles {
+		if role == actor.Role {
+			return nil
+		}
+	}
+	return ErrForbiddenTransition
+}
+
+// IsActive reports whether an order in status still counts as active
+func IsActive(status string) bool {
+	switch status {
+	case StatusDelivered, StatusCancelled, StatusRefunded:
+		return false
+	}
+	return status != ""
+}
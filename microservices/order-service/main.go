@@ -2,29 +2,43 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"go-microservices/order-service/cache"
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/db"
-	"go-microservices/order-service/queue"
+	"go-microservices/order-service/outbox"
 	"go-microservices/order-service/routes"
-
-	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go-microservices/pkg/idempotency"
+	"go-microservices/pkg/platform"
+	"go-microservices/pkg/queue"
 )
 
 func main() {
+	config := platform.LoadConfig("order-service", "8081", db.Defaults)
+	server := platform.NewServer(config)
+	// Background work stops with the server
+	ctx := server.Context()
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
-	// Initialize Redis
+	// Initialize Redis; orders are cached in memory only until it is reachable
 	if err := cache.InitRedis(); err != nil {
 		log.Printf("Warning: Failed to initialize Redis: %v\n", err)
 	}
+	cache.MonitorRedis(5*time.Second, ctx.Done())
 
 	// Initialize RabbitMQ
 	if err := queue.InitRabbitMQ(); err != nil {
@@ -46,18 +60,47 @@ func main() {
 	// Create order controller
 	orderController := controller.NewOrderController(database)
 
-	// Initialize router
-	router := gin.Default()
+	// Resume or compensate checkouts abandoned by a stopped or crashed replica
+	orderController.Checkout.StartRecovery(ctx)
+
+	// Process async order batches, resuming any left unfinished
+	orderController.Batches.Start(ctx)
 
-	// Add prometheus metrics endpoint
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	// Relay order events from the outbox to RabbitMQ
+	outbox.NewRelay(database, orderController.Queue).Start(ctx.Done())
+
+	// Drop cached orders as their change events arrive, including changes
+	// made by other replicas
+	if err := consumeCacheInvalidations(orderQueue, orderController); err != nil {
+		log.Printf("Warning: Failed to subscribe to cache invalidations: %v\n", err)
+	}
 
 	// Setup routes
-	routes.SetupRoutes(router, orderController)
+	idempotencyStore := idempotency.NewStore(database, cache.Redis{})
+	if pgStore, ok := idempotencyStore.(*idempotency.PostgresStore); ok {
+		go pgStore.PurgeEvery(time.Hour)
+	}
+	routes.SetupRoutes(server.Router, orderController, idempotency.Middleware(idempotencyStore, idempotency.TTL()))
 
-	// Start server
-	log.Println("Order Service starting on port 8081...")
-	if err := router.Run(":8081"); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
 }
+
+// consumeCacheInvalidations subscribes this replica to every order event on
+// the orders exchange and invalidates the cached orders they refer to
+func consumeCacheInvalidations(orderQueue queue.Config, orderController *controller.OrderController) error {
+	name, err := queue.DeclareExclusiveQueue(orderQueue)
+	if err != nil {
+		return err
+	}
+	invalidations := orderQueue
+	invalidations.QueueName = name
+	// Retry queues named after this replica's queue would outlive it, so a
+	// failed invalidation goes straight to a dead-letter queue shared by all
+	// replicas; the cached order still expires with its TTL
+	invalidations.MaxAttempts = 1
+	invalidations.DeadLetterQueue = "orders.cache.dead"
+	return queue.ConsumeMessages(invalidations, orderController.HandleOrderEvent)
+}
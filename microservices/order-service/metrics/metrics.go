@@ -31,4 +31,24 @@ var (
 		Name: "active_orders",
 		Help: "The current number of active orders",
 	})
+
+	CacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_hits_total",
+		Help: "The total number of cache lookups served from cache, including cached not-found results, by tier (memory or redis)",
+	}, []string{"tier"})
+
+	CacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cache_misses_total",
+		Help: "The total number of cache lookups that had to load the value",
+	})
+
+	CacheStampedes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_stampedes_suppressed_total",
+		Help: "The total number of cache misses that waited for another load of the same key, by lock (local or redis)",
+	}, []string{"lock"})
+
+	CacheRedisUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cache_redis_up",
+		Help: "Whether Redis is reachable (1) or orders are cached in memory only (0)",
+	})
 )
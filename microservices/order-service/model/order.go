@@ -1,16 +1,83 @@
 package model
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
-// Order represents an order entity
+// Order represents an order aggregate made up of one or more order lines.
+// Prices are snapshotted at creation time so later catalogue changes do not
+// rewrite order history.
 type Order struct {
-	ID         int       `json:"id"`
-	CustomerID int       `json:"customer_id"`
-	ProductID  int       `json:"product_id"`
-	Quantity   int       `json:"quantity"`
-	TotalPrice float64   `json:"total_price"`
-	Status     string    `json:"status"` // pending, processing, shipped, delivered, cancelled
-	CreatedAt  time.Time `json:"created_at"`
+	ID             int         `json:"id"`
+	CustomerID     int         `json:"customer_id"`
+	Items          []OrderItem `json:"items" binding:"required,min=1,dive"`
+	PromoCode      string      `json:"promo_code,omitempty"`
+	Subtotal       float64     `json:"subtotal"`
+	DiscountAmount float64     `json:"discount_amount"`
+	TaxAmount      float64     `json:"tax_amount"`
+	TotalPrice     float64     `json:"total_price"`
+	ReservationID  int         `json:"reservation_id,omitempty"`
+	Status         string      `json:"status"` // pending, paid, processing, shipped, delivered, cancelled, refunded
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// OrderItem represents a single product line within an order
+type OrderItem struct {
+	ID          int     `json:"id"`
+	OrderID     int     `json:"order_id"`
+	ProductID   int     `json:"product_id" binding:"required"`
+	ProductName string  `json:"product_name,omitempty"`
+	Quantity    int     `json:"quantity" binding:"required,min=1"`
+	UnitPrice   float64 `json:"unit_price"`
+	LineTotal   float64 `json:"line_total"`
+}
+
+// CalculateTotal recomputes each line total, the subtotal and the order total.
+// DiscountAmount and TaxAmount are expected to be set by the pricing step.
+func (o *Order) CalculateTotal() {
+	subtotal := 0.0
+	for i := range o.Items {
+		o.Items[i].LineTotal = RoundPrice(float64(o.Items[i].Quantity) * o.Items[i].UnitPrice)
+		subtotal += o.Items[i].LineTotal
+	}
+	o.Subtotal = RoundPrice(subtotal)
+	o.TotalPrice = RoundPrice(o.Subtotal - o.DiscountAmount + o.TaxAmount)
+}
+
+// RoundPrice rounds a monetary amount to whole cents
+func RoundPrice(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Product is the subset of product-service data needed to price an order
+type Product struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// Promotion is a promotion-service discount code. Discount is a percentage
+// taken off the order subtotal.
+type Promotion struct {
+	ID       int     `json:"id"`
+	Code     string  `json:"code"`
+	Discount float64 `json:"discount"`
+}
+
+// Reservation is an inventory-service stock hold for an order
+type Reservation struct {
+	ID        int               `json:"id"`
+	Reference string            `json:"reference"`
+	Status    string            `json:"status"`
+	Items     []ReservationItem `json:"items"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// ReservationItem is a single product quantity held by a reservation
+type ReservationItem struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
 }
 
 // InventoryCheck is used to check inventory availability
@@ -25,9 +92,88 @@ type InventoryResponse struct {
 	Message   string `json:"message,omitempty"`
 }
 
-// OrderStatusUpdate is used to notify about order status updates
-type OrderStatusUpdate struct {
-	OrderID    int    `json:"order_id"`
-	CustomerID int    `json:"customer_id"`
-	Status     string `json:"status"`
+// OrderStatusHistory records one status transition of an order
+type OrderStatusHistory struct {
+	ID         int       `json:"id"`
+	OrderID    int       `json:"order_id"`
+	CustomerID int       `json:"-"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorRole  string    `json:"actor_role"`
+	ActorID    string    `json:"actor_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OrderListParams filters, sorts and pages an order listing
+type OrderListParams struct {
+	CustomerID  int
+	Statuses    []string
+	ProductID   int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Sort is a sortable field, prefixed with "-" for descending order
+	Sort  string
+	Limit int
+	// After continues the listing from the last order of a previous page
+	After *OrderCursor
+}
+
+// OrderCursor marks the last order of a page by its sort value and ID
+type OrderCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// OrderPage is one page of an order listing
+type OrderPage struct {
+	Data       []Order `json:"data"`
+	NextCursor string  `json:"next_cursor"`
+	HasMore    bool    `json:"has_more"`
+	TotalCount int     `json:"total_count"`
+	Limit      int     `json:"limit"`
+}
+
+// Status of each order of a batch
+const (
+	BatchItemPending    = "pending"
+	BatchItemCreated    = "created"
+	BatchItemFailed     = "failed"
+	BatchItemNotCreated = "not_created"
+)
+
+// Status of an async batch job
+const (
+	BatchJobQueued    = "queued"
+	BatchJobRunning   = "running"
+	BatchJobCompleted = "completed"
+	BatchJobFailed    = "failed"
+)
+
+// BatchItemResult is the outcome of one order of a batch
+type BatchItemResult struct {
+	Index   int    `json:"index"`
+	OrderID int    `json:"order_id,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	// Code is the HTTP status the order would have failed with on its own
+	Code int `json:"code,omitempty"`
+}
+
+// BatchJob is an order batch processed in the background
+type BatchJob struct {
+	ID          int               `json:"job_id"`
+	CustomerID  int               `json:"customer_id"`
+	Mode        string            `json:"mode"`
+	Status      string            `json:"status"`
+	Total       int               `json:"total_orders"`
+	Processed   int               `json:"processed"`
+	Successful  int               `json:"successful"`
+	Failed      int               `json:"failed"`
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	Items       []BatchItemResult `json:"items,omitempty"`
 }
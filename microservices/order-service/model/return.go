@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// Return is a customer's request to send back items of a delivered order.
+// RefundAmount is the share of the order total paid for the returned items,
+// and RefundedAmount how much of it payment-service has refunded so far.
+type Return struct {
+	ID             int                   `json:"id"`
+	OrderID        int                   `json:"order_id"`
+	CustomerID     int                   `json:"customer_id"`
+	Status         string                `json:"status"` // requested, approved, rejected, received
+	Reason         string                `json:"reason"`
+	Notes          string                `json:"notes,omitempty"`
+	Items          []ReturnItem          `json:"items"`
+	RefundAmount   float64               `json:"refund_amount"`
+	RefundedAmount float64               `json:"refunded_amount"`
+	RefundStatus   string                `json:"refund_status,omitempty"` // pending, processing, refunded, failed
+	Restocked      bool                  `json:"restocked"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+	History        []ReturnStatusHistory `json:"history,omitempty"`
+}
+
+// ReturnItem is a quantity of one product of the order being returned
+type ReturnItem struct {
+	ProductID   int     `json:"product_id" binding:"required"`
+	ProductName string  `json:"product_name,omitempty"`
+	Quantity    int     `json:"quantity" binding:"required,min=1"`
+	UnitPrice   float64 `json:"unit_price"`
+}
+
+// ReturnRequest is the body of POST /orders/:id/returns
+type ReturnRequest struct {
+	Reason string       `json:"reason" binding:"required"`
+	Notes  string       `json:"notes"`
+	Items  []ReturnItem `json:"items" binding:"required,min=1,dive"`
+}
+
+// ReturnStatusHistory records one status transition of a return
+type ReturnStatusHistory struct {
+	ID         int       `json:"id"`
+	ReturnID   int       `json:"return_id"`
+	OrderID    int       `json:"-"`
+	CustomerID int       `json:"-"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorRole  string    `json:"actor_role"`
+	ActorID    string    `json:"actor_id,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
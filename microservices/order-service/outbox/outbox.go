@@ -0,0 +1,234 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/queue"
+)
+
+// Order event types, also used as the RabbitMQ routing key
+const (
+	EventOrderCreated       = events.OrderCreated
+	EventOrderStatusChanged = events.OrderStatusChanged
+	EventOrderCancelled     = events.OrderCancelled
+	EventOrderUpdated       = events.OrderUpdated
+	EventOrderDeleted       = events.OrderDeleted
+
+	EventReturnStatusChanged = events.OrderReturnStatusChanged
+)
+
+// Source names order-service as the producer in event envelopes
+const Source = "order-service"
+
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	publishTimeout      = 5 * time.Second
+	maxBackoff          = 5 * time.Minute
+)
+
+// Event is a pending message stored in the outbox table
+type Event struct {
+	ID          int64
+	AggregateID int
+	EventType   string
+	Payload     json.RawMessage
+	Attempts    int
+}
+
+// Publisher delivers a message to the broker
+type Publisher interface {
+	PublishMessage(ctx context.Context, config queue.Config, message interface{}) error
+}
+
+// Enqueue writes an event to the outbox within the caller's transaction, so
+// the event is recorded if and only if the business change commits. The
+// payload is wrapped in an events.Envelope and must match the event's schema.
+func Enqueue(tx *sql.Tx, eventType string, aggregateID int, payload interface{}) error {
+	body, err := envelop(eventType, aggregateID, payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO outbox (aggregate_id, event_type, payload)
+		VALUES ($1, $2, $3)`,
+		aggregateID, eventType, body)
+	return err
+}
+
+// Relay publishes pending outbox rows and marks them sent. Delivery is
+// at-least-once: a crash between publishing and marking a row sent causes
+// the event to be published again.
+type Relay struct {
+	DB           *sql.DB
+	Publisher    Publisher
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+// NewRelay creates a relay polling at OUTBOX_POLL_INTERVAL (default 1s)
+func NewRelay(db *sql.DB, publisher Publisher) *Relay {
+	interval := defaultPollInterval
+	if v := os.Getenv("OUTBOX_POLL_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+
+	return &Relay{
+		DB:           db,
+		Publisher:    publisher,
+		BatchSize:    defaultBatchSize,
+		PollInterval: interval,
+	}
+}
+
+// Start runs the relay in the background until stop is closed
+func (r *Relay) Start(stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(r.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				// Drain the backlog before waiting for the next tick
+				for {
+					sent, err := r.PublishPending()
+					if err != nil {
+						log.Printf("Warning: Outbox relay failed: %v\n", err)
+					}
+					if err != nil || sent < r.BatchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+// PublishPending publishes one batch of due events in insertion order and
+// returns how many were sent. Rows are locked with SKIP LOCKED so several
+// order-service replicas can relay concurrently without double-sending.
+// The batch stops at the first failure, which is recorded on the row and
+// retried later with exponential backoff.
+func (r *Relay) PublishPending() (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, aggregate_id, event_type, payload, attempts
+		FROM outbox
+		WHERE sent_at IS NULL AND next_attempt_at <= $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, time.Now(), r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.AggregateID, &e.EventType, &e.Payload, &e.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var publishErr error
+	for _, e := range events {
+		if publishErr = r.publish(e); publishErr != nil {
+			attempts := e.Attempts + 1
+			if _, err := tx.Exec(`
+				UPDATE outbox SET attempts = $1, last_error = $2, next_attempt_at = $3
+				WHERE id = $4`,
+				attempts, publishErr.Error(), time.Now().Add(backoff(attempts)), e.ID); err != nil {
+				return 0, err
+			}
+			break
+		}
+
+		if _, err := tx.Exec("UPDATE outbox SET sent_at = $1, attempts = attempts + 1 WHERE id = $2", time.Now(), e.ID); err != nil {
+			return 0, err
+		}
+		sent++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if publishErr != nil {
+		return sent, fmt.Errorf("failed to publish outbox event: %w", publishErr)
+	}
+	return sent, nil
+}
+
+// publish sends a single event to the orders exchange. A publish the broker
+// holds up is abandoned after publishTimeout and retried like any failure.
+func (r *Relay) publish(e Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	body := e.Payload
+	if _, err := events.Parse(body); err != nil {
+		// Rows enqueued before events were enveloped hold the bare payload
+		if body, err = envelop(e.EventType, e.AggregateID, e.Payload); err != nil {
+			return err
+		}
+	}
+
+	return r.Publisher.PublishMessage(ctx, queue.Config{
+		QueueName:    "orders",
+		RoutingKey:   e.EventType,
+		ExchangeName: "orders",
+	}, body)
+}
+
+// envelop wraps a payload in an envelope for eventType. Every event about an
+// order carries the order as its correlation ID, so consumers can trace the
+// order's history across services.
+func envelop(eventType string, aggregateID int, payload interface{}) (json.RawMessage, error) {
+	envelope, err := events.New(Source, eventType, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s event: %w", eventType, err)
+	}
+	envelope.CorrelationID = fmt.Sprintf("order-%d", aggregateID)
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+	return body, nil
+}
+
+// backoff returns the delay before the given retry attempt
+func backoff(attempts int) time.Duration {
+	if attempts > 9 {
+		return maxBackoff
+	}
+	delay := time.Duration(1<<uint(attempts)) * time.Second
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,72 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"go-microservices/order-service/model"
+)
+
+// ErrPriceMismatch is returned when a client-quoted price differs from the server-side price
+var ErrPriceMismatch = errors.New("quoted price does not match current price")
+
+// Calculator prices orders from catalogue data fetched server-side
+type Calculator struct {
+	TaxRate float64 // fraction of the discounted subtotal, e.g. 0.1 for 10%
+}
+
+// NewCalculator creates a calculator configured from the environment
+func NewCalculator() *Calculator {
+	taxRate, err := strconv.ParseFloat(os.Getenv("ORDER_TAX_RATE"), 64)
+	if err != nil || taxRate < 0 {
+		taxRate = 0
+	}
+	return &Calculator{TaxRate: taxRate}
+}
+
+// Apply snapshots catalogue prices onto the order lines and computes the
+// discount, tax and total. Any unit price or total the client sent is treated
+// as a quote and must match what the server computes.
+func (c *Calculator) Apply(order *model.Order, products map[int]*model.Product, promotion *model.Promotion) error {
+	quotedTotal := order.TotalPrice
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		product, ok := products[item.ProductID]
+		if !ok {
+			return fmt.Errorf("no price available for product %d", item.ProductID)
+		}
+		if item.UnitPrice != 0 && model.RoundPrice(item.UnitPrice) != model.RoundPrice(product.Price) {
+			return fmt.Errorf("%w: product %d quoted at %.2f, current price is %.2f",
+				ErrPriceMismatch, item.ProductID, item.UnitPrice, product.Price)
+		}
+		item.UnitPrice = model.RoundPrice(product.Price)
+		item.ProductName = product.Name
+	}
+
+	order.DiscountAmount = 0
+	order.TaxAmount = 0
+	order.CalculateTotal()
+
+	if promotion != nil {
+		percent := promotion.Discount
+		if percent < 0 {
+			percent = 0
+		} else if percent > 100 {
+			percent = 100
+		}
+		order.PromoCode = promotion.Code
+		order.DiscountAmount = model.RoundPrice(order.Subtotal * percent / 100)
+	}
+	order.TaxAmount = model.RoundPrice((order.Subtotal - order.DiscountAmount) * c.TaxRate)
+	order.CalculateTotal()
+
+	if quotedTotal != 0 && model.RoundPrice(quotedTotal) != order.TotalPrice {
+		return fmt.Errorf("%w: order quoted at %.2f, current total is %.2f",
+			ErrPriceMismatch, quotedTotal, order.TotalPrice)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package returns
+
+import (
+	"errors"
+
+	"go-microservices/order-service/lifecycle"
+)
+
+// Return statuses. A return is requested by the customer, approved (and
+// refunded) or rejected by an admin, and received once the goods are back.
+const (
+	StatusRequested = "requested"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusReceived  = "received"
+)
+
+// Reason codes a customer can give for a return
+const (
+	ReasonDamaged        = "damaged"
+	ReasonDefective      = "defective"
+	ReasonWrongItem      = "wrong_item"
+	ReasonNotAsDescribed = "not_as_described"
+	ReasonNoLongerNeeded = "no_longer_needed"
+	ReasonOther          = "other"
+)
+
+// Refund outcomes recorded on an approved return; processing marks a refund
+// that one approval has claimed and is carrying out
+const (
+	RefundPending    = "pending"
+	RefundProcessing = "processing"
+	RefundCompleted  = "refunded"
+	RefundFailed     = "failed"
+)
+
+var (
+	// ErrInvalidTransition is returned when the target status cannot be reached from the current one
+	ErrInvalidTransition = errors.New("invalid return status transition")
+	// ErrForbiddenTransition is returned when the actor may not trigger the transition
+	ErrForbiddenTransition = errors.New("return status transition not allowed for this role")
+)
+
+var reasons = map[string]bool{
+	ReasonDamaged:        true,
+	ReasonDefective:      true,
+	ReasonWrongItem:      true,
+	ReasonNotAsDescribed: true,
+	ReasonNoLongerNeeded: true,
+	ReasonOther:          true,
+}
+
+var staff = []string{lifecycle.RoleAdmin, lifecycle.RoleSystem}
+
+// transitions lists, for each status, the statuses it may move to and who
+// may trigger the move. Only staff decide on a return.
+var transitions = map[string]map[string][]string{
+	StatusRequested: {
+		StatusApproved: {lifecycle.RoleAdmin},
+		StatusRejected: {lifecycle.RoleAdmin},
+	},
+	StatusApproved: {
+		StatusReceived: staff,
+	},
+	StatusRejected: {},
+	StatusReceived: {},
+}
+
+// IsReason reports whether code is a known return reason
+func IsReason(code string) bool {
+	return reasons[code]
+}
+
+// CanTransition checks whether actor may move a return from one status to another
+func CanTransition(from, to string, actor lifecycle.Actor) error {
+	roles, ok := transitions[from][to]
+	if !ok {
+		return ErrInvalidTransition
+	}
+	for _, role := range roles {
+		if role == actor.Role {
+			return nil
+		}
+	}
+	return ErrForbiddenTransition
+}
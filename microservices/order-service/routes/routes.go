@@ -7,16 +7,37 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes configures the API routes for the order service
-func SetupRoutes(router *gin.Engine, orderController *controller.OrderController) {
+// SetupRoutes configures the API routes for the order service; idempotency
+// guards the order creation endpoints against duplicate retries
+func SetupRoutes(router *gin.Engine, orderController *controller.OrderController, idempotency gin.HandlerFunc) {
 	// Order routes
 	// Require authentication for order creation and modifications
-	router.POST("/orders", middleware.RequireAuth(), orderController.CreateOrder)
-	router.POST("/orders/with-payment", middleware.RequireAuth(), orderController.CreateOrderWithPayment)
+	router.POST("/orders", middleware.RequireAuth(), idempotency, orderController.CreateOrder)
+	router.POST("/orders/with-payment", middleware.RequireAuth(), idempotency, orderController.CreateOrderWithPayment)
 	router.POST("/orders/batch", middleware.RequireAuth(), orderController.CreateBatchOrders)
-	router.GET("/orders", orderController.GetOrders)
+	router.GET("/orders/batch/:jobId", middleware.RequireAuth(), orderController.GetBatchJob)
+	router.GET("/orders", middleware.RequireAuth(), orderController.GetOrders)
 	router.GET("/orders/:id", middleware.RequireAuth(), orderController.GetOrder)
 	router.PUT("/orders/:id", middleware.RequireAuth(), orderController.UpdateOrder)
 	router.DELETE("/orders/:id", middleware.RequireAuth(), orderController.DeleteOrder)
+	router.POST("/orders/:id/cancel", middleware.RequireAuth(), orderController.CancelOrder)
 	router.PATCH("/orders/:id/status", middleware.RequireAuth(), orderController.UpdateOrderStatus)
+	router.GET("/orders/:id/history", middleware.RequireAuth(), orderController.GetOrderHistory)
+
+	// Return routes
+	router.POST("/orders/:id/returns", middleware.RequireAuth(), orderController.CreateReturn)
+	router.GET("/orders/:id/returns", middleware.RequireAuth(), orderController.GetOrderReturns)
+	router.GET("/returns/:returnId", middleware.RequireAuth(), orderController.GetReturn)
+	router.POST("/returns/:returnId/approve", middleware.RequireAuth(), orderController.ApproveReturn)
+	router.POST("/returns/:returnId/reject", middleware.RequireAuth(), orderController.RejectReturn)
+	router.POST("/returns/:returnId/receive", middleware.RequireAuth(), orderController.ReceiveReturn)
+
+	// Dead-letter queue administration
+	router.GET("/admin/dead-letters", middleware.RequireAuth(), orderController.ListDeadLetterQueues)
+	router.GET("/admin/dead-letters/:queue", middleware.RequireAuth(), orderController.ListDeadLetters)
+	router.DELETE("/admin/dead-letters/:queue", middleware.RequireAuth(), orderController.PurgeDeadLetters)
+	router.POST("/admin/dead-letters/:queue/replay", middleware.RequireAuth(), orderController.ReplayDeadLetters)
+	router.GET("/admin/dead-letters/:queue/:messageId", middleware.RequireAuth(), orderController.GetDeadLetter)
+	router.DELETE("/admin/dead-letters/:queue/:messageId", middleware.RequireAuth(), orderController.PurgeDeadLetters)
+	router.POST("/admin/dead-letters/:queue/:messageId/replay", middleware.RequireAuth(), orderController.ReplayDeadLetters)
 }
@@ -0,0 +1,286 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/service"
+)
+
+// Saga statuses
+const (
+	StatusRunning      = "running"
+	StatusCompleted    = "completed"
+	StatusCompensating = "compensating"
+	StatusCompensated  = "compensated"
+	StatusFailed       = "failed" // compensation itself failed and needs manual attention
+)
+
+// Checkout steps, recorded after each one completes
+const (
+	StepStarted           = "started"
+	StepInventoryReserved = "inventory_reserved"
+	StepOrderCreated      = "order_created"
+	StepPaymentCreated    = "payment_created"
+)
+
+// InventoryReserver holds and releases stock for a checkout
+type InventoryReserver interface {
+	ReserveItems(reference string, items []model.OrderItem) (*model.Reservation, error)
+	ReleaseReservation(reservationID int) error
+}
+
+// OrderStore creates orders and cancels them during compensation
+type OrderStore interface {
+	InsertOrder(order *model.Order) error
+	UpdateOrderStatus(orderID int, status string) error
+}
+
+// PaymentCreator creates payment intents in payment-service and cancels them
+// during compensation
+type PaymentCreator interface {
+	CreatePayment(orderID int, customerID int, amount float64, currency string) (*service.PaymentResponse, error)
+	CancelPayment(paymentID int, customerID int) error
+}
+
+// Payload is the checkout input persisted alongside the saga
+type Payload struct {
+	Order    model.Order `json:"order"`
+	Currency string      `json:"currency"`
+}
+
+// CheckoutSaga is the persisted state of a single checkout
+type CheckoutSaga struct {
+	ID            int       `json:"id"`
+	Status        string    `json:"status"`
+	Step          string    `json:"step"`
+	CustomerID    int       `json:"customer_id"`
+	OrderID       int       `json:"order_id"`
+	ReservationID int       `json:"reservation_id"`
+	PaymentID     int       `json:"payment_id"`
+	Payload       Payload   `json:"payload"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Payment is the payment-service response; it is only set on the run that created it
+	Payment *service.PaymentResponse `json:"-"`
+}
+
+// StepError reports which checkout step failed
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("checkout step %s failed: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultStaleAfter is how long a saga may go unsaved before it is treated as
+// abandoned. Every checkout step is a single HTTP call with a 10s timeout.
+const DefaultStaleAfter = 10 * time.Minute
+
+// recoverInterval is how often abandoned sagas are looked for
+const recoverInterval = time.Minute
+
+// CheckoutOrchestrator runs the checkout saga: reserve inventory, create the
+// order, create the payment intent, and compensate on any failure
+type CheckoutOrchestrator struct {
+	Store      Store
+	Inventory  InventoryReserver
+	Orders     OrderStore
+	Payments   PaymentCreator
+	StaleAfter time.Duration
+}
+
+// NewCheckoutOrchestrator creates a new checkout orchestrator
+func NewCheckoutOrchestrator(store Store, inventory InventoryReserver, orders OrderStore, payments PaymentCreator) *CheckoutOrchestrator {
+	return &CheckoutOrchestrator{
+		Store:      store,
+		Inventory:  inventory,
+		Orders:     orders,
+		Payments:   payments,
+		StaleAfter: DefaultStaleAfter,
+	}
+}
+
+// StartRecovery recovers abandoned sagas now and then every recoverInterval
+// until ctx is done. Sagas of a process that has just crashed only become
+// stale a while after it restarts, so recovering once at startup isn't enough.
+func (o *CheckoutOrchestrator) StartRecovery(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(recoverInterval)
+		defer ticker.Stop()
+		for {
+			if err := o.Recover(); err != nil {
+				log.Printf("Warning: Failed to recover checkout sagas: %v\n", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Execute runs a checkout for an already priced order. On success the order
+// is updated with its assigned ID; on failure every completed step has been
+// compensated and the returned saga records why.
+func (o *CheckoutOrchestrator) Execute(order *model.Order, currency string) (*CheckoutSaga, error) {
+	cs := &CheckoutSaga{
+		Status:     StatusRunning,
+		Step:       StepStarted,
+		CustomerID: order.CustomerID,
+		Payload:    Payload{Order: *order, Currency: currency},
+	}
+	if err := o.Store.Create(cs); err != nil {
+		return nil, fmt.Errorf("failed to start checkout saga: %w", err)
+	}
+
+	if err := o.run(cs); err != nil {
+		o.compensate(cs, err)
+		return cs, err
+	}
+
+	*order = cs.Payload.Order
+	return cs, nil
+}
+
+// run executes the remaining steps of a saga, persisting progress after each one
+func (o *CheckoutOrchestrator) run(cs *CheckoutSaga) error {
+	if cs.Step == StepStarted {
+		reservation, err := o.Inventory.ReserveItems(fmt.Sprintf("checkout-saga-%d", cs.ID), cs.Payload.Order.Items)
+		if err != nil {
+			return &StepError{Step: "reserve_inventory", Err: err}
+		}
+		cs.ReservationID = reservation.ID
+		if err := o.advance(cs, StepInventoryReserved); err != nil {
+			return err
+		}
+	}
+
+	if cs.Step == StepInventoryReserved {
+		order := &cs.Payload.Order
+		order.ReservationID = cs.ReservationID
+		if err := o.Orders.InsertOrder(order); err != nil {
+			return &StepError{Step: "create_order", Err: err}
+		}
+		cs.OrderID = order.ID
+		if err := o.advance(cs, StepOrderCreated); err != nil {
+			return err
+		}
+	}
+
+	if cs.Step == StepOrderCreated {
+		order := cs.Payload.Order
+		payment, err := o.Payments.CreatePayment(order.ID, order.CustomerID, order.TotalPrice, cs.Payload.Currency)
+		if err != nil {
+			return &StepError{Step: "create_payment", Err: err}
+		}
+		cs.Payment = payment
+		cs.PaymentID = payment.Payment.ID
+		if err := o.advance(cs, StepPaymentCreated); err != nil {
+			return err
+		}
+	}
+
+	cs.Status = StatusCompleted
+	if err := o.Store.Save(cs); err != nil {
+		log.Printf("Warning: failed to mark checkout saga %d completed: %v\n", cs.ID, err)
+	}
+	return nil
+}
+
+// advance records that a step completed
+func (o *CheckoutOrchestrator) advance(cs *CheckoutSaga, step string) error {
+	cs.Step = step
+	if err := o.Store.Save(cs); err != nil {
+		return &StepError{Step: "persist_" + step, Err: err}
+	}
+	return nil
+}
+
+// compensate undoes completed steps in reverse order: cancel the payment
+// intent, cancel the order, then release the stock. Every action is safe to
+// repeat, so a compensation interrupted by a restart can simply run again.
+func (o *CheckoutOrchestrator) compensate(cs *CheckoutSaga, cause error) {
+	cs.Status = StatusCompensating
+	if cs.Error == "" {
+		cs.Error = cause.Error()
+	}
+	if err := o.Store.Save(cs); err != nil {
+		log.Printf("Warning: failed to persist compensation of checkout saga %d: %v\n", cs.ID, err)
+	}
+
+	failed := false
+	if cs.PaymentID != 0 {
+		if err := o.Payments.CancelPayment(cs.PaymentID, cs.CustomerID); err != nil {
+			log.Printf("Checkout saga %d: failed to cancel payment %d: %v\n", cs.ID, cs.PaymentID, err)
+			failed = true
+		}
+	}
+	if cs.OrderID != 0 {
+		if err := o.Orders.UpdateOrderStatus(cs.OrderID, "cancelled"); err != nil {
+			log.Printf("Checkout saga %d: failed to cancel order %d: %v\n", cs.ID, cs.OrderID, err)
+			failed = true
+		}
+	}
+	if cs.ReservationID != 0 {
+		if err := o.Inventory.ReleaseReservation(cs.ReservationID); err != nil {
+			log.Printf("Checkout saga %d: failed to release reservation %d: %v\n", cs.ID, cs.ReservationID, err)
+			failed = true
+		}
+	}
+
+	cs.Status = StatusCompensated
+	if failed {
+		cs.Status = StatusFailed
+	}
+	if err := o.Store.Save(cs); err != nil {
+		log.Printf("Warning: failed to persist compensation of checkout saga %d: %v\n", cs.ID, err)
+	}
+}
+
+// Recover resumes or compensates sagas abandoned by a process that stopped
+// or crashed. A saga counts as abandoned once it has not been saved for
+// StaleAfter, far longer than any live checkout takes, and each one is
+// claimed so only one replica recovers it. A running saga that already
+// created its payment is completed; anything earlier is rolled back,
+// because the interrupted request may have reached a downstream service
+// without its result being recorded. A saga that was compensating when the
+// process stopped finishes compensating, cancelling its payment intent if it
+// got that far. A reservation made just before a crash without its ID being
+// saved is freed by the inventory reservation TTL, and a payment intent
+// whose ID was lost can never mark the cancelled order paid.
+func (o *CheckoutOrchestrator) Recover() error {
+	sagas, err := o.Store.ClaimStale(time.Now().Add(-o.StaleAfter))
+	if err != nil {
+		return fmt.Errorf("failed to claim abandoned checkout sagas: %w", err)
+	}
+
+	for _, cs := range sagas {
+		switch {
+		case cs.Status == StatusCompensating:
+			o.compensate(cs, errors.New(cs.Error))
+		case cs.Step == StepPaymentCreated:
+			if err := o.run(cs); err != nil {
+				o.compensate(cs, err)
+			}
+		default:
+			o.compensate(cs, errors.New("checkout interrupted by service restart"))
+		}
+		log.Printf("Recovered checkout saga %d: %s\n", cs.ID, cs.Status)
+	}
+
+	return nil
+}
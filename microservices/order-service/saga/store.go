@@ -0,0 +1,100 @@
+package saga
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Store persists checkout saga state so an interrupted checkout can be
+// resumed or compensated after a restart
+type Store interface {
+	Create(s *CheckoutSaga) error
+	Save(s *CheckoutSaga) error
+	ClaimStale(before time.Time) ([]*CheckoutSaga, error)
+}
+
+// DBStore implements Store using the orders database
+type DBStore struct {
+	DB *sql.DB
+}
+
+// NewDBStore creates a new database-backed saga store
+func NewDBStore(db *sql.DB) *DBStore {
+	return &DBStore{DB: db}
+}
+
+// Create inserts a new saga row and assigns its ID
+func (s *DBStore) Create(cs *CheckoutSaga) error {
+	payload, err := json.Marshal(cs.Payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	cs.CreatedAt = now
+	cs.UpdatedAt = now
+
+	return s.DB.QueryRow(`
+		INSERT INTO checkout_sagas (status, step, customer_id, order_id, reservation_id, payment_id, payload, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
+		cs.Status, cs.Step, cs.CustomerID, cs.OrderID, cs.ReservationID, cs.PaymentID, payload, cs.Error, cs.CreatedAt, cs.UpdatedAt,
+	).Scan(&cs.ID)
+}
+
+// Save persists the current step and status of a saga
+func (s *DBStore) Save(cs *CheckoutSaga) error {
+	payload, err := json.Marshal(cs.Payload)
+	if err != nil {
+		return err
+	}
+
+	cs.UpdatedAt = time.Now()
+	_, err = s.DB.Exec(`
+		UPDATE checkout_sagas
+		SET status = $1, step = $2, order_id = $3, reservation_id = $4, payment_id = $5, payload = $6, error = $7, updated_at = $8
+		WHERE id = $9`,
+		cs.Status, cs.Step, cs.OrderID, cs.ReservationID, cs.PaymentID, payload, cs.Error, cs.UpdatedAt, cs.ID)
+	return err
+}
+
+// ClaimStale returns the running or compensating sagas last saved before
+// before, touching them as it does so that no other process claims them
+// too. Rows another process is claiming at the same time are skipped.
+func (s *DBStore) ClaimStale(before time.Time) ([]*CheckoutSaga, error) {
+	rows, err := s.DB.Query(`
+		UPDATE checkout_sagas SET updated_at = $1
+		WHERE id IN (
+			SELECT id FROM checkout_sagas
+			WHERE status IN ($2, $3) AND updated_at < $4
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, status, step, customer_id, order_id, reservation_id, payment_id, payload, error, created_at, updated_at`,
+		time.Now(), StatusRunning, StatusCompensating, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sagas []*CheckoutSaga
+	for rows.Next() {
+		var cs CheckoutSaga
+		var payload []byte
+		if err := rows.Scan(&cs.ID, &cs.Status, &cs.Step, &cs.CustomerID, &cs.OrderID, &cs.ReservationID, &cs.PaymentID,
+			&payload, &cs.Error, &cs.CreatedAt, &cs.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &cs.Payload); err != nil {
+			return nil, err
+		}
+		sagas = append(sagas, &cs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING doesn't keep the order of the subquery
+	sort.Slice(sagas, func(i, j int) bool { return sagas[i].ID < sagas[j].ID })
+	return sagas, nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,6 +15,9 @@ import (
 	"github.com/sony/gobreaker"
 )
 
+// ErrInsufficientStock is returned when inventory-service cannot hold the requested quantities
+var ErrInsufficientStock = errors.New("insufficient stock")
+
 // InventoryService is a client for the inventory service
 type InventoryService struct {
 	BaseURL    string
@@ -119,3 +123,117 @@ func (s *InventoryService) CheckAvailability(productID int, quantity int) (bool,
 
 	return result.(bool), nil
 }
+
+// ReserveItems places a hold on stock for every order line. The reference ties
+// the reservation back to the caller (e.g. a checkout saga).
+func (is *InventoryService) ReserveItems(reference string, items []model.OrderItem) (*model.Reservation, error) {
+	request := struct {
+		Reference string                  `json:"reference"`
+		Items     []model.ReservationItem `json:"items"`
+	}{Reference: reference}
+	for _, item := range items {
+		request.Items = append(request.Items, model.ReservationItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reservation request: %w", err)
+	}
+
+	result, err := is.cb.Execute(func() (interface{}, error) {
+		resp, err := is.HTTPClient.Post(is.BaseURL+"/inventory/reservations", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("inventory service request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		// Not enough stock is a business outcome and must not trip the breaker
+		if resp.StatusCode == http.StatusConflict {
+			return nil, nil
+		}
+		if resp.StatusCode != http.StatusCreated {
+			return nil, fmt.Errorf("inventory service returned status: %d", resp.StatusCode)
+		}
+
+		var reservation model.Reservation
+		if err := json.NewDecoder(resp.Body).Decode(&reservation); err != nil {
+			return nil, fmt.Errorf("failed to decode reservation response: %w", err)
+		}
+
+		return &reservation, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reservation, _ := result.(*model.Reservation)
+	if reservation == nil {
+		return nil, ErrInsufficientStock
+	}
+
+	return reservation, nil
+}
+
+// CommitReservation turns a reservation into a permanent stock decrement
+func (is *InventoryService) CommitReservation(reservationID int) error {
+	return is.reservationAction(reservationID, "commit")
+}
+
+// ReleaseReservation returns the held stock to the available pool
+func (is *InventoryService) ReleaseReservation(reservationID int) error {
+	return is.reservationAction(reservationID, "release")
+}
+
+// Restock returns the items of a customer return to stock. Inventory-service
+// applies each reference once, so a failed call can be retried safely.
+func (is *InventoryService) Restock(reference string, items []model.ReturnItem) error {
+	request := struct {
+		Reference string                  `json:"reference"`
+		Items     []model.ReservationItem `json:"items"`
+	}{Reference: reference}
+	for _, item := range items {
+		request.Items = append(request.Items, model.ReservationItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal restock request: %w", err)
+	}
+
+	_, err = resilience.ExecuteWithRetry(is.cb, func() (interface{}, error) {
+		resp, err := is.HTTPClient.Post(is.BaseURL+"/inventory/restock", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("inventory service request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("inventory service returned status: %d", resp.StatusCode)
+		}
+
+		return nil, nil
+	}, 3) // Maximum 3 retries
+
+	return err
+}
+
+// reservationAction posts a state transition for a reservation
+func (is *InventoryService) reservationAction(reservationID int, action string) error {
+	url := fmt.Sprintf("%s/inventory/reservations/%d/%s", is.BaseURL, reservationID, action)
+
+	_, err := resilience.ExecuteWithRetry(is.cb, func() (interface{}, error) {
+		resp, err := is.HTTPClient.Post(url, "application/json", nil)
+		if err != nil {
+			return nil, fmt.Errorf("inventory service request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("inventory service returned status: %d", resp.StatusCode)
+		}
+
+		return nil, nil
+	}, 3) // Maximum 3 retries
+
+	return err
+}
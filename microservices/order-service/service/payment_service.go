@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 
+	"go-microservices/pkg/platform"
+
 	"github.com/sony/gobreaker"
 )
 
@@ -46,7 +49,7 @@ type PaymentResponse struct {
 
 // NewPaymentService creates a new payment service instance
 func NewPaymentService() *PaymentService {
-	baseURL := getEnv("PAYMENT_SERVICE_URL", "http://payment-service:8084")
+	baseURL := platform.Getenv("PAYMENT_SERVICE_URL", "http://payment-service:8084")
 	
 	// Circuit breaker settings
 	settings := gobreaker.Settings{
@@ -86,12 +89,13 @@ func (ps *PaymentService) CreatePayment(orderID, customerID int, amount float64,
 	}
 
 	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
-		req, err := http.NewRequest("POST", ps.baseURL+"/payments", bytes.NewBuffer(jsonData))
+		req, err := http.NewRequest("POST", ps.baseURL+"/payments/", bytes.NewBuffer(jsonData))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
 		req.Header.Set("Content-Type", "application/json")
+		setSystemHeaders(req, customerID)
 
 		resp, err := ps.client.Do(req)
 		if err != nil {
@@ -123,6 +127,34 @@ func (ps *PaymentService) CreatePayment(orderID, customerID int, amount float64,
 	return paymentResp, nil
 }
 
+// CancelPayment cancels a payment intent that has not been paid, on behalf
+// of its customer. Cancelling an already cancelled payment succeeds.
+func (ps *PaymentService) CancelPayment(paymentID, customerID int) error {
+	_, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
+		url := fmt.Sprintf("%s/payments/%d/cancel", ps.baseURL, paymentID)
+		req, err := http.NewRequest("POST", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		setSystemHeaders(req, customerID)
+
+		resp, err := ps.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("payment service returned status: %d", resp.StatusCode)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("payment service circuit breaker: %w", err)
+	}
+	return nil
+}
+
 // GetPaymentsByOrder retrieves payments for a specific order
 func (ps *PaymentService) GetPaymentsByOrder(orderID int) ([]PaymentResponse, error) {
 	url := fmt.Sprintf("%s/payments/order/%d", ps.baseURL, orderID)
@@ -163,11 +195,191 @@ func (ps *PaymentService) GetPaymentsByOrder(orderID int) ([]PaymentResponse, er
 	return payments, nil
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// orderPayment is the part of a payment-service payment that cancellations
+// and refunds need
+type orderPayment struct {
+	ID     int     `json:"id"`
+	Amount float64 `json:"amount"`
+	Status string  `json:"status"`
+}
+
+// CancelOrderPayments cancels the payment intents of an order that have not
+// been paid, so a cancelled order can't be charged afterwards. It fails if
+// any of them could not be cancelled, including one paid in the meantime.
+func (ps *PaymentService) CancelOrderPayments(orderID, customerID int) error {
+	payments, err := ps.orderPayments(orderID, customerID)
+	if err != nil {
+		return err
+	}
+
+	for _, payment := range payments {
+		if payment.Status != "pending" && payment.Status != "failed" {
+			continue
+		}
+		if err := ps.CancelPayment(payment.ID, customerID); err != nil {
+			return fmt.Errorf("failed to cancel payment %d: %w", payment.ID, err)
+		}
 	}
-	return value
-}
\ No newline at end of file
+	return nil
+}
+
+// RefundOrder refunds the outstanding balance of every captured payment of an
+// order on behalf of its customer, returning the amount refunded. A payment
+// that was refunded in the meantime is skipped.
+func (ps *PaymentService) RefundOrder(orderID, customerID int, reason string) (float64, error) {
+	return ps.refund(orderID, customerID, 0, reason, "")
+}
+
+// RefundAmount refunds up to amount across the captured payments of an
+// order, returning the amount refunded. Each refund request carries an
+// Idempotency-Key made of idempotencyKey, the payment and the amount, so a
+// request repeated by a retry of the same refund is only carried out once.
+func (ps *PaymentService) RefundAmount(orderID, customerID int, amount float64, reason, idempotencyKey string) (float64, error) {
+	if amount <= 0 {
+		return 0, nil
+	}
+	return ps.refund(orderID, customerID, amount, reason, idempotencyKey)
+}
+
+// refund refunds up to amount of an order's payments; zero means all of it
+func (ps *PaymentService) refund(orderID, customerID int, amount float64, reason, idempotencyKey string) (float64, error) {
+	payments, err := ps.orderPayments(orderID, customerID)
+	if err != nil {
+		return 0, err
+	}
+
+	var refunded float64
+	for _, payment := range payments {
+		if payment.Status != "succeeded" && payment.Status != "partially_refunded" {
+			continue
+		}
+
+		// Zero asks payment-service for the payment's whole refundable balance
+		request := 0.0
+		if amount > 0 {
+			left := math.Round((amount-refunded)*100) / 100
+			if left <= 0 {
+				break
+			}
+			request = math.Min(left, payment.Amount)
+		}
+
+		got, err := ps.refundPayment(payment.ID, customerID, request, reason, idempotencyKey)
+		if err != nil {
+			return refunded, fmt.Errorf("failed to refund payment %d: %w", payment.ID, err)
+		}
+		refunded += got
+	}
+
+	return math.Round(refunded*100) / 100, nil
+}
+
+// orderPayments lists the payments of an order on behalf of its customer
+func (ps *PaymentService) orderPayments(orderID, customerID int) ([]orderPayment, error) {
+	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/payments/order/%d", ps.baseURL, orderID), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		setSystemHeaders(req, customerID)
+
+		resp, err := ps.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("payment service returned status: %d", resp.StatusCode)
+		}
+
+		var payments []orderPayment
+		if err := json.NewDecoder(resp.Body).Decode(&payments); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		return payments, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payment service circuit breaker: %w", err)
+	}
+	return result.([]orderPayment), nil
+}
+
+// refundPayment refunds amount of a payment, or its whole balance when amount
+// is zero. When less than amount is left, the rest of the balance is refunded.
+func (ps *PaymentService) refundPayment(paymentID, customerID int, amount float64, reason, idempotencyKey string) (float64, error) {
+	result, err := ps.circuitBreaker.Execute(func() (interface{}, error) {
+		request := map[string]interface{}{"reason": reason}
+		if amount > 0 {
+			request["amount"] = amount
+		}
+		jsonData, err := json.Marshal(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal refund request: %w", err)
+		}
+
+		url := fmt.Sprintf("%s/payments/%d/refunds", ps.baseURL, paymentID)
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setSystemHeaders(req, customerID)
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", fmt.Sprintf("%s-payment-%d-%.0f", idempotencyKey, paymentID, math.Round(amount*100)))
+		}
+
+		resp, err := ps.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		var refundResp struct {
+			Refund struct {
+				Amount float64 `json:"amount"`
+			} `json:"refund"`
+			Refundable float64 `json:"refundable"`
+		}
+		switch resp.StatusCode {
+		case http.StatusCreated:
+			if err := json.NewDecoder(resp.Body).Decode(&refundResp); err != nil {
+				return nil, fmt.Errorf("failed to decode response: %w", err)
+			}
+			return refundOutcome{refunded: refundResp.Refund.Amount}, nil
+		case http.StatusConflict:
+			// The payment is already fully refunded
+			return refundOutcome{}, nil
+		case http.StatusBadRequest:
+			// The amount exceeds what is left of the payment
+			if amount > 0 && json.NewDecoder(resp.Body).Decode(&refundResp) == nil && refundResp.Refundable > 0 {
+				return refundOutcome{refundable: refundResp.Refundable}, nil
+			}
+		}
+		return nil, fmt.Errorf("payment service returned status: %d", resp.StatusCode)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("payment service circuit breaker: %w", err)
+	}
+
+	outcome := result.(refundOutcome)
+	if outcome.refundable > 0 && outcome.refundable < amount {
+		return ps.refundPayment(paymentID, customerID, outcome.refundable, reason, idempotencyKey)
+	}
+	return outcome.refunded, nil
+}
+
+// refundOutcome is the result of a refund request: the amount refunded, or
+// the smaller balance left when the request asked for too much
+type refundOutcome struct {
+	refunded   float64
+	refundable float64
+}
+
+// setSystemHeaders authenticates a service-to-service request made on behalf
+// of a customer
+func setSystemHeaders(req *http.Request, customerID int) {
+	req.Header.Set("X-User-Id", strconv.Itoa(customerID))
+	req.Header.Set("X-User-Roles", "system")
+}
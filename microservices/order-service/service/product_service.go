@@ -0,0 +1,83 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/resilience"
+
+	"github.com/sony/gobreaker"
+)
+
+// ErrProductNotFound is returned when product-service has no product with the requested ID
+var ErrProductNotFound = errors.New("product not found")
+
+// ProductService is a client for the product service
+type ProductService struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	cb         *gobreaker.CircuitBreaker
+}
+
+// NewProductService creates a new product service client
+func NewProductService() *ProductService {
+	baseURL := os.Getenv("PRODUCT_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://product-service:8080" // Docker default
+	}
+
+	// Create circuit breaker
+	cbConfig := resilience.DefaultConfig("product-service")
+	cb := resilience.NewCircuitBreaker(cbConfig)
+
+	return &ProductService{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: time.Second * 10,
+		},
+		cb: cb,
+	}
+}
+
+// GetProduct fetches the current catalogue entry for a product
+func (ps *ProductService) GetProduct(productID int) (*model.Product, error) {
+	url := fmt.Sprintf("%s/products/%d", ps.BaseURL, productID)
+
+	result, err := ps.cb.Execute(func() (interface{}, error) {
+		resp, err := ps.HTTPClient.Get(url)
+		if err != nil {
+			return nil, fmt.Errorf("product service request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		// A missing product is a valid answer, not a failure of the dependency
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("product service returned status: %d", resp.StatusCode)
+		}
+
+		var product model.Product
+		if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
+			return nil, fmt.Errorf("failed to decode product response: %w", err)
+		}
+
+		return &product, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	product, _ := result.(*model.Product)
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+
+	return product, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/resilience"
+
+	"github.com/sony/gobreaker"
+)
+
+// ErrPromotionNotFound is returned when no promotion matches the requested code
+var ErrPromotionNotFound = errors.New("promotion not found")
+
+// PromotionService is a client for the promotion service
+type PromotionService struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	cb         *gobreaker.CircuitBreaker
+}
+
+// NewPromotionService creates a new promotion service client
+func NewPromotionService() *PromotionService {
+	baseURL := os.Getenv("PROMOTION_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://promotion-service:8091" // Docker default
+	}
+
+	// Create circuit breaker
+	cbConfig := resilience.DefaultConfig("promotion-service")
+	cb := resilience.NewCircuitBreaker(cbConfig)
+
+	return &PromotionService{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout: time.Second * 10,
+		},
+		cb: cb,
+	}
+}
+
+// GetPromotion looks up an active promotion by its code
+func (ps *PromotionService) GetPromotion(code string) (*model.Promotion, error) {
+	result, err := ps.cb.Execute(func() (interface{}, error) {
+		resp, err := ps.HTTPClient.Get(ps.BaseURL + "/promotions")
+		if err != nil {
+			return nil, fmt.Errorf("promotion service request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("promotion service returned status: %d", resp.StatusCode)
+		}
+
+		var promotions []model.Promotion
+		if err := json.NewDecoder(resp.Body).Decode(&promotions); err != nil {
+			return nil, fmt.Errorf("failed to decode promotion response: %w", err)
+		}
+
+		return promotions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, promotion := range result.([]model.Promotion) {
+		if strings.EqualFold(promotion.Code, code) {
+			return &promotion, nil
+		}
+	}
+
+	return nil, ErrPromotionNotFound
+}
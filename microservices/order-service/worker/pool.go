@@ -2,56 +2,69 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"log"
 	"sync"
-	"time"
 
 	"go-microservices/order-service/model"
 )
 
+// ErrNotProcessed is reported for jobs the pool never got to
+var ErrNotProcessed = errors.New("order was not processed")
+
 // Job represents a task to be processed
 type Job struct {
+	// Index is the position of the order in its batch
+	Index int
 	Order model.Order
 }
 
 // Result represents the outcome of job processing
 type Result struct {
+	Index   int
 	OrderID int
-	Error   error
+	// Order is the order as processed, with its assigned ID and pricing
+	Order *model.Order
+	Error error
 }
 
+// ProcessFunc processes one job. It should give up when ctx is cancelled.
+type ProcessFunc func(ctx context.Context, job Job) Result
+
 // Pool represents a worker pool
 type Pool struct {
 	numWorkers  int
 	jobQueue    chan Job
 	resultQueue chan Result
-	done        chan bool
+	done        chan struct{}
+	closeOnce   sync.Once
 	ctx         context.Context
 	cancel      context.CancelFunc
 }
 
-// NewPool creates a new worker pool
-func NewPool(numWorkers int, queueSize int) *Pool {
-	ctx, cancel := context.WithCancel(context.Background())
+// NewPool creates a new worker pool. Cancelling ctx stops the workers from
+// picking up further jobs.
+func NewPool(ctx context.Context, numWorkers int, queueSize int) *Pool {
+	ctx, cancel := context.WithCancel(ctx)
 	return &Pool{
 		numWorkers:  numWorkers,
 		jobQueue:    make(chan Job, queueSize),
 		resultQueue: make(chan Result, queueSize),
-		done:        make(chan bool),
+		done:        make(chan struct{}),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 }
 
 // Start initializes the worker pool
-func (p *Pool) Start(processFunc func(Job) Result) {
+func (p *Pool) Start(process ProcessFunc) {
 	// Start workers
 	var wg sync.WaitGroup
 	for i := 0; i < p.numWorkers; i++ {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			p.worker(workerID, processFunc)
+			p.worker(workerID, process)
 		}(i)
 	}
 
@@ -59,23 +72,24 @@ func (p *Pool) Start(processFunc func(Job) Result) {
 	go func() {
 		wg.Wait()
 		close(p.resultQueue)
-		p.done <- true
+		close(p.done)
 	}()
 }
 
-// worker processes jobs from the job queue
-func (p *Pool) worker(id int, processFunc func(Job) Result) {
-	log.Printf("Worker %d starting\n", id)
+// worker processes jobs from the job queue until it is closed or the pool's
+// context is cancelled. A job that was started always reports its result,
+// so work that already had side effects is never lost.
+func (p *Pool) worker(id int, process ProcessFunc) {
 	for {
 		select {
 		case job, ok := <-p.jobQueue:
 			if !ok {
-				log.Printf("Worker %d shutting down\n", id)
 				return
 			}
-			// Process the job and send the result
-			result := processFunc(job)
-			p.resultQueue <- result
+			if p.ctx.Err() != nil {
+				return
+			}
+			p.resultQueue <- process(p.ctx, job)
 
 		case <-p.ctx.Done():
 			log.Printf("Worker %d cancelled\n", id)
@@ -84,61 +98,72 @@ func (p *Pool) worker(id int, processFunc func(Job) Result) {
 	}
 }
 
-// Submit adds a job to the queue
-func (p *Pool) Submit(job Job) {
-	p.jobQueue <- job
+// Submit adds a job to the queue, failing if the pool has been cancelled
+func (p *Pool) Submit(job Job) error {
+	select {
+	case p.jobQueue <- job:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// Close signals that no more jobs will be submitted; workers exit once the
+// queue is drained
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() { close(p.jobQueue) })
 }
 
-// Results returns the channel for receiving results
+// Results returns the channel for receiving results. It is closed once all
+// workers have exited and must be drained by the caller.
 func (p *Pool) Results() <-chan Result {
 	return p.resultQueue
 }
 
-// Stop gracefully shuts down the worker pool
+// Stop cancels outstanding jobs and waits for the workers to exit
 func (p *Pool) Stop() {
 	p.cancel()
-	close(p.jobQueue)
+	p.Close()
 	<-p.done
 }
 
-// ProcessBatch handles a batch of orders with timeout
-func ProcessBatch(orders []model.Order, numWorkers int, timeout time.Duration) []Result {
-	// Create a worker pool with buffer size equal to number of orders
-	pool := NewPool(numWorkers, len(orders))
-
-	// Start the pool with the processing function
-	pool.Start(func(job Job) Result {
-		// Simulate processing time (replace with actual processing)
-		time.Sleep(100 * time.Millisecond)
-		return Result{
-			OrderID: job.Order.ID,
-			Error:   nil,
-		}
-	})
+// ProcessBatch runs process for every order on numWorkers workers and
+// returns one result per order, in batch order. When ctx is cancelled or
+// times out, orders that were not started report the context's error.
+func ProcessBatch(ctx context.Context, orders []model.Order, numWorkers int, process ProcessFunc) []Result {
+	results := make([]Result, len(orders))
+	if len(orders) == 0 {
+		return results
+	}
+	if numWorkers > len(orders) {
+		numWorkers = len(orders)
+	}
 
-	// Submit all orders to the pool
-	go func() {
-		for _, order := range orders {
-			pool.Submit(Job{Order: order})
+	// Buffers hold the whole batch so neither submitting nor reporting blocks
+	pool := NewPool(ctx, numWorkers, len(orders))
+	pool.Start(process)
+	for i, order := range orders {
+		if err := pool.Submit(Job{Index: i, Order: order}); err != nil {
+			break
 		}
-	}()
+	}
+	pool.Close()
 
-	// Collect results with timeout
-	results := make([]Result, 0, len(orders))
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
+	processed := make([]bool, len(orders))
+	for result := range pool.Results() {
+		results[result.Index] = result
+		processed[result.Index] = true
+	}
+	pool.Stop()
 
-	for i := 0; i < len(orders); i++ {
-		select {
-		case result := <-pool.Results():
-			results = append(results, result)
-		case <-timer.C:
-			log.Printf("Batch processing timeout after %v\n", timeout)
-			pool.Stop()
-			return results
+	for i := range results {
+		if !processed[i] {
+			err := ctx.Err()
+			if err == nil {
+				err = ErrNotProcessed
+			}
+			results[i] = Result{Index: i, Error: err}
 		}
 	}
-
-	pool.Stop()
 	return results
 }
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-microservices/pkg/platform"
+)
+
+// errOrderNotFound is returned when order-service has no such order for the
+// customer
+var errOrderNotFound = errors.New("order not found")
+
+// payableOrder is the part of an order-service order payments are checked
+// against
+type payableOrder struct {
+	ID         int     `json:"id"`
+	CustomerID int     `json:"customer_id"`
+	TotalPrice float64 `json:"total_price"`
+	Status     string  `json:"status"`
+}
+
+var orderClient = &http.Client{Timeout: 10 * time.Second}
+
+func orderServiceURL() string {
+	return platform.Getenv("ORDER_SERVICE_URL", "http://order-service:8081")
+}
+
+// fetchOrder loads an order from order-service on behalf of its customer.
+// Orders belonging to someone else are reported as not found.
+func fetchOrder(orderID, customerID int) (*payableOrder, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/orders/%d", orderServiceURL(), orderID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-User-Id", strconv.Itoa(customerID))
+
+	resp, err := orderClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden:
+		return nil, errOrderNotFound
+	default:
+		return nil, fmt.Errorf("order service returned status: %d", resp.StatusCode)
+	}
+
+	var order payableOrder
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		return nil, fmt.Errorf("failed to decode order: %w", err)
+	}
+	return &order, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,25 +14,37 @@ import (
 	"time"
 
 	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stripe/stripe-go/v76"
-	"github.com/stripe/stripe-go/v76/paymentintent"
 )
 
 type PaymentController struct {
-	db *sql.DB
+	db       *sql.DB
+	provider provider.PaymentProvider
 }
 
 func NewPaymentController(db *sql.DB) *PaymentController {
-	// Initialize Stripe if configured; otherwise, run in stub mode (useful for integration tests)
+	webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		log.Println("Warning: STRIPE_WEBHOOK_SECRET not set — webhooks will be rejected")
+	}
+
+	// Use Stripe if configured; otherwise, run against the in-memory fake (useful for integration tests)
 	stripeKey := os.Getenv("STRIPE_SECRET_KEY")
 	if stripeKey == "" {
-		log.Println("Warning: STRIPE_SECRET_KEY not set — running payment service in stub mode")
-	} else {
-		stripe.Key = stripeKey
+		log.Println("Warning: STRIPE_SECRET_KEY not set — running payment service with the fake provider")
+		return NewPaymentControllerWithProvider(db, provider.NewFake(webhookSecret))
 	}
-		db: db,
+
+	return NewPaymentControllerWithProvider(db, provider.NewStripe(stripeKey, webhookSecret))
+}
+
+// NewPaymentControllerWithProvider creates a payment controller using the given payment provider
+func NewPaymentControllerWithProvider(db *sql.DB, p provider.PaymentProvider) *PaymentController {
+	return &PaymentController{
+		db:       db,
+		provider: p,
 	}
 }
 
@@ -59,34 +72,38 @@ func (pc *PaymentController) CreatePayment(c *gin.Context) {
 		req.CustomerID = cid
 	}
 
-	// Convert amount to cents for Stripe (Stripe expects amounts in cents)
-	amountCents := int64(req.Amount * 100)
-
-	var pi *stripe.PaymentIntent
-	var err error
-	if stripe.Key == "" {
-		// Stub mode: generate fake payment intent
-		pi = &stripe.PaymentIntent{
-			ID:           "pi_stub_" + strconv.FormatInt(time.Now().UnixNano(), 10),
-			ClientSecret: "secret_stub_" + strconv.FormatInt(time.Now().UnixNano(), 10),
-			Status:       stripe.PaymentIntentStatusRequiresPaymentMethod,
-		}
-	} else {
-		// Create payment intent with Stripe
-		params := &stripe.PaymentIntentParams{
-			Amount:   stripe.Int64(amountCents),
-			Currency: stripe.String(req.Currency),
-			Metadata: map[string]string{
-				"order_id":    strconv.Itoa(req.OrderID),
-				"customer_id": strconv.Itoa(req.CustomerID),
-			},
-		}
+	// The amount must be the order's total as order-service priced it, so a
+	// client can't pay less than it owes
+	order, err := fetchOrder(req.OrderID, req.CustomerID)
+	if errors.Is(err, errOrderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to retrieve order: " + err.Error()})
+		return
+	}
+	if order.Status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is not awaiting payment", "status": order.Status})
+		return
+	}
+	if toCents(req.Amount) != toCents(order.TotalPrice) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment amount does not match the order total", "order_total": order.TotalPrice})
+		return
+	}
 
-		pi, err = paymentintent.New(params)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment intent: " + err.Error()})
-			return
-		}
+	// Create payment intent with the provider (amounts are in cents)
+	pi, err := pc.provider.CreateIntent(provider.CreateIntentParams{
+		Amount:   toCents(req.Amount),
+		Currency: req.Currency,
+		Metadata: map[string]string{
+			"order_id":    strconv.Itoa(req.OrderID),
+			"customer_id": strconv.Itoa(req.CustomerID),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment intent: " + err.Error()})
+		return
 	}
 
 	// Save payment to database
@@ -132,24 +149,35 @@ func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
 		return
 	}
 
-	// Retrieve payment intent from Stripe or stub
-	var pi *stripe.PaymentIntent
-	var err error
-	if stripe.Key == "" {
-		// Stub: assume success
-		pi = &stripe.PaymentIntent{ID: req.PaymentIntentID, Status: stripe.PaymentIntentStatusSucceeded, PaymentMethod: &stripe.PaymentMethod{Type: "card"}}
-	} else {
-		pi, err = paymentintent.Get(req.PaymentIntentID, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment intent: " + err.Error()})
-			return
-		}
+	// Retrieve payment intent from the provider
+	pi, err := pc.provider.GetIntent(req.PaymentIntentID)
+	if errors.Is(err, provider.ErrIntentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment intent not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment intent: " + err.Error()})
+		return
+	}
+
+	tx, err := pc.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+		return
 	}
+	defer tx.Rollback()
 
-	// Fetch existing payment to enforce ownership
-	var existing model.Payment
-	err = pc.db.QueryRow("SELECT id, order_id, customer_id FROM payments WHERE stripe_payment_id = $1", req.PaymentIntentID).
-		Scan(&existing.ID, &existing.OrderID, &existing.CustomerID)
+	// Fetch existing payment to enforce ownership, locked so a concurrent
+	// webhook or refund can't change it between the check and the update
+	var payment model.Payment
+	err = tx.QueryRow(`
+		SELECT id, order_id, customer_id, amount, currency, status, stripe_payment_id,
+		       COALESCE(payment_method, '') as payment_method, created_at, updated_at
+		FROM payments WHERE stripe_payment_id = $1
+		FOR UPDATE`, req.PaymentIntentID).Scan(
+		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
+		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
+	)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment: " + err.Error()})
 		return
@@ -161,7 +189,7 @@ func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
-	if strconv.Itoa(existing.CustomerID) != uid && !strings.Contains(roles, "admin") {
+	if strconv.Itoa(payment.CustomerID) != uid && !strings.Contains(roles, "admin") {
 		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 		return
 	}
@@ -169,29 +197,37 @@ func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
 	// Update payment status in database
 	status := model.PaymentStatusPending
 	switch pi.Status {
-	case stripe.PaymentIntentStatusSucceeded:
+	case provider.IntentStatusSucceeded:
 		status = model.PaymentStatusSucceeded
-	case stripe.PaymentIntentStatusCanceled:
+	case provider.IntentStatusCanceled:
 		status = model.PaymentStatusCanceled
-	case stripe.PaymentIntentStatusProcessing:
+	case provider.IntentStatusProcessing, provider.IntentStatusRequiresAction:
 		status = model.PaymentStatusPending
 	default:
 		status = model.PaymentStatusFailed
 	}
 
-	query := `
-		UPDATE payments 
-		SET status = $1, payment_method = $2, updated_at = $3
-		WHERE stripe_payment_id = $4
-		RETURNING id, order_id, customer_id, amount, currency, status, stripe_payment_id, payment_method, created_at, updated_at
-	`
+	// The intent still reads succeeded once the payment has been refunded,
+	// so the same transitions as webhooks apply
+	updated := canTransitionPayment(payment.Status, status)
+	if updated {
+		query := `
+			UPDATE payments 
+			SET status = $1, payment_method = $2, updated_at = $3
+			WHERE stripe_payment_id = $4
+			RETURNING id, order_id, customer_id, amount, currency, status, stripe_payment_id, payment_method, created_at, updated_at
+		`
+		err = tx.QueryRow(query, status, pi.PaymentMethod, time.Now(), pi.ID).Scan(
+			&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
+			&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+			return
+		}
+	}
 
-	var payment model.Payment
-	err = pc.db.QueryRow(query, status, string(pi.PaymentMethod.Type), time.Now(), pi.ID).Scan(
-		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
-		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
-	)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
 		return
 	}
@@ -200,34 +236,130 @@ func (pc *PaymentController) ConfirmPayment(c *gin.Context) {
 		Payment: payment,
 		Message: "Payment status updated successfully",
 	}
+	switch {
+	case payment.Status != status:
+		response.Message = "Payment is already " + payment.Status
+	case pi.Status == provider.IntentStatusRequiresAction:
+		response.Message = "Payment requires additional authentication"
+	case pi.DeclineCode != "":
+		response.Message = "Payment declined: " + pi.DeclineCode
+	}
 
-	// If payment succeeded, attempt to update order status to 'completed'
-	if status == model.PaymentStatusSucceeded {
-		go func(orderID int, customerID int) {
-			orderServiceURL := getEnv("ORDER_SERVICE_URL", "http://order-service:8081")
-			url := fmt.Sprintf("%s/orders/%d/status", orderServiceURL, orderID)
-			body := map[string]string{"status": "completed"}
-			b, _ := json.Marshal(body)
-			req, _ := http.NewRequest("PATCH", url, bytes.NewReader(b))
-			req.Header.Set("Content-Type", "application/json")
-			// Set X-User-Id header so ownership checks pass
-			req.Header.Set("X-User-Id", strconv.Itoa(customerID))
-			client := &http.Client{Timeout: 10 * time.Second}
-			resp, err := client.Do(req)
-			if err != nil {
-				log.Printf("Failed to notify order service about payment success: %v\n", err)
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusOK {
-				log.Printf("Order service returned non-OK status when updating status: %d\n", resp.StatusCode)
-			}
-		}(payment.OrderID, payment.CustomerID)
+	// If payment succeeded, attempt to update order status to 'paid'
+	if updated && status == model.PaymentStatusSucceeded {
+		go notifyOrderPaid(payment)
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// CancelPayment cancels a payment intent that has not been paid. order-service
+// cancels with the system role when a checkout is compensated; cancelling an
+// already cancelled payment succeeds so compensation can be repeated.
+func (pc *PaymentController) CancelPayment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	tx, err := pc.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel payment: " + err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var payment model.Payment
+	err = tx.QueryRow(`
+		SELECT id, order_id, customer_id, amount, currency, status, stripe_payment_id,
+		       COALESCE(payment_method, '') as payment_method, created_at, updated_at
+		FROM payments WHERE id = $1
+		FOR UPDATE`, id).Scan(
+		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
+		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment: " + err.Error()})
+		return
+	}
+
+	// Ownership: allow owner, admin or system
+	uid := c.GetHeader("X-User-Id")
+	roles := c.GetHeader("X-User-Roles")
+	if strconv.Itoa(payment.CustomerID) != uid && !strings.Contains(roles, "admin") && !strings.Contains(roles, "system") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	switch payment.Status {
+	case model.PaymentStatusCanceled:
+		c.JSON(http.StatusOK, model.PaymentResponse{Payment: payment, Message: "Payment already canceled"})
+		return
+	case model.PaymentStatusPending, model.PaymentStatusFailed:
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment cannot be canceled in status " + payment.Status})
+		return
+	}
+
+	if _, err := pc.provider.CancelIntent(payment.StripePaymentID); err != nil && !errors.Is(err, provider.ErrIntentNotFound) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to cancel payment intent: " + err.Error()})
+		return
+	}
+
+	payment.Status = model.PaymentStatusCanceled
+	payment.UpdatedAt = time.Now()
+	_, err = tx.Exec("UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3", payment.Status, payment.UpdatedAt, payment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.PaymentResponse{Payment: payment, Message: "Payment canceled successfully"})
+}
+
+// notifyOrderPaid asks order-service to mark the order paid once its payment
+// has succeeded. An order whose total no longer matches the amount paid is
+// left for an admin to resolve.
+func notifyOrderPaid(payment model.Payment) {
+	order, err := fetchOrder(payment.OrderID, payment.CustomerID)
+	if err != nil {
+		log.Printf("Failed to load order %d to mark it paid: %v\n", payment.OrderID, err)
+		return
+	}
+	if toCents(order.TotalPrice) != toCents(payment.Amount) {
+		log.Printf("Not marking order %d paid: payment %d of %.2f does not match its total of %.2f\n",
+			payment.OrderID, payment.ID, payment.Amount, order.TotalPrice)
+		return
+	}
+
+	url := fmt.Sprintf("%s/orders/%d/status", orderServiceURL(), payment.OrderID)
+	body := map[string]string{"status": "paid"}
+	b, _ := json.Marshal(body)
+	req, _ := http.NewRequest("PATCH", url, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	// Act as the system: only admin or system may move an order to paid
+	req.Header.Set("X-User-Id", strconv.Itoa(payment.CustomerID))
+	req.Header.Set("X-User-Roles", "system")
+	resp, err := orderClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to notify order service about payment success: %v\n", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Order service returned non-OK status when updating status: %d\n", resp.StatusCode)
+	}
+}
+
 // GetPayment retrieves a payment by ID
 func (pc *PaymentController) GetPayment(c *gin.Context) {
 	idParam := c.Param("id")
@@ -329,21 +461,3 @@ func (pc *PaymentController) GetPaymentsByOrder(c *gin.Context) {
 
 	c.JSON(http.StatusOK, payments)
 }
-
-// HealthCheck returns the health status of the payment service
-func (pc *PaymentController) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"service": "payment-service",
-		"time":    time.Now().UTC(),
-	})
-}
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
\ No newline at end of file
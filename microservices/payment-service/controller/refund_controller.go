@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateRefund refunds all or part of a captured payment. Refunds are
+// serialised per payment by locking its row, so the cumulative refunded
+// amount can never exceed what was captured. Admins refund on request;
+// order-service refunds with the system role when a paid order is cancelled.
+func (pc *PaymentController) CreateRefund(c *gin.Context) {
+	roles := c.GetHeader("X-User-Roles")
+	if !strings.Contains(roles, "admin") && !strings.Contains(roles, "system") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	var req model.RefundRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	tx, err := pc.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create refund: " + err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var payment model.Payment
+	err = tx.QueryRow(`
+		SELECT id, order_id, customer_id, amount, currency, status, stripe_payment_id,
+		       COALESCE(payment_method, '') as payment_method, created_at, updated_at
+		FROM payments WHERE id = $1
+		FOR UPDATE`, id).Scan(
+		&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Currency,
+		&payment.Status, &payment.StripePaymentID, &payment.PaymentMethod, &payment.CreatedAt, &payment.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment: " + err.Error()})
+		return
+	}
+
+	if payment.Status != model.PaymentStatusSucceeded && payment.Status != model.PaymentStatusPartiallyRefunded {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment cannot be refunded in status " + payment.Status})
+		return
+	}
+
+	var refunded float64
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM refunds
+		WHERE payment_id = $1 AND status NOT IN ('failed', 'canceled')`, payment.ID).Scan(&refunded)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve refunds: " + err.Error()})
+		return
+	}
+
+	// Compare in cents so float rounding can't let a refund slip past the limit
+	remainingCents := toCents(payment.Amount) - toCents(refunded)
+	amountCents := remainingCents
+	if req.Amount > 0 {
+		amountCents = toCents(req.Amount)
+	}
+	if remainingCents <= 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment has already been fully refunded"})
+		return
+	}
+	if amountCents > remainingCents {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Refund amount exceeds the refundable balance",
+			"refundable": float64(remainingCents) / 100,
+		})
+		return
+	}
+
+	re, err := pc.provider.Refund(provider.RefundParams{
+		PaymentIntentID: payment.StripePaymentID,
+		Amount:          amountCents,
+		Reason:          req.Reason,
+		Metadata: map[string]string{
+			"payment_id": strconv.Itoa(payment.ID),
+			"order_id":   strconv.Itoa(payment.OrderID),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create refund: " + err.Error()})
+		return
+	}
+
+	rf := model.Refund{
+		PaymentID:      payment.ID,
+		Amount:         float64(amountCents) / 100,
+		Currency:       payment.Currency,
+		Status:         re.Status,
+		Reason:         req.Reason,
+		StripeRefundID: re.ID,
+		CreatedAt:      time.Now(),
+	}
+	err = tx.QueryRow(`
+		INSERT INTO refunds (payment_id, amount, currency, status, reason, stripe_refund_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		rf.PaymentID, rf.Amount, rf.Currency, rf.Status, rf.Reason, rf.StripeRefundID, rf.CreatedAt,
+	).Scan(&rf.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save refund: " + err.Error()})
+		return
+	}
+
+	payment.Status = model.PaymentStatusPartiallyRefunded
+	if amountCents == remainingCents {
+		payment.Status = model.PaymentStatusRefunded
+	}
+	payment.UpdatedAt = time.Now()
+	_, err = tx.Exec("UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3", payment.Status, payment.UpdatedAt, payment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save refund: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, model.RefundResponse{
+		Refund:  rf,
+		Payment: payment,
+		Message: "Refund created successfully",
+	})
+}
+
+// toCents converts a currency amount to its smallest unit
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
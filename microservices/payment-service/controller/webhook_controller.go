@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBodyBytes is the payload limit recommended by Stripe
+const maxWebhookBodyBytes = 65536
+
+// webhookStatuses maps provider webhook events to the payment status they imply
+var webhookStatuses = map[string]string{
+	provider.EventPaymentSucceeded:         model.PaymentStatusSucceeded,
+	provider.EventPaymentFailed:            model.PaymentStatusFailed,
+	provider.EventPaymentCanceled:          model.PaymentStatusCanceled,
+	provider.EventPaymentRefunded:          model.PaymentStatusRefunded,
+	provider.EventPaymentPartiallyRefunded: model.PaymentStatusPartiallyRefunded,
+}
+
+// HandleStripeWebhook verifies a provider webhook and applies the payment
+// status it carries. Each event is processed at most once, keyed by its ID.
+func (pc *PaymentController) HandleStripeWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodyBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	event, err := pc.provider.VerifyWebhook(payload, c.GetHeader("Stripe-Signature"))
+	if errors.Is(err, provider.ErrWebhookNotConfigured) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook secret not configured"})
+		return
+	}
+	if errors.Is(err, provider.ErrInvalidSignature) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event payload: " + err.Error()})
+		return
+	}
+
+	paymentIntentID := event.PaymentIntentID
+	status := webhookStatuses[event.Type]
+	if status == "" {
+		// Acknowledge events we don't act on so Stripe stops retrying them
+		c.JSON(http.StatusOK, gin.H{"received": true, "ignored": true})
+		return
+	}
+
+	tx, err := pc.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook: " + err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO stripe_webhook_events (event_id, event_type, stripe_payment_id, processed_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (event_id) DO NOTHING`,
+		event.ID, event.Type, paymentIntentID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record webhook: " + err.Error()})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusOK, gin.H{"received": true, "duplicate": true})
+		return
+	}
+
+	var payment model.Payment
+	err = tx.QueryRow(`
+		SELECT id, order_id, customer_id, amount, status
+		FROM payments WHERE stripe_payment_id = $1
+		FOR UPDATE`, paymentIntentID).Scan(&payment.ID, &payment.OrderID, &payment.CustomerID, &payment.Amount, &payment.Status)
+	if err == sql.ErrNoRows {
+		// Not one of ours; keep the event recorded so redeliveries are skipped
+		log.Printf("Stripe webhook %s references unknown payment intent %s\n", event.ID, paymentIntentID)
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record webhook: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"received": true, "ignored": true})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment: " + err.Error()})
+		return
+	}
+
+	updated := canTransitionPayment(payment.Status, status)
+	if updated {
+		_, err = tx.Exec("UPDATE payments SET status = $1, updated_at = $2 WHERE id = $3", status, time.Now(), payment.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment: " + err.Error()})
+		return
+	}
+
+	// Same order-status update that ConfirmPayment triggers
+	if updated && status == model.PaymentStatusSucceeded {
+		go notifyOrderPaid(payment)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true, "payment_id": payment.ID, "status": status, "updated": updated})
+}
+
+// canTransitionPayment reports whether a webhook or a confirmation may move a
+// payment from one status to another. Stripe does not guarantee delivery
+// order, so a late failure must not overwrite a success and a full refund is
+// final.
+func canTransitionPayment(from, to string) bool {
+	if from == to {
+		return false
+	}
+	switch from {
+	case model.PaymentStatusSucceeded:
+		return to == model.PaymentStatusPartiallyRefunded || to == model.PaymentStatusRefunded
+	case model.PaymentStatusPartiallyRefunded:
+		return to == model.PaymentStatusRefunded
+	case model.PaymentStatusRefunded, model.PaymentStatusCanceled:
+		return false
+	}
+	return true
+}
@@ -2,38 +2,43 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"go-microservices/payment-service/controller"
 	"go-microservices/payment-service/db"
 	"go-microservices/payment-service/routes"
-
-	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go-microservices/pkg/idempotency"
+	"go-microservices/pkg/platform"
 )
 
 func main() {
+	config := platform.LoadConfig("payment-service", "8084", db.Defaults)
+	server := platform.NewServer(config)
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	// Create payment controller
 	paymentController := controller.NewPaymentController(database)
 
-	// Initialize router
-	router := gin.Default()
-
-	// Add prometheus metrics endpoint
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
-
 	// Setup routes
-	routes.SetupRoutes(router, paymentController)
+	idempotencyStore := &idempotency.PostgresStore{DB: database}
+	go idempotencyStore.PurgeEvery(time.Hour)
+	routes.SetupRoutes(server.Router, paymentController, idempotency.Middleware(idempotencyStore, idempotency.TTL()))
 
-	// Start server
-	log.Println("Payment Service starting on port 8084...")
-	if err := router.Run(":8084"); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
@@ -45,4 +45,41 @@ const (
 	PaymentStatusSucceeded = "succeeded"
 	PaymentStatusFailed    = "failed"
 	PaymentStatusCanceled  = "canceled"
-)
\ No newline at end of file
+	PaymentStatusRefunded  = "refunded"
+
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+)
+
+// Refund represents a full or partial refund of a payment
+type Refund struct {
+	ID             int       `json:"id" db:"id"`
+	PaymentID      int       `json:"payment_id" db:"payment_id"`
+	Amount         float64   `json:"amount" db:"amount"`
+	Currency       string    `json:"currency" db:"currency"`
+	Status         string    `json:"status" db:"status"`
+	Reason         string    `json:"reason,omitempty" db:"reason"`
+	StripeRefundID string    `json:"stripe_refund_id" db:"stripe_refund_id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// RefundRequest represents a refund creation request; omitting the amount
+// refunds whatever has not been refunded yet
+type RefundRequest struct {
+	Amount float64 `json:"amount" binding:"omitempty,min=0.01"`
+	Reason string  `json:"reason" binding:"omitempty,oneof=duplicate fraudulent requested_by_customer"`
+}
+
+// RefundResponse represents a refund response
+type RefundResponse struct {
+	Refund  Refund  `json:"refund"`
+	Payment Payment `json:"payment"`
+	Message string  `json:"message,omitempty"`
+}
+
+// WebhookEvent records a processed Stripe webhook so redeliveries are ignored
+type WebhookEvent struct {
+	EventID         string    `json:"event_id" db:"event_id"`
+	EventType       string    `json:"event_type" db:"event_type"`
+	StripePaymentID string    `json:"stripe_payment_id" db:"stripe_payment_id"`
+	ProcessedAt     time.Time `json:"processed_at" db:"processed_at"`
+}
\ No newline at end of file
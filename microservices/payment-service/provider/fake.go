@@ -0,0 +1,272 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Outcome decides how the fake resolves a payment intent once the customer
+// has had a chance to confirm it
+type Outcome string
+
+// Outcomes supported by the fake provider
+const (
+	// OutcomeSucceed succeeds immediately
+	OutcomeSucceed Outcome = "succeed"
+	// OutcomeDecline fails with a card_declined error
+	OutcomeDecline Outcome = "decline"
+	// OutcomeRequiresAction waits for a 3DS challenge, see CompleteAction
+	OutcomeRequiresAction Outcome = "requires_action"
+	// OutcomeDelayedSuccess stays processing for the configured delay
+	OutcomeDelayedSuccess Outcome = "delayed_success"
+)
+
+// DeclineCodeCardDeclined is reported for intents resolved with OutcomeDecline
+const DeclineCodeCardDeclined = "card_declined"
+
+// Fake is an in-memory PaymentProvider for local runs and tests. Intents
+// start as requires_payment_method like Stripe's and are resolved according
+// to their Outcome the first time they are retrieved, which stands in for
+// the customer confirming the payment in the browser.
+type Fake struct {
+	mu            sync.Mutex
+	intents       map[string]*fakeIntent
+	refunds       map[string][]Refund
+	queued        []Outcome
+	seq           int
+	webhookSecret string
+
+	// DefaultOutcome applies when no outcome has been queued
+	DefaultOutcome Outcome
+	// ProcessingDelay is how long OutcomeDelayedSuccess stays processing
+	ProcessingDelay time.Duration
+	// Now is the clock used for delayed success
+	Now func() time.Time
+}
+
+type fakeIntent struct {
+	intent      PaymentIntent
+	outcome     Outcome
+	confirmed   bool
+	succeedAt   time.Time
+	actionsDone bool
+}
+
+// NewFake creates a fake provider that succeeds by default and verifies
+// webhooks signed with webhookSecret (see SignWebhook)
+func NewFake(webhookSecret string) *Fake {
+	return &Fake{
+		intents:         make(map[string]*fakeIntent),
+		refunds:         make(map[string][]Refund),
+		webhookSecret:   webhookSecret,
+		DefaultOutcome:  OutcomeSucceed,
+		ProcessingDelay: 5 * time.Second,
+		Now:             time.Now,
+	}
+}
+
+// QueueOutcome sets the outcome of the next intents created, in order
+func (f *Fake) QueueOutcome(outcomes ...Outcome) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queued = append(f.queued, outcomes...)
+}
+
+// SetOutcome changes the outcome of an existing, unresolved intent
+func (f *Fake) SetOutcome(id string, outcome Outcome) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, ok := f.intents[id]
+	if !ok {
+		return ErrIntentNotFound
+	}
+	fi.outcome = outcome
+	return nil
+}
+
+// CompleteAction simulates the customer passing a 3DS challenge
+func (f *Fake) CompleteAction(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, ok := f.intents[id]
+	if !ok {
+		return ErrIntentNotFound
+	}
+	fi.actionsDone = true
+	f.resolve(fi)
+	return nil
+}
+
+// CreateIntent stores a new intent awaiting confirmation
+func (f *Fake) CreateIntent(params CreateIntentParams) (*PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	outcome := f.DefaultOutcome
+	if len(f.queued) > 0 {
+		outcome = f.queued[0]
+		f.queued = f.queued[1:]
+	}
+
+	f.seq++
+	id := fmt.Sprintf("pi_fake_%d_%d", time.Now().UnixNano(), f.seq)
+	fi := &fakeIntent{
+		intent: PaymentIntent{
+			ID:           id,
+			ClientSecret: id + "_secret",
+			Status:       IntentStatusRequiresPaymentMethod,
+			Amount:       params.Amount,
+			Currency:     params.Currency,
+			Metadata:     params.Metadata,
+		},
+		outcome: outcome,
+	}
+	f.intents[id] = fi
+
+	intent := fi.intent
+	return &intent, nil
+}
+
+// GetIntent returns the intent, resolving it according to its outcome
+func (f *Fake) GetIntent(id string) (*PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, ok := f.intents[id]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	f.resolve(fi)
+
+	intent := fi.intent
+	return &intent, nil
+}
+
+// CancelIntent cancels an intent that has not succeeded
+func (f *Fake) CancelIntent(id string) (*PaymentIntent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, ok := f.intents[id]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if fi.intent.Status == IntentStatusSucceeded {
+		return nil, fmt.Errorf("payment intent %s has already succeeded", id)
+	}
+	fi.intent.Status = IntentStatusCanceled
+
+	intent := fi.intent
+	return &intent, nil
+}
+
+// Refund records a refund against a succeeded intent
+func (f *Fake) Refund(params RefundParams) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, ok := f.intents[params.PaymentIntentID]
+	if !ok {
+		// Intents created before a restart are unknown to the fake; accept the refund
+		return f.addRefund(params), nil
+	}
+	if fi.intent.Status != IntentStatusSucceeded {
+		return nil, fmt.Errorf("payment intent %s has not succeeded", params.PaymentIntentID)
+	}
+
+	var refunded int64
+	for _, r := range f.refunds[params.PaymentIntentID] {
+		refunded += r.Amount
+	}
+	if refunded+params.Amount > fi.intent.Amount {
+		return nil, fmt.Errorf("refund of %d exceeds remaining %d", params.Amount, fi.intent.Amount-refunded)
+	}
+
+	return f.addRefund(params), nil
+}
+
+// VerifyWebhook checks an HMAC-SHA256 signature produced by SignWebhook and
+// decodes a {"id", "type", "payment_intent_id"} payload
+func (f *Fake) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if f.webhookSecret == "" {
+		return nil, ErrWebhookNotConfigured
+	}
+	if !hmac.Equal([]byte(signature), []byte(f.SignWebhook(payload))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event struct {
+		ID              string `json:"id"`
+		Type            string `json:"type"`
+		PaymentIntentID string `json:"payment_intent_id"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	return &WebhookEvent{ID: event.ID, Type: event.Type, PaymentIntentID: event.PaymentIntentID}, nil
+}
+
+// SignWebhook returns the signature VerifyWebhook expects for payload
+func (f *Fake) SignWebhook(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.webhookSecret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// resolve advances an intent according to its outcome; callers hold f.mu
+func (f *Fake) resolve(fi *fakeIntent) {
+	status := fi.intent.Status
+	if status == IntentStatusSucceeded || status == IntentStatusCanceled {
+		return
+	}
+
+	if !fi.confirmed {
+		fi.confirmed = true
+		fi.succeedAt = f.Now().Add(f.ProcessingDelay)
+	}
+
+	switch fi.outcome {
+	case OutcomeDecline:
+		fi.intent.Status = IntentStatusRequiresPaymentMethod
+		fi.intent.DeclineCode = DeclineCodeCardDeclined
+	case OutcomeRequiresAction:
+		if fi.actionsDone {
+			f.succeed(fi)
+		} else {
+			fi.intent.Status = IntentStatusRequiresAction
+		}
+	case OutcomeDelayedSuccess:
+		if f.Now().Before(fi.succeedAt) {
+			fi.intent.Status = IntentStatusProcessing
+		} else {
+			f.succeed(fi)
+		}
+	default:
+		f.succeed(fi)
+	}
+}
+
+func (f *Fake) succeed(fi *fakeIntent) {
+	fi.intent.Status = IntentStatusSucceeded
+	fi.intent.PaymentMethod = "card"
+	fi.intent.DeclineCode = ""
+}
+
+func (f *Fake) addRefund(params RefundParams) *Refund {
+	f.seq++
+	r := Refund{
+		ID:     fmt.Sprintf("re_fake_%d_%d", time.Now().UnixNano(), f.seq),
+		Status: "succeeded",
+		Amount: params.Amount,
+	}
+	f.refunds[params.PaymentIntentID] = append(f.refunds[params.PaymentIntentID], r)
+	return &r
+}
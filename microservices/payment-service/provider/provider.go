@@ -0,0 +1,84 @@
+package provider
+
+import "errors"
+
+var (
+	// ErrWebhookNotConfigured is returned when no webhook signing secret is set
+	ErrWebhookNotConfigured = errors.New("webhook secret not configured")
+	// ErrInvalidSignature is returned when a webhook payload fails verification
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrIntentNotFound is returned when the provider has no such payment intent
+	ErrIntentNotFound = errors.New("payment intent not found")
+)
+
+// Payment intent statuses, mirroring the Stripe lifecycle
+const (
+	IntentStatusRequiresPaymentMethod = "requires_payment_method"
+	IntentStatusRequiresAction        = "requires_action"
+	IntentStatusProcessing            = "processing"
+	IntentStatusSucceeded             = "succeeded"
+	IntentStatusCanceled              = "canceled"
+)
+
+// Webhook event types understood by the payment service
+const (
+	EventPaymentSucceeded         = "payment.succeeded"
+	EventPaymentFailed            = "payment.failed"
+	EventPaymentCanceled          = "payment.canceled"
+	EventPaymentRefunded          = "payment.refunded"
+	EventPaymentPartiallyRefunded = "payment.partially_refunded"
+)
+
+// PaymentIntent is a provider-neutral view of a payment intent
+type PaymentIntent struct {
+	ID            string
+	ClientSecret  string
+	Status        string
+	Amount        int64
+	Currency      string
+	PaymentMethod string
+	// DeclineCode is set when the last payment attempt was declined
+	DeclineCode string
+	Metadata    map[string]string
+}
+
+// CreateIntentParams describes a new payment intent; Amount is in the
+// smallest currency unit
+type CreateIntentParams struct {
+	Amount   int64
+	Currency string
+	Metadata map[string]string
+}
+
+// Refund is a provider-neutral view of a refund
+type Refund struct {
+	ID     string
+	Status string
+	Amount int64
+}
+
+// RefundParams describes a refund against a payment intent; Amount is in
+// the smallest currency unit
+type RefundParams struct {
+	PaymentIntentID string
+	Amount          int64
+	Reason          string
+	Metadata        map[string]string
+}
+
+// WebhookEvent is a verified webhook normalised to the events we act on.
+// Type is empty for events the payment service ignores.
+type WebhookEvent struct {
+	ID              string
+	Type            string
+	PaymentIntentID string
+}
+
+// PaymentProvider is implemented by each payment gateway
+type PaymentProvider interface {
+	CreateIntent(params CreateIntentParams) (*PaymentIntent, error)
+	GetIntent(id string) (*PaymentIntent, error)
+	CancelIntent(id string) (*PaymentIntent, error)
+	Refund(params RefundParams) (*Refund, error)
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
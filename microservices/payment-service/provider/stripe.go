@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/paymentintent"
+	"github.com/stripe/stripe-go/v76/refund"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+// Stripe implements PaymentProvider against the Stripe API. Each instance
+// carries its own key rather than relying on the stripe.Key global.
+type Stripe struct {
+	intents       *paymentintent.Client
+	refunds       *refund.Client
+	webhookSecret string
+}
+
+// NewStripe creates a Stripe provider for the given secret key and webhook
+// signing secret
+func NewStripe(secretKey string, webhookSecret string) *Stripe {
+	backend := stripe.GetBackend(stripe.APIBackend)
+	return &Stripe{
+		intents:       &paymentintent.Client{B: backend, Key: secretKey},
+		refunds:       &refund.Client{B: backend, Key: secretKey},
+		webhookSecret: webhookSecret,
+	}
+}
+
+// CreateIntent creates a Stripe payment intent
+func (s *Stripe) CreateIntent(params CreateIntentParams) (*PaymentIntent, error) {
+	pi, err := s.intents.New(&stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(params.Amount),
+		Currency: stripe.String(params.Currency),
+		Metadata: params.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromStripeIntent(pi), nil
+}
+
+// GetIntent retrieves a Stripe payment intent with its payment method
+func (s *Stripe) GetIntent(id string) (*PaymentIntent, error) {
+	params := &stripe.PaymentIntentParams{}
+	params.AddExpand("payment_method")
+
+	pi, err := s.intents.Get(id, params)
+	if err != nil {
+		if stripeErr, ok := err.(*stripe.Error); ok && stripeErr.HTTPStatusCode == 404 {
+			return nil, ErrIntentNotFound
+		}
+		return nil, err
+	}
+	return fromStripeIntent(pi), nil
+}
+
+// CancelIntent cancels a Stripe payment intent
+func (s *Stripe) CancelIntent(id string) (*PaymentIntent, error) {
+	pi, err := s.intents.Cancel(id, nil)
+	if err != nil {
+		return nil, err
+	}
+	return fromStripeIntent(pi), nil
+}
+
+// Refund refunds all or part of a Stripe payment intent
+func (s *Stripe) Refund(params RefundParams) (*Refund, error) {
+	rp := &stripe.RefundParams{
+		PaymentIntent: stripe.String(params.PaymentIntentID),
+		Amount:        stripe.Int64(params.Amount),
+		Metadata:      params.Metadata,
+	}
+	if params.Reason != "" {
+		rp.Reason = stripe.String(params.Reason)
+	}
+
+	re, err := s.refunds.New(rp)
+	if err != nil {
+		return nil, err
+	}
+	return &Refund{ID: re.ID, Status: string(re.Status), Amount: re.Amount}, nil
+}
+
+// VerifyWebhook checks the Stripe-Signature header and maps the event onto
+// the payment events we handle
+func (s *Stripe) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if s.webhookSecret == "" {
+		return nil, ErrWebhookNotConfigured
+	}
+
+	event, err := webhook.ConstructEventWithOptions(payload, signature, s.webhookSecret,
+		webhook.ConstructEventOptions{IgnoreAPIVersionMismatch: true})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	result := &WebhookEvent{ID: event.ID}
+	switch event.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed", "payment_intent.canceled":
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return nil, err
+		}
+		result.PaymentIntentID = pi.ID
+		switch event.Type {
+		case "payment_intent.succeeded":
+			result.Type = EventPaymentSucceeded
+		case "payment_intent.payment_failed":
+			result.Type = EventPaymentFailed
+		default:
+			result.Type = EventPaymentCanceled
+		}
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return nil, err
+		}
+		if charge.PaymentIntent == nil || charge.AmountRefunded == 0 {
+			break
+		}
+		result.PaymentIntentID = charge.PaymentIntent.ID
+		result.Type = EventPaymentPartiallyRefunded
+		if charge.Refunded {
+			result.Type = EventPaymentRefunded
+		}
+	}
+
+	return result, nil
+}
+
+// fromStripeIntent converts a Stripe payment intent to the neutral type
+func fromStripeIntent(pi *stripe.PaymentIntent) *PaymentIntent {
+	intent := &PaymentIntent{
+		ID:           pi.ID,
+		ClientSecret: pi.ClientSecret,
+		Status:       string(pi.Status),
+		Amount:       pi.Amount,
+		Currency:     string(pi.Currency),
+		Metadata:     pi.Metadata,
+	}
+	if pi.PaymentMethod != nil {
+		intent.PaymentMethod = string(pi.PaymentMethod.Type)
+	}
+	if pi.LastPaymentError != nil {
+		intent.DeclineCode = string(pi.LastPaymentError.DeclineCode)
+		if intent.DeclineCode == "" {
+			intent.DeclineCode = string(pi.LastPaymentError.Code)
+		}
+	}
+	return intent
+}
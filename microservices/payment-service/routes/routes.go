@@ -7,17 +7,20 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes configures the payment service routes
-func SetupRoutes(router *gin.Engine, paymentController *controller.PaymentController) {
-	// Health check
-	router.GET("/health", paymentController.HealthCheck)
-
+// SetupRoutes configures the payment service routes; idempotency guards
+// payment creation and refunds against duplicate retries
+func SetupRoutes(router *gin.Engine, paymentController *controller.PaymentController, idempotency gin.HandlerFunc) {
 	// Payment routes
 	paymentRoutes := router.Group("/payments")
 	{
-		paymentRoutes.POST("/", middleware.RequireAuth(), paymentController.CreatePayment)            // Create payment intent
+		paymentRoutes.POST("/", middleware.RequireAuth(), idempotency, paymentController.CreatePayment) // Create payment intent
 		paymentRoutes.POST("/confirm", middleware.RequireAuth(), paymentController.ConfirmPayment)    // Confirm payment
 		paymentRoutes.GET("/:id", middleware.RequireAuth(), paymentController.GetPayment)            // Get payment by ID
 		paymentRoutes.GET("/order/:orderId", middleware.RequireAuth(), paymentController.GetPaymentsByOrder) // Get payments by order ID
+		paymentRoutes.POST("/:id/refunds", middleware.RequireAuth(), idempotency, paymentController.CreateRefund) // Refund payment (admin or system)
+		paymentRoutes.POST("/:id/cancel", middleware.RequireAuth(), paymentController.CancelPayment)         // Cancel unpaid payment intent
+
+		// Stripe webhooks authenticate with the Stripe-Signature header instead of X-User-Id
+		paymentRoutes.POST("/webhook", paymentController.HandleStripeWebhook)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,151 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrIncompatibleSchema is returned when a new version of an event schema
+// would break producers or consumers of the previous one
+var ErrIncompatibleSchema = errors.New("incompatible schema")
+
+// CheckCompatibility reports whether next can replace previous as the schema
+// of an event while services on either version keep talking to each other:
+// every event valid under previous must stay valid under next, and the other
+// way round. In practice a new version may only add optional properties to
+// objects that allow them; anything else needs a new event type.
+func CheckCompatibility(previous, next []byte) error {
+	var previousDocument, nextDocument map[string]interface{}
+	if err := json.Unmarshal(previous, &previousDocument); err != nil {
+		return fmt.Errorf("invalid previous schema: %w", err)
+	}
+	if err := json.Unmarshal(next, &nextDocument); err != nil {
+		return fmt.Errorf("invalid next schema: %w", err)
+	}
+	return checkCompatibility(previousDocument, nextDocument)
+}
+
+// checkCompatibility compares two decoded schemas, listing every breaking
+// change it finds
+func checkCompatibility(previous, next map[string]interface{}) error {
+	var problems []string
+	compareSchemas("", previous, next, &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrIncompatibleSchema, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// compareSchemas records the breaking changes from previous to next at the
+// schema location at
+func compareSchemas(at string, previous, next map[string]interface{}, problems *[]string) {
+	location := at
+	if location == "" {
+		location = "/"
+	}
+	report := func(format string, args ...interface{}) {
+		*problems = append(*problems, location+": "+fmt.Sprintf(format, args...))
+	}
+
+	if !reflect.DeepEqual(stringSet(previous["type"]), stringSet(next["type"])) {
+		report("type changed from %v to %v", previous["type"], next["type"])
+	}
+	if !reflect.DeepEqual(valueSet(previous["enum"]), valueSet(next["enum"])) {
+		report("allowed values changed from %v to %v", previous["enum"], next["enum"])
+	}
+
+	previousRequired, nextRequired := stringSet(previous["required"]), stringSet(next["required"])
+	for _, name := range nextRequired {
+		if !contains(previousRequired, name) {
+			report("property %q became required", name)
+		}
+	}
+	for _, name := range previousRequired {
+		if !contains(nextRequired, name) {
+			report("property %q is no longer required", name)
+		}
+	}
+
+	previousOpen, nextOpen := previous["additionalProperties"] != false, next["additionalProperties"] != false
+	if previousOpen && !nextOpen {
+		report("additional properties are no longer allowed")
+	}
+
+	previousProperties, _ := previous["properties"].(map[string]interface{})
+	nextProperties, _ := next["properties"].(map[string]interface{})
+	for _, name := range sortedKeys(previousProperties) {
+		nextProperty, ok := nextProperties[name].(map[string]interface{})
+		if !ok {
+			report("property %q was removed", name)
+			continue
+		}
+		previousProperty, _ := previousProperties[name].(map[string]interface{})
+		compareSchemas(at+"/properties/"+name, previousProperty, nextProperty, problems)
+	}
+	for _, name := range sortedKeys(nextProperties) {
+		if _, ok := previousProperties[name]; !ok && !previousOpen {
+			report("property %q was added where additional properties are not allowed", name)
+		}
+	}
+
+	previousItems, previousHasItems := previous["items"].(map[string]interface{})
+	nextItems, nextHasItems := next["items"].(map[string]interface{})
+	switch {
+	case previousHasItems && nextHasItems:
+		compareSchemas(at+"/items", previousItems, nextItems, problems)
+	case previousHasItems != nextHasItems:
+		report("array items schema was added or removed")
+	}
+}
+
+// stringSet returns a keyword that is a string or list of strings as a
+// sorted list
+func stringSet(value interface{}) []string {
+	var set []string
+	switch v := value.(type) {
+	case string:
+		set = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				set = append(set, s)
+			}
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+// valueSet returns the members of a list keyword in a canonical order
+func valueSet(value interface{}) []string {
+	items, _ := value.([]interface{})
+	set := make([]string, 0, len(items))
+	for _, item := range items {
+		encoded, _ := json.Marshal(item)
+		set = append(set, string(encoded))
+	}
+	sort.Strings(set)
+	return set
+}
+
+func contains(set []string, name string) bool {
+	for _, s := range set {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
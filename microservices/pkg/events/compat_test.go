@@ -0,0 +1,71 @@
+package events
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const statusV1 = `{
+	"type": "object",
+	"required": ["order_id", "status"],
+	"properties": {
+		"order_id": {"type": "integer"},
+		"status": {"type": "string"},
+		"items": {"type": "array", "items": {"type": "object", "properties": {"quantity": {"type": "integer"}}}}
+	}
+}`
+
+func TestCheckCompatibilityAllowsNewOptionalProperties(t *testing.T) {
+	next := strings.Replace(statusV1, `"status": {"type": "string"},`, `"status": {"type": "string"}, "note": {"type": "string"},`, 1)
+
+	if err := CheckCompatibility([]byte(statusV1), []byte(next)); err != nil {
+		t.Fatalf("got %v, want compatible", err)
+	}
+}
+
+func TestCheckCompatibilityRejectsBreakingChanges(t *testing.T) {
+	for name, next := range map[string]string{
+		"new required property": strings.Replace(statusV1, `["order_id", "status"]`, `["order_id", "status", "items"]`, 1),
+		"dropped requirement":   strings.Replace(statusV1, `["order_id", "status"]`, `["order_id"]`, 1),
+		"removed property":      strings.Replace(statusV1, `"status": {"type": "string"},`, ``, 1),
+		"changed type":          strings.Replace(statusV1, `"order_id": {"type": "integer"}`, `"order_id": {"type": "string"}`, 1),
+		"nested type change":    strings.Replace(statusV1, `"quantity": {"type": "integer"}`, `"quantity": {"type": "number"}`, 1),
+		"closed object":         strings.Replace(statusV1, `"type": "object",`, `"type": "object", "additionalProperties": false,`, 1),
+		"narrowed values":       strings.Replace(statusV1, `"status": {"type": "string"}`, `"status": {"type": "string", "enum": ["paid"]}`, 1),
+	} {
+		if err := CheckCompatibility([]byte(statusV1), []byte(next)); !errors.Is(err, ErrIncompatibleSchema) {
+			t.Errorf("%s: got %v, want ErrIncompatibleSchema", name, err)
+		}
+	}
+}
+
+func TestRegisterEnforcesVersionOrderAndCompatibility(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.Register("order.status_changed", 2, []byte(statusV1)); err == nil {
+		t.Fatal("registered v2 before v1")
+	}
+	if err := registry.Register("order.status_changed", 1, []byte(statusV1)); err != nil {
+		t.Fatalf("Register v1: %v", err)
+	}
+
+	breaking := strings.Replace(statusV1, `["order_id", "status"]`, `["order_id", "status", "items"]`, 1)
+	if err := registry.Register("order.status_changed", 2, []byte(breaking)); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("got %v, want ErrIncompatibleSchema", err)
+	}
+	if version, _ := registry.Latest("order.status_changed"); version != 1 {
+		t.Fatalf("Latest = %d after a rejected version, want 1", version)
+	}
+
+	// Consumers still on v1 validate against v1 while producers move on
+	compatible := strings.Replace(statusV1, `"status": {"type": "string"},`, `"status": {"type": "string"}, "note": {"type": "string"},`, 1)
+	if err := registry.Register("order.status_changed", 2, []byte(compatible)); err != nil {
+		t.Fatalf("Register v2: %v", err)
+	}
+	payload := []byte(`{"order_id": 1, "status": "paid", "note": "gift"}`)
+	for version := 1; version <= 2; version++ {
+		if err := registry.Validate("order.status_changed", version, payload); err != nil {
+			t.Errorf("Validate v%d: %v", version, err)
+		}
+	}
+}
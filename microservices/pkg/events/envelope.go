@@ -0,0 +1,104 @@
+// Package events defines the envelope every message between services is
+// wrapped in, the typed payloads of those messages and the JSON Schemas they
+// are validated against.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrMalformedEnvelope is returned when a message isn't a valid envelope
+var ErrMalformedEnvelope = errors.New("malformed event envelope")
+
+// Envelope carries one event with the metadata consumers need to route,
+// deduplicate and trace it. Payload is validated against the JSON Schema
+// registered for Type at Version.
+type Envelope struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	Source        string          `json:"source"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// New wraps payload in an envelope for the latest version of eventType
+// registered in the default registry
+func New(source, eventType string, payload interface{}) (*Envelope, error) {
+	return Default.New(source, eventType, payload)
+}
+
+// Parse decodes a message into an envelope, checking its payload against
+// the default registry
+func Parse(body []byte) (*Envelope, error) {
+	return Default.Parse(body)
+}
+
+// New wraps payload in an envelope for the latest registered version of
+// eventType. The payload must validate against that version's schema.
+func (r *Registry) New(source, eventType string, payload interface{}) (*Envelope, error) {
+	version, ok := r.Latest(eventType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, eventType)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+	}
+	if err := r.Validate(eventType, version, body); err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		ID:         newEventID(),
+		Type:       eventType,
+		Version:    version,
+		Source:     source,
+		OccurredAt: time.Now().UTC(),
+		Payload:    body,
+	}, nil
+}
+
+// Parse decodes a message into an envelope. The envelope must name its
+// event and the payload must validate against the schema of that version.
+func (r *Registry) Parse(body []byte) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+	if envelope.ID == "" || envelope.Type == "" || envelope.Version < 1 || len(envelope.Payload) == 0 {
+		return nil, fmt.Errorf("%w: id, type, version and payload are required", ErrMalformedEnvelope)
+	}
+	if err := r.Validate(envelope.Type, envelope.Version, envelope.Payload); err != nil {
+		return nil, err
+	}
+	return &envelope, nil
+}
+
+// Decode unmarshals the payload into v
+func (e *Envelope) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("failed to decode %s payload: %w", e.Type, err)
+	}
+	return nil
+}
+
+// newEventID returns a random UUID (version 4)
+func newEventID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	s := hex.EncodeToString(id)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
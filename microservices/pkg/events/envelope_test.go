@@ -0,0 +1,70 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestNewAndParseRoundTrip(t *testing.T) {
+	envelope, err := New("order-service", OrderStatusChanged, StatusChange{
+		OrderID: 42, CustomerID: 7, ReservationID: 9, PreviousStatus: "pending", Status: "paid",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if envelope.ID == "" || envelope.Version != 1 || envelope.Source != "order-service" || envelope.OccurredAt.IsZero() {
+		t.Fatalf("envelope metadata not set: %+v", envelope)
+	}
+
+	body, _ := json.Marshal(envelope)
+	parsed, err := Parse(body)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var change StatusChange
+	if err := parsed.Decode(&change); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if parsed.ID != envelope.ID || change.OrderID != 42 || change.ReservationID != 9 || change.Status != "paid" {
+		t.Fatalf("got %+v with %+v", parsed, change)
+	}
+}
+
+func TestNewRejectsPayloadsThatBreakTheSchema(t *testing.T) {
+	_, err := New("order-service", OrderStatusChanged, map[string]interface{}{"order_id": "42", "status": "paid"})
+	if !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("got %v, want ErrInvalidPayload", err)
+	}
+
+	_, err = New("order-service", "order.shipped", StatusChange{OrderID: 42})
+	if !errors.Is(err, ErrUnknownEvent) {
+		t.Fatalf("got %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestParseRejectsBareAndUnknownMessages(t *testing.T) {
+	for name, body := range map[string]string{
+		"not json":     `{`,
+		"bare payload": `{"order_id":42,"status":"paid"}`,
+	} {
+		if _, err := Parse([]byte(body)); !errors.Is(err, ErrMalformedEnvelope) {
+			t.Errorf("%s: got %v, want ErrMalformedEnvelope", name, err)
+		}
+	}
+
+	body := `{"id":"1","type":"order.status_changed","version":2,"payload":{"order_id":42}}`
+	if _, err := Parse([]byte(body)); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("unknown version: got %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestEmbeddedSchemasCoverOrderEvents(t *testing.T) {
+	for _, eventType := range []string{
+		OrderCreated, OrderUpdated, OrderStatusChanged, OrderCancelled, OrderDeleted, OrderReturnStatusChanged,
+	} {
+		if _, ok := Default.Latest(eventType); !ok {
+			t.Errorf("no schema registered for %s", eventType)
+		}
+	}
+}
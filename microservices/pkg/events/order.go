@@ -0,0 +1,57 @@
+package events
+
+import "time"
+
+// Order event types, published by order-service on the orders exchange with
+// the type as routing key
+const (
+	OrderCreated             = "order.created"
+	OrderUpdated             = "order.updated"
+	OrderStatusChanged       = "order.status_changed"
+	OrderCancelled           = "order.cancelled"
+	OrderDeleted             = "order.deleted"
+	OrderReturnStatusChanged = "order.return_status_changed"
+)
+
+// Order is the payload of order.created and order.updated events. Producers
+// may send the full order; these are the fields every version carries.
+type Order struct {
+	ID            int         `json:"id"`
+	CustomerID    int         `json:"customer_id"`
+	Items         []OrderItem `json:"items"`
+	TotalPrice    float64     `json:"total_price"`
+	ReservationID int         `json:"reservation_id,omitempty"`
+	Status        string      `json:"status"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// OrderItem is one line of an Order
+type OrderItem struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// StatusChange is the payload of order.status_changed and order.cancelled
+// events
+type StatusChange struct {
+	OrderID        int    `json:"order_id"`
+	CustomerID     int    `json:"customer_id"`
+	ReservationID  int    `json:"reservation_id,omitempty"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+}
+
+// OrderRemoval is the payload of order.deleted events
+type OrderRemoval struct {
+	OrderID    int `json:"order_id"`
+	CustomerID int `json:"customer_id"`
+}
+
+// ReturnStatusChange is the payload of order.return_status_changed events
+type ReturnStatusChange struct {
+	ReturnID       int    `json:"return_id"`
+	OrderID        int    `json:"order_id"`
+	CustomerID     int    `json:"customer_id"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+}
@@ -0,0 +1,172 @@
+package events
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var (
+	// ErrUnknownEvent is returned for an event type or version without a
+	// registered schema
+	ErrUnknownEvent = errors.New("unknown event")
+
+	// ErrInvalidPayload is returned when a payload doesn't match its schema
+	ErrInvalidPayload = errors.New("invalid event payload")
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// Default holds the schemas of every event exchanged between the services
+var Default = mustLoad(schemaFiles, "schemas")
+
+// schemaFileName matches schema files named <event type>.v<version>.json
+var schemaFileName = regexp.MustCompile(`^(.+)\.v([0-9]+)\.json$`)
+
+// Registry maps each event type and version to the JSON Schema its payload
+// must satisfy. Versions of a type are registered in order, and each must
+// be compatible with the one before it.
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[string][]*schema
+}
+
+// schema is one registered version of an event's payload schema
+type schema struct {
+	document map[string]interface{}
+	compiled *jsonschema.Schema
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{schemas: make(map[string][]*schema)}
+}
+
+// Register adds the next version of eventType. Version 1 starts a type; any
+// later version must directly follow the latest one and pass
+// CheckCompatibility against it.
+func (r *Registry) Register(eventType string, version int, source []byte) error {
+	var document map[string]interface{}
+	if err := json.Unmarshal(source, &document); err != nil {
+		return fmt.Errorf("invalid schema for %s v%d: %w", eventType, version, err)
+	}
+
+	url := fmt.Sprintf("%s.v%d.json", eventType, version)
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(source)); err != nil {
+		return fmt.Errorf("invalid schema for %s v%d: %w", eventType, version, err)
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return fmt.Errorf("invalid schema for %s v%d: %w", eventType, version, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions := r.schemas[eventType]
+	if version != len(versions)+1 {
+		return fmt.Errorf("cannot register %s v%d: the next version is v%d", eventType, version, len(versions)+1)
+	}
+	if len(versions) > 0 {
+		if err := checkCompatibility(versions[len(versions)-1].document, document); err != nil {
+			return fmt.Errorf("%s v%d: %w", eventType, version, err)
+		}
+	}
+
+	r.schemas[eventType] = append(versions, &schema{document: document, compiled: compiled})
+	return nil
+}
+
+// Latest returns the newest registered version of eventType
+func (r *Registry) Latest(eventType string) (int, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := len(r.schemas[eventType])
+	return versions, versions > 0
+}
+
+// Validate checks a payload against the schema of eventType at version
+func (r *Registry) Validate(eventType string, version int, payload []byte) error {
+	r.mu.RLock()
+	versions := r.schemas[eventType]
+	r.mu.RUnlock()
+	if version < 1 || version > len(versions) {
+		return fmt.Errorf("%w: %s v%d", ErrUnknownEvent, eventType, version)
+	}
+
+	// Numbers are kept as json.Number so large integers validate exactly
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("%w: %s v%d: %v", ErrInvalidPayload, eventType, version, err)
+	}
+	if err := versions[version-1].compiled.Validate(value); err != nil {
+		return fmt.Errorf("%w: %s v%d: %v", ErrInvalidPayload, eventType, version, err)
+	}
+	return nil
+}
+
+// Load registers every <event type>.v<version>.json file in dir of fsys,
+// each type's versions in ascending order
+func (r *Registry) Load(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	type schemaFile struct {
+		name      string
+		eventType string
+		version   int
+	}
+	var files []schemaFile
+	for _, entry := range entries {
+		match := schemaFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[2])
+		files = append(files, schemaFile{name: entry.Name(), eventType: match[1], version: version})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].eventType != files[j].eventType {
+			return files[i].eventType < files[j].eventType
+		}
+		return files[i].version < files[j].version
+	})
+
+	for _, file := range files {
+		source, err := fs.ReadFile(fsys, path.Join(dir, file.name))
+		if err != nil {
+			return err
+		}
+		if err := r.Register(file.eventType, file.version, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mustLoad builds a registry from schema files that ship with the binary,
+// where a broken or incompatible schema is a programming error
+func mustLoad(fsys fs.FS, dir string) *Registry {
+	registry := NewRegistry()
+	if err := registry.Load(fsys, dir); err != nil {
+		panic(fmt.Sprintf("events: %v", err))
+	}
+	return registry
+}
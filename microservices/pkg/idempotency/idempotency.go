@@ -0,0 +1,186 @@
+// Package idempotency lets clients retry non-idempotent requests safely: a
+// request sent again with the same Idempotency-Key gets the first response
+// instead of running twice. Keys live in Postgres, or in Redis for services
+// that have it.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header is the request header carrying the client's idempotency key
+const Header = "Idempotency-Key"
+
+const (
+	defaultTTL = 24 * time.Hour
+	maxKeyLen  = 255
+	// lease is how long a request holds its key while it runs. A key that
+	// is neither completed nor released by then, because the process
+	// crashed, can be taken over by a retry.
+	lease = time.Minute
+)
+
+// Record is what is stored for a key: the hash of the request that
+// first used it and, once that request finished, its response
+type Record struct {
+	RequestHash string `json:"request_hash"`
+	Completed   bool   `json:"completed"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// Store persists idempotency keys
+type Store interface {
+	// Reserve claims key for a new request for the given lease, taking over
+	// a key whose lease has run out before it was completed. When the key is
+	// already taken it returns the existing record and false.
+	Reserve(ctx context.Context, key string, requestHash string, lease time.Duration) (*Record, bool, error)
+	// Complete stores the response for a reserved key
+	Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error
+	// Release frees a reserved key so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// TTL returns how long completed responses are kept, from
+// IDEMPOTENCY_KEY_TTL (default 24h)
+func TTL() time.Duration {
+	if v := os.Getenv("IDEMPOTENCY_KEY_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultTTL
+}
+
+// NewStore selects the store named by IDEMPOTENCY_STORE: "redis" keeps keys
+// in cache, anything else in the service's database. Services without Redis
+// pass a nil cache and always use the database.
+func NewStore(db *sql.DB, cache Cache) Store {
+	if cache != nil && os.Getenv("IDEMPOTENCY_STORE") == "redis" {
+		return &RedisStore{Cache: cache}
+	}
+	return &PostgresStore{DB: db}
+}
+
+// Middleware makes a handler safe to retry. Requests carrying an
+// Idempotency-Key replay the stored response when the same request is sent
+// again, and get 409 when the key is reused for a different request or
+// while the first one is still running. Requests without a key pass through.
+// A store that fails or doesn't answer before the request's context is done
+// gets 503. A handler that panics releases its key before the panic carries
+// on, and a key left behind by a crash is free again after its lease.
+func Middleware(store Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped per user so one user can never replay another's response
+		storeKey := c.GetHeader("X-User-Id") + ":" + key
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.Path, body)
+
+		existing, reserved, err := store.Reserve(c.Request.Context(), storeKey, requestHash, lease)
+		if err != nil {
+			log.Printf("Idempotency store unavailable: %v\n", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Idempotency store unavailable"})
+			return
+		}
+		if !reserved {
+			switch {
+			case existing.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case !existing.Completed:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				if err := store.Release(context.WithoutCancel(c.Request.Context()), storeKey); err != nil {
+					log.Printf("Failed to release idempotency key: %v\n", err)
+				}
+				panic(r)
+			}
+		}()
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// The handler has run, so settle the key even if the client has gone
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		// Server errors are not remembered so the client can retry them
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := store.Release(ctx, storeKey); err != nil {
+				log.Printf("Failed to release idempotency key: %v\n", err)
+			}
+			return
+		}
+
+		err = store.Complete(ctx, storeKey, &Record{
+			RequestHash: requestHash,
+			Completed:   true,
+			StatusCode:  writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}, ttl)
+		if err != nil {
+			log.Printf("Failed to store idempotent response: %v\n", err)
+		}
+	}
+}
+
+// hashRequest fingerprints a request by method, path and body
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// capturingWriter keeps a copy of the response body
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
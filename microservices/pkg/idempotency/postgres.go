@@ -0,0 +1,88 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// PostgresStore implements Store using the service's idempotency_keys table
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+// staleKey matches keys that have expired or whose request lost its lease
+// before completing
+const staleKey = "(expires_at <= $2 OR (completed = FALSE AND locked_until <= $2))"
+
+// Reserve inserts the key unless a live row already holds it. The row stays
+// locked until the lease runs out and, until it is completed, expires then.
+func (s *PostgresStore) Reserve(ctx context.Context, key string, requestHash string, lease time.Duration) (*Record, bool, error) {
+	now := time.Now()
+	if _, err := s.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND "+staleKey, key, now); err != nil {
+		return nil, false, err
+	}
+
+	result, err := s.DB.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, request_hash, completed, created_at, locked_until, expires_at)
+		VALUES ($1, $2, FALSE, $3, $4, $4)
+		ON CONFLICT (key) DO NOTHING`,
+		key, requestHash, now, now.Add(lease))
+	if err != nil {
+		return nil, false, err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	var record Record
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT request_hash, completed, response_code, content_type, response_body
+		FROM idempotency_keys WHERE key = $1`, key).Scan(
+		&record.RequestHash, &record.Completed, &record.StatusCode, &record.ContentType, &record.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The holder released the key in the meantime; try again
+		return s.Reserve(ctx, key, requestHash, lease)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &record, false, nil
+}
+
+// Complete stores the response for the key and keeps it for ttl
+func (s *PostgresStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET completed = TRUE, response_code = $1, content_type = $2, response_body = $3, locked_until = NULL, expires_at = $4
+		WHERE key = $5`,
+		record.StatusCode, record.ContentType, record.Body, time.Now().Add(ttl), key)
+	return err
+}
+
+// Release deletes the key
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1", key)
+	return err
+}
+
+// PurgeExpired removes expired keys and keys abandoned by their request
+func (s *PostgresStore) PurgeExpired() (int64, error) {
+	result, err := s.DB.Exec(`DELETE FROM idempotency_keys
+		WHERE expires_at <= $1 OR (completed = FALSE AND locked_until <= $1)`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeEvery removes expired keys every interval, for as long as the service runs
+func (s *PostgresStore) PurgeEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := s.PurgeExpired(); err != nil {
+			log.Printf("Warning: Failed to purge idempotency keys: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// Cache is the Redis-backed key-value store RedisStore keeps keys in. Values
+// are encoded by the cache, and SetNX only sets a key that doesn't exist.
+type Cache interface {
+	Get(ctx context.Context, key string, value interface{}) error
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// RedisStore implements Store using a Redis cache; Redis expiry takes care
+// of old keys
+type RedisStore struct {
+	Cache Cache
+}
+
+// Reserve claims the key with SET NX. The claim expires with its lease, after
+// which a retry can take the key over.
+func (s *RedisStore) Reserve(ctx context.Context, key string, requestHash string, lease time.Duration) (*Record, bool, error) {
+	redisKey := "idempotency:" + key
+	reserved, err := s.Cache.SetNX(ctx, redisKey, Record{RequestHash: requestHash}, lease)
+	if err != nil {
+		return nil, false, err
+	}
+	if reserved {
+		return nil, true, nil
+	}
+
+	var record Record
+	if err := s.Cache.Get(ctx, redisKey, &record); err != nil {
+		return nil, false, err
+	}
+	return &record, false, nil
+}
+
+// Complete stores the response for the key and keeps it for ttl
+func (s *RedisStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	return s.Cache.Set(ctx, "idempotency:"+key, record, ttl)
+}
+
+// Release deletes the key
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.Cache.Delete(ctx, "idempotency:"+key)
+}
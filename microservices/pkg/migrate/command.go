@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Usage describes the arguments Command accepts
+const Usage = "migrate [up | down [steps] | status]"
+
+// Command runs the migrate subcommand described by args: up (the default)
+// applies pending migrations, down rolls back the last steps (default 1)
+// and status lists every migration. Results are written to out.
+func Command(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	action := "up"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	switch {
+	case action == "up" && len(args) == 0:
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Applied %d migration(s); schema is at version %d\n", applied, m.Latest())
+		return nil
+
+	case action == "down" && len(args) <= 1:
+		steps := 1
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q; usage: %s", args[0], Usage)
+			}
+			steps = n
+		}
+		rolledBack, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Rolled back %d migration(s)\n", rolledBack)
+		return nil
+
+	case action == "status" && len(args) == 0:
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("usage: %s", Usage)
+	}
+}
@@ -0,0 +1,157 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"migrations/0001_create_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id SERIAL PRIMARY KEY);")},
+		"migrations/0001_create_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+		"migrations/0002_add_name.up.sql":         {Data: []byte("ALTER TABLE widgets ADD COLUMN name TEXT;")},
+		"migrations/0002_add_name.down.sql":       {Data: []byte("-- Nothing to undo\n")},
+	}
+}
+
+func TestLoadOrdersAndValidatesMigrations(t *testing.T) {
+	migrations, err := Load(testMigrations(), "migrations")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "create_widgets" || migrations[1].Version != 2 {
+		t.Fatalf("unexpected migrations %+v", migrations)
+	}
+
+	for name, change := range map[string]func(fstest.MapFS){
+		"missing down": func(fsys fstest.MapFS) { delete(fsys, "migrations/0002_add_name.down.sql") },
+		"gap": func(fsys fstest.MapFS) {
+			fsys["migrations/0004_skip.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+		},
+		"stray file": func(fsys fstest.MapFS) { fsys["migrations/README.md"] = &fstest.MapFile{} },
+		"renamed half": func(fsys fstest.MapFS) {
+			fsys["migrations/0002_add_label.down.sql"] = fsys["migrations/0002_add_name.down.sql"]
+			delete(fsys, "migrations/0002_add_name.down.sql")
+		},
+	} {
+		fsys := testMigrations()
+		change(fsys)
+		if _, err := Load(fsys, "migrations"); !errors.Is(err, ErrInvalidMigration) {
+			t.Errorf("%s: got %v, want ErrInvalidMigration", name, err)
+		}
+	}
+}
+
+// newMigrator returns a migrator over the test migrations whose database
+// reports applied as already applied
+func newMigrator(t *testing.T, applied ...int) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock db: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	migrations, err := Load(testMigrations(), "migrations")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_version`).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, version := range applied {
+		rows.AddRow(version, time.Date(2026, 1, version, 0, 0, 0, 0, time.UTC))
+	}
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_version`).WillReturnRows(rows)
+	return New(database, migrations), mock
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestUpAppliesPendingMigrationsUnderLock(t *testing.T) {
+	migrator, mock := newMigrator(t, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE widgets ADD COLUMN name TEXT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_version`).WithArgs(2, "add_name").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil || applied != 1 {
+		t.Fatalf("Up = %d, %v; want 1 applied", applied, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpStopsAtFailingMigration(t *testing.T) {
+	migrator, mock := newMigrator(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE widgets`).WillReturnError(errors.New("permission denied"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	applied, err := migrator.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "0001_create_widgets") || applied != 0 {
+		t.Fatalf("Up = %d, %v; want migration 1 to fail", applied, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDownRollsBackNewestFirst(t *testing.T) {
+	migrator, mock := newMigrator(t, 1, 2)
+	// Version 2's down file only has a comment, so only its row is removed
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM schema_version WHERE version = \$1`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	rolledBack, err := migrator.Down(context.Background(), 1)
+	if err != nil || rolledBack != 1 {
+		t.Fatalf("Down = %d, %v; want 1 rolled back", rolledBack, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDownRefusesUnknownVersions(t *testing.T) {
+	migrator, mock := newMigrator(t, 1, 2, 3)
+	expectUnlock(mock)
+
+	if _, err := migrator.Down(context.Background(), 1); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("got %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestCommandStatus(t *testing.T) {
+	migrator, mock := newMigrator(t, 1)
+	expectUnlock(mock)
+
+	var out bytes.Buffer
+	if err := Command(context.Background(), migrator, []string{"status"}, &out); err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+	want := "0001_create_widgets\tapplied 2026-01-01 00:00:00\n0002_add_name\tpending\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+
+	for _, args := range [][]string{{"sideways"}, {"down", "zero"}, {"up", "1"}} {
+		if err := Command(context.Background(), migrator, args, &out); err == nil {
+			t.Errorf("Command(%v) succeeded, want a usage error", args)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package platform holds what every service needs to start: configuration
+// from the environment, the database pool, structured logging and an HTTP
+// server with request IDs, metrics, health and readiness endpoints and
+// graceful shutdown.
+package platform
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is how a service runs. LoadConfig fills it from the environment.
+type Config struct {
+	// Service names the service in logs
+	Service string
+	// Port is the HTTP port the service listens on
+	Port string
+	// DB is the service's database; services without one leave it empty
+	DB DBConfig
+	// MigrateOnStart applies pending schema migrations when the service
+	// starts; without it they are applied with the migrate subcommand
+	MigrateOnStart bool
+	// HTTP bounds how long the server waits on clients
+	HTTP HTTPConfig
+	// ShutdownTimeout is how long in-flight requests may take to finish once
+	// the service is asked to stop
+	ShutdownTimeout time.Duration
+	// ShutdownDelay is how long readiness fails before the server stops
+	// accepting connections, for load balancers to stop routing to it
+	ShutdownDelay time.Duration
+	// LogLevel is the least severe level logged
+	LogLevel slog.Level
+	// LogFormat is "json" (the default) or "text"
+	LogFormat string
+}
+
+// HTTPConfig holds the server timeouts
+type HTTPConfig struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
+// LoadConfig reads the configuration of service, which listens on port and
+// connects to database unless the environment says otherwise. PORT,
+// MIGRATE_ON_START (default true), SHUTDOWN_TIMEOUT (10s), SHUTDOWN_DELAY
+// (none), HTTP_READ_HEADER_TIMEOUT (5s), HTTP_READ_TIMEOUT (15s),
+// HTTP_WRITE_TIMEOUT (30s), HTTP_IDLE_TIMEOUT (60s), LOG_LEVEL (debug, info,
+// warn or error; default info) and LOG_FORMAT (json or text) are read here;
+// see DBConfigFromEnv for the database.
+func LoadConfig(service, port string, database DBConfig) Config {
+	config := Config{
+		Service:        service,
+		Port:           Getenv("PORT", port),
+		MigrateOnStart: Getenv("MIGRATE_ON_START", "true") == "true",
+		HTTP: HTTPConfig{
+			ReadHeaderTimeout: envDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+			ReadTimeout:       envDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:      envDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:       envDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+		},
+		ShutdownTimeout: envDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		ShutdownDelay:   envDuration("SHUTDOWN_DELAY", 0),
+		LogFormat:       strings.ToLower(Getenv("LOG_FORMAT", "json")),
+	}
+	if database != (DBConfig{}) {
+		config.DB = DBConfigFromEnv(database)
+	}
+	if err := config.LogLevel.UnmarshalText([]byte(Getenv("LOG_LEVEL", "info"))); err != nil {
+		config.LogLevel = slog.LevelInfo
+	}
+	return config
+}
+
+// Getenv returns the value of an environment variable or a fallback
+func Getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// envDuration reads a positive duration, ignoring values that don't parse
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
+
+// envInt reads a non-negative integer, ignoring values that don't parse
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,54 @@
+package platform
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigUsesServiceDefaults(t *testing.T) {
+	config := LoadConfig("product-service", "8080", DBConfig{Host: "product-db", Port: "5432", User: "postgres", Name: "products_db"})
+
+	if config.Port != "8080" || config.LogLevel != slog.LevelInfo || config.ShutdownTimeout != 10*time.Second || !config.MigrateOnStart {
+		t.Fatalf("unexpected defaults: %+v", config)
+	}
+	if config.DB.Host != "product-db" || config.DB.Name != "products_db" {
+		t.Fatalf("database defaults not kept: %+v", config.DB)
+	}
+	if config.DB.MaxOpenConns != defaultMaxOpenConns || config.DB.ConnMaxLifetime != defaultConnMaxLifetime {
+		t.Fatalf("pool defaults not applied: %+v", config.DB)
+	}
+}
+
+func TestLoadConfigReadsEnvironment(t *testing.T) {
+	t.Setenv("PORT", "9090")
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("DB_MAX_OPEN_CONNS", "5")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "1m")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "2m")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("MIGRATE_ON_START", "false")
+	// Values that don't parse keep the default
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+
+	config := LoadConfig("product-service", "8080", DBConfig{Host: "product-db", Name: "products_db"})
+
+	if config.Port != "9090" || config.HTTP.WriteTimeout != 2*time.Minute || config.LogLevel != slog.LevelDebug || config.MigrateOnStart {
+		t.Fatalf("environment not applied: %+v", config)
+	}
+	if config.ShutdownTimeout != 10*time.Second {
+		t.Fatalf("ShutdownTimeout = %v, want the default", config.ShutdownTimeout)
+	}
+	if config.DB.Host != "db.internal" || config.DB.MaxOpenConns != 5 || config.DB.ConnMaxLifetime != time.Minute {
+		t.Fatalf("database environment not applied: %+v", config.DB)
+	}
+}
+
+func TestLoadConfigWithoutDatabase(t *testing.T) {
+	t.Setenv("DB_HOST", "db.internal")
+
+	config := LoadConfig("api-gateway", "8000", DBConfig{})
+	if config.DB != (DBConfig{}) {
+		t.Fatalf("DB = %+v, want none for a service without a database", config.DB)
+	}
+}
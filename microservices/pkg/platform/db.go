@@ -0,0 +1,95 @@
+package platform
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Pool defaults, sized for a single replica sharing Postgres with the others
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+	connectTimeout         = 10 * time.Second
+)
+
+// DBConfig is a Postgres database and the pool kept to it
+type DBConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DBConfigFromEnv overrides defaults with DB_HOST, DB_PORT, DB_USER,
+// DB_PASSWORD and DB_NAME, and sizes the pool from DB_MAX_OPEN_CONNS
+// (default 25), DB_MAX_IDLE_CONNS (10), DB_CONN_MAX_LIFETIME (30m) and
+// DB_CONN_MAX_IDLE_TIME (5m)
+func DBConfigFromEnv(defaults DBConfig) DBConfig {
+	config := DBConfig{
+		Host:            Getenv("DB_HOST", defaults.Host),
+		Port:            Getenv("DB_PORT", defaults.Port),
+		User:            Getenv("DB_USER", defaults.User),
+		Password:        Getenv("DB_PASSWORD", defaults.Password),
+		Name:            Getenv("DB_NAME", defaults.Name),
+		MaxOpenConns:    defaults.MaxOpenConns,
+		MaxIdleConns:    defaults.MaxIdleConns,
+		ConnMaxLifetime: defaults.ConnMaxLifetime,
+		ConnMaxIdleTime: defaults.ConnMaxIdleTime,
+	}
+	if config.MaxOpenConns == 0 {
+		config.MaxOpenConns = defaultMaxOpenConns
+	}
+	if config.MaxIdleConns == 0 {
+		config.MaxIdleConns = defaultMaxIdleConns
+	}
+	if config.ConnMaxLifetime == 0 {
+		config.ConnMaxLifetime = defaultConnMaxLifetime
+	}
+	if config.ConnMaxIdleTime == 0 {
+		config.ConnMaxIdleTime = defaultConnMaxIdleTime
+	}
+
+	config.MaxOpenConns = envInt("DB_MAX_OPEN_CONNS", config.MaxOpenConns)
+	config.MaxIdleConns = envInt("DB_MAX_IDLE_CONNS", config.MaxIdleConns)
+	config.ConnMaxLifetime = envDuration("DB_CONN_MAX_LIFETIME", config.ConnMaxLifetime)
+	config.ConnMaxIdleTime = envDuration("DB_CONN_MAX_IDLE_TIME", config.ConnMaxIdleTime)
+	return config
+}
+
+// DSN is the lib/pq connection string for the database
+func (c DBConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		c.Host, c.Port, c.User, c.Password, c.Name)
+}
+
+// OpenDB opens the pool to the database and checks it is reachable
+func OpenDB(config DBConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", config.DSN())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to %s on %s:%s: %w", config.Name, config.Host, config.Port, err)
+	}
+	return db, nil
+}
@@ -0,0 +1,24 @@
+package platform
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// NewLogger returns the structured logger for the service, tagging every
+// record with its name
+func NewLogger(config Config) *slog.Logger {
+	return newLogger(config, os.Stdout)
+}
+
+func newLogger(config Config, w io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{Level: config.LogLevel}
+	var handler slog.Handler
+	if config.LogFormat == "text" {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(handler).With("service", config.Service)
+}
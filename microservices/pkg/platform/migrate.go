@@ -0,0 +1,50 @@
+package platform
+
+import (
+	"database/sql"
+	"io/fs"
+	"os"
+
+	"go-microservices/pkg/migrate"
+)
+
+// MigrationsDir is where a service's embedded filesystem keeps its
+// migrations
+const MigrationsDir = "migrations"
+
+// Migrate brings the database schema up to the service's migrations, or
+// checks it is when MigrateOnStart is off. When the service was started as
+// `<service> migrate ...` it runs that command instead and returns exit
+// true: main should return without serving.
+func (s *Server) Migrate(db *sql.DB, migrations fs.FS) (exit bool, err error) {
+	list, err := migrate.Load(migrations, MigrationsDir)
+	if err != nil {
+		return false, err
+	}
+	migrator := migrate.New(db, list)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return true, migrate.Command(s.ctx, migrator, os.Args[2:], os.Stdout)
+	}
+
+	if !s.Config.MigrateOnStart {
+		statuses, err := migrator.Status(s.ctx)
+		if err != nil {
+			return false, err
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				s.Logger.Warn("schema migration pending; run the migrate subcommand",
+					"version", status.Version, "name", status.Name)
+			}
+		}
+		return false, nil
+	}
+
+	applied, err := migrator.Up(s.ctx)
+	if err != nil {
+		return false, err
+	}
+	s.Logger.Info("database schema up to date", "version", migrator.Latest(), "applied", applied)
+	return false, nil
+}
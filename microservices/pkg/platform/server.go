@@ -0,0 +1,173 @@
+package platform
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// readinessCheckTimeout bounds each readiness check
+const readinessCheckTimeout = 2 * time.Second
+
+// Check reports whether a dependency the service needs is usable
+type Check func(ctx context.Context) error
+
+// Server is a service's HTTP server. Its Router already serves GET /health,
+// GET /ready and GET /metrics, and tags, logs and measures every request.
+type Server struct {
+	Config Config
+	Logger *slog.Logger
+	Router *gin.Engine
+
+	ctx      context.Context
+	stop     context.CancelFunc
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	checks map[string]Check
+}
+
+// NewServer sets up logging for the service and the router its routes are
+// added to. The server stops on SIGINT or SIGTERM.
+func NewServer(config Config) *Server {
+	logger := NewLogger(config)
+	// Route the log package, still used throughout the services, through
+	// the structured logger
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	s := &Server{
+		Config: config,
+		Logger: logger,
+		Router: gin.New(),
+		ctx:    ctx,
+		stop:   stop,
+		checks: make(map[string]Check),
+	}
+	s.Router.Use(RequestID(), AccessLog(logger), Metrics(), gin.Recovery())
+	s.Router.GET("/health", s.health)
+	s.Router.GET("/ready", s.ready)
+	s.Router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	return s
+}
+
+// Context is cancelled once the server is asked to stop. Background work
+// that should end with the service stops on it.
+func (s *Server) Context() context.Context {
+	return s.ctx
+}
+
+// Stop asks the server to stop as if it had received SIGTERM
+func (s *Server) Stop() {
+	s.stop()
+}
+
+// AddReadinessCheck makes GET /ready fail while check does
+func (s *Server) AddReadinessCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[name] = check
+}
+
+// Run serves requests until the server is asked to stop. It then fails
+// readiness for ShutdownDelay, stops accepting connections and waits up to
+// ShutdownTimeout for in-flight requests. It returns an error only if the server couldn't start or
+// didn't finish in time.
+func (s *Server) Run() error {
+	server := &http.Server{
+		Addr:              ":" + s.Config.Port,
+		Handler:           s.Router,
+		ReadHeaderTimeout: s.Config.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       s.Config.HTTP.ReadTimeout,
+		WriteTimeout:      s.Config.HTTP.WriteTimeout,
+		IdleTimeout:       s.Config.HTTP.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		s.Logger.Info("starting server", "addr", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-s.ctx.Done():
+	}
+	// A second signal kills the process instead of waiting for the drain
+	s.stop()
+
+	s.Logger.Info("shutting down server", "timeout", s.Config.ShutdownTimeout)
+	s.draining.Store(true)
+	// Keep serving while load balancers see /ready fail and stop routing here
+	time.Sleep(s.Config.ShutdownDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), s.Config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	s.Logger.Info("server stopped")
+	return nil
+}
+
+// health reports the process is up; it doesn't look at dependencies, so a
+// database outage doesn't get every replica restarted
+func (s *Server) health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "service": s.Config.Service})
+}
+
+// ready reports whether the service should receive traffic: it isn't
+// shutting down and every readiness check passes
+func (s *Server) ready(c *gin.Context) {
+	if s.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+
+	s.mu.Lock()
+	checks := make(map[string]Check, len(s.checks))
+	for name, check := range s.checks {
+		checks[name] = check
+	}
+	s.mu.Unlock()
+
+	failures := gin.H{}
+	for name, check := range checks {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessCheckTimeout)
+		err := check(ctx)
+		cancel()
+		if err != nil {
+			failures[name] = err.Error()
+		}
+	}
+	if len(failures) > 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": failures})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// OpenDB opens the service's database and makes GET /ready fail while it is
+// unreachable
+func (s *Server) OpenDB() (*sql.DB, error) {
+	db, err := OpenDB(s.Config.DB)
+	if err != nil {
+		return nil, err
+	}
+	s.AddReadinessCheck("database", db.PingContext)
+	return db, nil
+}
@@ -0,0 +1,135 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	config := LoadConfig("test-service", "0", DBConfig{})
+	config.ShutdownTimeout = time.Second
+	s := NewServer(config)
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func serve(s *Server, path, requestID string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if requestID != "" {
+		req.Header.Set(RequestIDHeader, requestID)
+	}
+	s.Router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReadyReportsFailingChecks(t *testing.T) {
+	s := newTestServer(t)
+
+	if w := serve(s, "/ready", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET /ready = %d without checks, want 200", w.Code)
+	}
+
+	s.AddReadinessCheck("database", func(ctx context.Context) error { return errors.New("connection refused") })
+	w := serve(s, "/ready", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("GET /ready = %d with a failing check, want 503", w.Code)
+	}
+	var body struct {
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Checks["database"] != "connection refused" {
+		t.Fatalf("unexpected body %s", w.Body)
+	}
+
+	// Liveness doesn't depend on the database
+	if w := serve(s, "/health", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET /health = %d, want 200", w.Code)
+	}
+}
+
+func TestRequestIDIsKeptOrGenerated(t *testing.T) {
+	s := newTestServer(t)
+	var seen string
+	s.Router.GET("/echo", func(c *gin.Context) {
+		seen = c.Request.Header.Get(RequestIDHeader)
+		c.Status(http.StatusNoContent)
+	})
+
+	w := serve(s, "/echo", "abc-123")
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" || seen != "abc-123" {
+		t.Fatalf("request ID = %q (handler saw %q), want the caller's", got, seen)
+	}
+
+	for _, requestID := range []string{"", "bad id\n"} {
+		w = serve(s, "/echo", requestID)
+		if got := w.Header().Get(RequestIDHeader); len(got) != 32 || seen != got {
+			t.Fatalf("request ID = %q (handler saw %q), want a generated one", got, seen)
+		}
+	}
+}
+
+func TestAccessLogIncludesRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	router := gin.New()
+	router.Use(RequestID(), AccessLog(newLogger(Config{Service: "test-service"}, &logs)))
+	router.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("access log isn't JSON: %s", logs.String())
+	}
+	if record["request_id"] != "abc-123" || record["service"] != "test-service" || record["status"] != float64(404) {
+		t.Fatalf("unexpected access log %s", logs.String())
+	}
+}
+
+func TestMetricsAreServed(t *testing.T) {
+	s := newTestServer(t)
+	serve(s, "/health", "")
+
+	w := serve(s, "/metrics", "")
+	if !strings.Contains(w.Body.String(), `http_requests_total{code="200",method="GET",route="/health"}`) {
+		t.Fatalf("request metrics missing from /metrics")
+	}
+}
+
+func TestRunStopsGracefully(t *testing.T) {
+	s := newTestServer(t)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run() }()
+	time.Sleep(50 * time.Millisecond)
+	s.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after Stop")
+	}
+	if w := serve(s, "/ready", ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("GET /ready = %d after shutdown, want 503", w.Code)
+	}
+	if s.Context().Err() == nil {
+		t.Fatal("Context not cancelled after shutdown")
+	}
+}
@@ -0,0 +1,340 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const heartbeat = 10 * time.Second
+
+var (
+	// ErrPublishBufferFull is returned when too many publishes are already
+	// waiting for the broker to come back
+	ErrPublishBufferFull = errors.New("RabbitMQ publish buffer is full")
+	// ErrNacked is returned when the broker refuses a published message
+	ErrNacked = errors.New("RabbitMQ did not acknowledge the message")
+	// ErrConnectionClosed is returned once the connection has been closed
+	ErrConnectionClosed = errors.New("RabbitMQ connection is closed")
+	// ErrNotConnected is returned by operations that can't wait for a
+	// reconnection
+	ErrNotConnected = errors.New("RabbitMQ is not connected")
+)
+
+// connection keeps a RabbitMQ connection open. When the broker goes away it
+// reconnects with exponential backoff, declares the recorded topology again
+// and restarts the consumers. Publishes go through a channel in confirm mode
+// and wait, up to Settings.PublishBuffer of them, while it reconnects.
+type connection struct {
+	settings Settings
+
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	publisher *amqp.Channel
+	// ready is closed while connected and replaced when the connection is lost
+	ready     chan struct{}
+	topology  []func(*amqp.Channel) error
+	consumers []consumer
+	// deadLetters holds the dead-letter queues of the consumers
+	deadLetters map[string]bool
+	closed      bool
+
+	stop    chan struct{}
+	waiting chan struct{}
+}
+
+func newConnection(settings Settings) *connection {
+	return &connection{
+		settings:    settings,
+		ready:       make(chan struct{}),
+		deadLetters: make(map[string]bool),
+		stop:        make(chan struct{}),
+		waiting:     make(chan struct{}, settings.PublishBuffer),
+	}
+}
+
+// start connects and keeps reconnecting in the background until close. An
+// error from the first attempt is returned, but the retries go on.
+func (c *connection) start() error {
+	closes, err := c.connect()
+	go c.maintain(closes)
+	return err
+}
+
+// maintain waits for the connection to be lost and reconnects, until close
+func (c *connection) maintain(closes chan *amqp.Error) {
+	for {
+		if closes != nil {
+			select {
+			case <-c.stop:
+				return
+			case err, ok := <-closes:
+				if ok {
+					log.Printf("Warning: RabbitMQ connection lost: %v\n", err)
+				}
+			}
+			c.disconnected()
+		}
+
+		closes = c.reconnect()
+		if closes == nil {
+			return
+		}
+	}
+}
+
+// reconnect dials until it succeeds, waiting longer after each failure. It
+// returns nil if the connection is closed in the meantime.
+func (c *connection) reconnect() chan *amqp.Error {
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-c.stop:
+			return nil
+		case <-time.After(backoff(attempt, c.settings.MaxBackoff)):
+		}
+
+		closes, err := c.connect()
+		if err == nil {
+			log.Println("Reconnected to RabbitMQ")
+			return closes
+		}
+		log.Printf("Warning: Failed to reconnect to RabbitMQ: %v\n", err)
+	}
+}
+
+// connect dials the broker, declares the topology and starts the consumers.
+// It returns the channel notified when the connection closes.
+func (c *connection) connect() (chan *amqp.Error, error) {
+	conn, err := amqp.DialConfig(c.settings.URL, amqp.Config{
+		Heartbeat:       heartbeat,
+		TLSClientConfig: c.settings.TLS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	publisher, err := conn.Channel()
+	if err == nil {
+		err = publisher.Confirm(false)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	// A channel error, such as publishing to a missing exchange, closes only
+	// the channel; reconnect to get a working one
+	go func() {
+		if err, ok := <-publisher.NotifyClose(make(chan *amqp.Error, 1)); ok {
+			log.Printf("Warning: RabbitMQ publish channel closed: %v\n", err)
+			conn.Close()
+		}
+	}()
+	closes := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		conn.Close()
+		return nil, ErrConnectionClosed
+	}
+
+	// One bad declaration or consumer must not keep the rest offline
+	for _, declare := range c.topology {
+		if err := declareOn(conn, declare); err != nil {
+			log.Printf("Warning: Failed to declare RabbitMQ topology: %v\n", err)
+		}
+	}
+	for _, sub := range c.consumers {
+		if err := c.startConsumer(conn, sub); err != nil {
+			log.Printf("Warning: Failed to restart consumer of %s: %v\n", sub.config.QueueName, err)
+		}
+	}
+
+	c.conn, c.publisher = conn, publisher
+	close(c.ready)
+	return closes, nil
+}
+
+// disconnected makes publishes wait for the next connection
+func (c *connection) disconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return
+	}
+	c.conn, c.publisher = nil, nil
+	c.ready = make(chan struct{})
+}
+
+// lost is disconnected for a publisher found closed, unless the connection
+// was already replaced
+func (c *connection) lost(publisher *amqp.Channel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.publisher != publisher {
+		return
+	}
+	c.conn, c.publisher = nil, nil
+	c.ready = make(chan struct{})
+}
+
+// declare records a declaration to repeat on every connection and runs it
+// now if connected
+func (c *connection) declare(declare func(*amqp.Channel) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.topology = append(c.topology, declare)
+	if c.conn == nil {
+		return nil
+	}
+	return declareOn(c.conn, declare)
+}
+
+// declareOn runs a declaration on its own channel, since a failed one closes
+// the channel it ran on
+func declareOn(conn *amqp.Connection, declare func(*amqp.Channel) error) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+	return declare(ch)
+}
+
+// consume records a consumer to restart on every connection and starts it
+// now if connected
+func (c *connection) consume(sub consumer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.consumers = append(c.consumers, sub)
+	if c.conn == nil {
+		return nil
+	}
+	return c.startConsumer(c.conn, sub)
+}
+
+// publish sends msg and waits for the broker to confirm it. While
+// disconnected it waits for the connection to come back; if the connection
+// drops before the confirm arrives the message is published again, so
+// delivery is at-least-once.
+func (c *connection) publish(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	for {
+		publisher, err := c.channel(ctx)
+		if err != nil {
+			return err
+		}
+
+		acked, err := publishAndConfirm(ctx, publisher, exchange, key, msg)
+		if err == nil && acked {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if !publisher.IsClosed() {
+			if err != nil {
+				return err
+			}
+			return ErrNacked
+		}
+		// The channel closed under the publish; wait for the reconnection
+		c.lost(publisher)
+	}
+}
+
+// channel returns the publish channel, waiting for a connection if needed
+func (c *connection) channel(ctx context.Context) (*amqp.Channel, error) {
+	buffered := false
+	defer func() {
+		if buffered {
+			<-c.waiting
+		}
+	}()
+
+	for {
+		c.mu.Lock()
+		publisher, ready, closed := c.publisher, c.ready, c.closed
+		c.mu.Unlock()
+		if closed {
+			return nil, ErrConnectionClosed
+		}
+		if publisher != nil {
+			return publisher, nil
+		}
+
+		if !buffered {
+			select {
+			case c.waiting <- struct{}{}:
+				buffered = true
+			default:
+				return nil, ErrPublishBufferFull
+			}
+		}
+		select {
+		case <-ready:
+		case <-c.stop:
+			return nil, ErrConnectionClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// publishAndConfirm publishes on ch and waits for the broker's confirm. The
+// client doesn't interrupt a blocked write when ctx is done, so the publish
+// runs in its own goroutine.
+func publishAndConfirm(ctx context.Context, ch *amqp.Channel, exchange, key string, msg amqp.Publishing) (bool, error) {
+	type result struct {
+		confirm *amqp.DeferredConfirmation
+		err     error
+	}
+	published := make(chan result, 1)
+	go func() {
+		confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+		published <- result{confirm, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case r := <-published:
+		if r.err != nil {
+			return false, r.err
+		}
+		return r.confirm.WaitContext(ctx)
+	}
+}
+
+// close stops reconnecting and closes the connection. Waiting publishes
+// fail with ErrConnectionClosed.
+func (c *connection) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.stop)
+	if c.conn != nil {
+		if err := c.conn.Close(); err != nil {
+			fmt.Printf("Error closing connection: %v\n", err)
+		}
+	}
+}
+
+// backoff returns the delay before the given reconnection attempt
+func backoff(attempt int, max time.Duration) time.Duration {
+	if attempt > 10 {
+		return max
+	}
+	delay := time.Duration(1<<uint(attempt)) * time.Second
+	if delay > max {
+		return max
+	}
+	return delay
+}
@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestSettingsFromEnvDefaults(t *testing.T) {
+	for _, key := range []string{"RABBITMQ_URL", "RABBITMQ_HOST", "RABBITMQ_PORT", "RABBITMQ_USER", "RABBITMQ_PASSWORD", "RABBITMQ_VHOST", "RABBITMQ_TLS"} {
+		t.Setenv(key, "")
+	}
+
+	settings, err := SettingsFromEnv()
+	if err != nil {
+		t.Fatalf("SettingsFromEnv: %v", err)
+	}
+	if settings.URL != "amqp://rabbitmq/" {
+		t.Fatalf("URL = %q, want amqp://rabbitmq/", settings.URL)
+	}
+	if settings.TLS != nil {
+		t.Fatal("TLS configured without RABBITMQ_TLS")
+	}
+	if settings.PublishBuffer != defaultPublishBuffer || settings.MaxBackoff != defaultMaxBackoff {
+		t.Fatalf("got buffer %d, backoff %v; want the defaults", settings.PublishBuffer, settings.MaxBackoff)
+	}
+}
+
+func TestSettingsFromEnvBuildsURL(t *testing.T) {
+	t.Setenv("RABBITMQ_URL", "")
+	t.Setenv("RABBITMQ_HOST", "broker:5673")
+	t.Setenv("RABBITMQ_PORT", "")
+	t.Setenv("RABBITMQ_USER", "orders")
+	t.Setenv("RABBITMQ_PASSWORD", "s3cret")
+	t.Setenv("RABBITMQ_VHOST", "shop")
+	t.Setenv("RABBITMQ_TLS", "true")
+
+	settings, err := SettingsFromEnv()
+	if err != nil {
+		t.Fatalf("SettingsFromEnv: %v", err)
+	}
+	uri, err := amqp.ParseURI(settings.URL)
+	if err != nil {
+		t.Fatalf("ParseURI(%q): %v", settings.URL, err)
+	}
+	want := amqp.URI{Scheme: "amqps", Host: "broker", Port: 5673, Username: "orders", Password: "s3cret", Vhost: "shop"}
+	if uri != want {
+		t.Fatalf("URI = %+v, want %+v", uri, want)
+	}
+	if settings.TLS == nil {
+		t.Fatal("TLS not configured with RABBITMQ_TLS=true")
+	}
+}
+
+func TestPublishWaitsForConnection(t *testing.T) {
+	c := newConnection(Settings{PublishBuffer: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.publish(ctx, "orders", "order.created", amqp.Publishing{}); err != context.DeadlineExceeded {
+		t.Fatalf("publish err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPublishFailsWhenBufferIsFull(t *testing.T) {
+	c := newConnection(Settings{PublishBuffer: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := make(chan error)
+	go func() {
+		waiting <- c.publish(ctx, "orders", "order.created", amqp.Publishing{})
+	}()
+	// Let the first publish take the only buffer slot
+	for len(c.waiting) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := c.publish(context.Background(), "orders", "order.created", amqp.Publishing{}); !errors.Is(err, ErrPublishBufferFull) {
+		t.Fatalf("publish err = %v, want ErrPublishBufferFull", err)
+	}
+
+	// Closing the connection releases the waiting publish
+	c.close()
+	if err := <-waiting; !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("waiting publish err = %v, want ErrConnectionClosed", err)
+	}
+	cancel()
+}
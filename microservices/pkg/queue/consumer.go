@@ -0,0 +1,277 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Consumer defaults, used when the Config fields are zero
+const (
+	defaultPrefetch    = 20
+	defaultConcurrency = 1
+	defaultMaxAttempts = 5
+	defaultRetryDelay  = time.Second
+	maxRetryDelay      = 10 * time.Minute
+
+	// retryTimeout bounds republishing a failed message
+	retryTimeout = 5 * time.Second
+)
+
+// DeadLetterExchange receives the messages that exhausted their attempts,
+// routed by the name of their dead-letter queue
+const DeadLetterExchange = "dead-letters"
+
+// Headers kept on failed messages
+const (
+	headerRetryCount         = "x-retry-count"
+	headerLastError          = "x-last-error"
+	headerOriginalQueue      = "x-original-queue"
+	headerOriginalRoutingKey = "x-original-routing-key"
+	headerDeadLetteredAt     = "x-dead-lettered-at"
+)
+
+// consumer is a subscription restarted on every connection
+type consumer struct {
+	config  Config
+	handler func([]byte) error
+}
+
+// withDefaults fills in the zero consumer settings
+func (config Config) withDefaults() Config {
+	if config.Prefetch <= 0 {
+		config.Prefetch = defaultPrefetch
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+	if config.DeadLetterQueue == "" {
+		config.DeadLetterQueue = config.QueueName + ".dead"
+	}
+	return config
+}
+
+// retryQueue names the queue holding messages for their given retry
+func (config Config) retryQueue(retry int) string {
+	return config.QueueName + ".retry." + strconv.Itoa(retry)
+}
+
+// retryDelay is how long a message waits before its given retry; it doubles
+// with each retry
+func (config Config) retryDelay(retry int) time.Duration {
+	delay := config.RetryDelay
+	for i := 1; i < retry && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// declareRetries declares the consumer's retry queues and dead-letter queue.
+// Each retry queue holds messages for a fixed delay and then dead-letters
+// them back to the consumed queue through the default exchange.
+func declareRetries(config Config) func(*amqp.Channel) error {
+	return func(channel *amqp.Channel) error {
+		for retry := 1; retry < config.MaxAttempts; retry++ {
+			_, err := channel.QueueDeclare(
+				config.retryQueue(retry),
+				true,  // durable
+				false, // delete when unused
+				false, // exclusive
+				false, // no-wait
+				amqp.Table{
+					"x-message-ttl":             config.retryDelay(retry).Milliseconds(),
+					"x-dead-letter-exchange":    "",
+					"x-dead-letter-routing-key": config.QueueName,
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to declare retry queue: %w", err)
+			}
+		}
+
+		err := channel.ExchangeDeclare(
+			DeadLetterExchange,
+			"direct",
+			true,  // durable
+			false, // auto-deleted
+			false, // internal
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+		}
+
+		_, err = channel.QueueDeclare(
+			config.DeadLetterQueue,
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+		}
+
+		err = channel.QueueBind(
+			config.DeadLetterQueue,
+			config.DeadLetterQueue,
+			DeadLetterExchange,
+			false,
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to bind dead-letter queue: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// startConsumer consumes the queue on its own channel until the connection
+// closes, handling up to Config.Concurrency messages at once
+func (c *connection) startConsumer(conn *amqp.Connection, sub consumer) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	if err := ch.Qos(sub.config.Prefetch, 0, false); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	msgs, err := ch.Consume(
+		sub.config.QueueName,
+		"",    // consumer
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to register a consumer: %w", err)
+	}
+
+	var workers sync.WaitGroup
+	for i := 0; i < sub.config.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for msg := range msgs {
+				c.deliver(sub, msg)
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		ch.Close()
+	}()
+
+	return nil
+}
+
+// deliver hands a message to the handler. A failed message is acked once it
+// has been moved to the next retry queue or, after Config.MaxAttempts
+// deliveries, to the dead-letter queue, so it never blocks the queue.
+func (c *connection) deliver(sub consumer, msg amqp.Delivery) {
+	err := handle(sub.handler, msg.Body)
+	if err == nil {
+		if err := msg.Ack(false); err != nil { // Positive acknowledgement
+			fmt.Printf("Error sending ack: %v\n", err)
+		}
+		return
+	}
+
+	fmt.Printf("Error processing message: %v\n", err)
+	if err := c.retry(sub.config, msg, err); err != nil {
+		log.Printf("Warning: Failed to schedule retry of message from %s: %v\n", sub.config.QueueName, err)
+		if err := msg.Nack(false, true); err != nil { // Negative acknowledgement, requeue
+			fmt.Printf("Error sending nack: %v\n", err)
+		}
+		return
+	}
+	if err := msg.Ack(false); err != nil {
+		fmt.Printf("Error sending ack: %v\n", err)
+	}
+}
+
+// handle runs the handler, turning a panic into an error so a poison
+// message can't take the consumer down
+func handle(handler func([]byte) error, body []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return handler(body)
+}
+
+// retry republishes a failed message to its next retry queue, or to the
+// dead-letter exchange once it has had all its attempts
+func (c *connection) retry(config Config, msg amqp.Delivery, cause error) error {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	retries := retryCount(msg.Headers) + 1
+	headers[headerRetryCount] = int32(retries)
+	headers[headerLastError] = cause.Error()
+	headers[headerOriginalQueue] = config.QueueName
+	if _, ok := headers[headerOriginalRoutingKey]; !ok {
+		headers[headerOriginalRoutingKey] = msg.RoutingKey
+	}
+
+	exchange, key := "", config.retryQueue(retries)
+	if retries >= config.MaxAttempts {
+		exchange, key = DeadLetterExchange, config.DeadLetterQueue
+		headers[headerDeadLetteredAt] = time.Now().UTC()
+		log.Printf("Dead-lettering message from %s after %d attempts: %v\n", config.QueueName, retries, cause)
+	}
+
+	messageID := msg.MessageId
+	if messageID == "" {
+		messageID = newMessageID()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), retryTimeout)
+	defer cancel()
+	return c.publish(ctx, exchange, key, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageID,
+		Timestamp:    msg.Timestamp,
+		Type:         msg.Type,
+		Body:         msg.Body,
+	})
+}
+
+// retryCount reads how many times a message has been retried
+func retryCount(headers amqp.Table) int {
+	switch n := headers[headerRetryCount].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestConfigDefaults(t *testing.T) {
+	config := Config{QueueName: "orders"}.withDefaults()
+
+	if config.Prefetch != defaultPrefetch || config.Concurrency != defaultConcurrency || config.MaxAttempts != defaultMaxAttempts {
+		t.Fatalf("got %+v, want the package defaults", config)
+	}
+	if config.DeadLetterQueue != "orders.dead" {
+		t.Fatalf("DeadLetterQueue = %q, want orders.dead", config.DeadLetterQueue)
+	}
+	if got := config.retryQueue(2); got != "orders.retry.2" {
+		t.Fatalf("retryQueue(2) = %q, want orders.retry.2", got)
+	}
+}
+
+func TestRetryDelayDoublesUpToTheCap(t *testing.T) {
+	config := Config{QueueName: "orders", RetryDelay: time.Second}.withDefaults()
+
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 30: maxRetryDelay} {
+		if got := config.retryDelay(retry); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", retry, got, want)
+		}
+	}
+}
+
+func TestRetryCountReadsBrokerIntegerTypes(t *testing.T) {
+	for _, headers := range []amqp.Table{{headerRetryCount: int32(3)}, {headerRetryCount: int64(3)}, {headerRetryCount: 3}} {
+		if got := retryCount(headers); got != 3 {
+			t.Errorf("retryCount(%v) = %d, want 3", headers, got)
+		}
+	}
+	if got := retryCount(nil); got != 0 {
+		t.Errorf("retryCount(nil) = %d, want 0", got)
+	}
+}
+
+func TestHandleTurnsPanicsIntoErrors(t *testing.T) {
+	err := handle(func([]byte) error { panic("malformed") }, []byte("{"))
+	if err == nil {
+		t.Fatal("handle returned nil for a panicking handler")
+	}
+
+	want := errors.New("failed")
+	if err := handle(func([]byte) error { return want }, nil); err != want {
+		t.Fatalf("handle err = %v, want %v", err, want)
+	}
+}
+
+func TestNewDeadLetterReadsFailureHeaders(t *testing.T) {
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	letter := newDeadLetter(amqp.Delivery{
+		MessageId:   "abc",
+		ContentType: "application/json",
+		Body:        []byte("not json"),
+		Headers: amqp.Table{
+			headerRetryCount:         int32(5),
+			headerLastError:          "invalid character",
+			headerOriginalQueue:      "orders",
+			headerOriginalRoutingKey: "order.created",
+			headerDeadLetteredAt:     at,
+		},
+	})
+
+	want := DeadLetter{
+		ID:             "abc",
+		Queue:          "orders",
+		RoutingKey:     "order.created",
+		Attempts:       5,
+		Error:          "invalid character",
+		DeadLetteredAt: at,
+		ContentType:    "application/json",
+		Body:           "not json",
+	}
+	if letter != want {
+		t.Fatalf("got %+v, want %+v", letter, want)
+	}
+}
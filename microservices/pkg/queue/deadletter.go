@@ -0,0 +1,311 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// maxDeadLetterScan bounds how many messages one dead-letter operation reads
+const maxDeadLetterScan = 10000
+
+var (
+	// ErrUnknownDeadLetterQueue is returned for a queue that isn't the
+	// dead-letter queue of one of this process's consumers
+	ErrUnknownDeadLetterQueue = errors.New("unknown dead-letter queue")
+	// ErrDeadLetterNotFound is returned when no dead letter has the given ID
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrOriginalQueueGone is returned when a dead letter can't be replayed
+	// because the queue it failed on no longer exists
+	ErrOriginalQueueGone = errors.New("original queue no longer exists")
+)
+
+// DeadLetterQueue summarises a dead-letter queue
+type DeadLetterQueue struct {
+	Name     string `json:"name"`
+	Messages int    `json:"messages"`
+}
+
+// DeadLetter is a message that exhausted its attempts
+type DeadLetter struct {
+	ID             string    `json:"id"`
+	Queue          string    `json:"queue"`
+	RoutingKey     string    `json:"routing_key"`
+	Attempts       int       `json:"attempts"`
+	Error          string    `json:"error"`
+	DeadLetteredAt time.Time `json:"dead_lettered_at"`
+	ContentType    string    `json:"content_type"`
+	// Body is kept as a string since a poison message may not be valid JSON
+	Body string `json:"body"`
+}
+
+// newDeadLetter describes a delivery from a dead-letter queue
+func newDeadLetter(msg amqp.Delivery) DeadLetter {
+	letter := DeadLetter{
+		ID:          msg.MessageId,
+		Attempts:    retryCount(msg.Headers),
+		ContentType: msg.ContentType,
+		Body:        string(msg.Body),
+	}
+	letter.Queue, _ = msg.Headers[headerOriginalQueue].(string)
+	letter.RoutingKey, _ = msg.Headers[headerOriginalRoutingKey].(string)
+	letter.Error, _ = msg.Headers[headerLastError].(string)
+	letter.DeadLetteredAt, _ = msg.Headers[headerDeadLetteredAt].(time.Time)
+	return letter
+}
+
+// addDeadLetterQueue registers a dead-letter queue for the admin operations
+func (c *connection) addDeadLetterQueue(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadLetters[name] = true
+}
+
+// scanDeadLetters reads up to limit messages of a dead-letter queue without
+// removing them, passing each to visit with the scan's channel until it
+// returns true. Messages visit acks are removed; the rest go back to the
+// queue when the channel closes.
+func (c *connection) scanDeadLetters(queue string, limit int, visit func(*amqp.Channel, amqp.Delivery) (bool, error)) error {
+	c.mu.Lock()
+	known, conn := c.deadLetters[queue], c.conn
+	c.mu.Unlock()
+	if !known {
+		return ErrUnknownDeadLetterQueue
+	}
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	for i := 0; i < limit; i++ {
+		msg, ok, err := ch.Get(queue, false)
+		if err != nil {
+			return fmt.Errorf("failed to read dead-letter queue: %w", err)
+		}
+		if !ok {
+			return nil
+		}
+		done, err := visit(ch, msg)
+		if err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeadLetterQueues lists the dead-letter queues of this process's consumers
+// with their message counts
+func DeadLetterQueues() ([]DeadLetterQueue, error) {
+	if rabbit == nil {
+		return nil, errNotInitialized
+	}
+
+	rabbit.mu.Lock()
+	conn := rabbit.conn
+	names := make([]string, 0, len(rabbit.deadLetters))
+	for name := range rabbit.deadLetters {
+		names = append(names, name)
+	}
+	rabbit.mu.Unlock()
+	if conn == nil {
+		return nil, ErrNotConnected
+	}
+	sort.Strings(names)
+
+	queues := make([]DeadLetterQueue, 0, len(names))
+	for _, name := range names {
+		var q amqp.Queue
+		err := declareOn(conn, func(channel *amqp.Channel) (err error) {
+			q, err = channel.QueueDeclarePassive(name, true, false, false, false, nil)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect dead-letter queue: %w", err)
+		}
+		queues = append(queues, DeadLetterQueue{Name: name, Messages: q.Messages})
+	}
+	return queues, nil
+}
+
+// ListDeadLetters returns up to limit messages of a dead-letter queue, oldest
+// first, leaving them in place
+func ListDeadLetters(queue string, limit int) ([]DeadLetter, error) {
+	if rabbit == nil {
+		return nil, errNotInitialized
+	}
+
+	letters := []DeadLetter{}
+	err := rabbit.scanDeadLetters(queue, limit, func(_ *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		letters = append(letters, newDeadLetter(msg))
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// GetDeadLetter returns one message of a dead-letter queue, leaving it in place
+func GetDeadLetter(queue string, id string) (*DeadLetter, error) {
+	if rabbit == nil {
+		return nil, errNotInitialized
+	}
+
+	var found *DeadLetter
+	err := rabbit.scanDeadLetters(queue, maxDeadLetterScan, func(_ *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		if msg.MessageId != id {
+			return false, nil
+		}
+		letter := newDeadLetter(msg)
+		found = &letter
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	return found, nil
+}
+
+// ReplayDeadLetters publishes dead letters back to the queue they failed on,
+// with a fresh set of attempts, and removes them from the dead-letter queue.
+// An empty id replays every message. It returns how many were replayed. A
+// message whose queue no longer exists, such as a replica's exclusive queue,
+// stays dead-lettered and the replay stops with ErrOriginalQueueGone.
+func ReplayDeadLetters(ctx context.Context, queue string, id string) (int, error) {
+	if rabbit == nil {
+		return 0, errNotInitialized
+	}
+
+	replayed := 0
+	var returns chan amqp.Return
+	err := rabbit.scanDeadLetters(queue, maxDeadLetterScan, func(ch *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		if id != "" && msg.MessageId != id {
+			return false, nil
+		}
+		if returns == nil {
+			if err := ch.Confirm(false); err != nil {
+				return false, fmt.Errorf("failed to enable publisher confirms: %w", err)
+			}
+			returns = ch.NotifyReturn(make(chan amqp.Return, 1))
+		}
+		original, _ := msg.Headers[headerOriginalQueue].(string)
+		if original == "" {
+			return false, fmt.Errorf("dead letter %s has no original queue", msg.MessageId)
+		}
+
+		headers := amqp.Table{}
+		for k, v := range msg.Headers {
+			headers[k] = v
+		}
+		delete(headers, headerRetryCount)
+		delete(headers, headerDeadLetteredAt)
+
+		// Publish before removing it, so a failure leaves it dead-lettered
+		err := replay(ctx, ch, returns, original, amqp.Publishing{
+			Headers:      headers,
+			ContentType:  msg.ContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    msg.MessageId,
+			Timestamp:    msg.Timestamp,
+			Type:         msg.Type,
+			Body:         msg.Body,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to replay dead letter %s: %w", msg.MessageId, err)
+		}
+		if err := msg.Ack(false); err != nil {
+			return false, fmt.Errorf("failed to remove replayed dead letter: %w", err)
+		}
+		replayed++
+		return id != "", nil
+	})
+	if err == nil && id != "" && replayed == 0 {
+		err = ErrDeadLetterNotFound
+	}
+	return replayed, err
+}
+
+// replay publishes a dead letter to queue on the scan's channel and waits for
+// the broker to confirm it. The publish is mandatory, so the broker hands back
+// a message it can't route instead of silently dropping it; it does so before
+// confirming the publish.
+func replay(ctx context.Context, ch *amqp.Channel, returns <-chan amqp.Return, queue string, msg amqp.Publishing) error {
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, true, false, msg)
+	if err != nil {
+		return err
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-returns:
+		return ErrOriginalQueueGone
+	default:
+	}
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+// PurgeDeadLetters deletes dead letters. An empty id purges the whole queue.
+// It returns how many were deleted.
+func PurgeDeadLetters(queue string, id string) (int, error) {
+	if rabbit == nil {
+		return 0, errNotInitialized
+	}
+
+	if id == "" {
+		rabbit.mu.Lock()
+		known, conn := rabbit.deadLetters[queue], rabbit.conn
+		rabbit.mu.Unlock()
+		if !known {
+			return 0, ErrUnknownDeadLetterQueue
+		}
+		if conn == nil {
+			return 0, ErrNotConnected
+		}
+
+		purged := 0
+		err := declareOn(conn, func(channel *amqp.Channel) (err error) {
+			purged, err = channel.QueuePurge(queue, false)
+			return err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge dead-letter queue: %w", err)
+		}
+		return purged, nil
+	}
+
+	purged := 0
+	err := rabbit.scanDeadLetters(queue, maxDeadLetterScan, func(_ *amqp.Channel, msg amqp.Delivery) (bool, error) {
+		if msg.MessageId != id {
+			return false, nil
+		}
+		if err := msg.Ack(false); err != nil {
+			return false, fmt.Errorf("failed to purge dead letter: %w", err)
+		}
+		purged++
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if purged == 0 {
+		return 0, ErrDeadLetterNotFound
+	}
+	return purged, nil
+}
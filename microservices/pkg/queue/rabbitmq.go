@@ -0,0 +1,224 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rabbit is the connection shared by the package functions
+var rabbit *connection
+
+// Config holds RabbitMQ configuration
+type Config struct {
+	QueueName       string
+	RoutingKey      string
+	ExchangeName    string
+	ExchangeType    string
+	ConnectionRetry int
+
+	// Consumer settings; zero values use the package defaults
+	Prefetch        int           // unacknowledged messages delivered at once (20)
+	Concurrency     int           // messages handled in parallel (1)
+	MaxAttempts     int           // deliveries before a message is dead-lettered (5)
+	RetryDelay      time.Duration // wait before the first retry, doubled for each further one (1s)
+	DeadLetterQueue string        // where exhausted messages go (QueueName + ".dead")
+}
+
+// InitRabbitMQ connects to RabbitMQ with the settings from SettingsFromEnv.
+// If the broker can't be reached the error is returned, but connecting goes
+// on in the background, so declarations, consumers and publishes made in the
+// meantime take effect once it is up.
+func InitRabbitMQ() error {
+	settings, err := SettingsFromEnv()
+	if err != nil {
+		return err
+	}
+
+	rabbit = newConnection(settings)
+	return rabbit.start()
+}
+
+// errNotInitialized is returned when InitRabbitMQ hasn't been called
+var errNotInitialized = errors.New("RabbitMQ connection is not initialized")
+
+// DeclareQueue declares a queue with given configuration. The declaration is
+// repeated whenever the connection is re-established.
+func DeclareQueue(config Config) error {
+	if rabbit == nil {
+		return errNotInitialized
+	}
+
+	return rabbit.declare(func(channel *amqp.Channel) error {
+		// Declare exchange
+		err := channel.ExchangeDeclare(
+			config.ExchangeName,
+			config.ExchangeType,
+			true,  // durable
+			false, // auto-deleted
+			false, // internal
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare exchange: %w", err)
+		}
+
+		// Declare queue
+		_, err = channel.QueueDeclare(
+			config.QueueName,
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare queue: %w", err)
+		}
+
+		// Bind queue to exchange
+		err = channel.QueueBind(
+			config.QueueName,
+			config.RoutingKey,
+			config.ExchangeName,
+			false,
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to bind queue: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// DeclareExclusiveQueue declares a uniquely named queue that is deleted when
+// the connection closes, binds it to the configured exchange and returns its
+// name. It gives each replica its own copy of the exchange's messages. The
+// queue is declared again under the same name after a reconnection.
+func DeclareExclusiveQueue(config Config) (string, error) {
+	if rabbit == nil {
+		return "", errNotInitialized
+	}
+
+	name := config.QueueName + "." + newMessageID()
+
+	err := rabbit.declare(func(channel *amqp.Channel) error {
+		_, err := channel.QueueDeclare(
+			name,
+			false, // durable
+			true,  // delete when unused
+			true,  // exclusive
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare queue: %w", err)
+		}
+
+		err = channel.QueueBind(
+			name,
+			config.RoutingKey,
+			config.ExchangeName,
+			false,
+			nil,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to bind queue: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// PublishMessage publishes a message to queue and returns nil once the
+// broker has confirmed it. While reconnecting the publish waits for the
+// connection, or fails with ErrPublishBufferFull if too many already are.
+// It gives up with ctx's error once ctx is done, even if the broker is still
+// blocking the publish.
+func PublishMessage(ctx context.Context, config Config, message interface{}) error {
+	if rabbit == nil {
+		return errNotInitialized
+	}
+
+	body, err := encodeMessage(message)
+	if err != nil {
+		return err
+	}
+
+	err = rabbit.publish(ctx, config.ExchangeName, config.RoutingKey, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+
+	return nil
+}
+
+// encodeMessage returns the JSON body of a message. Bytes are taken to be
+// JSON already and sent as they are; marshaling them again would send a
+// base64 string.
+func encodeMessage(message interface{}) ([]byte, error) {
+	switch m := message.(type) {
+	case json.RawMessage:
+		return m, nil
+	case []byte:
+		return m, nil
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return body, nil
+}
+
+// ConsumeMessages starts consuming messages from queue. The consumer is
+// restarted whenever the connection is re-established. A message the handler
+// fails on is retried after an exponentially growing delay, through the
+// queue's retry queues, and moved to its dead-letter queue after
+// Config.MaxAttempts deliveries.
+func ConsumeMessages(config Config, handler func([]byte) error) error {
+	if rabbit == nil {
+		return errNotInitialized
+	}
+
+	config = config.withDefaults()
+	if err := rabbit.declare(declareRetries(config)); err != nil {
+		return err
+	}
+	rabbit.addDeadLetterQueue(config.DeadLetterQueue)
+	return rabbit.consume(consumer{config: config, handler: handler})
+}
+
+// newMessageID returns a random identifier for queue and message names
+func newMessageID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(id)
+}
+
+// Close closes RabbitMQ connection
+func Close() {
+	if rabbit != nil {
+		rabbit.close()
+	}
+}
@@ -0,0 +1,24 @@
+package queue
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEncodeMessageDoesNotReencodeJSON(t *testing.T) {
+	for name, message := range map[string]interface{}{
+		"bytes":       []byte(`{"id":10}`),
+		"raw message": json.RawMessage(`{"id":10}`),
+		"struct": struct {
+			ID int `json:"id"`
+		}{ID: 10},
+	} {
+		body, err := encodeMessage(message)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(body) != `{"id":10}` {
+			t.Errorf("%s: body = %s, want {\"id\":10}", name, body)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	defaultMaxBackoff    = 30 * time.Second
+	defaultPublishBuffer = 1000
+)
+
+// Settings holds how to connect to the broker
+type Settings struct {
+	// URL is the amqp:// or amqps:// URL, including credentials and vhost
+	URL string
+	// TLS configures amqps connections; nil uses the system roots
+	TLS *tls.Config
+	// MaxBackoff caps the wait between reconnection attempts
+	MaxBackoff time.Duration
+	// PublishBuffer is how many publishes may wait for a reconnection before
+	// further publishes fail with ErrPublishBufferFull
+	PublishBuffer int
+}
+
+// SettingsFromEnv reads the connection settings. RABBITMQ_URL is used as is
+// when set; otherwise the URL is built from RABBITMQ_HOST (default rabbitmq),
+// RABBITMQ_PORT, RABBITMQ_USER and RABBITMQ_PASSWORD (default guest) and
+// RABBITMQ_VHOST (default /). RABBITMQ_TLS=true switches to amqps, trusting
+// RABBITMQ_CA_FILE and presenting RABBITMQ_CERT_FILE/RABBITMQ_KEY_FILE when
+// set. RABBITMQ_RECONNECT_MAX_BACKOFF (default 30s) and
+// RABBITMQ_PUBLISH_BUFFER (default 1000) tune reconnection.
+func SettingsFromEnv() (Settings, error) {
+	settings := Settings{
+		URL:           os.Getenv("RABBITMQ_URL"),
+		MaxBackoff:    defaultMaxBackoff,
+		PublishBuffer: defaultPublishBuffer,
+	}
+	if v := os.Getenv("RABBITMQ_RECONNECT_MAX_BACKOFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			settings.MaxBackoff = d
+		}
+	}
+	if v := os.Getenv("RABBITMQ_PUBLISH_BUFFER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			settings.PublishBuffer = n
+		}
+	}
+
+	useTLS := os.Getenv("RABBITMQ_TLS") == "true"
+	if settings.URL == "" {
+		uri, err := uriFromEnv(useTLS)
+		if err != nil {
+			return Settings{}, err
+		}
+		settings.URL = uri.String()
+	}
+
+	if useTLS {
+		tlsConfig, err := tlsConfigFromEnv()
+		if err != nil {
+			return Settings{}, err
+		}
+		settings.TLS = tlsConfig
+	}
+
+	return settings, nil
+}
+
+// uriFromEnv builds the broker URI from the individual RABBITMQ_* variables
+func uriFromEnv(useTLS bool) (amqp.URI, error) {
+	uri := amqp.URI{
+		Scheme:   "amqp",
+		Host:     getEnv("RABBITMQ_HOST", "rabbitmq"), // Docker default
+		Port:     5672,
+		Username: getEnv("RABBITMQ_USER", "guest"),
+		Password: getEnv("RABBITMQ_PASSWORD", "guest"),
+		Vhost:    getEnv("RABBITMQ_VHOST", "/"),
+	}
+	if useTLS {
+		uri.Scheme = "amqps"
+		uri.Port = 5671
+	}
+
+	// Some environments pass host:port in RABBITMQ_HOST
+	if host, port, err := net.SplitHostPort(uri.Host); err == nil {
+		uri.Host = host
+		if uri.Port, err = strconv.Atoi(port); err != nil {
+			return amqp.URI{}, fmt.Errorf("invalid RABBITMQ_HOST port %q", port)
+		}
+	}
+	if v := os.Getenv("RABBITMQ_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return amqp.URI{}, fmt.Errorf("invalid RABBITMQ_PORT %q", v)
+		}
+		uri.Port = port
+	}
+
+	return uri, nil
+}
+
+// tlsConfigFromEnv loads the CA and client certificate files
+func tlsConfigFromEnv() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile := os.Getenv("RABBITMQ_CA_FILE"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RabbitMQ CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in RabbitMQ CA file %s", caFile)
+		}
+	}
+
+	certFile, keyFile := os.Getenv("RABBITMQ_CERT_FILE"), os.Getenv("RABBITMQ_KEY_FILE")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load RabbitMQ client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
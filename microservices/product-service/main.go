@@ -3,33 +3,38 @@ package main
 import (
 	"log"
 
+	"go-microservices/pkg/platform"
 	"go-microservices/product-service/controller"
 	"go-microservices/product-service/db"
 	"go-microservices/product-service/routes"
-
-	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	config := platform.LoadConfig("product-service", "8080", db.Defaults)
+	server := platform.NewServer(config)
+
 	// Initialize database connection
-	database := db.GetDB()
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	// Initialize database schema
-	db.InitSchema(database)
+	// Apply pending schema migrations; `migrate` subcommands exit here
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	// Create product controller
 	productController := controller.NewProductController(database)
 
-	// Initialize router
-	router := gin.Default()
-
 	// Setup routes
-	routes.SetupRoutes(router, productController)
+	routes.SetupRoutes(server.Router, productController)
 
-	// Start server
-	log.Println("Product Service starting on port 8080...")
-	if err := router.Run(":8080"); err != nil {
+	// Serve until SIGTERM, then let in-flight requests finish
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
 }
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    code TEXT NOT NULL,
+    discount NUMERIC NOT NULL
+);
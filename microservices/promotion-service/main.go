@@ -3,27 +3,33 @@ package main
 import (
 	"log"
 
+	"go-microservices/pkg/platform"
 	"go-microservices/promotion-service/controller"
 	"go-microservices/promotion-service/db"
 	"go-microservices/promotion-service/routes"
-
-	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	database := db.GetDB()
+	config := platform.LoadConfig("promotion-service", "8091", db.Defaults)
+	server := platform.NewServer(config)
+
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	db.InitSchema(database)
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	promoController := controller.NewPromotionController(database)
 
-	router := gin.Default()
-
-	routes.SetupRoutes(router, promoController)
+	routes.SetupRoutes(server.Router, promoController)
 
-	log.Println("Promotion Service starting on port 8091...")
-	if err := router.Run(":8091"); err != nil {
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
@@ -7,8 +7,6 @@ import (
 )
 
 func SetupRoutes(r *gin.Engine, pc *controller.PromotionController) {
-	r.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
-
 	r.POST("/promotions", pc.CreatePromotion)
 	r.GET("/promotions", pc.GetPromotions)
 	// Delete
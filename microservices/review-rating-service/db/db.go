@@ -1,57 +1,22 @@
 package db
 
 import (
-	"database/sql"
-	"fmt"
-	"log"
-	"os"
+	"embed"
 
-	_ "github.com/lib/pq"
+	"go-microservices/pkg/platform"
 )
 
-func GetDB() *sql.DB {
-	host := getEnv("DB_HOST", "review-db")
-	port := getEnv("DB_PORT", "5440")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "canh177")
-	dbname := getEnv("DB_NAME", "reviews_db")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if err := db.Ping(); err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Connected to review DB")
-	return db
+// Defaults is the reviews database, used unless DB_* variables say otherwise
+var Defaults = platform.DBConfig{
+	Host:     "review-db",
+	Port:     "5440",
+	User:     "postgres",
+	Password: "canh177",
+	Name:     "reviews_db",
 }
 
-func getEnv(key, defaultValue string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		return defaultValue
-	}
-	return v
-}
-
-func InitSchema(db *sql.DB) {
-	create := `
-	CREATE TABLE IF NOT EXISTS reviews (
-		id SERIAL PRIMARY KEY,
-		product_id INTEGER NOT NULL,
-		customer_id INTEGER NOT NULL,
-		rating INTEGER NOT NULL,
-		comment TEXT
-	);`
-
-	if _, err := db.Exec(create); err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Reviews table ready")
-}
\ No newline at end of file
+// Migrations holds the service's numbered schema migrations, applied in
+// order at startup or with the migrate subcommand
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -3,27 +3,33 @@ package main
 import (
 	"log"
 
+	"go-microservices/pkg/platform"
 	"go-microservices/review-rating-service/controller"
 	"go-microservices/review-rating-service/db"
 	"go-microservices/review-rating-service/routes"
-
-	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	database := db.GetDB()
+	config := platform.LoadConfig("review-rating-service", "8088", db.Defaults)
+	server := platform.NewServer(config)
+
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	defer database.Close()
 
-	db.InitSchema(database)
+	if exit, err := server.Migrate(database, db.Migrations); err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	} else if exit {
+		return
+	}
 
 	reviewController := controller.NewReviewController(database)
 
-	router := gin.Default()
-
-	routes.SetupRoutes(router, reviewController)
+	routes.SetupRoutes(server.Router, reviewController)
 
-	log.Println("Review & Rating Service starting on port 8088...")
-	if err := router.Run(":8088"); err != nil {
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
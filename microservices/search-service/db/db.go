@@ -1,45 +1,12 @@
 package db
 
-import (
-	"database/sql"
-	"fmt"
-	"log"
-	"os"
-
-	_ "github.com/lib/pq"
-)
-
-func GetDB() *sql.DB {
-	host := getEnv("DB_HOST", "search-db")
-	port := getEnv("DB_PORT", "5441")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "canh177")
-	dbname := getEnv("DB_NAME", "search_db")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if err := db.Ping(); err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Connected to search DB")
-	return db
-}
-
-func getEnv(key, defaultValue string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		return defaultValue
-	}
-	return v
+import "go-microservices/pkg/platform"
+
+// Defaults is the search database, used unless DB_* variables say otherwise
+var Defaults = platform.DBConfig{
+	Host:     "search-db",
+	Port:     "5441",
+	User:     "postgres",
+	Password: "canh177",
+	Name:     "search_db",
 }
-
-func InitSchema(db *sql.DB) {
-	// For now no tables required; placeholder
-	log.Println("Search DB initialized (no tables required)")
-}
\ No newline at end of file
@@ -3,27 +3,27 @@ package main
 import (
 	"log"
 
+	"go-microservices/pkg/platform"
 	"go-microservices/search-service/controller"
 	"go-microservices/search-service/db"
 	"go-microservices/search-service/routes"
-
-	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	database := db.GetDB()
-	defer database.Close()
+	config := platform.LoadConfig("search-service", "8089", db.Defaults)
+	server := platform.NewServer(config)
 
-	db.InitSchema(database)
+	database, err := server.OpenDB()
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	defer database.Close()
 
 	searchController := controller.NewSearchController(database)
 
-	router := gin.Default()
-
-	routes.SetupRoutes(router, searchController)
+	routes.SetupRoutes(server.Router, searchController)
 
-	log.Println("Search Service starting on port 8089...")
-	if err := router.Run(":8089"); err != nil {
+	if err := server.Run(); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
@@ -7,8 +7,6 @@ import (
 )
 
 func SetupRoutes(r *gin.Engine, sc *controller.SearchController) {
-	r.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok"}) })
-
 	// Search endpoint
 	r.GET("/search", sc.SearchProducts)
 }
\ No newline at end of file
@@ -159,7 +159,10 @@ func TestEndToEnd_Flow(t *testing.T) {
 	}
 
 	// 7) Create an order with payment (auth required)
-	order := map[string]interface{}{"product_id": 1, "quantity": 2, "total_price": 100.0, "currency": "usd"}
+	order := map[string]interface{}{
+		"items":    []map[string]interface{}{{"product_id": 1, "quantity": 2, "unit_price": 50.0}},
+		"currency": "usd",
+	}
 	ob, _ := json.Marshal(order)
 	oreq, _ := http.NewRequest("POST", "http://localhost:8000/api/v1/orders/with-payment", bytes.NewReader(ob))
 	oreq.Header.Set("Content-Type", "application/json")
@@ -201,8 +204,8 @@ func TestEndToEnd_Flow(t *testing.T) {
 		t.Fatalf("confirm payment failed: status=%d body=%s", confirmRes.StatusCode, string(b))
 	}
 
-	// 9) Wait for order status to become 'completed' (poll)
-	completed := false
+	// 9) Wait for order status to become 'paid' (poll)
+	paid := false
 	for i := 0; i < 10; i++ {
 		goreq, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:8000/api/v1/orders/%d", orderResID), nil)
 		goreq.Header.Set("Authorization", "Bearer "+loginResp.AccessToken)
@@ -218,14 +221,14 @@ func TestEndToEnd_Flow(t *testing.T) {
 		if err := json.NewDecoder(gores.Body).Decode(&or); err != nil {
 			t.Fatalf("failed to decode order response: %v", err)
 		}
-		if or.Status == "completed" {
-			completed = true
+		if or.Status == "paid" {
+			paid = true
 			break
 		}
 		time.Sleep(1 * time.Second)
 	}
-	if !completed {
-		t.Fatalf("order did not transition to completed after payment confirmation")
+	if !paid {
+		t.Fatalf("order did not transition to paid after payment confirmation")
 	}
 
 	// Success
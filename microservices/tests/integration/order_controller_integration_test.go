@@ -2,9 +2,9 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -15,7 +15,8 @@ import (
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/db"
 	"go-microservices/order-service/model"
-	"go-microservices/order-service/queue"
+	"go-microservices/pkg/platform"
+	"go-microservices/pkg/queue"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -29,15 +30,8 @@ func setupIntegrationTestEnvironment(t *testing.T) (*gin.Engine, *sql.DB, func()
 	}
 
 	// Setup database
-	database, err := func() (*sql.DB, error) {
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Printf("Database connection failed: %v\n", r)
-			}
-		}()
-		return db.GetDB(), nil
-	}()
-	if database == nil || err != nil {
+	database, err := platform.OpenDB(platform.DBConfigFromEnv(db.Defaults))
+	if err != nil {
 		t.Skipf("Failed to initialize database: %v", err)
 	}
 
@@ -69,7 +63,7 @@ func setupIntegrationTestEnvironment(t *testing.T) (*gin.Engine, *sql.DB, func()
 		}
 
 		// Clean up Redis
-		cache.Flush()
+		cache.Flush(context.Background())
 		cache.Close()
 
 		// Close RabbitMQ
@@ -89,9 +83,8 @@ func TestCreateOrderIntegration_Success(t *testing.T) {
 
 	// Prepare test data
 	order := model.Order{
-		ProductID:  1,
 		CustomerID: 999, // Use a test customer ID
-		Quantity:   1,
+		Items:      []model.OrderItem{{ProductID: 1, Quantity: 1}},
 	}
 
 	// Create request
@@ -116,7 +109,7 @@ func TestCreateOrderIntegration_Success(t *testing.T) {
 	// Test that order is cached
 	cacheKey := "order:" + strconv.Itoa(int(createdOrder.ID))
 	var cachedOrder model.Order
-	err = cache.Get(cacheKey, &cachedOrder)
+	err = cache.Get(context.Background(), cacheKey, &cachedOrder)
 	assert.NoError(t, err, "Cache lookup failed")
 	assert.Equal(t, createdOrder.ID, cachedOrder.ID)
 }
@@ -131,9 +124,8 @@ func TestGetOrderIntegration_Success(t *testing.T) {
 
 	// First create an order
 	order := model.Order{
-		ProductID:  1,
 		CustomerID: 999,
-		Quantity:   1,
+		Items:      []model.OrderItem{{ProductID: 1, Quantity: 1}},
 	}
 	orderJSON, _ := json.Marshal(order)
 	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(orderJSON))
@@ -13,7 +13,7 @@ import (
 	"go-microservices/order-service/cache"
 	"go-microservices/order-service/controller"
 	"go-microservices/order-service/model"
-	"go-microservices/order-service/queue"
+	"go-microservices/pkg/queue"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -58,9 +58,8 @@ func TestOrderFlowIntegration(t *testing.T) {
 
 	// Test creating an order
 	order := model.Order{
-		ProductID:  1,
 		CustomerID: 1,
-		Quantity:   2,
+		Items:      []model.OrderItem{{ProductID: 1, Quantity: 2}},
 	}
 	orderJSON, _ := json.Marshal(order)
 
@@ -79,14 +78,14 @@ func TestOrderFlowIntegration(t *testing.T) {
 	// Test retrieving the order from cache
 	cacheKey := fmt.Sprintf("order:%d", createdOrder.ID)
 	var cachedOrder model.Order
-	err = cache.Get(cacheKey, &cachedOrder)
+	err = cache.Get(context.Background(), cacheKey, &cachedOrder)
 	assert.NoError(t, err)
 	assert.Equal(t, createdOrder.ID, cachedOrder.ID)
 
 	// Test batch order creation
 	orders := []model.Order{
-		{ProductID: 1, CustomerID: 1, Quantity: 1},
-		{ProductID: 2, CustomerID: 1, Quantity: 2},
+		{CustomerID: 1, Items: []model.OrderItem{{ProductID: 1, Quantity: 1}}},
+		{CustomerID: 1, Items: []model.OrderItem{{ProductID: 2, Quantity: 2}}},
 	}
 	batchJSON, _ := json.Marshal(orders)
 
@@ -109,14 +108,14 @@ func TestCacheIntegration(t *testing.T) {
 
 	// Create test data
 	testOrder := model.Order{
-		ID:        1,
-		ProductID: 1,
-		Status:    "pending",
+		ID:     1,
+		Items:  []model.OrderItem{{ProductID: 1, Quantity: 1}},
+		Status: "pending",
 	}
 
 	// Set in cache
 	cacheKey := "order:1"
-	err := cache.Set(cacheKey, testOrder, 1*time.Minute)
+	err := cache.Set(context.Background(), cacheKey, testOrder, 1*time.Minute)
 	assert.NoError(t, err)
 
 	// Test retrieving from cache via API
@@ -164,9 +163,8 @@ func TestMessageQueueIntegration(t *testing.T) {
 
 	// Create an order
 	order := model.Order{
-		ProductID:  1,
 		CustomerID: 1,
-		Quantity:   2,
+		Items:      []model.OrderItem{{ProductID: 1, Quantity: 2}},
 	}
 	orderJSON, _ := json.Marshal(order)
 
@@ -183,7 +181,7 @@ func TestMessageQueueIntegration(t *testing.T) {
 		var receivedOrder model.Order
 		err := json.Unmarshal(msg, &receivedOrder)
 		assert.NoError(t, err)
-		assert.Equal(t, order.ProductID, receivedOrder.ProductID)
+		assert.Equal(t, order.Items[0].ProductID, receivedOrder.Items[0].ProductID)
 	case <-time.After(5 * time.Second):
 		t.Fatal("Timeout waiting for message")
 	}
@@ -0,0 +1,305 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/worker"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBatchJobStore is a mock implementation of BatchJobStore
+type MockBatchJobStore struct {
+	mock.Mock
+}
+
+func (m *MockBatchJobStore) CreateJob(job *model.BatchJob, orders []model.Order) error {
+	args := m.Called(job, orders)
+	return args.Error(0)
+}
+
+func (m *MockBatchJobStore) GetJob(id int) (*model.BatchJob, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.BatchJob), args.Error(1)
+}
+
+func (m *MockBatchJobStore) Claim(id int) (func(), bool, error) {
+	args := m.Called(id)
+	return func() {}, args.Bool(0), args.Error(1)
+}
+
+func (m *MockBatchJobStore) PendingOrders(id int) ([]worker.Job, error) {
+	args := m.Called(id)
+	return args.Get(0).([]worker.Job), args.Error(1)
+}
+
+func (m *MockBatchJobStore) CreateOrders(id int, orders map[int]*model.Order) error {
+	args := m.Called(id, orders)
+	return args.Error(0)
+}
+
+func (m *MockBatchJobStore) RecordItems(id int, items []model.BatchItemResult) error {
+	args := m.Called(id, items)
+	return args.Error(0)
+}
+
+func (m *MockBatchJobStore) FinishJob(id int, status string, errMsg string) error {
+	args := m.Called(id, status, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockBatchJobStore) UnfinishedJobs() ([]int, error) {
+	args := m.Called()
+	return args.Get(0).([]int), args.Error(1)
+}
+
+// setupBatchJobTest wires the async batch endpoints to a mock store; like
+// setupBatchTest, product 1 is in stock and product 2 is not
+func setupBatchJobTest() (*gin.Engine, *controller.OrderController, *MockBatchJobStore) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockInventory := new(MockInventoryService)
+	mockInventory.On("CheckAvailability", 1, mock.Anything).Return(true, nil).Maybe()
+	mockInventory.On("CheckAvailability", 2, mock.Anything).Return(false, nil).Maybe()
+	mockProduct := new(MockProductService)
+	for id, product := range catalogue {
+		mockProduct.On("GetProduct", id).Return(product, nil).Maybe()
+	}
+
+	store := new(MockBatchJobStore)
+	orderController := &controller.OrderController{
+		OrderRepo:        new(MockOrderRepository),
+		InventoryService: mockInventory,
+		ProductService:   mockProduct,
+	}
+	orderController.Batches = controller.NewBatchRunner(store, orderController)
+	router.POST("/orders/batch", orderController.CreateBatchOrders)
+	router.GET("/orders/batch/:jobId", orderController.GetBatchJob)
+
+	return router, orderController, store
+}
+
+func TestCreateBatchOrders_AsyncQueuesJob(t *testing.T) {
+	router, _, store := setupBatchJobTest()
+
+	store.On("CreateJob", mock.AnythingOfType("*model.BatchJob"), mock.MatchedBy(func(orders []model.Order) bool {
+		return len(orders) == 5
+	})).Return(nil).Run(func(args mock.Arguments) {
+		job := args.Get(0).(*model.BatchJob)
+		assert.Equal(t, 1, job.CustomerID)
+		assert.Equal(t, controller.BatchModeAllOrNothing, job.Mode)
+		job.ID = 42
+		job.Status = model.BatchJobQueued
+		job.Total = 5
+	})
+
+	w, response := postBatch(router, "?async=true&mode=all_or_nothing", batchOrders())
+
+	assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, "/orders/batch/42", w.Header().Get("Location"))
+	assert.Equal(t, float64(42), response["job_id"])
+	assert.Equal(t, "queued", response["status"])
+	assert.Equal(t, float64(5), response["total_orders"])
+	store.AssertExpectations(t)
+}
+
+func TestCreateBatchOrders_AsyncAllowsLargerBatches(t *testing.T) {
+	router, _, store := setupBatchJobTest()
+	store.On("CreateJob", mock.Anything, mock.Anything).Return(nil)
+
+	orders := make([]model.Order, 1000)
+	w, _ := postBatch(router, "?async=true", orders)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w, _ = postBatch(router, "", orders)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = postBatch(router, "?async=maybe", batchOrders())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func getBatchJob(router *gin.Engine, id string, userID string, roles string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/orders/batch/"+id, nil)
+	req.Header.Set("X-User-Id", userID)
+	if roles != "" {
+		req.Header.Set("X-User-Roles", roles)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetBatchJob_OwnerOrAdmin(t *testing.T) {
+	router, _, store := setupBatchJobTest()
+	store.On("GetJob", 42).Return(&model.BatchJob{
+		ID: 42, CustomerID: 7, Mode: controller.BatchModePartial, Status: model.BatchJobRunning,
+		Total: 3, Processed: 2, Successful: 1, Failed: 1,
+		Items: []model.BatchItemResult{
+			{Index: 0, Status: model.BatchItemCreated, OrderID: 100},
+			{Index: 1, Status: model.BatchItemFailed, Error: "Product 2 is not available", Code: http.StatusBadRequest},
+			{Index: 2, Status: model.BatchItemPending},
+		},
+	}, nil)
+	store.On("GetJob", 43).Return(nil, sql.ErrNoRows)
+
+	w := getBatchJob(router, "42", "7", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var job model.BatchJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, 42, job.ID)
+	assert.Equal(t, 2, job.Processed)
+	assert.Len(t, job.Items, 3)
+	assert.Equal(t, "Product 2 is not available", job.Items[1].Error)
+
+	assert.Equal(t, http.StatusForbidden, getBatchJob(router, "42", "8", "").Code)
+	assert.Equal(t, http.StatusOK, getBatchJob(router, "42", "1", "admin").Code)
+	assert.Equal(t, http.StatusNotFound, getBatchJob(router, "43", "7", "").Code)
+	assert.Equal(t, http.StatusBadRequest, getBatchJob(router, "abc", "7", "").Code)
+}
+
+// pendingJobs returns the orders of a resumed job: the first two orders of
+// its batch already ran before the restart
+func pendingJobs() []worker.Job {
+	return []worker.Job{
+		{Index: 2, Order: model.Order{Items: []model.OrderItem{{ProductID: 1, Quantity: 1}}}},
+		{Index: 3, Order: model.Order{Items: []model.OrderItem{{ProductID: 2, Quantity: 1}}}},
+	}
+}
+
+func TestBatchRunner_PartialResumesPendingOrders(t *testing.T) {
+	_, oc, store := setupBatchJobTest()
+	store.On("Claim", 42).Return(true, nil)
+	store.On("GetJob", 42).Return(&model.BatchJob{ID: 42, CustomerID: 7, Mode: controller.BatchModePartial}, nil)
+	store.On("PendingOrders", 42).Return(pendingJobs(), nil)
+	store.On("CreateOrders", 42, mock.MatchedBy(func(orders map[int]*model.Order) bool {
+		order, ok := orders[2]
+		return len(orders) == 1 && ok && order.CustomerID == 7 && order.TotalPrice > 0
+	})).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(map[int]*model.Order)[2].ID = 100
+	})
+	store.On("RecordItems", 42, mock.MatchedBy(func(items []model.BatchItemResult) bool {
+		return len(items) == 1 && items[0].Index == 3 && items[0].Status == model.BatchItemFailed &&
+			items[0].Code == http.StatusBadRequest
+	})).Return(nil)
+	store.On("FinishJob", 42, model.BatchJobCompleted, "").Return(nil)
+
+	oc.Batches.Run(context.Background(), 42)
+
+	store.AssertExpectations(t)
+}
+
+func TestBatchRunner_AllOrNothingRecordsRejection(t *testing.T) {
+	_, oc, store := setupBatchJobTest()
+	store.On("Claim", 42).Return(true, nil)
+	store.On("GetJob", 42).Return(&model.BatchJob{ID: 42, CustomerID: 7, Mode: controller.BatchModeAllOrNothing}, nil)
+	store.On("PendingOrders", 42).Return(pendingJobs(), nil)
+	store.On("RecordItems", 42, mock.MatchedBy(func(items []model.BatchItemResult) bool {
+		return len(items) == 2 &&
+			items[0].Index == 2 && items[0].Status == model.BatchItemNotCreated &&
+			items[1].Index == 3 && items[1].Status == model.BatchItemFailed
+	})).Return(nil)
+	store.On("FinishJob", 42, model.BatchJobFailed, mock.AnythingOfType("string")).Return(nil)
+
+	oc.Batches.Run(context.Background(), 42)
+
+	store.AssertExpectations(t)
+	store.AssertNotCalled(t, "CreateOrders", mock.Anything, mock.Anything)
+}
+
+func TestBatchRunner_SkipsJobsClaimedElsewhere(t *testing.T) {
+	_, oc, store := setupBatchJobTest()
+	store.On("Claim", 42).Return(false, nil)
+
+	oc.Batches.Run(context.Background(), 42)
+
+	store.AssertNotCalled(t, "PendingOrders", mock.Anything)
+	store.AssertNotCalled(t, "FinishJob", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBatchRunner_InterruptedJobStaysResumable(t *testing.T) {
+	_, oc, store := setupBatchJobTest()
+	store.On("Claim", 42).Return(true, nil)
+	store.On("GetJob", 42).Return(&model.BatchJob{ID: 42, CustomerID: 7, Mode: controller.BatchModePartial}, nil)
+	store.On("PendingOrders", 42).Return(pendingJobs(), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	oc.Batches.Run(ctx, 42)
+
+	// Nothing is recorded, so every order is still pending on resume
+	store.AssertNotCalled(t, "RecordItems", mock.Anything, mock.Anything)
+	store.AssertNotCalled(t, "CreateOrders", mock.Anything, mock.Anything)
+	store.AssertNotCalled(t, "FinishJob", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDBBatchJobStore_CreateOrdersMarksItemsInSameTransaction(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT id FROM batch_jobs WHERE id = \$1 FOR UPDATE`).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	sqlMock.ExpectQuery(`INSERT INTO orders`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	sqlMock.ExpectQuery(`INSERT INTO order_items`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(`INSERT INTO order_status_history`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(`UPDATE batch_job_items SET status = \$1, order_id = \$2`).
+		WithArgs(model.BatchItemCreated, 10, 42, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec(`UPDATE batch_jobs j`).WithArgs(42, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	var created []int
+	repo := &controller.DBOrderRepository{DB: db, OnTransition: func(entry model.OrderStatusHistory) {
+		created = append(created, entry.OrderID)
+	}}
+	store := controller.NewDBBatchJobStore(db, repo)
+	err = store.CreateOrders(42, map[int]*model.Order{2: {
+		CustomerID: 7,
+		Items:      []model.OrderItem{{ProductID: 1, Quantity: 1, UnitPrice: 10}},
+	}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{10}, created)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDBBatchJobStore_FailedJobSettlesPendingItems(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// Creating the orders of an all-or-nothing job failed, so none was created
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT id FROM batch_jobs WHERE id = \$1 FOR UPDATE`).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	sqlMock.ExpectExec(`UPDATE batch_job_items SET status = \$1 WHERE job_id = \$2 AND status = \$3`).
+		WithArgs(model.BatchItemNotCreated, 42, model.BatchItemPending).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec(`UPDATE batch_jobs j`).WithArgs(42, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec(`UPDATE batch_jobs SET status = \$1, error = \$2`).
+		WithArgs(model.BatchJobFailed, "failed to create orders: connection reset", sqlmock.AnyArg(), 42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	store := controller.NewDBBatchJobStore(db, nil)
+	assert.NoError(t, store.FinishJob(42, model.BatchJobFailed, "failed to create orders: connection reset"))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,217 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/worker"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProcessBatch_ReturnsResultsInBatchOrder(t *testing.T) {
+	orders := make([]model.Order, 20)
+	for i := range orders {
+		orders[i].CustomerID = i + 1
+	}
+
+	results := worker.ProcessBatch(context.Background(), orders, 4, func(ctx context.Context, job worker.Job) worker.Result {
+		if job.Order.CustomerID%5 == 0 {
+			return worker.Result{Index: job.Index, Error: errors.New("rejected")}
+		}
+		return worker.Result{Index: job.Index, OrderID: 100 + job.Order.CustomerID}
+	})
+
+	assert.Len(t, results, 20)
+	for i, result := range results {
+		assert.Equal(t, i, result.Index)
+		if (i+1)%5 == 0 {
+			assert.Error(t, result.Error)
+		} else {
+			assert.Equal(t, 101+i, result.OrderID)
+		}
+	}
+}
+
+func TestProcessBatch_CancellationStopsOutstandingWork(t *testing.T) {
+	orders := make([]model.Order, 50)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var started int32
+	results := worker.ProcessBatch(ctx, orders, 2, func(ctx context.Context, job worker.Job) worker.Result {
+		if atomic.AddInt32(&started, 1) == 3 {
+			cancel()
+		}
+		return worker.Result{Index: job.Index, OrderID: job.Index + 1}
+	})
+
+	assert.Len(t, results, 50)
+	assert.Less(t, int(atomic.LoadInt32(&started)), 50)
+	cancelled := 0
+	for _, result := range results {
+		if errors.Is(result.Error, context.Canceled) {
+			cancelled++
+		} else {
+			// Jobs that ran keep their result
+			assert.NotZero(t, result.OrderID)
+		}
+	}
+	assert.Equal(t, 50-int(atomic.LoadInt32(&started)), cancelled)
+}
+
+func TestProcessBatch_TimeoutReportsUnstartedOrders(t *testing.T) {
+	orders := make([]model.Order, 5)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	results := worker.ProcessBatch(ctx, orders, 1, func(ctx context.Context, job worker.Job) worker.Result {
+		<-ctx.Done()
+		return worker.Result{Index: job.Index, Error: ctx.Err()}
+	})
+
+	for _, result := range results {
+		assert.ErrorIs(t, result.Error, context.DeadlineExceeded)
+	}
+}
+
+// setupBatchTest wires CreateBatchOrders to mocks; product 1 is in stock,
+// product 2 is not
+func setupBatchTest() (*gin.Engine, *MockOrderRepository) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockOrderRepo := new(MockOrderRepository)
+	mockInventory := new(MockInventoryService)
+	mockInventory.On("CheckAvailability", 1, mock.Anything).Return(true, nil).Maybe()
+	mockInventory.On("CheckAvailability", 2, mock.Anything).Return(false, nil).Maybe()
+	mockProduct := new(MockProductService)
+	for id, product := range catalogue {
+		mockProduct.On("GetProduct", id).Return(product, nil).Maybe()
+	}
+
+	orderController := &controller.OrderController{
+		OrderRepo:        mockOrderRepo,
+		InventoryService: mockInventory,
+		ProductService:   mockProduct,
+	}
+	router.POST("/orders/batch", orderController.CreateBatchOrders)
+
+	return router, mockOrderRepo
+}
+
+func postBatch(router *gin.Engine, query string, orders []model.Order) (*httptest.ResponseRecorder, map[string]interface{}) {
+	body, _ := json.Marshal(orders)
+	req := httptest.NewRequest("POST", "/orders/batch"+query, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func batchOrders() []model.Order {
+	return []model.Order{
+		{Items: []model.OrderItem{{ProductID: 1, Quantity: 1}}},
+		{Items: []model.OrderItem{{ProductID: 2, Quantity: 1}}},
+		{Items: []model.OrderItem{}},
+		{CustomerID: 2, Items: []model.OrderItem{{ProductID: 1, Quantity: 1}}},
+		{Items: []model.OrderItem{{ProductID: 1, Quantity: 3}}},
+	}
+}
+
+func TestCreateBatchOrders_PartialCreatesValidOrders(t *testing.T) {
+	router, mockOrderRepo := setupBatchTest()
+
+	var nextID int32 = 100
+	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil).Run(func(args mock.Arguments) {
+		order := args.Get(0).(*model.Order)
+		order.ID = int(atomic.AddInt32(&nextID, 1))
+		assert.Equal(t, 1, order.CustomerID)
+		assert.NotZero(t, order.TotalPrice)
+	})
+
+	w, response := postBatch(router, "", batchOrders())
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "partial", response["mode"])
+	assert.Equal(t, float64(2), response["successful"])
+	assert.Equal(t, float64(3), response["failed"])
+	mockOrderRepo.AssertNumberOfCalls(t, "InsertOrder", 2)
+
+	results := response["results"].([]interface{})
+	codes := make([]float64, len(results))
+	for i, r := range results {
+		item := r.(map[string]interface{})
+		assert.Equal(t, float64(i), item["index"])
+		if item["status"] == "created" {
+			assert.NotZero(t, item["order_id"])
+		} else {
+			codes[i] = item["code"].(float64)
+		}
+	}
+	assert.Equal(t, []float64{0, http.StatusBadRequest, http.StatusBadRequest, http.StatusForbidden, 0}, codes)
+	assert.NotEmpty(t, response["processing_time"])
+}
+
+func TestCreateBatchOrders_AllOrNothingRejectsWholeBatch(t *testing.T) {
+	router, mockOrderRepo := setupBatchTest()
+
+	w, response := postBatch(router, "?mode=all_or_nothing", batchOrders())
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	assert.Equal(t, float64(0), response["successful"])
+	assert.Equal(t, float64(3), response["failed"])
+	item := response["results"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "not_created", item["status"])
+	mockOrderRepo.AssertNotCalled(t, "InsertOrder", mock.Anything)
+	mockOrderRepo.AssertNotCalled(t, "InsertOrders", mock.Anything)
+}
+
+func TestCreateBatchOrders_AllOrNothingInsertsInOneTransaction(t *testing.T) {
+	router, mockOrderRepo := setupBatchTest()
+
+	mockOrderRepo.On("InsertOrders", mock.MatchedBy(func(orders []*model.Order) bool {
+		return len(orders) == 2
+	})).Return(nil).Run(func(args mock.Arguments) {
+		for i, order := range args.Get(0).([]*model.Order) {
+			order.ID = 200 + i
+		}
+	})
+
+	orders := []model.Order{
+		{Items: []model.OrderItem{{ProductID: 1, Quantity: 1}}},
+		{Items: []model.OrderItem{{ProductID: 1, Quantity: 2}}},
+	}
+	w, response := postBatch(router, "?mode=all_or_nothing", orders)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, float64(2), response["successful"])
+	results := response["results"].([]interface{})
+	assert.Equal(t, float64(200), results[0].(map[string]interface{})["order_id"])
+	assert.Equal(t, float64(201), results[1].(map[string]interface{})["order_id"])
+	mockOrderRepo.AssertNotCalled(t, "InsertOrder", mock.Anything)
+}
+
+func TestCreateBatchOrders_RejectsInvalidRequests(t *testing.T) {
+	router, _ := setupBatchTest()
+
+	w, _ := postBatch(router, "?mode=sometimes", batchOrders())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = postBatch(router, "", []model.Order{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,353 @@
+package unit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/saga"
+	"go-microservices/order-service/service"
+	paymentcontroller "go-microservices/payment-service/controller"
+	"go-microservices/payment-service/provider"
+	paymentroutes "go-microservices/payment-service/routes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// memorySagaStore keeps saga state in memory; saving failStep fails
+type memorySagaStore struct {
+	sagas    map[int]saga.CheckoutSaga
+	nextID   int
+	failStep string
+}
+
+func newMemorySagaStore() *memorySagaStore {
+	return &memorySagaStore{sagas: make(map[int]saga.CheckoutSaga)}
+}
+
+func (s *memorySagaStore) Create(cs *saga.CheckoutSaga) error {
+	s.nextID++
+	cs.ID = s.nextID
+	s.sagas[cs.ID] = *cs
+	return nil
+}
+
+func (s *memorySagaStore) Save(cs *saga.CheckoutSaga) error {
+	if cs.Step == s.failStep && cs.Status == saga.StatusRunning {
+		return errors.New("database unavailable")
+	}
+	s.sagas[cs.ID] = *cs
+	return nil
+}
+
+func (s *memorySagaStore) ClaimStale(before time.Time) ([]*saga.CheckoutSaga, error) {
+	var stale []*saga.CheckoutSaga
+	for id := range s.sagas {
+		cs := s.sagas[id]
+		if (cs.Status == saga.StatusRunning || cs.Status == saga.StatusCompensating) && cs.UpdatedAt.Before(before) {
+			cs.UpdatedAt = time.Now()
+			s.sagas[id] = cs
+			stale = append(stale, &cs)
+		}
+	}
+	return stale, nil
+}
+
+type MockReserver struct {
+	mock.Mock
+}
+
+func (m *MockReserver) ReserveItems(reference string, items []model.OrderItem) (*model.Reservation, error) {
+	args := m.Called(reference, items)
+	reservation, _ := args.Get(0).(*model.Reservation)
+	return reservation, args.Error(1)
+}
+
+func (m *MockReserver) ReleaseReservation(reservationID int) error {
+	args := m.Called(reservationID)
+	return args.Error(0)
+}
+
+type MockPaymentService struct {
+	mock.Mock
+}
+
+func (m *MockPaymentService) CreatePayment(orderID int, customerID int, amount float64, currency string) (*service.PaymentResponse, error) {
+	args := m.Called(orderID, customerID, amount, currency)
+	resp, _ := args.Get(0).(*service.PaymentResponse)
+	return resp, args.Error(1)
+}
+
+func (m *MockPaymentService) CancelPayment(paymentID int, customerID int) error {
+	args := m.Called(paymentID, customerID)
+	return args.Error(0)
+}
+
+func (m *MockPaymentService) CancelOrderPayments(orderID int, customerID int) error {
+	args := m.Called(orderID, customerID)
+	return args.Error(0)
+}
+
+func (m *MockPaymentService) RefundOrder(orderID int, customerID int, reason string) (float64, error) {
+	args := m.Called(orderID, customerID, reason)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPaymentService) RefundAmount(orderID int, customerID int, amount float64, reason string, idempotencyKey string) (float64, error) {
+	args := m.Called(orderID, customerID, amount, reason, idempotencyKey)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func newCheckoutOrder() *model.Order {
+	return &model.Order{
+		CustomerID: 5,
+		Items:      []model.OrderItem{{ProductID: 1, Quantity: 2, UnitPrice: 10}},
+		TotalPrice: 20,
+	}
+}
+
+func TestCheckoutSaga_Success(t *testing.T) {
+	store := newMemorySagaStore()
+	reserver := new(MockReserver)
+	orders := new(MockOrderRepository)
+	payments := new(MockPaymentService)
+
+	payment := &service.PaymentResponse{}
+	payment.Payment.ID = 77
+	reserver.On("ReserveItems", "checkout-saga-1", mock.Anything).Return(&model.Reservation{ID: 9}, nil)
+	orders.On("InsertOrder", mock.AnythingOfType("*model.Order")).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.Order).ID = 42
+	}).Return(nil)
+	payments.On("CreatePayment", 42, 5, 20.0, "usd").Return(payment, nil)
+
+	orchestrator := saga.NewCheckoutOrchestrator(store, reserver, orders, payments)
+	order := newCheckoutOrder()
+	cs, err := orchestrator.Execute(order, "usd")
+
+	assert.NoError(t, err)
+	assert.Equal(t, saga.StatusCompleted, cs.Status)
+	assert.Equal(t, 42, order.ID)
+	assert.Equal(t, 9, order.ReservationID)
+	assert.Equal(t, 77, cs.PaymentID)
+	reserver.AssertNotCalled(t, "ReleaseReservation", mock.Anything)
+	orders.AssertNotCalled(t, "UpdateOrderStatus", mock.Anything, mock.Anything)
+}
+
+func TestCheckoutSaga_PaymentFailureCompensates(t *testing.T) {
+	store := newMemorySagaStore()
+	reserver := new(MockReserver)
+	orders := new(MockOrderRepository)
+	payments := new(MockPaymentService)
+
+	reserver.On("ReserveItems", mock.Anything, mock.Anything).Return(&model.Reservation{ID: 9}, nil)
+	reserver.On("ReleaseReservation", 9).Return(nil)
+	orders.On("InsertOrder", mock.AnythingOfType("*model.Order")).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.Order).ID = 42
+	}).Return(nil)
+	orders.On("UpdateOrderStatus", 42, "cancelled").Return(nil)
+	payments.On("CreatePayment", 42, 5, 20.0, "usd").Return(nil, errors.New("payment service down"))
+
+	orchestrator := saga.NewCheckoutOrchestrator(store, reserver, orders, payments)
+	cs, err := orchestrator.Execute(newCheckoutOrder(), "usd")
+
+	assert.Error(t, err)
+	assert.Equal(t, saga.StatusCompensated, cs.Status)
+	assert.Contains(t, cs.Error, "create_payment")
+	reserver.AssertCalled(t, "ReleaseReservation", 9)
+	orders.AssertCalled(t, "UpdateOrderStatus", 42, "cancelled")
+}
+
+func TestCheckoutSaga_ReservationFailureSkipsOrder(t *testing.T) {
+	store := newMemorySagaStore()
+	reserver := new(MockReserver)
+	orders := new(MockOrderRepository)
+	payments := new(MockPaymentService)
+
+	reserver.On("ReserveItems", mock.Anything, mock.Anything).Return(nil, service.ErrInsufficientStock)
+
+	orchestrator := saga.NewCheckoutOrchestrator(store, reserver, orders, payments)
+	cs, err := orchestrator.Execute(newCheckoutOrder(), "usd")
+
+	assert.ErrorIs(t, err, service.ErrInsufficientStock)
+	assert.Equal(t, saga.StatusCompensated, cs.Status)
+	orders.AssertNotCalled(t, "InsertOrder", mock.Anything)
+	reserver.AssertNotCalled(t, "ReleaseReservation", mock.Anything)
+	payments.AssertNotCalled(t, "CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCheckoutSaga_FailureAfterPaymentCancelsIntent(t *testing.T) {
+	store := newMemorySagaStore()
+	store.failStep = saga.StepPaymentCreated
+	reserver := new(MockReserver)
+	orders := new(MockOrderRepository)
+	payments := new(MockPaymentService)
+
+	payment := &service.PaymentResponse{}
+	payment.Payment.ID = 77
+	reserver.On("ReserveItems", mock.Anything, mock.Anything).Return(&model.Reservation{ID: 9}, nil)
+	reserver.On("ReleaseReservation", 9).Return(nil)
+	orders.On("InsertOrder", mock.AnythingOfType("*model.Order")).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.Order).ID = 42
+	}).Return(nil)
+	orders.On("UpdateOrderStatus", 42, "cancelled").Return(nil)
+	payments.On("CreatePayment", 42, 5, 20.0, "usd").Return(payment, nil)
+	payments.On("CancelPayment", 77, 5).Return(nil)
+
+	orchestrator := saga.NewCheckoutOrchestrator(store, reserver, orders, payments)
+	cs, err := orchestrator.Execute(newCheckoutOrder(), "usd")
+
+	assert.Error(t, err)
+	assert.Equal(t, saga.StatusCompensated, cs.Status)
+	payments.AssertCalled(t, "CancelPayment", 77, 5)
+	orders.AssertCalled(t, "UpdateOrderStatus", 42, "cancelled")
+	reserver.AssertCalled(t, "ReleaseReservation", 9)
+}
+
+func TestCheckoutSaga_FailedPaymentCancelNeedsAttention(t *testing.T) {
+	store := newMemorySagaStore()
+	reserver := new(MockReserver)
+	orders := new(MockOrderRepository)
+	payments := new(MockPaymentService)
+
+	// Interrupted while compensating a checkout whose intent was created
+	store.sagas[1] = saga.CheckoutSaga{ID: 1, Status: saga.StatusCompensating, Step: saga.StepPaymentCreated,
+		CustomerID: 5, OrderID: 10, ReservationID: 3, PaymentID: 8, Error: "checkout step create_payment failed"}
+	store.nextID = 1
+
+	payments.On("CancelPayment", 8, 5).Return(errors.New("payment service returned status: 409"))
+	orders.On("UpdateOrderStatus", 10, "cancelled").Return(nil)
+	reserver.On("ReleaseReservation", 3).Return(nil)
+
+	orchestrator := saga.NewCheckoutOrchestrator(store, reserver, orders, payments)
+	assert.NoError(t, orchestrator.Recover())
+
+	assert.Equal(t, saga.StatusFailed, store.sagas[1].Status)
+	orders.AssertCalled(t, "UpdateOrderStatus", 10, "cancelled")
+	reserver.AssertCalled(t, "ReleaseReservation", 3)
+}
+
+func TestCheckoutSaga_RecoverAfterRestart(t *testing.T) {
+	store := newMemorySagaStore()
+	reserver := new(MockReserver)
+	orders := new(MockOrderRepository)
+	payments := new(MockPaymentService)
+
+	// One checkout crashed after creating its order, another after creating its payment
+	store.sagas[1] = saga.CheckoutSaga{ID: 1, Status: saga.StatusRunning, Step: saga.StepOrderCreated, OrderID: 10, ReservationID: 3}
+	store.sagas[2] = saga.CheckoutSaga{ID: 2, Status: saga.StatusRunning, Step: saga.StepPaymentCreated, OrderID: 11, ReservationID: 4, PaymentID: 8}
+	store.nextID = 2
+
+	orders.On("UpdateOrderStatus", 10, "cancelled").Return(nil)
+	reserver.On("ReleaseReservation", 3).Return(nil)
+
+	orchestrator := saga.NewCheckoutOrchestrator(store, reserver, orders, payments)
+	assert.NoError(t, orchestrator.Recover())
+
+	assert.Equal(t, saga.StatusCompensated, store.sagas[1].Status)
+	assert.Equal(t, saga.StatusCompleted, store.sagas[2].Status)
+	reserver.AssertNotCalled(t, "ReleaseReservation", 4)
+	payments.AssertNotCalled(t, "CreatePayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	payments.AssertNotCalled(t, "CancelPayment", mock.Anything, mock.Anything)
+}
+
+func TestCheckoutSaga_RecoverLeavesLiveCheckoutsAlone(t *testing.T) {
+	store := newMemorySagaStore()
+	reserver := new(MockReserver)
+	orders := new(MockOrderRepository)
+	payments := new(MockPaymentService)
+
+	// Another replica saved this checkout a moment ago and is still running it
+	store.sagas[1] = saga.CheckoutSaga{ID: 1, Status: saga.StatusRunning, Step: saga.StepOrderCreated, OrderID: 10, ReservationID: 3, UpdatedAt: time.Now()}
+	store.nextID = 1
+
+	orchestrator := saga.NewCheckoutOrchestrator(store, reserver, orders, payments)
+	assert.NoError(t, orchestrator.Recover())
+
+	assert.Equal(t, saga.StatusRunning, store.sagas[1].Status)
+	orders.AssertNotCalled(t, "UpdateOrderStatus", mock.Anything, mock.Anything)
+	reserver.AssertNotCalled(t, "ReleaseReservation", mock.Anything)
+}
+
+func TestDBStore_ClaimStaleSkipsSagasClaimedElsewhere(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	before := time.Now().Add(-saga.DefaultStaleAfter)
+	sqlMock.ExpectQuery(`UPDATE checkout_sagas SET updated_at = \$1\s+WHERE id IN \(\s+SELECT id FROM checkout_sagas\s+` +
+		`WHERE status IN \(\$2, \$3\) AND updated_at < \$4\s+FOR UPDATE SKIP LOCKED\)`).
+		WithArgs(sqlmock.AnyArg(), saga.StatusRunning, saga.StatusCompensating, before).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "step", "customer_id", "order_id", "reservation_id", "payment_id", "payload", "error", "created_at", "updated_at"}).
+			AddRow(2, saga.StatusRunning, saga.StepOrderCreated, 5, 11, 4, 0, []byte(`{}`), "", before, time.Now()).
+			AddRow(1, saga.StatusCompensating, saga.StepInventoryReserved, 5, 0, 3, 0, []byte(`{}`), "out of stock", before, time.Now()))
+
+	sagas, err := saga.NewDBStore(db).ClaimStale(before)
+
+	assert.NoError(t, err)
+	assert.Len(t, sagas, 2)
+	assert.Equal(t, 1, sagas[0].ID)
+	assert.Equal(t, 2, sagas[1].ID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// newPaymentServiceServer serves payment-service's real routes, which require
+// X-User-Id, over a mocked database and the fake provider. Order-service
+// serves order 42 of customer 5, totalling 20.00.
+func newPaymentServiceServer(t *testing.T) sqlmock.Sqlmock {
+	orderService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/orders/42" || r.Header.Get("X-User-Id") != "5" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id": 42, "customer_id": 5, "total_price": 20, "status": "pending"}`))
+	}))
+	t.Cleanup(orderService.Close)
+	t.Setenv("ORDER_SERVICE_URL", orderService.URL)
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	noIdempotency := func(c *gin.Context) { c.Next() }
+	paymentroutes.SetupRoutes(router, paymentcontroller.NewPaymentControllerWithProvider(db, provider.NewFake("")), noIdempotency)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	t.Setenv("PAYMENT_SERVICE_URL", server.URL)
+	return sqlMock
+}
+
+func TestPaymentService_CreateAndCancelPaymentAuthenticate(t *testing.T) {
+	sqlMock := newPaymentServiceServer(t)
+	sqlMock.ExpectQuery(`INSERT INTO payments`).WithArgs(42, 5, 20.0, "usd", "pending",
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(77))
+	payments := service.NewPaymentService()
+
+	payment, err := payments.CreatePayment(42, 5, 20, "usd")
+	assert.NoError(t, err)
+	if !assert.NotNil(t, payment) {
+		return
+	}
+	assert.Equal(t, 77, payment.Payment.ID)
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`FROM payments WHERE id = \$1\s+FOR UPDATE`).WithArgs(77).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "customer_id", "amount", "currency", "status",
+			"stripe_payment_id", "payment_method", "created_at", "updated_at"}).
+			AddRow(77, 42, 5, 20.0, "usd", "pending", payment.Payment.StripePaymentID, "", time.Now(), time.Now()))
+	sqlMock.ExpectExec(`UPDATE payments SET status`).WithArgs("canceled", sqlmock.AnyArg(), 77).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	assert.NoError(t, payments.CancelPayment(77, 5))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
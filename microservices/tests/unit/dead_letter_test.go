@@ -0,0 +1,157 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/pkg/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDeadLetterQueue struct {
+	mock.Mock
+}
+
+func (m *MockDeadLetterQueue) Queues() ([]queue.DeadLetterQueue, error) {
+	args := m.Called()
+	return args.Get(0).([]queue.DeadLetterQueue), args.Error(1)
+}
+
+func (m *MockDeadLetterQueue) List(queueName string, limit int) ([]queue.DeadLetter, error) {
+	args := m.Called(queueName, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]queue.DeadLetter), args.Error(1)
+}
+
+func (m *MockDeadLetterQueue) Get(queueName string, id string) (*queue.DeadLetter, error) {
+	args := m.Called(queueName, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.DeadLetter), args.Error(1)
+}
+
+func (m *MockDeadLetterQueue) Replay(ctx context.Context, queueName string, id string) (int, error) {
+	args := m.Called(queueName, id)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDeadLetterQueue) Purge(queueName string, id string) (int, error) {
+	args := m.Called(queueName, id)
+	return args.Int(0), args.Error(1)
+}
+
+func setupDeadLetterTest() (*gin.Engine, *MockDeadLetterQueue) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockDeadLetters := new(MockDeadLetterQueue)
+	orderController := &controller.OrderController{DeadLetters: mockDeadLetters}
+	router.GET("/admin/dead-letters", orderController.ListDeadLetterQueues)
+	router.GET("/admin/dead-letters/:queue", orderController.ListDeadLetters)
+	router.DELETE("/admin/dead-letters/:queue", orderController.PurgeDeadLetters)
+	router.POST("/admin/dead-letters/:queue/replay", orderController.ReplayDeadLetters)
+	router.GET("/admin/dead-letters/:queue/:messageId", orderController.GetDeadLetter)
+	router.DELETE("/admin/dead-letters/:queue/:messageId", orderController.PurgeDeadLetters)
+	router.POST("/admin/dead-letters/:queue/:messageId/replay", orderController.ReplayDeadLetters)
+
+	return router, mockDeadLetters
+}
+
+func deadLetterRequest(router *gin.Engine, method, path, roles string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-User-Id", "1")
+	req.Header.Set("X-User-Roles", roles)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDeadLetters_RequireAdmin(t *testing.T) {
+	router, mockDeadLetters := setupDeadLetterTest()
+
+	assert.Equal(t, http.StatusForbidden, deadLetterRequest(router, "GET", "/admin/dead-letters/orders.dead", "customer").Code)
+	assert.Equal(t, http.StatusForbidden, deadLetterRequest(router, "POST", "/admin/dead-letters/orders.dead/replay", "customer").Code)
+	assert.Equal(t, http.StatusForbidden, deadLetterRequest(router, "DELETE", "/admin/dead-letters/orders.dead", "customer").Code)
+	mockDeadLetters.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+	mockDeadLetters.AssertNotCalled(t, "Replay", mock.Anything, mock.Anything)
+	mockDeadLetters.AssertNotCalled(t, "Purge", mock.Anything, mock.Anything)
+}
+
+func TestDeadLetters_ListUsesLimit(t *testing.T) {
+	router, mockDeadLetters := setupDeadLetterTest()
+	mockDeadLetters.On("List", "orders.dead", 10).Return([]queue.DeadLetter{
+		{ID: "abc", Queue: "orders", Attempts: 5, Error: "invalid character", Body: "{"},
+	}, nil)
+
+	w := deadLetterRequest(router, "GET", "/admin/dead-letters/orders.dead?limit=10", "admin")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Messages []queue.DeadLetter `json:"messages"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Messages, 1)
+	assert.Equal(t, "{", body.Messages[0].Body)
+
+	assert.Equal(t, http.StatusBadRequest, deadLetterRequest(router, "GET", "/admin/dead-letters/orders.dead?limit=0", "admin").Code)
+}
+
+func TestDeadLetters_MapsErrors(t *testing.T) {
+	router, mockDeadLetters := setupDeadLetterTest()
+	mockDeadLetters.On("List", "unknown", 50).Return(nil, queue.ErrUnknownDeadLetterQueue)
+	mockDeadLetters.On("Get", "orders.dead", "missing").Return(nil, queue.ErrDeadLetterNotFound)
+	mockDeadLetters.On("Queues").Return([]queue.DeadLetterQueue(nil), queue.ErrNotConnected)
+	mockDeadLetters.On("Replay", "cache.dead", "gone").Return(0, queue.ErrOriginalQueueGone)
+
+	assert.Equal(t, http.StatusNotFound, deadLetterRequest(router, "GET", "/admin/dead-letters/unknown", "admin").Code)
+	assert.Equal(t, http.StatusNotFound, deadLetterRequest(router, "GET", "/admin/dead-letters/orders.dead/missing", "admin").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, deadLetterRequest(router, "GET", "/admin/dead-letters", "admin").Code)
+	// A replica's exclusive queue is gone; the message stays dead-lettered
+	assert.Equal(t, http.StatusConflict, deadLetterRequest(router, "POST", "/admin/dead-letters/cache.dead/gone/replay", "admin").Code)
+}
+
+func TestDeadLetters_ReplayOneOrAll(t *testing.T) {
+	router, mockDeadLetters := setupDeadLetterTest()
+	mockDeadLetters.On("Replay", "orders.dead", "abc").Return(1, nil).Once()
+	mockDeadLetters.On("Replay", "orders.dead", "").Return(3, nil).Once()
+
+	one := deadLetterRequest(router, "POST", "/admin/dead-letters/orders.dead/abc/replay", "admin")
+	all := deadLetterRequest(router, "POST", "/admin/dead-letters/orders.dead/replay", "admin")
+
+	assert.Equal(t, http.StatusOK, one.Code)
+	assert.JSONEq(t, `{"replayed": 1}`, one.Body.String())
+	assert.Equal(t, http.StatusOK, all.Code)
+	assert.JSONEq(t, `{"replayed": 3}`, all.Body.String())
+	mockDeadLetters.AssertExpectations(t)
+}
+
+func TestDeadLetters_PartialReplayReportsProgress(t *testing.T) {
+	router, mockDeadLetters := setupDeadLetterTest()
+	mockDeadLetters.On("Replay", "orders.dead", "").Return(2, errors.New("publish failed"))
+
+	w := deadLetterRequest(router, "POST", "/admin/dead-letters/orders.dead/replay", "admin")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"replayed":2`)
+}
+
+func TestDeadLetters_PurgeOneOrAll(t *testing.T) {
+	router, mockDeadLetters := setupDeadLetterTest()
+	mockDeadLetters.On("Purge", "orders.dead", "abc").Return(1, nil).Once()
+	mockDeadLetters.On("Purge", "orders.dead", "").Return(7, nil).Once()
+
+	assert.JSONEq(t, `{"purged": 1}`, deadLetterRequest(router, "DELETE", "/admin/dead-letters/orders.dead/abc", "admin").Body.String())
+	assert.JSONEq(t, `{"purged": 7}`, deadLetterRequest(router, "DELETE", "/admin/dead-letters/orders.dead", "admin").Body.String())
+	mockDeadLetters.AssertExpectations(t)
+}
@@ -0,0 +1,320 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-microservices/pkg/idempotency"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryIdempotencyStore is an in-memory idempotency.Store honouring expiry
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*idempotency.Record
+	expires map[string]time.Time
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{
+		records: make(map[string]*idempotency.Record),
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, key string, requestHash string, lease time.Duration) (*idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok && time.Now().Before(s.expires[key]) {
+		copied := *record
+		return &copied, false, nil
+	}
+	s.records[key] = &idempotency.Record{RequestHash: requestHash}
+	s.expires[key] = time.Now().Add(lease)
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, key string, record *idempotency.Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = record
+	s.expires[key] = time.Now().Add(ttl)
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	delete(s.expires, key)
+	return nil
+}
+
+// hangingIdempotencyStore never answers Reserve before the caller gives up
+type hangingIdempotencyStore struct {
+	*memoryIdempotencyStore
+}
+
+func (s hangingIdempotencyStore) Reserve(ctx context.Context, key string, requestHash string, lease time.Duration) (*idempotency.Record, bool, error) {
+	<-ctx.Done()
+	return nil, false, ctx.Err()
+}
+
+// setupIdempotencyRouter counts handler executions; the handler responds
+// with the given status
+func setupIdempotencyRouter(store idempotency.Store, ttl time.Duration, status *int, calls *int, block chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", idempotency.Middleware(store, ttl), func(c *gin.Context) {
+		*calls++
+		if block != nil {
+			<-block
+		}
+		c.JSON(*status, gin.H{"order_id": *calls})
+	})
+	return router
+}
+
+func postIdempotent(router *gin.Engine, key, user, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", user)
+	if key != "" {
+		req.Header.Set(idempotency.Header, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysIdenticalRetry(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	router := setupIdempotencyRouter(newMemoryIdempotencyStore(), time.Hour, &status, &calls, nil)
+
+	first := postIdempotent(router, "key-1", "1", `{"items":[1]}`)
+	second := postIdempotent(router, "key-1", "1", `{"items":[1]}`)
+
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_DifferentBodyConflicts(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	router := setupIdempotencyRouter(newMemoryIdempotencyStore(), time.Hour, &status, &calls, nil)
+
+	postIdempotent(router, "key-1", "1", `{"items":[1]}`)
+	w := postIdempotent(router, "key-1", "1", `{"items":[2]}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_KeysAreScopedPerUser(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	router := setupIdempotencyRouter(newMemoryIdempotencyStore(), time.Hour, &status, &calls, nil)
+
+	postIdempotent(router, "key-1", "1", `{"items":[1]}`)
+	w := postIdempotent(router, "key-1", "2", `{"items":[1]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_InFlightDuplicateConflicts(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	block := make(chan struct{})
+	router := setupIdempotencyRouter(newMemoryIdempotencyStore(), time.Hour, &status, &calls, block)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postIdempotent(router, "key-1", "1", `{}`) }()
+
+	// Wait until the first request holds the key
+	assert.Eventually(t, func() bool {
+		w := postIdempotent(router, "key-1", "1", `{}`)
+		return w.Code == http.StatusConflict
+	}, time.Second, 5*time.Millisecond)
+
+	close(block)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+}
+
+func TestIdempotency_ServerErrorsAreNotStored(t *testing.T) {
+	status, calls := http.StatusServiceUnavailable, 0
+	router := setupIdempotencyRouter(newMemoryIdempotencyStore(), time.Hour, &status, &calls, nil)
+
+	assert.Equal(t, http.StatusServiceUnavailable, postIdempotent(router, "key-1", "1", `{}`).Code)
+
+	status = http.StatusCreated
+	w := postIdempotent(router, "key-1", "1", `{}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_ExpiredKeyRunsAgain(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	router := setupIdempotencyRouter(newMemoryIdempotencyStore(), 20*time.Millisecond, &status, &calls, nil)
+
+	postIdempotent(router, "key-1", "1", `{}`)
+	time.Sleep(30 * time.Millisecond)
+	w := postIdempotent(router, "key-1", "1", `{}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_NoKeyPassesThrough(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	router := setupIdempotencyRouter(newMemoryIdempotencyStore(), time.Hour, &status, &calls, nil)
+
+	postIdempotent(router, "", "1", `{}`)
+	postIdempotent(router, "", "1", `{}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_StoreTimeoutReturnsServiceUnavailable(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	router := setupIdempotencyRouter(hangingIdempotencyStore{newMemoryIdempotencyStore()}, time.Hour, &status, &calls, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBufferString(`{}`)).WithContext(ctx)
+	req.Header.Set("X-User-Id", "1")
+	req.Header.Set(idempotency.Header, "key-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestPostgresIdempotencyStore_ReserveExistingKey(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectExec(`DELETE FROM idempotency_keys WHERE key = \$1 AND \(expires_at <= \$2 OR \(completed = FALSE AND locked_until <= \$2\)\)`).
+		WithArgs("1:key-1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec(`INSERT INTO idempotency_keys .* ON CONFLICT \(key\) DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery(`SELECT request_hash, completed, response_code, content_type, response_body`).
+		WithArgs("1:key-1").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "completed", "response_code", "content_type", "response_body"}).
+			AddRow("abc", true, 201, "application/json", []byte(`{"id":1}`)))
+
+	store := &idempotency.PostgresStore{DB: db}
+	record, reserved, err := store.Reserve(context.Background(), "1:key-1", "abc", time.Hour)
+
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, 201, record.StatusCode)
+	assert.Equal(t, `{"id":1}`, string(record.Body))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPostgresIdempotencyStore_TakesOverAbandonedKey(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// The key's request crashed and its lease ran out, so the row is
+	// deleted and the key reserved afresh with a new lease
+	sqlMock.ExpectExec(`DELETE FROM idempotency_keys WHERE key = \$1 AND \(expires_at <= \$2 OR \(completed = FALSE AND locked_until <= \$2\)\)`).
+		WithArgs("1:key-1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec(`INSERT INTO idempotency_keys \(key, request_hash, completed, created_at, locked_until, expires_at\)\s+` +
+		`VALUES \(\$1, \$2, FALSE, \$3, \$4, \$4\)`).
+		WithArgs("1:key-1", "abc", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := &idempotency.PostgresStore{DB: db}
+	record, reserved, err := store.Reserve(context.Background(), "1:key-1", "abc", time.Minute)
+
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Nil(t, record)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	calls := 0
+	router.POST("/orders", idempotency.Middleware(newMemoryIdempotencyStore(), time.Hour), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler bug")
+		}
+		c.JSON(http.StatusCreated, gin.H{"order_id": calls})
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, postIdempotent(router, "key-1", "1", `{}`).Code)
+	w := postIdempotent(router, "key-1", "1", `{}`)
+
+	// The retry runs instead of being told the first request is in progress
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, calls)
+}
+
+// memoryCache is an idempotency.Cache keeping JSON values in a map, as Redis would
+type memoryCache map[string][]byte
+
+func (m memoryCache) Get(ctx context.Context, key string, value interface{}) error {
+	data, ok := m[key]
+	if !ok {
+		return errors.New("key does not exist")
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (m memoryCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	m[key] = data
+	return err
+}
+
+func (m memoryCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	if _, ok := m[key]; ok {
+		return false, nil
+	}
+	return true, m.Set(ctx, key, value, expiration)
+}
+
+func (m memoryCache) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestRedisIdempotencyStore_ReplaysThroughMiddleware(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_STORE", "redis")
+	store := idempotency.NewStore(nil, memoryCache{})
+	assert.IsType(t, &idempotency.RedisStore{}, store)
+
+	status, calls := http.StatusCreated, 0
+	router := setupIdempotencyRouter(store, time.Hour, &status, &calls, nil)
+
+	first := postIdempotent(router, "key-1", "1", `{"item":1}`)
+	second := postIdempotent(router, "key-1", "1", `{"item":1}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	// Without Redis the database store is used whatever IDEMPOTENCY_STORE says
+	assert.IsType(t, &idempotency.PostgresStore{}, idempotency.NewStore(nil, nil))
+}
@@ -0,0 +1,27 @@
+package unit
+
+import (
+	"testing"
+
+	"go-microservices/order-service/db"
+	"go-microservices/pkg/migrate"
+	"go-microservices/pkg/platform"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderMigrations_LoadInOrder(t *testing.T) {
+	migrations, err := migrate.Load(db.Migrations, platform.MigrationsDir)
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, migrations) {
+		return
+	}
+
+	// The first migration creates the orders table with every column the
+	// order inserts write
+	assert.Equal(t, "initial_schema", migrations[0].Name)
+	for _, column := range []string{"customer_id", "total_price", "status", "created_at"} {
+		assert.Contains(t, migrations[0].Up, column)
+	}
+	assert.Contains(t, migrations[0].Down, "DROP TABLE IF EXISTS orders")
+}
@@ -0,0 +1,151 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/pkg/events"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupCacheTest() (*gin.Engine, *controller.OrderController, *MockOrderRepository, *MockCache) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockOrderRepo := new(MockOrderRepository)
+	mockCache := new(MockCache)
+	orderController := &controller.OrderController{
+		OrderRepo: mockOrderRepo,
+		Cache:     mockCache,
+	}
+	router.GET("/orders/:id", orderController.GetOrder)
+	router.DELETE("/orders/:id", orderController.DeleteOrder)
+
+	return router, orderController, mockOrderRepo, mockCache
+}
+
+// cacheHit makes GetOrSet serve order as if it had been cached
+func cacheHit(mockCache *MockCache, key string, order model.Order) {
+	mockCache.On("GetOrSet", key, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(1).(*model.Order) = order
+		}).Return(nil)
+}
+
+func getOrder(router *gin.Engine, id string, userID string, roles string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/orders/"+id, nil)
+	if userID != "" {
+		req.Header.Set("X-User-Id", userID)
+	}
+	if roles != "" {
+		req.Header.Set("X-User-Roles", roles)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetOrder_CachedOrderEnforcesOwnership(t *testing.T) {
+	router, _, mockOrderRepo, mockCache := setupCacheTest()
+	cacheHit(mockCache, "order:42", model.Order{ID: 42, CustomerID: 7, Status: "paid"})
+
+	assert.Equal(t, http.StatusOK, getOrder(router, "42", "7", "").Code)
+	assert.Equal(t, http.StatusOK, getOrder(router, "42", "1", "admin").Code)
+	assert.Equal(t, http.StatusForbidden, getOrder(router, "42", "8", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, getOrder(router, "42", "", "").Code)
+	mockOrderRepo.AssertNotCalled(t, "GetOrderFromDB", mock.Anything)
+}
+
+func TestGetOrder_MissLoadsFromDatabase(t *testing.T) {
+	router, _, mockOrderRepo, mockCache := setupCacheTest()
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7}, nil)
+	mockCache.On("GetOrSet", "order:42", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			load := args.Get(3).(func() (interface{}, error))
+			order, err := load()
+			assert.NoError(t, err)
+			*args.Get(1).(*model.Order) = *order.(*model.Order)
+		}).Return(nil)
+
+	w := getOrder(router, "42", "8", "")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestGetOrder_TimeoutReturnsServiceUnavailable(t *testing.T) {
+	router, _, _, mockCache := setupCacheTest()
+	mockCache.On("GetOrSet", "order:42", mock.Anything, mock.Anything, mock.Anything).Return(context.DeadlineExceeded)
+
+	w := getOrder(router, "42", "7", "")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestGetOrder_WithoutCacheEnforcesOwnership(t *testing.T) {
+	router, orderController, mockOrderRepo, _ := setupCacheTest()
+	orderController.Cache = nil
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7}, nil)
+
+	assert.Equal(t, http.StatusOK, getOrder(router, "42", "7", "").Code)
+	assert.Equal(t, http.StatusForbidden, getOrder(router, "42", "8", "").Code)
+}
+
+func TestDeleteOrder_InvalidatesCache(t *testing.T) {
+	router, _, mockOrderRepo, mockCache := setupCacheTest()
+	mockOrderRepo.On("DeleteOrder", 42).Return(nil)
+	mockCache.On("Delete", "order:42").Return(nil)
+
+	req := httptest.NewRequest("DELETE", "/orders/42", nil)
+	req.Header.Set("X-User-Id", "1")
+	req.Header.Set("X-User-Roles", "admin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockCache.AssertExpectations(t)
+}
+
+func TestRecordTransition_InvalidatesCache(t *testing.T) {
+	_, orderController, _, mockCache := setupCacheTest()
+	// A failed invalidation is logged, not fatal
+	mockCache.On("Delete", "order:42").Return(errors.New("redis down"))
+
+	orderController.RecordTransition(model.OrderStatusHistory{OrderID: 42, FromStatus: "paid", ToStatus: "processing"})
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestHandleOrderEvent_InvalidatesReferencedOrder(t *testing.T) {
+	_, orderController, _, mockCache := setupCacheTest()
+	mockCache.On("Delete", "order:42").Return(nil).Once()
+	mockCache.On("Delete", "order:43").Return(nil).Once()
+
+	assert.NoError(t, orderController.HandleOrderEvent(envelopeBody(t, events.OrderStatusChanged,
+		events.StatusChange{OrderID: 42, CustomerID: 7, PreviousStatus: "paid", Status: "shipped"})))
+	assert.NoError(t, orderController.HandleOrderEvent(envelopeBody(t, events.OrderCreated,
+		events.Order{ID: 43, CustomerID: 7, Status: "pending", Items: []events.OrderItem{{ProductID: 1, Quantity: 1}}})))
+	// Malformed and unenveloped events are dropped rather than redelivered forever
+	assert.NoError(t, orderController.HandleOrderEvent([]byte(`not json`)))
+	assert.NoError(t, orderController.HandleOrderEvent([]byte(`{"order_id": 44}`)))
+
+	mockCache.AssertExpectations(t)
+}
+
+// envelopeBody returns an order-service event as it arrives from the broker
+func envelopeBody(t *testing.T, eventType string, payload interface{}) []byte {
+	envelope, err := events.New("order-service", eventType, payload)
+	assert.NoError(t, err)
+	body, err := json.Marshal(envelope)
+	assert.NoError(t, err)
+	return body
+}
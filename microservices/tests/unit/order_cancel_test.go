@@ -0,0 +1,278 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/lifecycle"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupCancelTest() (*gin.Engine, *MockOrderRepository, *MockPaymentService) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockOrderRepo := new(MockOrderRepository)
+	mockPayments := new(MockPaymentService)
+	orderController := &controller.OrderController{
+		OrderRepo:      mockOrderRepo,
+		PaymentService: mockPayments,
+	}
+	router.POST("/orders/:id/cancel", orderController.CancelOrder)
+	router.DELETE("/orders/:id", orderController.DeleteOrder)
+
+	return router, mockOrderRepo, mockPayments
+}
+
+func cancelOrder(router *gin.Engine, id string, userID string, roles string, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest("POST", "/orders/"+id+"/cancel", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", userID)
+	if roles != "" {
+		req.Header.Set("X-User-Roles", roles)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestCancelOrder_PaidOrderIsRefunded(t *testing.T) {
+	router, mockOrderRepo, mockPayments := setupCancelTest()
+
+	customer := lifecycle.Actor{Role: lifecycle.RoleCustomer, UserID: "7"}
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, ReservationID: 9, Status: "paid"}, nil)
+	mockOrderRepo.On("TransitionOrderStatus", 42, "cancelled", customer, "changed my mind").
+		Return(&model.OrderStatusHistory{OrderID: 42, FromStatus: "paid", ToStatus: "cancelled"}, nil)
+	mockOrderRepo.On("ClaimOrderRefund", 42).Return(true, nil)
+	mockPayments.On("RefundOrder", 42, 7, "requested_by_customer").Return(30.0, nil)
+	mockOrderRepo.On("RecordOrderRefund", 42, controller.RefundCompleted).Return(nil)
+
+	w, response := cancelOrder(router, "42", "7", "", `{"reason": "changed my mind"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "cancelled", response["status"])
+	assert.Equal(t, "paid", response["previous_status"])
+	refund := response["refund"].(map[string]interface{})
+	assert.Equal(t, controller.RefundCompleted, refund["status"])
+	assert.Equal(t, 30.0, refund["amount"])
+	mockOrderRepo.AssertNotCalled(t, "DeleteOrder", mock.Anything)
+	mockOrderRepo.AssertExpectations(t)
+	mockPayments.AssertExpectations(t)
+}
+
+func TestCancelOrder_PendingOrderNeedsNoRefund(t *testing.T) {
+	router, mockOrderRepo, mockPayments := setupCancelTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, Status: "pending"}, nil)
+	mockOrderRepo.On("TransitionOrderStatus", 42, "cancelled", mock.Anything, "").
+		Return(&model.OrderStatusHistory{OrderID: 42, FromStatus: "pending", ToStatus: "cancelled"}, nil)
+	mockPayments.On("CancelOrderPayments", 42, 7).Return(nil)
+
+	w, response := cancelOrder(router, "42", "7", "", "")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, controller.RefundNotRequired, response["refund"].(map[string]interface{})["status"])
+	mockPayments.AssertNotCalled(t, "RefundOrder", mock.Anything, mock.Anything, mock.Anything)
+	mockPayments.AssertExpectations(t)
+}
+
+func TestCancelOrder_PendingOrderStaysOpenWhenItsIntentCantBeCancelled(t *testing.T) {
+	router, mockOrderRepo, mockPayments := setupCancelTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, Status: "pending"}, nil)
+	mockPayments.On("CancelOrderPayments", 42, 7).Return(errors.New("payment service returned status: 409"))
+
+	w, _ := cancelOrder(router, "42", "7", "", "")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	mockOrderRepo.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCancelOrder_ReportsFailedRefund(t *testing.T) {
+	router, mockOrderRepo, mockPayments := setupCancelTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, Status: "processing"}, nil)
+	mockOrderRepo.On("TransitionOrderStatus", 42, "cancelled", mock.Anything, "").
+		Return(&model.OrderStatusHistory{OrderID: 42, FromStatus: "processing", ToStatus: "cancelled"}, nil)
+	mockOrderRepo.On("ClaimOrderRefund", 42).Return(true, nil)
+	mockPayments.On("RefundOrder", 42, 7, "requested_by_customer").Return(0.0, errors.New("payment service down"))
+	mockOrderRepo.On("RecordOrderRefund", 42, controller.RefundFailed).Return(nil)
+
+	w, response := cancelOrder(router, "42", "1", "admin", "")
+
+	// The cancellation stands; the failed refund is recorded for a retry
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	refund := response["refund"].(map[string]interface{})
+	assert.Equal(t, controller.RefundFailed, refund["status"])
+	assert.Contains(t, refund["error"], "payment service down")
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestCancelOrder_RepeatedCancelRetriesFailedRefund(t *testing.T) {
+	router, mockOrderRepo, mockPayments := setupCancelTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, Status: "cancelled"}, nil)
+	mockOrderRepo.On("ClaimOrderRefund", 42).Return(true, nil)
+	mockPayments.On("RefundOrder", 42, 7, "requested_by_customer").Return(30.0, nil)
+	mockOrderRepo.On("RecordOrderRefund", 42, controller.RefundCompleted).Return(nil)
+
+	w, response := cancelOrder(router, "42", "7", "", "")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	refund := response["refund"].(map[string]interface{})
+	assert.Equal(t, controller.RefundCompleted, refund["status"])
+	assert.Equal(t, 30.0, refund["amount"])
+	mockOrderRepo.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockOrderRepo.AssertExpectations(t)
+	mockPayments.AssertExpectations(t)
+}
+
+func TestCancelOrder_NotAllowedAfterShipping(t *testing.T) {
+	router, mockOrderRepo, mockPayments := setupCancelTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, ReservationID: 9, Status: "shipped"}, nil)
+	mockOrderRepo.On("TransitionOrderStatus", 42, "cancelled", mock.Anything, "").
+		Return(nil, fmt.Errorf("%w: shipped -> cancelled", lifecycle.ErrInvalidTransition))
+
+	w, response := cancelOrder(router, "42", "7", "", "")
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "shipped", response["status"])
+	mockPayments.AssertNotCalled(t, "RefundOrder", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCancelOrder_RejectsOtherCustomersAndRepeats(t *testing.T) {
+	router, mockOrderRepo, _ := setupCancelTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, Status: "paid"}, nil)
+	mockOrderRepo.On("GetOrderFromDB", "43").Return(&model.Order{ID: 43, CustomerID: 7, Status: "cancelled"}, nil)
+	// Nothing is left to refund
+	mockOrderRepo.On("ClaimOrderRefund", 43).Return(false, nil)
+
+	w, _ := cancelOrder(router, "42", "8", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w, _ = cancelOrder(router, "43", "7", "", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockOrderRepo.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteOrder_IsAdminOnlyPurge(t *testing.T) {
+	router, mockOrderRepo, _ := setupCancelTest()
+	mockOrderRepo.On("DeleteOrder", 42).Return(nil)
+
+	for _, roles := range []string{"", "customer"} {
+		req := httptest.NewRequest("DELETE", "/orders/42", nil)
+		req.Header.Set("X-User-Id", "7")
+		req.Header.Set("X-User-Roles", roles)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+	mockOrderRepo.AssertNotCalled(t, "DeleteOrder", mock.Anything)
+
+	req := httptest.NewRequest("DELETE", "/orders/42", nil)
+	req.Header.Set("X-User-Id", "1")
+	req.Header.Set("X-User-Roles", "admin")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockOrderRepo.AssertCalled(t, "DeleteOrder", 42)
+}
+
+func TestPaymentService_RefundOrderRefundsCapturedPayments(t *testing.T) {
+	var refunded []string
+	paymentService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "7", r.Header.Get("X-User-Id"))
+		assert.Equal(t, "system", r.Header.Get("X-User-Roles"))
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/payments/order/42":
+			w.Write([]byte(`[{"id": 1, "status": "succeeded"}, {"id": 2, "status": "failed"}, {"id": 3, "status": "refunded"}, {"id": 4, "status": "partially_refunded"}]`))
+		case r.Method == "POST" && r.URL.Path == "/payments/1/refunds":
+			refunded = append(refunded, r.URL.Path)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"refund": {"amount": 20}}`))
+		case r.Method == "POST" && r.URL.Path == "/payments/4/refunds":
+			// Fully refunded since it was listed
+			refunded = append(refunded, r.URL.Path)
+			w.WriteHeader(http.StatusConflict)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer paymentService.Close()
+	t.Setenv("PAYMENT_SERVICE_URL", paymentService.URL)
+
+	amount, err := service.NewPaymentService().RefundOrder(42, 7, "requested_by_customer")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, amount)
+	assert.Equal(t, []string{"/payments/1/refunds", "/payments/4/refunds"}, refunded)
+}
+
+func TestPaymentService_CancelOrderPaymentsCancelsOpenIntents(t *testing.T) {
+	var cancelled []string
+	paymentService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "7", r.Header.Get("X-User-Id"))
+		assert.Equal(t, "system", r.Header.Get("X-User-Roles"))
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/payments/order/42":
+			w.Write([]byte(`[{"id": 1, "status": "pending"}, {"id": 2, "status": "failed"}, {"id": 3, "status": "canceled"}, {"id": 4, "status": "succeeded"}]`))
+		case r.Method == "POST" && (r.URL.Path == "/payments/1/cancel" || r.URL.Path == "/payments/2/cancel"):
+			cancelled = append(cancelled, r.URL.Path)
+			w.Write([]byte(`{"message": "Payment canceled"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer paymentService.Close()
+	t.Setenv("PAYMENT_SERVICE_URL", paymentService.URL)
+
+	err := service.NewPaymentService().CancelOrderPayments(42, 7)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/payments/1/cancel", "/payments/2/cancel"}, cancelled)
+}
+
+func TestDBOrderRepository_ClaimOrderRefund(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	claim := `UPDATE orders SET refund_status = \$1, refund_claimed_at = \$2\s+WHERE id = \$3 AND status = \$4\s+` +
+		`AND \(refund_status IN \(\$5, \$6\) OR \(refund_status = \$1 AND refund_claimed_at < \$7\)\)`
+	sqlMock.ExpectExec(claim).
+		WithArgs("processing", sqlmock.AnyArg(), 42, "cancelled", "pending", "failed", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// A refund another request is still carrying out isn't claimed again
+	sqlMock.ExpectExec(claim).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	repo := &controller.DBOrderRepository{DB: db}
+	claimed, err := repo.ClaimOrderRefund(42)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = repo.ClaimOrderRefund(42)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -2,6 +2,7 @@ package unit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,8 +10,9 @@ import (
 	"time"
 
 	"go-microservices/order-service/controller"
+	"go-microservices/order-service/lifecycle"
 	"go-microservices/order-service/model"
-	"go-microservices/order-service/queue"
+	"go-microservices/pkg/queue"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -27,18 +29,22 @@ func (m *MockInventoryService) CheckAvailability(productID int, quantity int) (b
 	return args.Bool(0), args.Error(1)
 }
 
-type MockNotificationService struct {
-	mock.Mock
+func (m *MockInventoryService) Restock(reference string, items []model.ReturnItem) error {
+	args := m.Called(reference, items)
+	return args.Error(0)
 }
 
-func (m *MockNotificationService) SendOrderNotification(orderID int) error {
-	args := m.Called(orderID)
-	return args.Error(0)
+type MockProductService struct {
+	mock.Mock
 }
 
-func (m *MockNotificationService) SendOrderStatusUpdate(orderID int, customerID int, status string) error {
-	args := m.Called(orderID, customerID, status)
-	return args.Error(0)
+func (m *MockProductService) GetProduct(productID int) (*model.Product, error) {
+	args := m.Called(productID)
+	product, ok := args.Get(0).(*model.Product)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return product, args.Error(1)
 }
 
 type MockOrderRepository struct {
@@ -50,6 +56,11 @@ func (m *MockOrderRepository) InsertOrder(order *model.Order) error {
 	return args.Error(0)
 }
 
+func (m *MockOrderRepository) InsertOrders(orders []*model.Order) error {
+	args := m.Called(orders)
+	return args.Error(0)
+}
+
 func (m *MockOrderRepository) GetOrderFromDB(orderID string) (*model.Order, error) {
 	args := m.Called(orderID)
 	order, ok := args.Get(0).(*model.Order)
@@ -59,11 +70,57 @@ func (m *MockOrderRepository) GetOrderFromDB(orderID string) (*model.Order, erro
 	return order, args.Error(1)
 }
 
+func (m *MockOrderRepository) ListOrders(params model.OrderListParams) (*model.OrderPage, error) {
+	args := m.Called(params)
+	page, ok := args.Get(0).(*model.OrderPage)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return page, args.Error(1)
+}
+
+func (m *MockOrderRepository) UpdateOrder(order *model.Order) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) UpdateOrderStatus(orderID int, status string) error {
+	args := m.Called(orderID, status)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) TransitionOrderStatus(orderID int, status string, actor lifecycle.Actor, reason string) (*model.OrderStatusHistory, error) {
+	args := m.Called(orderID, status, actor, reason)
+	entry, _ := args.Get(0).(*model.OrderStatusHistory)
+	return entry, args.Error(1)
+}
+
+func (m *MockOrderRepository) ClaimOrderRefund(orderID int) (bool, error) {
+	args := m.Called(orderID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOrderRepository) RecordOrderRefund(orderID int, status string) error {
+	args := m.Called(orderID, status)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) GetOrderHistory(orderID int) ([]model.OrderStatusHistory, error) {
+	args := m.Called(orderID)
+	history, _ := args.Get(0).([]model.OrderStatusHistory)
+	return history, args.Error(1)
+}
+
+func (m *MockOrderRepository) DeleteOrder(orderID int) error {
+	args := m.Called(orderID)
+	return args.Error(0)
+}
+
 type MockMessageQueue struct {
 	mock.Mock
 }
 
-func (m *MockMessageQueue) PublishMessage(config queue.Config, message interface{}) error {
+func (m *MockMessageQueue) PublishMessage(ctx context.Context, config queue.Config, message interface{}) error {
 	args := m.Called(config, message)
 	return args.Error(0)
 }
@@ -72,23 +129,35 @@ type MockCache struct {
 	mock.Mock
 }
 
-func (m *MockCache) Get(key string, value interface{}) error {
+func (m *MockCache) Get(ctx context.Context, key string, value interface{}) error {
 	args := m.Called(key, value)
 	return args.Error(0)
 }
 
-func (m *MockCache) Set(key string, value interface{}, expiration time.Duration) error {
+func (m *MockCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	args := m.Called(key, value, expiration)
 	return args.Error(0)
 }
 
-func (m *MockCache) GetOrSet(key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
+func (m *MockCache) GetOrSet(ctx context.Context, key string, value interface{}, expiration time.Duration, fn func() (interface{}, error)) error {
 	args := m.Called(key, value, expiration, fn)
 	return args.Error(0)
 }
 
+func (m *MockCache) Delete(ctx context.Context, key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+// catalogue is the product price list served by the mock product service
+var catalogue = map[int]*model.Product{
+	1: {ID: 1, Name: "Keyboard", Price: 10},
+	2: {ID: 2, Name: "Mouse", Price: 5.5},
+	3: {ID: 3, Name: "Cable", Price: 1},
+}
+
 // setupTestEnvironment creates a test environment with mock dependencies
-func setupTestEnvironment() (*gin.Engine, *MockOrderRepository, *MockInventoryService, *MockNotificationService, *MockMessageQueue, *MockCache) {
+func setupTestEnvironment() (*gin.Engine, *MockOrderRepository, *MockInventoryService, *MockMessageQueue, *MockCache) {
 	// Setup Gin
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -96,47 +165,50 @@ func setupTestEnvironment() (*gin.Engine, *MockOrderRepository, *MockInventorySe
 	// Create mocks
 	mockOrderRepo := new(MockOrderRepository)
 	mockInventory := new(MockInventoryService)
-	mockNotification := new(MockNotificationService)
 	mockQueue := new(MockMessageQueue)
 	mockCache := new(MockCache)
+	mockProduct := new(MockProductService)
+	for id, product := range catalogue {
+		mockProduct.On("GetProduct", id).Return(product, nil).Maybe()
+	}
 
 	// Create controller with mocks
 	orderController := &controller.OrderController{
-		OrderRepo:           mockOrderRepo,
-		InventoryService:    mockInventory,
-		NotificationService: mockNotification,
-		Queue:               mockQueue,
-		Cache:               mockCache,
+		OrderRepo:        mockOrderRepo,
+		InventoryService: mockInventory,
+		ProductService:   mockProduct,
+		Queue:            mockQueue,
+		Cache:            mockCache,
 	}
 
 	// Setup routes
 	router.POST("/orders", orderController.CreateOrder)
 	router.GET("/orders/:id", orderController.GetOrder)
 
-	return router, mockOrderRepo, mockInventory, mockNotification, mockQueue, mockCache
+	return router, mockOrderRepo, mockInventory, mockQueue, mockCache
 }
 
 func TestCreateOrder_Success(t *testing.T) {
 	// Setup
-	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, _ := setupTestEnvironment()
+	router, mockOrderRepo, mockInventory, mockQueue, _ := setupTestEnvironment()
 
 	// Prepare test data
 	order := model.Order{
-		ProductID:  1,
 		CustomerID: 1,
-		Quantity:   2,
+		Items: []model.OrderItem{
+			{ProductID: 1, Quantity: 2, UnitPrice: 10},
+		},
 	}
 
 	// Set up mock expectations
 	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
 	mockInventory.On("CheckAvailability", 1, 2).Return(true, nil)
-	mockNotification.On("SendOrderNotification", mock.AnythingOfType("int")).Return(nil)
-	mockQueue.On("PublishMessage", mock.AnythingOfType("queue.Config"), mock.Anything).Return(nil)
 
 	// Create request
 	orderJSON, _ := json.Marshal(order)
 	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(orderJSON))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
 
 	// Create response recorder
 	w := httptest.NewRecorder()
@@ -150,24 +222,23 @@ func TestCreateOrder_Success(t *testing.T) {
 	// Verify all mocks were called as expected
 	mockOrderRepo.AssertExpectations(t)
 	mockInventory.AssertExpectations(t)
-	mockNotification.AssertExpectations(t)
-	mockQueue.AssertExpectations(t)
+	// order.created goes through the outbox written by the repository
+	mockQueue.AssertNotCalled(t, "PublishMessage", mock.Anything, mock.Anything)
 
 	// Specifically verify that InsertOrder was called exactly once
 	mockOrderRepo.AssertNumberOfCalls(t, "InsertOrder", 1)
-	// Specifically verify that SendOrderNotification was called exactly once
-	mockNotification.AssertNumberOfCalls(t, "SendOrderNotification", 1)
 }
 
 func TestCreateOrder_ProductNotAvailable(t *testing.T) {
 	// Setup
-	router, mockOrderRepo, mockInventory, mockNotification, mockQueue, _ := setupTestEnvironment()
+	router, mockOrderRepo, mockInventory, mockQueue, _ := setupTestEnvironment()
 
 	// Prepare test data
 	order := model.Order{
-		ProductID:  1,
 		CustomerID: 1,
-		Quantity:   100, // Large quantity that should not be available
+		Items: []model.OrderItem{
+			{ProductID: 1, Quantity: 100}, // Large quantity that should not be available
+		},
 	}
 
 	// Set up mock expectations
@@ -178,6 +249,7 @@ func TestCreateOrder_ProductNotAvailable(t *testing.T) {
 	orderJSON, _ := json.Marshal(order)
 	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(orderJSON))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
 
 	// Create response recorder
 	w := httptest.NewRecorder()
@@ -192,6 +264,130 @@ func TestCreateOrder_ProductNotAvailable(t *testing.T) {
 	mockInventory.AssertExpectations(t)
 	// Verify that other mocks were not called
 	mockOrderRepo.AssertNotCalled(t, "InsertOrder")
-	mockNotification.AssertNotCalled(t, "SendOrderNotification")
 	mockQueue.AssertNotCalled(t, "PublishMessage")
-}
\ No newline at end of file
+}
+func TestCreateOrder_MultipleItems(t *testing.T) {
+	// Setup
+	router, mockOrderRepo, mockInventory, _, _ := setupTestEnvironment()
+
+	// Prepare test data
+	order := model.Order{
+		Items: []model.OrderItem{
+			{ProductID: 1, Quantity: 2, UnitPrice: 10},
+			{ProductID: 2, Quantity: 1, UnitPrice: 5.5},
+			{ProductID: 3, Quantity: 3, UnitPrice: 1},
+		},
+	}
+
+	// Set up mock expectations
+	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
+	mockInventory.On("CheckAvailability", 1, 2).Return(true, nil)
+	mockInventory.On("CheckAvailability", 2, 1).Return(true, nil)
+	mockInventory.On("CheckAvailability", 3, 3).Return(true, nil)
+
+	// Create request
+	orderJSON, _ := json.Marshal(order)
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(orderJSON))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "7")
+	w := httptest.NewRecorder()
+
+	// Perform request
+	router.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created model.Order
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, 7, created.CustomerID)
+	assert.Len(t, created.Items, 3)
+	assert.Equal(t, "Keyboard", created.Items[0].ProductName)
+	assert.Equal(t, 20.0, created.Items[0].LineTotal)
+	assert.Equal(t, 28.5, created.Subtotal)
+	assert.Equal(t, 28.5, created.TotalPrice)
+
+	// A single order aggregate is inserted for the whole basket
+	mockOrderRepo.AssertNumberOfCalls(t, "InsertOrder", 1)
+	mockInventory.AssertExpectations(t)
+}
+
+func TestCreateOrder_NoItems(t *testing.T) {
+	// Setup
+	router, mockOrderRepo, mockInventory, _, _ := setupTestEnvironment()
+
+	// Create request without order lines
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBufferString(`{"items": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
+	w := httptest.NewRecorder()
+
+	// Perform request
+	router.ServeHTTP(w, req)
+
+	// Assert response
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockInventory.AssertNotCalled(t, "CheckAvailability", mock.Anything, mock.Anything)
+	mockOrderRepo.AssertNotCalled(t, "InsertOrder", mock.Anything)
+}
+
+func TestCreateOrder_PricesFromCatalogue(t *testing.T) {
+	// Setup
+	router, mockOrderRepo, mockInventory, _, _ := setupTestEnvironment()
+
+	// Client sends no prices at all
+	order := model.Order{
+		Items: []model.OrderItem{{ProductID: 2, Quantity: 4}},
+	}
+
+	mockOrderRepo.On("InsertOrder", mock.AnythingOfType("*model.Order")).Return(nil)
+	mockInventory.On("CheckAvailability", 2, 4).Return(true, nil)
+
+	orderJSON, _ := json.Marshal(order)
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(orderJSON))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created model.Order
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, 5.5, created.Items[0].UnitPrice)
+	assert.Equal(t, 22.0, created.TotalPrice)
+}
+
+func TestCreateOrder_PriceMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		order model.Order
+	}{
+		{
+			name:  "unit price below catalogue",
+			order: model.Order{Items: []model.OrderItem{{ProductID: 1, Quantity: 1, UnitPrice: 0.01}}},
+		},
+		{
+			name:  "total below computed total",
+			order: model.Order{Items: []model.OrderItem{{ProductID: 1, Quantity: 1}}, TotalPrice: 0.01},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockOrderRepo, mockInventory, mockQueue, _ := setupTestEnvironment()
+			mockInventory.On("CheckAvailability", 1, 1).Return(true, nil)
+
+			orderJSON, _ := json.Marshal(tt.order)
+			req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(orderJSON))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-User-Id", "1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusConflict, w.Code)
+			mockOrderRepo.AssertNotCalled(t, "InsertOrder", mock.Anything)
+			mockQueue.AssertNotCalled(t, "PublishMessage", mock.Anything, mock.Anything)
+		})
+	}
+}
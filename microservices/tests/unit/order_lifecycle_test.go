@@ -0,0 +1,286 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/lifecycle"
+	"go-microservices/order-service/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLifecycle_CanTransition(t *testing.T) {
+	customer := lifecycle.Actor{Role: lifecycle.RoleCustomer, UserID: "1"}
+	admin := lifecycle.Actor{Role: lifecycle.RoleAdmin, UserID: "9"}
+
+	tests := []struct {
+		name  string
+		from  string
+		to    string
+		actor lifecycle.Actor
+		err   error
+	}{
+		{"system marks paid", lifecycle.StatusPending, lifecycle.StatusPaid, lifecycle.System, nil},
+		{"admin ships", lifecycle.StatusProcessing, lifecycle.StatusShipped, admin, nil},
+		{"admin delivers", lifecycle.StatusShipped, lifecycle.StatusDelivered, admin, nil},
+		{"admin refunds delivered", lifecycle.StatusDelivered, lifecycle.StatusRefunded, admin, nil},
+		{"customer cancels pending", lifecycle.StatusPending, lifecycle.StatusCancelled, customer, nil},
+		{"customer cancels processing", lifecycle.StatusProcessing, lifecycle.StatusCancelled, customer, nil},
+		{"customer cannot pay", lifecycle.StatusPending, lifecycle.StatusPaid, customer, lifecycle.ErrForbiddenTransition},
+		{"customer cannot refund", lifecycle.StatusPaid, lifecycle.StatusRefunded, customer, lifecycle.ErrForbiddenTransition},
+		{"shipped cannot be cancelled", lifecycle.StatusShipped, lifecycle.StatusCancelled, admin, lifecycle.ErrInvalidTransition},
+		{"no skipping to shipped", lifecycle.StatusPending, lifecycle.StatusShipped, admin, lifecycle.ErrInvalidTransition},
+		{"cancelled is final", lifecycle.StatusCancelled, lifecycle.StatusPending, lifecycle.System, lifecycle.ErrInvalidTransition},
+		{"refunded is final", lifecycle.StatusRefunded, lifecycle.StatusPaid, admin, lifecycle.ErrInvalidTransition},
+		{"unknown status", lifecycle.StatusPending, "completed", admin, lifecycle.ErrUnknownStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.err, lifecycle.CanTransition(tt.from, tt.to, tt.actor))
+		})
+	}
+}
+
+func TestLifecycle_ActorFromHeaders(t *testing.T) {
+	assert.Equal(t, lifecycle.Actor{Role: lifecycle.RoleAdmin, UserID: "9"}, lifecycle.ActorFromHeaders("9", "user,admin"))
+	assert.Equal(t, lifecycle.Actor{Role: lifecycle.RoleSystem, UserID: "7"}, lifecycle.ActorFromHeaders("7", "system"))
+	assert.Equal(t, lifecycle.Actor{Role: lifecycle.RoleCustomer, UserID: "1"}, lifecycle.ActorFromHeaders("1", ""))
+}
+
+// setupLifecycleTest wires the status, history and update handlers to a mock repository
+func setupLifecycleTest() (*gin.Engine, *MockOrderRepository) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockOrderRepo := new(MockOrderRepository)
+	mockProduct := new(MockProductService)
+	for id, product := range catalogue {
+		mockProduct.On("GetProduct", id).Return(product, nil).Maybe()
+	}
+	orderController := &controller.OrderController{
+		OrderRepo:      mockOrderRepo,
+		ProductService: mockProduct,
+	}
+
+	router.PUT("/orders/:id", orderController.UpdateOrder)
+	router.PATCH("/orders/:id/status", orderController.UpdateOrderStatus)
+	router.GET("/orders/:id/history", orderController.GetOrderHistory)
+
+	return router, mockOrderRepo
+}
+
+func patchStatus(router *gin.Engine, body string, userID string, roles string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PATCH", "/orders/10/status", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", userID)
+	if roles != "" {
+		req.Header.Set("X-User-Roles", roles)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdateOrderStatus_CustomerCancelsOwnOrder(t *testing.T) {
+	router, mockOrderRepo := setupLifecycleTest()
+
+	actor := lifecycle.Actor{Role: lifecycle.RoleCustomer, UserID: "1"}
+	mockOrderRepo.On("GetOrderFromDB", "10").Return(&model.Order{ID: 10, CustomerID: 1, Status: "pending"}, nil)
+	mockOrderRepo.On("TransitionOrderStatus", 10, "cancelled", actor, "changed my mind").
+		Return(&model.OrderStatusHistory{OrderID: 10, FromStatus: "pending", ToStatus: "cancelled"}, nil)
+
+	w := patchStatus(router, `{"status":"cancelled","reason":"changed my mind"}`, "1", "")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestUpdateOrderStatus_CustomerCannotTouchOtherOrders(t *testing.T) {
+	router, mockOrderRepo := setupLifecycleTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "10").Return(&model.Order{ID: 10, CustomerID: 1, Status: "pending"}, nil)
+
+	w := patchStatus(router, `{"status":"cancelled"}`, "2", "")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockOrderRepo.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateOrderStatus_SystemActsOnAnyOrder(t *testing.T) {
+	router, mockOrderRepo := setupLifecycleTest()
+
+	actor := lifecycle.Actor{Role: lifecycle.RoleSystem, UserID: "2"}
+	mockOrderRepo.On("GetOrderFromDB", "10").Return(&model.Order{ID: 10, CustomerID: 1, Status: "pending"}, nil)
+	mockOrderRepo.On("TransitionOrderStatus", 10, "paid", actor, "").
+		Return(&model.OrderStatusHistory{OrderID: 10, FromStatus: "pending", ToStatus: "paid"}, nil)
+
+	w := patchStatus(router, `{"status":"paid"}`, "2", "system")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestUpdateOrderStatus_MapsLifecycleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"unknown status", lifecycle.ErrUnknownStatus, http.StatusBadRequest},
+		{"forbidden for role", lifecycle.ErrForbiddenTransition, http.StatusForbidden},
+		{"invalid transition", lifecycle.ErrInvalidTransition, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockOrderRepo := setupLifecycleTest()
+			mockOrderRepo.On("GetOrderFromDB", "10").Return(&model.Order{ID: 10, CustomerID: 1, Status: "shipped"}, nil)
+			mockOrderRepo.On("TransitionOrderStatus", 10, "delivered", mock.Anything, "").Return(nil, tt.err)
+
+			w := patchStatus(router, `{"status":"delivered"}`, "1", "")
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestUpdateOrder_RejectsOrdersPastPending(t *testing.T) {
+	router, mockOrderRepo := setupLifecycleTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "10").Return(&model.Order{ID: 10, CustomerID: 1, Status: "paid"}, nil)
+
+	body := `{"items":[{"product_id":1,"quantity":1}]}`
+	req := httptest.NewRequest("PUT", "/orders/10", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockOrderRepo.AssertNotCalled(t, "UpdateOrder", mock.Anything)
+}
+
+func TestUpdateOrder_RejectsCheckedOutOrders(t *testing.T) {
+	router, mockOrderRepo := setupLifecycleTest()
+
+	// A checkout order is pending but holds a reservation and payment intent
+	mockOrderRepo.On("GetOrderFromDB", "10").Return(&model.Order{ID: 10, CustomerID: 1, Status: "pending", ReservationID: 9}, nil)
+
+	body := `{"items":[{"product_id":1,"quantity":5}]}`
+	req := httptest.NewRequest("PUT", "/orders/10", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "checked out")
+	mockOrderRepo.AssertNotCalled(t, "UpdateOrder", mock.Anything)
+}
+
+func TestUpdateOrder_KeepsCustomerAndStatus(t *testing.T) {
+	router, mockOrderRepo := setupLifecycleTest()
+
+	mockOrderRepo.On("GetOrderFromDB", "10").Return(&model.Order{ID: 10, CustomerID: 1, Status: "pending"}, nil)
+	mockOrderRepo.On("UpdateOrder", mock.MatchedBy(func(o *model.Order) bool {
+		return o.CustomerID == 1 && o.Status == "pending"
+	})).Return(nil)
+
+	body := `{"customer_id":2,"status":"delivered","items":[{"product_id":1,"quantity":1}]}`
+	req := httptest.NewRequest("PUT", "/orders/10", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestGetOrderHistory(t *testing.T) {
+	router, mockOrderRepo := setupLifecycleTest()
+
+	history := []model.OrderStatusHistory{
+		{ID: 1, OrderID: 10, ToStatus: "pending", ActorRole: "system"},
+		{ID: 2, OrderID: 10, FromStatus: "pending", ToStatus: "paid", ActorRole: "system"},
+	}
+	mockOrderRepo.On("GetOrderFromDB", "10").Return(&model.Order{ID: 10, CustomerID: 1, Status: "paid"}, nil)
+	mockOrderRepo.On("GetOrderHistory", 10).Return(history, nil)
+
+	req := httptest.NewRequest("GET", "/orders/10/history", nil)
+	req.Header.Set("X-User-Id", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Status  string                     `json:"status"`
+		History []model.OrderStatusHistory `json:"history"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "paid", response.Status)
+	assert.Len(t, response.History, 2)
+	assert.Equal(t, "paid", response.History[1].ToStatus)
+
+	// Other customers cannot see it
+	req = httptest.NewRequest("GET", "/orders/10/history", nil)
+	req.Header.Set("X-User-Id", "2")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestTransitionOrderStatus_RejectsInvalidTransitionWithoutWriting(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT customer_id, status, reservation_id FROM orders WHERE id = \$1 FOR UPDATE`).WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "status", "reservation_id"}).AddRow(1, "shipped", nil))
+	sqlMock.ExpectRollback()
+
+	repo := &controller.DBOrderRepository{DB: db}
+	entry, err := repo.TransitionOrderStatus(10, "cancelled", lifecycle.Actor{Role: lifecycle.RoleCustomer, UserID: "1"}, "")
+
+	assert.ErrorIs(t, err, lifecycle.ErrInvalidTransition)
+	assert.Nil(t, entry)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestTransitionOrderStatus_RecordsHistoryAndNotifiesAfterCommit(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT customer_id, status, reservation_id FROM orders WHERE id = \$1 FOR UPDATE`).WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "status", "reservation_id"}).AddRow(1, "processing", nil))
+	sqlMock.ExpectExec(`UPDATE orders SET status`).WithArgs("shipped", 10).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery(`INSERT INTO order_status_history`).
+		WithArgs(10, "processing", "shipped", "admin", "9", "tracking 123", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	var transitions []model.OrderStatusHistory
+	repo := &controller.DBOrderRepository{DB: db, OnTransition: func(h model.OrderStatusHistory) {
+		transitions = append(transitions, h)
+	}}
+	entry, err := repo.TransitionOrderStatus(10, "shipped", lifecycle.Actor{Role: lifecycle.RoleAdmin, UserID: "9"}, "tracking 123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, entry.ID)
+	assert.Len(t, transitions, 1)
+	assert.Equal(t, 1, transitions[0].CustomerID)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,183 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupOrderListTest() (*gin.Engine, *MockOrderRepository) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockOrderRepo := new(MockOrderRepository)
+	orderController := &controller.OrderController{OrderRepo: mockOrderRepo}
+	router.GET("/orders", orderController.GetOrders)
+
+	return router, mockOrderRepo
+}
+
+func getOrders(router *gin.Engine, query string, userID string, roles string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/orders"+query, nil)
+	req.Header.Set("X-User-Id", userID)
+	if roles != "" {
+		req.Header.Set("X-User-Roles", roles)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetOrders_ScopesCustomersToTheirOwnOrders(t *testing.T) {
+	router, mockOrderRepo := setupOrderListTest()
+
+	mockOrderRepo.On("ListOrders", mock.MatchedBy(func(p model.OrderListParams) bool {
+		return p.CustomerID == 7 && p.Limit == 20 && p.Sort == "-created_at"
+	})).Return(&model.OrderPage{Data: []model.Order{{ID: 1, CustomerID: 7}}, TotalCount: 1, Limit: 20}, nil)
+
+	w := getOrders(router, "", "7", "")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page model.OrderPage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, page.TotalCount)
+	assert.Len(t, page.Data, 1)
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestGetOrders_CustomerCannotListOthers(t *testing.T) {
+	router, mockOrderRepo := setupOrderListTest()
+
+	w := getOrders(router, "?customer_id=8", "7", "")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockOrderRepo.AssertNotCalled(t, "ListOrders", mock.Anything)
+}
+
+func TestGetOrders_AdminFilters(t *testing.T) {
+	router, mockOrderRepo := setupOrderListTest()
+
+	mockOrderRepo.On("ListOrders", mock.MatchedBy(func(p model.OrderListParams) bool {
+		return p.CustomerID == 8 && p.ProductID == 3 && p.Limit == 5 && p.Sort == "total_price" &&
+			assert.ObjectsAreEqual([]string{"paid", "shipped"}, p.Statuses) &&
+			p.CreatedFrom.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) &&
+			p.CreatedTo.Equal(time.Date(2026, 1, 31, 23, 59, 59, 999999999, time.UTC))
+	})).Return(&model.OrderPage{Data: []model.Order{}, Limit: 5}, nil)
+
+	w := getOrders(router,
+		"?customer_id=8&product_id=3&status=paid,shipped&sort=total_price&limit=5&created_from=2026-01-01&created_to=2026-01-31",
+		"1", "admin")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestGetOrders_RejectsInvalidParams(t *testing.T) {
+	for _, query := range []string{
+		"?sort=customer_id",
+		"?limit=0",
+		"?limit=101",
+		"?status=completed",
+		"?created_from=yesterday",
+		"?cursor=not-a-cursor",
+	} {
+		t.Run(query, func(t *testing.T) {
+			router, mockOrderRepo := setupOrderListTest()
+
+			w := getOrders(router, query, "7", "")
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockOrderRepo.AssertNotCalled(t, "ListOrders", mock.Anything)
+		})
+	}
+}
+
+func orderListRows() *sqlmock.Rows {
+	columns := []string{"id", "customer_id", "promo_code", "subtotal", "discount_amount", "tax_amount",
+		"total_price", "reservation_id", "status", "created_at"}
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows(columns).
+		AddRow(12, 7, "", 30.0, 0.0, 0.0, 30.0, 0, "paid", created).
+		AddRow(11, 7, "", 20.0, 0.0, 0.0, 20.0, 0, "pending", created.Add(-time.Hour)).
+		AddRow(10, 7, "", 10.0, 0.0, 0.0, 10.0, 0, "pending", created.Add(-2*time.Hour))
+}
+
+func TestListOrders_PagesWithCursor(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// First page of two: the extra row tells there is more
+	sqlMock.ExpectQuery(`SELECT COUNT\(\*\) FROM orders WHERE customer_id = \$1`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	sqlMock.ExpectQuery(`FROM orders WHERE customer_id = \$1\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$2`).
+		WithArgs(7, 3).
+		WillReturnRows(orderListRows())
+	sqlMock.ExpectQuery(`FROM order_items`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "product_name", "quantity", "unit_price", "line_total"}))
+
+	repo := &controller.DBOrderRepository{DB: db}
+	page, err := repo.ListOrders(model.OrderListParams{CustomerID: 7, Sort: "-created_at", Limit: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, page.TotalCount)
+	assert.Len(t, page.Data, 2)
+	assert.True(t, page.HasMore)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// The cursor, passed back through the handler, continues after the last
+	// order of the first page
+	sqlMock.ExpectQuery(`SELECT COUNT\(\*\) FROM orders WHERE customer_id = \$1`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	sqlMock.ExpectQuery(`FROM orders WHERE customer_id = \$1 AND \(created_at, id\) < \(\$2, \$3\)\s+ORDER BY created_at DESC, id DESC`).
+		WithArgs(7, time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC), 11, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "promo_code", "subtotal", "discount_amount",
+			"tax_amount", "total_price", "reservation_id", "status", "created_at"}).
+			AddRow(10, 7, "", 10.0, 0.0, 0.0, 10.0, 0, "pending", time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)))
+	sqlMock.ExpectQuery(`FROM order_items`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "product_name", "quantity", "unit_price", "line_total"}))
+
+	router, mockOrderRepo := setupOrderListTest()
+	mockOrderRepo.On("ListOrders", mock.Anything).Return(nil, nil).Run(func(args mock.Arguments) {
+		page, err = repo.ListOrders(args.Get(0).(model.OrderListParams))
+	})
+	w := getOrders(router, "?limit=2&cursor="+page.NextCursor, "7", "")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 1)
+	assert.False(t, page.HasMore)
+	assert.Empty(t, page.NextCursor)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetOrders_CursorMustMatchSort(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectQuery(`SELECT COUNT`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	sqlMock.ExpectQuery(`FROM orders`).WillReturnRows(orderListRows())
+	sqlMock.ExpectQuery(`FROM order_items`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "product_id", "product_name", "quantity", "unit_price", "line_total"}))
+
+	repo := &controller.DBOrderRepository{DB: db}
+	page, err := repo.ListOrders(model.OrderListParams{Sort: "-created_at", Limit: 2})
+	assert.NoError(t, err)
+
+	router, mockOrderRepo := setupOrderListTest()
+	w := getOrders(router, "?sort=total_price&cursor="+page.NextCursor, "7", "")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockOrderRepo.AssertNotCalled(t, "ListOrders", mock.Anything)
+}
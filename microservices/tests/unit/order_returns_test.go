@@ -0,0 +1,432 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/lifecycle"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/returns"
+	"go-microservices/order-service/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReturnRepository struct {
+	mock.Mock
+}
+
+func (m *MockReturnRepository) CreateReturn(ret *model.Return, actor lifecycle.Actor) error {
+	args := m.Called(ret, actor)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepository) GetReturn(returnID int) (*model.Return, error) {
+	args := m.Called(returnID)
+	ret, _ := args.Get(0).(*model.Return)
+	return ret, args.Error(1)
+}
+
+func (m *MockReturnRepository) ListOrderReturns(orderID int) ([]model.Return, error) {
+	args := m.Called(orderID)
+	list, _ := args.Get(0).([]model.Return)
+	return list, args.Error(1)
+}
+
+func (m *MockReturnRepository) GetReturnHistory(returnID int) ([]model.ReturnStatusHistory, error) {
+	args := m.Called(returnID)
+	history, _ := args.Get(0).([]model.ReturnStatusHistory)
+	return history, args.Error(1)
+}
+
+func (m *MockReturnRepository) TransitionReturn(returnID int, status string, actor lifecycle.Actor, note string) (*model.ReturnStatusHistory, error) {
+	args := m.Called(returnID, status, actor, note)
+	entry, _ := args.Get(0).(*model.ReturnStatusHistory)
+	return entry, args.Error(1)
+}
+
+func (m *MockReturnRepository) ClaimReturnRefund(returnID int) (float64, bool, error) {
+	args := m.Called(returnID)
+	return args.Get(0).(float64), args.Bool(1), args.Error(2)
+}
+
+func (m *MockReturnRepository) RecordReturnRefund(returnID int, status string, refunded float64) error {
+	args := m.Called(returnID, status, refunded)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepository) MarkReturnRestocked(returnID int) error {
+	args := m.Called(returnID)
+	return args.Error(0)
+}
+
+func (m *MockReturnRepository) IsOrderFullyReturned(orderID int) (bool, error) {
+	args := m.Called(orderID)
+	return args.Bool(0), args.Error(1)
+}
+
+type returnMocks struct {
+	orders    *MockOrderRepository
+	returns   *MockReturnRepository
+	inventory *MockInventoryService
+	payments  *MockPaymentService
+}
+
+func setupReturnTest() (*gin.Engine, returnMocks) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mocks := returnMocks{
+		orders:    new(MockOrderRepository),
+		returns:   new(MockReturnRepository),
+		inventory: new(MockInventoryService),
+		payments:  new(MockPaymentService),
+	}
+	orderController := &controller.OrderController{
+		OrderRepo:        mocks.orders,
+		Returns:          mocks.returns,
+		InventoryService: mocks.inventory,
+		PaymentService:   mocks.payments,
+	}
+	router.POST("/orders/:id/returns", orderController.CreateReturn)
+	router.GET("/returns/:returnId", orderController.GetReturn)
+	router.POST("/returns/:returnId/approve", orderController.ApproveReturn)
+	router.POST("/returns/:returnId/reject", orderController.RejectReturn)
+	router.POST("/returns/:returnId/receive", orderController.ReceiveReturn)
+
+	return router, mocks
+}
+
+func returnRequest(router *gin.Engine, method string, path string, userID string, roles string, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", userID)
+	if roles != "" {
+		req.Header.Set("X-User-Roles", roles)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestCreateReturn_RecordsRequest(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	customer := lifecycle.Actor{Role: lifecycle.RoleCustomer, UserID: "7"}
+	mocks.orders.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, Status: "delivered"}, nil)
+	mocks.returns.On("CreateReturn", mock.MatchedBy(func(ret *model.Return) bool {
+		return ret.OrderID == 42 && ret.Reason == returns.ReasonDamaged && len(ret.Items) == 1
+	}), customer).Run(func(args mock.Arguments) {
+		ret := args.Get(0).(*model.Return)
+		ret.ID = 5
+		ret.Status = returns.StatusRequested
+	}).Return(nil)
+
+	w, response := returnRequest(router, "POST", "/orders/42/returns", "7", "",
+		`{"reason": "damaged", "items": [{"product_id": 3, "quantity": 1}]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, 5.0, response["id"])
+	assert.Equal(t, returns.StatusRequested, response["status"])
+	mocks.returns.AssertExpectations(t)
+}
+
+func TestCreateReturn_Rejections(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	mocks.orders.On("GetOrderFromDB", "42").Return(&model.Order{ID: 42, CustomerID: 7, Status: "delivered"}, nil)
+	mocks.orders.On("GetOrderFromDB", "43").Return(&model.Order{ID: 43, CustomerID: 7, Status: "shipped"}, nil)
+	mocks.returns.On("CreateReturn", mock.MatchedBy(func(ret *model.Return) bool { return ret.OrderID == 43 }), mock.Anything).
+		Return(controller.ErrOrderNotReturnable)
+
+	body := `{"reason": "damaged", "items": [{"product_id": 3, "quantity": 1}]}`
+
+	w, _ := returnRequest(router, "POST", "/orders/42/returns", "7", "", `{"reason": "bored", "items": [{"product_id": 3, "quantity": 1}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = returnRequest(router, "POST", "/orders/42/returns", "8", "", body)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w, response := returnRequest(router, "POST", "/orders/43/returns", "7", "", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "shipped", response["status"])
+}
+
+func TestApproveReturn_RefundsAndCompletesFullyReturnedOrder(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	admin := lifecycle.Actor{Role: lifecycle.RoleAdmin, UserID: "1"}
+	requested := &model.Return{ID: 5, OrderID: 42, CustomerID: 7, Status: returns.StatusRequested, RefundAmount: 25}
+	approved := &model.Return{ID: 5, OrderID: 42, CustomerID: 7, Status: returns.StatusApproved, RefundAmount: 25, RefundStatus: returns.RefundPending}
+	mocks.returns.On("GetReturn", 5).Return(requested, nil).Once()
+	mocks.returns.On("GetReturn", 5).Return(approved, nil).Once()
+	mocks.returns.On("TransitionReturn", 5, returns.StatusApproved, admin, "looks damaged").
+		Return(&model.ReturnStatusHistory{ReturnID: 5, FromStatus: returns.StatusRequested, ToStatus: returns.StatusApproved}, nil)
+	mocks.returns.On("ClaimReturnRefund", 5).Return(25.0, true, nil)
+	mocks.payments.On("RefundAmount", 42, 7, 25.0, "requested_by_customer", "return-5").Return(25.0, nil)
+	mocks.returns.On("RecordReturnRefund", 5, returns.RefundCompleted, 25.0).Return(nil)
+	mocks.returns.On("IsOrderFullyReturned", 42).Return(true, nil)
+	mocks.orders.On("TransitionOrderStatus", 42, lifecycle.StatusRefunded, lifecycle.System, "return 5").
+		Return(&model.OrderStatusHistory{OrderID: 42, FromStatus: "delivered", ToStatus: "refunded"}, nil)
+
+	w, response := returnRequest(router, "POST", "/returns/5/approve", "1", "admin", `{"note": "looks damaged"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	refund := response["refund"].(map[string]interface{})
+	assert.Equal(t, returns.RefundCompleted, refund["status"])
+	assert.Equal(t, 25.0, refund["amount"])
+	mocks.returns.AssertExpectations(t)
+	mocks.payments.AssertExpectations(t)
+	mocks.orders.AssertExpectations(t)
+}
+
+func TestApproveReturn_ReportsFailedRefund(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	approved := &model.Return{ID: 5, OrderID: 42, CustomerID: 7, Status: returns.StatusApproved, RefundAmount: 25, RefundStatus: returns.RefundPending}
+	mocks.returns.On("GetReturn", 5).Return(approved, nil)
+	mocks.returns.On("TransitionReturn", 5, returns.StatusApproved, mock.Anything, "").Return(nil, nil)
+	mocks.returns.On("ClaimReturnRefund", 5).Return(25.0, true, nil)
+	mocks.payments.On("RefundAmount", 42, 7, 25.0, "requested_by_customer", "return-5").Return(0.0, errors.New("payment service down"))
+	mocks.returns.On("RecordReturnRefund", 5, returns.RefundFailed, 0.0).Return(nil)
+
+	w, response := returnRequest(router, "POST", "/returns/5/approve", "1", "admin", "")
+
+	// The approval stands; approving again retries the refund
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	refund := response["refund"].(map[string]interface{})
+	assert.Equal(t, returns.RefundFailed, refund["status"])
+	assert.Contains(t, refund["error"], "payment service down")
+	mocks.returns.AssertNotCalled(t, "IsOrderFullyReturned", mock.Anything)
+	mocks.orders.AssertNotCalled(t, "TransitionOrderStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApproveReturn_RetryRefundsOnlyTheRemainder(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	// The first attempt refunded 15 of 25 before payment-service failed
+	approved := &model.Return{ID: 5, OrderID: 42, CustomerID: 7, Status: returns.StatusApproved,
+		RefundAmount: 25, RefundedAmount: 15, RefundStatus: returns.RefundFailed}
+	mocks.returns.On("GetReturn", 5).Return(approved, nil)
+	mocks.returns.On("TransitionReturn", 5, returns.StatusApproved, mock.Anything, "").Return(nil, nil)
+	mocks.returns.On("ClaimReturnRefund", 5).Return(10.0, true, nil)
+	mocks.payments.On("RefundAmount", 42, 7, 10.0, "requested_by_customer", "return-5").Return(10.0, nil)
+	mocks.returns.On("RecordReturnRefund", 5, returns.RefundCompleted, 10.0).Return(nil)
+	mocks.returns.On("IsOrderFullyReturned", 42).Return(false, nil)
+
+	w, response := returnRequest(router, "POST", "/returns/5/approve", "1", "admin", "")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	refund := response["refund"].(map[string]interface{})
+	assert.Equal(t, returns.RefundCompleted, refund["status"])
+	assert.Equal(t, 25.0, refund["amount"])
+	mocks.returns.AssertExpectations(t)
+	mocks.payments.AssertExpectations(t)
+}
+
+func TestApproveReturn_ClaimedRefundIsNotRepeated(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	// A concurrent approval claimed the refund first
+	approved := &model.Return{ID: 5, OrderID: 42, CustomerID: 7, Status: returns.StatusApproved,
+		RefundAmount: 25, RefundStatus: returns.RefundPending}
+	mocks.returns.On("GetReturn", 5).Return(approved, nil)
+	mocks.returns.On("TransitionReturn", 5, returns.StatusApproved, mock.Anything, "").Return(nil, nil)
+	mocks.returns.On("ClaimReturnRefund", 5).Return(0.0, false, nil)
+
+	w, _ := returnRequest(router, "POST", "/returns/5/approve", "1", "admin", "")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	mocks.payments.AssertNotCalled(t, "RefundAmount", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mocks.returns.AssertNotCalled(t, "RecordReturnRefund", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDBReturnRepository_ClaimReturnRefund(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	claim := `UPDATE order_returns SET refund_status = \$1, refund_claimed_at = \$2, updated_at = \$2\s+` +
+		`WHERE id = \$3 AND \(refund_status IN \(\$4, \$5\) OR \(refund_status = \$1 AND refund_claimed_at < \$6\)\)`
+	sqlMock.ExpectQuery(claim).
+		WithArgs(returns.RefundProcessing, sqlmock.AnyArg(), 5, returns.RefundPending, returns.RefundFailed, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"refund_amount", "refunded_amount"}).AddRow(25.0, 15.0))
+	sqlMock.ExpectQuery(claim).WillReturnRows(sqlmock.NewRows([]string{"refund_amount", "refunded_amount"}))
+
+	repo := &controller.DBReturnRepository{DB: db}
+	remaining, claimed, err := repo.ClaimReturnRefund(5)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, 10.0, remaining)
+
+	// Once claimed, a second approval finds nothing to refund until the
+	// claim goes stale
+	_, claimed, err = repo.ClaimReturnRefund(5)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDecideReturn_RequiresStaff(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	mocks.returns.On("GetReturn", 5).Return(&model.Return{ID: 5, OrderID: 42, CustomerID: 7, Status: returns.StatusRequested}, nil)
+
+	for _, path := range []string{"/returns/5/approve", "/returns/5/reject", "/returns/5/receive"} {
+		w, _ := returnRequest(router, "POST", path, "7", "", "")
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+	}
+	mocks.returns.AssertNotCalled(t, "TransitionReturn", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRejectReturn_InvalidTransition(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	mocks.returns.On("GetReturn", 5).Return(&model.Return{ID: 5, Status: returns.StatusReceived}, nil)
+	mocks.returns.On("TransitionReturn", 5, returns.StatusRejected, mock.Anything, "").
+		Return(nil, returns.ErrInvalidTransition)
+
+	w, response := returnRequest(router, "POST", "/returns/5/reject", "1", "admin", "")
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, returns.StatusReceived, response["status"])
+}
+
+func TestReceiveReturn_Restocks(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	items := []model.ReturnItem{{ProductID: 3, Quantity: 2, UnitPrice: 10}}
+	mocks.returns.On("GetReturn", 5).Return(&model.Return{ID: 5, OrderID: 42, Status: returns.StatusReceived, Items: items}, nil)
+	mocks.returns.On("TransitionReturn", 5, returns.StatusReceived, mock.Anything, "").
+		Return(&model.ReturnStatusHistory{ReturnID: 5, FromStatus: returns.StatusApproved, ToStatus: returns.StatusReceived}, nil)
+	mocks.inventory.On("Restock", "return-5", items).Return(nil)
+	mocks.returns.On("MarkReturnRestocked", 5).Return(nil)
+
+	w, response := returnRequest(router, "POST", "/returns/5/receive", "1", "admin", "")
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, true, response["return"].(map[string]interface{})["restocked"])
+	mocks.inventory.AssertExpectations(t)
+	mocks.returns.AssertExpectations(t)
+}
+
+func TestGetReturn_OwnerSeesHistory(t *testing.T) {
+	router, mocks := setupReturnTest()
+
+	mocks.returns.On("GetReturn", 5).Return(&model.Return{ID: 5, OrderID: 42, CustomerID: 7, Status: returns.StatusRequested}, nil)
+	mocks.returns.On("GetReturnHistory", 5).Return([]model.ReturnStatusHistory{{ReturnID: 5, ToStatus: returns.StatusRequested}}, nil)
+
+	w, response := returnRequest(router, "GET", "/returns/5", "7", "", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Len(t, response["history"], 1)
+
+	w, _ = returnRequest(router, "GET", "/returns/5", "8", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestDBReturnRepository_CreateReturnPricesItems(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT customer_id, status, subtotal, total_price FROM orders`).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "status", "subtotal", "total_price"}).AddRow(7, "delivered", 100.0, 90.0))
+	sqlMock.ExpectQuery(`FROM order_items`).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "product_name", "quantity", "line_total"}).
+			AddRow(3, "Mug", 4, 40.0).
+			AddRow(4, "Plate", 2, 60.0))
+	sqlMock.ExpectQuery(`FROM order_return_items`).WithArgs(42, returns.StatusRejected).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(3, 1))
+	sqlMock.ExpectQuery(`INSERT INTO order_returns`).
+		WithArgs(42, 7, returns.StatusRequested, returns.ReasonDamaged, "", 27.0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	sqlMock.ExpectExec(`INSERT INTO order_return_items`).WithArgs(5, 3, "Mug", 3, 10.0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectQuery(`INSERT INTO order_return_status_history`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	var transitions []model.ReturnStatusHistory
+	repo := &controller.DBReturnRepository{DB: db, OnTransition: func(entry model.ReturnStatusHistory) {
+		transitions = append(transitions, entry)
+	}}
+	ret := &model.Return{OrderID: 42, Reason: returns.ReasonDamaged, Items: []model.ReturnItem{{ProductID: 3, Quantity: 3}}}
+
+	err = repo.CreateReturn(ret, lifecycle.Actor{Role: lifecycle.RoleCustomer, UserID: "7"})
+
+	// 3 mugs at 10 each, scaled by the 10% order discount
+	assert.NoError(t, err)
+	assert.Equal(t, 5, ret.ID)
+	assert.Equal(t, 27.0, ret.RefundAmount)
+	assert.Len(t, transitions, 1)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDBReturnRepository_CreateReturnRejectsExcess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT customer_id, status, subtotal, total_price FROM orders`).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "status", "subtotal", "total_price"}).AddRow(7, "delivered", 40.0, 40.0))
+	sqlMock.ExpectQuery(`FROM order_items`).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "product_name", "quantity", "line_total"}).AddRow(3, "Mug", 4, 40.0))
+	sqlMock.ExpectQuery(`FROM order_return_items`).WithArgs(42, returns.StatusRejected).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(3, 2))
+	sqlMock.ExpectRollback()
+
+	repo := &controller.DBReturnRepository{DB: db}
+	ret := &model.Return{OrderID: 42, Reason: returns.ReasonDamaged, Items: []model.ReturnItem{{ProductID: 3, Quantity: 3}}}
+
+	err = repo.CreateReturn(ret, lifecycle.Actor{Role: lifecycle.RoleCustomer, UserID: "7"})
+
+	assert.ErrorIs(t, err, controller.ErrReturnExceedsOrder)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPaymentService_RefundAmountStopsAtAmount(t *testing.T) {
+	var refunds []map[string]interface{}
+	var keys []string
+	paymentService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/payments/order/42":
+			w.Write([]byte(`[{"id": 1, "amount": 20, "status": "succeeded"}, {"id": 2, "amount": 50, "status": "succeeded"}]`))
+		case r.Method == "POST" && (r.URL.Path == "/payments/1/refunds" || r.URL.Path == "/payments/2/refunds"):
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			refunds = append(refunds, body)
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"refund": map[string]interface{}{"amount": body["amount"]}})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer paymentService.Close()
+	t.Setenv("PAYMENT_SERVICE_URL", paymentService.URL)
+
+	amount, err := service.NewPaymentService().RefundAmount(42, 7, 25, "requested_by_customer", "return-5")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 25.0, amount)
+	if assert.Len(t, refunds, 2) {
+		assert.Equal(t, 20.0, refunds[0]["amount"])
+		assert.Equal(t, 5.0, refunds[1]["amount"])
+	}
+	// A retry repeating a request sends the same key, so it isn't refunded twice
+	assert.Equal(t, []string{"return-5-payment-1-2000", "return-5-payment-2-500"}, keys)
+}
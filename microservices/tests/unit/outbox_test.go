@@ -0,0 +1,222 @@
+package unit
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go-microservices/order-service/controller"
+	"go-microservices/order-service/model"
+	"go-microservices/order-service/outbox"
+	"go-microservices/pkg/events"
+	"go-microservices/pkg/queue"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// storedEnvelope is an order.created event as Enqueue writes it
+const storedEnvelope = `{"id":"e1","type":"order.created","version":1,"source":"order-service",` +
+	`"occurred_at":"2024-01-01T00:00:00Z","correlation_id":"order-10",` +
+	`"payload":{"id":10,"customer_id":1,"status":"pending","items":[{"product_id":1,"quantity":1}]}}`
+
+func outboxRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "aggregate_id", "event_type", "payload", "attempts"}).
+		AddRow(1, 10, outbox.EventOrderCreated, []byte(storedEnvelope), 0).
+		AddRow(2, 10, outbox.EventOrderStatusChanged,
+			[]byte(`{"order_id":10,"customer_id":1,"previous_status":"pending","status":"paid"}`), 0)
+}
+
+func TestOutboxRelay_PublishesPendingAndMarksSent(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`FROM outbox\s+WHERE sent_at IS NULL .* FOR UPDATE SKIP LOCKED`).WillReturnRows(outboxRows())
+	sqlMock.ExpectExec(`UPDATE outbox SET sent_at`).WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec(`UPDATE outbox SET sent_at`).WithArgs(sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	publisher := new(MockMessageQueue)
+	publisher.On("PublishMessage", mock.MatchedBy(func(c queue.Config) bool {
+		return c.RoutingKey == outbox.EventOrderCreated && c.ExchangeName == "orders"
+	}), json.RawMessage(storedEnvelope)).Return(nil).Once()
+	// A row written before events were enveloped is wrapped on the way out
+	publisher.On("PublishMessage", mock.MatchedBy(func(c queue.Config) bool {
+		return c.RoutingKey == outbox.EventOrderStatusChanged
+	}), mock.MatchedBy(func(body json.RawMessage) bool {
+		envelope, err := events.Parse(body)
+		return err == nil && envelope.Type == events.OrderStatusChanged && envelope.CorrelationID == "order-10"
+	})).Return(nil).Once()
+
+	relay := outbox.NewRelay(db, publisher)
+	sent, err := relay.PublishPending()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	publisher.AssertExpectations(t)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOutboxRelay_FailureSchedulesRetryAndStopsBatch(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`FROM outbox`).WillReturnRows(outboxRows())
+	sqlMock.ExpectExec(`UPDATE outbox SET attempts = \$1, last_error = \$2, next_attempt_at = \$3`).
+		WithArgs(1, "broker unavailable", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	publisher := new(MockMessageQueue)
+	publisher.On("PublishMessage", mock.AnythingOfType("queue.Config"), mock.Anything).
+		Return(errors.New("broker unavailable")).Once()
+
+	relay := outbox.NewRelay(db, publisher)
+	sent, err := relay.PublishPending()
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, sent)
+	// The second event must not overtake the failed one
+	publisher.AssertNumberOfCalls(t, "PublishMessage", 1)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestInsertOrder_WritesOutboxEventInSameTransaction(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO orders`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	sqlMock.ExpectQuery(`INSERT INTO order_items`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(`INSERT INTO order_status_history`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WithArgs(10, outbox.EventOrderCreated, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	repo := &controller.DBOrderRepository{DB: db}
+	err = repo.InsertOrder(&model.Order{
+		CustomerID: 1,
+		Items:      []model.OrderItem{{ProductID: 1, Quantity: 1, UnitPrice: 10}},
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestInsertOrder_OutboxFailureRollsBackOrder(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO orders`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	sqlMock.ExpectQuery(`INSERT INTO order_items`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(`INSERT INTO order_status_history`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WillReturnError(errors.New("disk full"))
+	sqlMock.ExpectRollback()
+
+	repo := &controller.DBOrderRepository{DB: db}
+	err = repo.InsertOrder(&model.Order{
+		CustomerID: 1,
+		Items:      []model.OrderItem{{ProductID: 1, Quantity: 1, UnitPrice: 10}},
+	})
+
+	assert.Error(t, err)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderStatus_CancelledEmitsStatusChangedAndCancelled(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT customer_id, status, reservation_id FROM orders WHERE id = \$1 FOR UPDATE`).WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "status", "reservation_id"}).AddRow(1, "pending", 9))
+	sqlMock.ExpectExec(`UPDATE orders SET status`).WithArgs("cancelled", 10).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery(`INSERT INTO order_status_history`).
+		WithArgs(10, "pending", "cancelled", "system", "", "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	// Consumers release the reservation from the event, so it must carry it
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WithArgs(10, outbox.EventOrderStatusChanged, statusChangedPayload{ReservationID: 9}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WithArgs(10, outbox.EventOrderCancelled, statusChangedPayload{ReservationID: 9}).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	sqlMock.ExpectCommit()
+
+	repo := &controller.DBOrderRepository{DB: db}
+	assert.NoError(t, repo.UpdateOrderStatus(10, "cancelled"))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpdateOrderStatus_CancellingPaidOrderOwesRefund(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT customer_id, status, reservation_id FROM orders WHERE id = \$1 FOR UPDATE`).WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "status", "reservation_id"}).AddRow(1, "paid", 9))
+	sqlMock.ExpectExec(`UPDATE orders SET status`).WithArgs("cancelled", 10).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec(`UPDATE orders SET refund_status`).WithArgs(controller.RefundPending, 10).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery(`INSERT INTO order_status_history`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec(`INSERT INTO outbox`).WillReturnResult(sqlmock.NewResult(2, 1))
+	sqlMock.ExpectCommit()
+
+	repo := &controller.DBOrderRepository{DB: db}
+	assert.NoError(t, repo.UpdateOrderStatus(10, "cancelled"))
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDeleteOrder_CancelsOnlyOrdersThatHaveNotShipped(t *testing.T) {
+	for status, wantCancelled := range map[string]bool{"paid": true, "processing": true, "delivered": false, "cancelled": false} {
+		t.Run(status, func(t *testing.T) {
+			db, sqlMock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			sqlMock.ExpectBegin()
+			sqlMock.ExpectQuery(`SELECT customer_id, status, reservation_id FROM orders WHERE id = \$1 FOR UPDATE`).WithArgs(10).
+				WillReturnRows(sqlmock.NewRows([]string{"customer_id", "status", "reservation_id"}).AddRow(1, status, 9))
+			sqlMock.ExpectExec(`DELETE FROM orders`).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 1))
+			sqlMock.ExpectExec(`INSERT INTO outbox`).WithArgs(10, outbox.EventOrderDeleted, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			if wantCancelled {
+				sqlMock.ExpectExec(`INSERT INTO outbox`).WithArgs(10, outbox.EventOrderCancelled, statusChangedPayload{ReservationID: 9}).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+			}
+			sqlMock.ExpectCommit()
+
+			repo := &controller.DBOrderRepository{DB: db}
+			assert.NoError(t, repo.DeleteOrder(10))
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+// statusChangedPayload matches an outbox payload carrying the given
+// reservation
+type statusChangedPayload struct {
+	ReservationID int
+}
+
+func (p statusChangedPayload) Match(v driver.Value) bool {
+	body, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	envelope, err := events.Parse(body)
+	if err != nil {
+		return false
+	}
+	var event events.StatusChange
+	return envelope.Decode(&event) == nil && event.ReservationID == p.ReservationID
+}
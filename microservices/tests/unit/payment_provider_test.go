@@ -0,0 +1,251 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	paymentcontroller "go-microservices/payment-service/controller"
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupProviderTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock, *provider.Fake) {
+	// Serve order 42 for payment checks and keep the succeeded-payment order
+	// update away from real hosts
+	orderService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/orders/42" && r.Header.Get("X-User-Id") == "7" {
+			w.Write([]byte(`{"id": 42, "customer_id": 7, "total_price": 25.5, "status": "pending"}`))
+			return
+		}
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(orderService.Close)
+	t.Setenv("ORDER_SERVICE_URL", orderService.URL)
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	fake := provider.NewFake("whsec_fake")
+	pc := paymentcontroller.NewPaymentControllerWithProvider(db, fake)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/payments/", pc.CreatePayment)
+	router.POST("/payments/confirm", pc.ConfirmPayment)
+	router.POST("/payments/webhook", pc.HandleStripeWebhook)
+
+	return router, sqlMock, fake
+}
+
+func postPaymentJSON(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "7")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// confirmWithStatus expects ConfirmPayment to move a payment in status from
+// to the given status, persisting it only if the status changes, and returns
+// the response
+func confirmWithStatus(t *testing.T, router *gin.Engine, sqlMock sqlmock.Sqlmock, intentID, from, status string) model.PaymentResponse {
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT id, order_id, customer_id, amount, currency, status, stripe_payment_id,.*FOR UPDATE`).
+		WithArgs(intentID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "customer_id", "amount", "currency", "status",
+			"stripe_payment_id", "payment_method", "created_at", "updated_at"}).
+			AddRow(5, 42, 7, 25.0, "usd", from, intentID, "", time.Now(), time.Now()))
+	if from != status {
+		sqlMock.ExpectQuery(`UPDATE payments`).
+			WithArgs(status, sqlmock.AnyArg(), sqlmock.AnyArg(), intentID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "customer_id", "amount", "currency", "status",
+				"stripe_payment_id", "payment_method", "created_at", "updated_at"}).
+				AddRow(5, 42, 7, 25.0, "usd", status, intentID, "", time.Now(), time.Now()))
+	}
+	sqlMock.ExpectCommit()
+
+	w := postPaymentJSON(router, "/payments/confirm", model.PaymentConfirmRequest{PaymentIntentID: intentID})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp model.PaymentResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, status, resp.Payment.Status)
+	return resp
+}
+
+func TestCreatePayment_UsesProviderIntent(t *testing.T) {
+	router, sqlMock, _ := setupProviderTest(t)
+
+	sqlMock.ExpectQuery(`INSERT INTO payments`).
+		WithArgs(42, 7, 25.5, "usd", model.PaymentStatusPending, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+	w := postPaymentJSON(router, "/payments/", model.PaymentRequest{OrderID: 42, CustomerID: 7, Amount: 25.5, Currency: "usd"})
+
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var resp model.PaymentResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Payment.StripePaymentID, "pi_fake_")
+	assert.NotEmpty(t, resp.ClientSecret)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreatePayment_RequiresOrderTotal(t *testing.T) {
+	router, sqlMock, _ := setupProviderTest(t)
+
+	w := postPaymentJSON(router, "/payments/", model.PaymentRequest{OrderID: 42, CustomerID: 7, Amount: 0.01, Currency: "usd"})
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"order_total":25.5`)
+
+	w = postPaymentJSON(router, "/payments/", model.PaymentRequest{OrderID: 43, CustomerID: 7, Amount: 25.5, Currency: "usd"})
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestConfirmPayment_FakeOutcomes(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		router, sqlMock, fake := setupProviderTest(t)
+		pi, _ := fake.CreateIntent(provider.CreateIntentParams{Amount: 2500, Currency: "usd"})
+
+		confirmWithStatus(t, router, sqlMock, pi.ID, model.PaymentStatusPending, model.PaymentStatusSucceeded)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("decline", func(t *testing.T) {
+		router, sqlMock, fake := setupProviderTest(t)
+		fake.QueueOutcome(provider.OutcomeDecline)
+		pi, _ := fake.CreateIntent(provider.CreateIntentParams{Amount: 2500, Currency: "usd"})
+
+		resp := confirmWithStatus(t, router, sqlMock, pi.ID, model.PaymentStatusPending, model.PaymentStatusFailed)
+		assert.Contains(t, resp.Message, provider.DeclineCodeCardDeclined)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("3DS required", func(t *testing.T) {
+		router, sqlMock, fake := setupProviderTest(t)
+		fake.QueueOutcome(provider.OutcomeRequiresAction)
+		pi, _ := fake.CreateIntent(provider.CreateIntentParams{Amount: 2500, Currency: "usd"})
+
+		resp := confirmWithStatus(t, router, sqlMock, pi.ID, model.PaymentStatusPending, model.PaymentStatusPending)
+		assert.Contains(t, resp.Message, "authentication")
+
+		assert.NoError(t, fake.CompleteAction(pi.ID))
+		confirmWithStatus(t, router, sqlMock, pi.ID, model.PaymentStatusPending, model.PaymentStatusSucceeded)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("delayed success", func(t *testing.T) {
+		router, sqlMock, fake := setupProviderTest(t)
+		now := time.Now()
+		fake.Now = func() time.Time { return now }
+		fake.ProcessingDelay = time.Minute
+		fake.QueueOutcome(provider.OutcomeDelayedSuccess)
+		pi, _ := fake.CreateIntent(provider.CreateIntentParams{Amount: 2500, Currency: "usd"})
+
+		confirmWithStatus(t, router, sqlMock, pi.ID, model.PaymentStatusPending, model.PaymentStatusPending)
+
+		now = now.Add(2 * time.Minute)
+		confirmWithStatus(t, router, sqlMock, pi.ID, model.PaymentStatusPending, model.PaymentStatusSucceeded)
+		assert.NoError(t, sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestConfirmPayment_KeepsRefundedPayments(t *testing.T) {
+	for _, status := range []string{model.PaymentStatusRefunded, model.PaymentStatusPartiallyRefunded} {
+		t.Run(status, func(t *testing.T) {
+			router, sqlMock, fake := setupProviderTest(t)
+			pi, _ := fake.CreateIntent(provider.CreateIntentParams{Amount: 2500, Currency: "usd"})
+
+			// The intent still reads succeeded, but the refund isn't reverted
+			sqlMock.ExpectBegin()
+			sqlMock.ExpectQuery(`SELECT id, order_id, customer_id, amount, currency, status, stripe_payment_id,.*FOR UPDATE`).
+				WithArgs(pi.ID).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "customer_id", "amount", "currency", "status",
+					"stripe_payment_id", "payment_method", "created_at", "updated_at"}).
+					AddRow(5, 42, 7, 25.0, "usd", status, pi.ID, "card", time.Now(), time.Now()))
+			sqlMock.ExpectCommit()
+
+			w := postPaymentJSON(router, "/payments/confirm", model.PaymentConfirmRequest{PaymentIntentID: pi.ID})
+
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var resp model.PaymentResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, status, resp.Payment.Status)
+			assert.Equal(t, "Payment is already "+status, resp.Message)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestConfirmPayment_UnknownIntent(t *testing.T) {
+	router, sqlMock, _ := setupProviderTest(t)
+
+	w := postPaymentJSON(router, "/payments/confirm", model.PaymentConfirmRequest{PaymentIntentID: "pi_missing"})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestFakeProvider_RefundsAndCancel(t *testing.T) {
+	fake := provider.NewFake("")
+	pi, _ := fake.CreateIntent(provider.CreateIntentParams{Amount: 1000, Currency: "usd"})
+
+	_, err := fake.Refund(provider.RefundParams{PaymentIntentID: pi.ID, Amount: 100})
+	assert.Error(t, err, "refunding an unconfirmed intent must fail")
+
+	_, _ = fake.GetIntent(pi.ID)
+	_, err = fake.Refund(provider.RefundParams{PaymentIntentID: pi.ID, Amount: 600})
+	assert.NoError(t, err)
+	_, err = fake.Refund(provider.RefundParams{PaymentIntentID: pi.ID, Amount: 500})
+	assert.Error(t, err, "cumulative refunds must not exceed the intent amount")
+
+	_, err = fake.CancelIntent(pi.ID)
+	assert.Error(t, err, "a succeeded intent cannot be canceled")
+
+	other, _ := fake.CreateIntent(provider.CreateIntentParams{Amount: 1000, Currency: "usd"})
+	canceled, err := fake.CancelIntent(other.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, provider.IntentStatusCanceled, canceled.Status)
+}
+
+func TestHandleWebhook_FakeProviderSignature(t *testing.T) {
+	router, sqlMock, fake := setupProviderTest(t)
+	payload := []byte(`{"id":"evt_fake_1","type":"payment.failed","payment_intent_id":"pi_test_123"}`)
+
+	req := httptest.NewRequest("POST", "/payments/webhook", bytes.NewReader(payload))
+	req.Header.Set("Stripe-Signature", "bogus")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	expectWebhookEvent(sqlMock, "evt_fake_1", 1)
+	expectPaymentLookup(sqlMock, model.PaymentStatusPending, 25)
+	sqlMock.ExpectExec(`UPDATE payments SET status = \$1`).
+		WithArgs(model.PaymentStatusFailed, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	req = httptest.NewRequest("POST", "/payments/webhook", bytes.NewReader(payload))
+	req.Header.Set("Stripe-Signature", fake.SignWebhook(payload))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,134 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	paymentcontroller "go-microservices/payment-service/controller"
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRefundTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/payments/:id/refunds", paymentcontroller.NewPaymentControllerWithProvider(db, provider.NewFake("")).CreateRefund)
+
+	return router, sqlMock
+}
+
+func postRefund(router *gin.Engine, roles string, body interface{}) *httptest.ResponseRecorder {
+	var b []byte
+	if body != nil {
+		b, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest("POST", "/payments/5/refunds", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "1")
+	req.Header.Set("X-User-Roles", roles)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func expectRefundablePayment(sqlMock sqlmock.Sqlmock, status string, amount, refunded float64) {
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`FROM payments WHERE id = \$1\s+FOR UPDATE`).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "customer_id", "amount", "currency", "status",
+			"stripe_payment_id", "payment_method", "created_at", "updated_at"}).
+			AddRow(5, 42, 7, amount, "usd", status, "pi_test_123", "card", time.Now(), time.Now()))
+	if status == model.PaymentStatusSucceeded || status == model.PaymentStatusPartiallyRefunded {
+		sqlMock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM refunds`).WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(refunded))
+	}
+}
+
+func TestCreateRefund_RequiresAdmin(t *testing.T) {
+	router, sqlMock := setupRefundTest(t)
+
+	w := postRefund(router, "user", model.RefundRequest{Amount: 5})
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateRefund_FullAndPartial(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       interface{}
+		refunded   float64
+		wantAmount float64
+		wantStatus string
+	}{
+		{"full refund without amount", nil, 0, 25, model.PaymentStatusRefunded},
+		{"partial refund", model.RefundRequest{Amount: 10, Reason: "requested_by_customer"}, 0, 10, model.PaymentStatusPartiallyRefunded},
+		{"refund of the remaining balance", model.RefundRequest{Amount: 15}, 10, 15, model.PaymentStatusRefunded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, sqlMock := setupRefundTest(t)
+
+			currentStatus := model.PaymentStatusSucceeded
+			if tt.refunded > 0 {
+				currentStatus = model.PaymentStatusPartiallyRefunded
+			}
+			expectRefundablePayment(sqlMock, currentStatus, 25, tt.refunded)
+			sqlMock.ExpectQuery(`INSERT INTO refunds`).
+				WithArgs(5, tt.wantAmount, "usd", "succeeded", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			sqlMock.ExpectExec(`UPDATE payments SET status = \$1`).
+				WithArgs(tt.wantStatus, sqlmock.AnyArg(), 5).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			sqlMock.ExpectCommit()
+
+			w := postRefund(router, "admin", tt.body)
+
+			assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+			var resp model.RefundResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantAmount, resp.Refund.Amount)
+			assert.Contains(t, resp.Refund.StripeRefundID, "re_fake_")
+			assert.Equal(t, tt.wantStatus, resp.Payment.Status)
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateRefund_CannotExceedCapturedAmount(t *testing.T) {
+	router, sqlMock := setupRefundTest(t)
+
+	// 25.00 captured, 20.00 already refunded
+	expectRefundablePayment(sqlMock, model.PaymentStatusPartiallyRefunded, 25, 20)
+	sqlMock.ExpectRollback()
+
+	w := postRefund(router, "admin", model.RefundRequest{Amount: 5.01})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"refundable":5`)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateRefund_RejectsUncapturedPayment(t *testing.T) {
+	router, sqlMock := setupRefundTest(t)
+
+	expectRefundablePayment(sqlMock, model.PaymentStatusPending, 25, 0)
+	sqlMock.ExpectRollback()
+
+	w := postRefund(router, "admin", model.RefundRequest{Amount: 5})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
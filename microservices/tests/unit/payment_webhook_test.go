@@ -0,0 +1,229 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	paymentcontroller "go-microservices/payment-service/controller"
+	"go-microservices/payment-service/model"
+	"go-microservices/payment-service/provider"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v76/webhook"
+)
+
+const testWebhookSecret = "whsec_test_secret"
+
+// setupWebhookTest builds a payment controller backed by sqlmock and points
+// order-service at a local server that serves order 42, totalling 25.00, and
+// reports status updates on the channel
+func setupWebhookTest(t *testing.T) (*gin.Engine, sqlmock.Sqlmock, chan string) {
+	orderUpdates := make(chan string, 1)
+	orderService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/orders/42" {
+			w.Write([]byte(`{"id": 42, "customer_id": 7, "total_price": 25, "status": "pending"}`))
+			return
+		}
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		orderUpdates <- r.Method + " " + r.URL.Path + " " + body["status"] + " as " + r.Header.Get("X-User-Roles")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(orderService.Close)
+	t.Setenv("ORDER_SERVICE_URL", orderService.URL)
+
+	db, sqlMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/payments/webhook", paymentcontroller.NewPaymentControllerWithProvider(db,
+		provider.NewStripe("sk_test_unused", testWebhookSecret)).HandleStripeWebhook)
+
+	return router, sqlMock, orderUpdates
+}
+
+// signedFixture loads a Stripe event fixture and signs it with the test secret
+func signedFixture(t *testing.T, name string) ([]byte, string) {
+	payload, err := os.ReadFile(filepath.Join("testdata", "stripe", name))
+	assert.NoError(t, err)
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    testWebhookSecret,
+		Timestamp: time.Now(),
+	})
+	return signed.Payload, signed.Header
+}
+
+func postWebhook(router *gin.Engine, payload []byte, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/payments/webhook", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", signature)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func expectWebhookEvent(sqlMock sqlmock.Sqlmock, eventID string, inserted int64) {
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec(`INSERT INTO stripe_webhook_events`).
+		WithArgs(eventID, sqlmock.AnyArg(), "pi_test_123", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, inserted))
+}
+
+func expectPaymentLookup(sqlMock sqlmock.Sqlmock, status string, amount float64) {
+	sqlMock.ExpectQuery(`SELECT id, order_id, customer_id, amount, status\s+FROM payments WHERE stripe_payment_id = \$1\s+FOR UPDATE`).
+		WithArgs("pi_test_123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "customer_id", "amount", "status"}).AddRow(5, 42, 7, amount, status))
+}
+
+func TestStripeWebhook_SucceededUpdatesPaymentAndOrder(t *testing.T) {
+	router, sqlMock, orderUpdates := setupWebhookTest(t)
+	payload, signature := signedFixture(t, "payment_intent_succeeded.json")
+
+	expectWebhookEvent(sqlMock, "evt_test_succeeded", 1)
+	expectPaymentLookup(sqlMock, model.PaymentStatusPending, 25)
+	sqlMock.ExpectExec(`UPDATE payments SET status = \$1`).
+		WithArgs(model.PaymentStatusSucceeded, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	w := postWebhook(router, payload, signature)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	select {
+	case update := <-orderUpdates:
+		assert.Equal(t, "PATCH /orders/42/status paid as system", update)
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for order status update")
+	}
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestStripeWebhook_SucceededBelowOrderTotalLeavesOrderUnpaid(t *testing.T) {
+	router, sqlMock, orderUpdates := setupWebhookTest(t)
+	payload, signature := signedFixture(t, "payment_intent_succeeded.json")
+
+	expectWebhookEvent(sqlMock, "evt_test_succeeded", 1)
+	expectPaymentLookup(sqlMock, model.PaymentStatusPending, 0.01)
+	sqlMock.ExpectExec(`UPDATE payments SET status = \$1`).
+		WithArgs(model.PaymentStatusSucceeded, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	w := postWebhook(router, payload, signature)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	select {
+	case update := <-orderUpdates:
+		t.Fatalf("unexpected order update for an underpaid order: %s", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestStripeWebhook_InvalidSignatureRejected(t *testing.T) {
+	router, sqlMock, _ := setupWebhookTest(t)
+	payload, _ := signedFixture(t, "payment_intent_succeeded.json")
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    "whsec_someone_else",
+		Timestamp: time.Now(),
+	})
+	w := postWebhook(router, payload, signed.Header)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postWebhook(router, payload, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestStripeWebhook_TamperedPayloadRejected(t *testing.T) {
+	router, sqlMock, _ := setupWebhookTest(t)
+	payload, signature := signedFixture(t, "payment_intent_succeeded.json")
+
+	tampered := bytes.Replace(payload, []byte(`"amount": 2500`), []byte(`"amount": 1`), 1)
+	w := postWebhook(router, tampered, signature)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestStripeWebhook_DuplicateEventIgnored(t *testing.T) {
+	router, sqlMock, orderUpdates := setupWebhookTest(t)
+	payload, signature := signedFixture(t, "payment_intent_succeeded.json")
+
+	expectWebhookEvent(sqlMock, "evt_test_succeeded", 0)
+	sqlMock.ExpectRollback()
+
+	w := postWebhook(router, payload, signature)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"duplicate":true`)
+	select {
+	case update := <-orderUpdates:
+		t.Fatalf("unexpected order update for duplicate event: %s", update)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestStripeWebhook_StatusTransitions(t *testing.T) {
+	tests := []struct {
+		name          string
+		fixture       string
+		eventID       string
+		currentStatus string
+		wantStatus    string
+		wantUpdate    bool
+	}{
+		{"payment failed", "payment_intent_payment_failed.json", "evt_test_failed", model.PaymentStatusPending, model.PaymentStatusFailed, true},
+		{"canceled", "payment_intent_canceled.json", "evt_test_canceled", model.PaymentStatusPending, model.PaymentStatusCanceled, true},
+		{"refunded", "charge_refunded.json", "evt_test_refunded", model.PaymentStatusSucceeded, model.PaymentStatusRefunded, true},
+		{"late failure after success", "payment_intent_payment_failed.json", "evt_test_failed", model.PaymentStatusSucceeded, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, sqlMock, _ := setupWebhookTest(t)
+			payload, signature := signedFixture(t, tt.fixture)
+
+			expectWebhookEvent(sqlMock, tt.eventID, 1)
+			expectPaymentLookup(sqlMock, tt.currentStatus, 25)
+			if tt.wantUpdate {
+				sqlMock.ExpectExec(`UPDATE payments SET status = \$1`).
+					WithArgs(tt.wantStatus, sqlmock.AnyArg(), 5).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			sqlMock.ExpectCommit()
+
+			w := postWebhook(router, payload, signature)
+
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.NoError(t, sqlMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStripeWebhook_UnhandledEventAcknowledged(t *testing.T) {
+	router, sqlMock, _ := setupWebhookTest(t)
+	payload, signature := signedFixture(t, "customer_created.json")
+
+	w := postWebhook(router, payload, signature)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"ignored":true`)
+	assert.NoError(t, sqlMock.ExpectationsWereMet())
+}